@@ -0,0 +1,121 @@
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"blogron/middleware"
+	"blogron/util"
+)
+
+const defaultAuditLimit = 200
+
+type auditFilter struct {
+	actor, resource, outcome string
+	since, until             time.Time
+}
+
+// GetAuditLog godoc
+// GET /api/audit?actor=jane&resource=vhosts&since=2024-01-01T00:00:00Z&until=...&outcome=failure&limit=200
+// Returns matching entries newest first. With format=jsonl the full matching
+// log is streamed as JSON Lines (oldest first) for export.
+func GetAuditLog(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	f := auditFilter{
+		actor:    q.Get("actor"),
+		resource: q.Get("resource"),
+		outcome:  q.Get("outcome"),
+	}
+	for name, dst := range map[string]*time.Time{"since": &f.since, "until": &f.until} {
+		if v := q.Get(name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				util.WriteErr(w, util.Invalid(name, name+" must be an RFC 3339 timestamp"))
+				return
+			}
+			*dst = t
+		}
+	}
+	limit := defaultAuditLimit
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			util.WriteErr(w, util.Invalid("limit", "limit must be a positive integer"))
+			return
+		}
+		limit = n
+	}
+
+	file, err := os.Open(filepath.Join(util.DataDir(), middleware.AuditFile))
+	if errors.Is(err, os.ErrNotExist) {
+		if q.Get("format") == "jsonl" {
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		util.WriteJSON(w, http.StatusOK, []middleware.AuditEntry{})
+		return
+	}
+	if err != nil {
+		util.WriteErr(w, util.Wrap(err, "cannot read audit log"))
+		return
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64<<10), 4<<20)
+
+	if q.Get("format") == "jsonl" {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Header().Set("Content-Disposition", `attachment; filename="blogron-audit.jsonl"`)
+		w.WriteHeader(http.StatusOK)
+		for scanner.Scan() {
+			var e middleware.AuditEntry
+			if json.Unmarshal(scanner.Bytes(), &e) == nil && f.match(e) {
+				w.Write(append(scanner.Bytes(), '\n'))
+			}
+		}
+		return
+	}
+
+	// Keep only the newest `limit` matches while scanning.
+	entries := []middleware.AuditEntry{}
+	for scanner.Scan() {
+		var e middleware.AuditEntry
+		if json.Unmarshal(scanner.Bytes(), &e) != nil || !f.match(e) {
+			continue
+		}
+		entries = append(entries, e)
+		if len(entries) > limit {
+			entries = entries[1:]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		util.WriteErr(w, util.Wrap(err, "cannot read audit log"))
+		return
+	}
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+	util.WriteJSON(w, http.StatusOK, entries)
+}
+
+// ── helpers ───────────────────────────────────────────────────────────────────
+
+func (f auditFilter) match(e middleware.AuditEntry) bool {
+	switch {
+	case f.actor != "" && e.Actor != f.actor,
+		f.resource != "" && e.Resource != f.resource,
+		f.outcome != "" && e.Outcome != f.outcome,
+		!f.since.IsZero() && e.Time.Before(f.since),
+		!f.until.IsZero() && e.Time.After(f.until):
+		return false
+	}
+	return true
+}
@@ -2,41 +2,23 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
-	"os"
-	"sync"
 	"time"
 
 	"blogron/util"
 
 	"github.com/golang-jwt/jwt/v5"
-	"golang.org/x/crypto/bcrypt"
 )
 
-// adminCreds holds the lazily-initialised credentials loaded from environment.
-// ADMIN_USER and ADMIN_PASSWORD are injected by install.sh into the systemd unit.
-var (
-	adminCreds struct {
-		username string
-		hash     []byte
-	}
-	adminCredsOnce sync.Once
+// Token types carried in the "typ" claim. JWTAuth only accepts access tokens;
+// pre-auth tokens are only good for completing a two-factor login.
+const (
+	tokenTypeAccess  = "access"
+	tokenTypePreAuth = "mfa"
 )
 
-func loadAdminCreds() {
-	adminCredsOnce.Do(func() {
-		adminCreds.username = os.Getenv("ADMIN_USER")
-		if adminCreds.username == "" {
-			adminCreds.username = "admin"
-		}
-		pass := os.Getenv("ADMIN_PASSWORD")
-		if pass == "" {
-			pass = "changeme"
-		}
-		hash, _ := bcrypt.GenerateFromPassword([]byte(pass), bcrypt.DefaultCost)
-		adminCreds.hash = hash
-	})
-}
+const preAuthTTL = 5 * time.Minute
 
 type loginRequest struct {
 	Username string `json:"username"`
@@ -44,51 +26,92 @@ type loginRequest struct {
 }
 
 type loginResponse struct {
-	Token   string `json:"token"`
-	Expires int64  `json:"expires"`
-	User    string `json:"user"`
+	Token          string `json:"token"`
+	Expires        int64  `json:"expires"`
+	RefreshToken   string `json:"refresh_token"`
+	RefreshExpires int64  `json:"refresh_expires"`
+	User           string `json:"user"`
+	Role           string `json:"role"`
+}
+
+type mfaChallengeResponse struct {
+	MFARequired  bool   `json:"mfa_required"`
+	PreAuthToken string `json:"pre_auth_token"`
+	Expires      int64  `json:"expires"`
 }
 
 // Login godoc
 // POST /api/auth/login
 // Body: { "username": "admin", "password": "..." }
+// Accounts with two-factor enabled get { "mfa_required": true, "pre_auth_token": "..." }
+// and must finish with POST /api/auth/login/verify.
 func Login(w http.ResponseWriter, r *http.Request) {
-	loadAdminCreds()
-
 	var req loginRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		util.WriteError(w, http.StatusBadRequest, "invalid request body")
+		util.WriteErr(w, util.ErrInvalidBody)
 		return
 	}
-
-	if req.Username != adminCreds.username {
-		util.WriteError(w, http.StatusUnauthorized, "invalid credentials")
+	if loginBlocked(w, r, req.Username) {
 		return
 	}
 
-	if err := bcrypt.CompareHashAndPassword(adminCreds.hash, []byte(req.Password)); err != nil {
-		util.WriteError(w, http.StatusUnauthorized, "invalid credentials")
+	user, err := authenticatePanelUser(req.Username, req.Password)
+	if errors.Is(err, errInvalidCredentials) {
+		recordLoginFailure(r, req.Username)
+		util.WriteErr(w, errInvalidCredentials)
 		return
 	}
-
-	expiry := time.Now().Add(8 * time.Hour)
-	claims := jwt.MapClaims{
-		"sub":  req.Username,
-		"role": "admin",
-		"exp":  expiry.Unix(),
-		"iat":  time.Now().Unix(),
+	if err != nil {
+		util.WriteErr(w, util.Wrap(err, "cannot read panel accounts"))
+		return
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	signed, err := token.SignedString(util.JWTSecret())
-	if err != nil {
-		util.WriteError(w, http.StatusInternalServerError, "could not sign token")
+	// With two-factor on, the password alone is not a successful login — keep
+	// the counters so failed codes keep accumulating across pre-auth tokens.
+	if user.TOTPEnabled {
+		expiry := time.Now().Add(preAuthTTL)
+		signed, err := signToken(jwt.MapClaims{
+			"sub": user.Username,
+			"typ": tokenTypePreAuth,
+			"exp": expiry.Unix(),
+			"iat": time.Now().Unix(),
+		})
+		if err != nil {
+			util.WriteError(w, http.StatusInternalServerError, "could not sign token")
+			return
+		}
+		util.WriteJSON(w, http.StatusOK, mfaChallengeResponse{
+			MFARequired:  true,
+			PreAuthToken: signed,
+			Expires:      expiry.Unix(),
+		})
 		return
 	}
 
-	util.WriteJSON(w, http.StatusOK, loginResponse{
-		Token:   signed,
-		Expires: expiry.Unix(),
-		User:    req.Username,
+	recordLoginSuccess(r, user.Username)
+	writeSessionTokens(w, r, user)
+}
+
+// ── helpers ───────────────────────────────────────────────────────────────────
+
+func signToken(claims jwt.MapClaims) (string, error) {
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(util.JWTSecret())
+}
+
+// parseToken validates signature and expiry and checks the "typ" claim.
+func parseToken(tokenStr, typ string) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+	token, err := jwt.ParseWithClaims(tokenStr, claims, func(t *jwt.Token) (interface{}, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, jwt.ErrSignatureInvalid
+		}
+		return util.JWTSecret(), nil
 	})
+	if err != nil || !token.Valid {
+		return nil, errors.New("invalid or expired token")
+	}
+	if t, _ := claims["typ"].(string); t != typ {
+		return nil, errors.New("wrong token type")
+	}
+	return claims, nil
 }
@@ -10,7 +10,7 @@ import (
 	"blogron/util"
 )
 
-const cronDir = "/var/spool/cron/crontabs"
+var cronDir = "/var/spool/cron/crontabs"
 
 type CronJob struct {
 	ID       int    `json:"id"`
@@ -43,6 +43,9 @@ func ListCronJobs(w http.ResponseWriter, r *http.Request) {
 	var jobs []CronJob
 	if user != "" {
 		user = util.Sanitize(user)
+		if !requireCronAccess(w, r, user) {
+			return
+		}
 		userJobs := readCrontab(user)
 		jobs = append(jobs, userJobs...)
 	} else {
@@ -50,13 +53,18 @@ func ListCronJobs(w http.ResponseWriter, r *http.Request) {
 		entries, err := os.ReadDir(cronDir)
 		if err == nil {
 			for _, e := range entries {
+				if !canAccessCron(r, e.Name()) {
+					continue
+				}
 				userJobs := readCrontab(e.Name())
 				jobs = append(jobs, userJobs...)
 			}
 		}
 		// Also read /etc/crontab for system jobs
-		systemJobs := readSystemCrontab()
-		jobs = append(jobs, systemJobs...)
+		if !isScoped(r) {
+			systemJobs := readSystemCrontab()
+			jobs = append(jobs, systemJobs...)
+		}
 	}
 
 	util.WriteJSON(w, http.StatusOK, jobs)
@@ -67,34 +75,45 @@ func ListCronJobs(w http.ResponseWriter, r *http.Request) {
 func CreateCronJob(w http.ResponseWriter, r *http.Request) {
 	var req createCronRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		util.WriteError(w, http.StatusBadRequest, "invalid body")
+		util.WriteErr(w, util.ErrInvalidBody)
 		return
 	}
 
 	if req.Command == "" {
-		util.WriteError(w, http.StatusBadRequest, "command is required")
+		util.WriteErr(w, util.Invalid("command", "command is required"))
 		return
 	}
 
 	// Validate cron fields (very basic — allow *, numbers, ranges, lists)
-	for _, field := range []string{req.Minute, req.Hour, req.Day, req.Month, req.Weekday} {
-		if !isValidCronField(field) {
-			util.WriteError(w, http.StatusBadRequest, "invalid cron field: "+field)
-			return
+	fields := map[string]string{}
+	var invalid []string
+	for _, f := range []struct{ name, value string }{
+		{"minute", req.Minute}, {"hour", req.Hour}, {"day", req.Day}, {"month", req.Month}, {"weekday", req.Weekday},
+	} {
+		if !isValidCronField(f.value) {
+			fields[f.name] = "invalid cron field: " + f.value
+			invalid = append(invalid, f.value)
 		}
 	}
+	if len(fields) > 0 {
+		util.WriteErr(w, util.InvalidFields("invalid cron field: "+strings.Join(invalid, ", "), fields))
+		return
+	}
 
 	user := req.User
 	if user == "" {
 		user = "root"
 	}
 	user = util.Sanitize(user)
+	if !requireCronAccess(w, r, user) {
+		return
+	}
 
 	cronLine := fmt.Sprintf("%s %s %s %s %s %s",
 		req.Minute, req.Hour, req.Day, req.Month, req.Weekday, req.Command)
 
 	if err := appendCrontab(user, cronLine); err != nil {
-		util.WriteError(w, http.StatusInternalServerError, "failed to write crontab: "+err.Error())
+		util.WriteErr(w, util.Wrap(err, "failed to write crontab"))
 		return
 	}
 
@@ -113,6 +132,13 @@ func DeleteCronJob(w http.ResponseWriter, r *http.Request) {
 	if user == "" {
 		user = "root"
 	}
+	if !requireCronAccess(w, r, user) {
+		return
+	}
+	if !isSiteUser(user) {
+		util.WriteErr(w, errUserNotManaged)
+		return
+	}
 
 	id := 0
 	for _, c := range idStr {
@@ -120,7 +146,7 @@ func DeleteCronJob(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := removeCronLine(user, id); err != nil {
-		util.WriteError(w, http.StatusInternalServerError, err.Error())
+		util.WriteErr(w, err)
 		return
 	}
 	util.WriteJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
@@ -137,7 +163,7 @@ func UpdateCronJob(w http.ResponseWriter, r *http.Request) {
 
 	var req createCronRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		util.WriteError(w, http.StatusBadRequest, "invalid body")
+		util.WriteErr(w, util.ErrInvalidBody)
 		return
 	}
 
@@ -146,12 +172,15 @@ func UpdateCronJob(w http.ResponseWriter, r *http.Request) {
 		user = "root"
 	}
 	user = util.Sanitize(user)
+	if !requireCronAccess(w, r, user) {
+		return
+	}
 
 	newLine := fmt.Sprintf("%s %s %s %s %s %s",
 		req.Minute, req.Hour, req.Day, req.Month, req.Weekday, req.Command)
 
 	if err := updateCronLine(user, id, newLine); err != nil {
-		util.WriteError(w, http.StatusInternalServerError, err.Error())
+		util.WriteErr(w, err)
 		return
 	}
 	util.WriteJSON(w, http.StatusOK, map[string]string{"status": "updated"})
@@ -165,6 +194,9 @@ func RunCronNow(w http.ResponseWriter, r *http.Request) {
 	if user == "" {
 		user = "root"
 	}
+	if !requireCronAccess(w, r, user) {
+		return
+	}
 
 	id := 0
 	for _, c := range idStr {
@@ -174,19 +206,39 @@ func RunCronNow(w http.ResponseWriter, r *http.Request) {
 	jobs := readCrontab(user)
 	for _, job := range jobs {
 		if job.ID == id {
-			// Execute the job command
-			go func(cmd string) {
-				util.RunCmd("bash", "-c", cmd)
-			}(job.Command)
-			util.WriteJSON(w, http.StatusOK, map[string]string{"status": "triggered", "command": job.Command})
+			// Execute the job command as the crontab's owner, like cron would.
+			// blogron.sudoers allows this for members of siteUsersGroup only.
+			// It outlives the request, so it is noted in the request's audit
+			// entry here and its output is kept on the job.
+			util.NoteBackgroundCmd(r.Context(), "sudo", "-u", user, "bash", "-c", job.Command)
+			cmd := job.Command
+			writeJobAccepted(w, r, "cron.run", user+"#"+idStr, func(j *jobRun) error {
+				j.Step(cmd)
+				_, err := runCmd(j.ctx, "sudo", "-u", user, "bash", "-c", cmd)
+				return err
+			})
 			return
 		}
 	}
-	util.WriteError(w, http.StatusNotFound, "cron job not found")
+	util.WriteErr(w, errCronJobNotFound)
 }
 
 // ── helpers ───────────────────────────────────────────────────────────────────
 
+// canAccessCron reports whether the caller may manage user's crontab. Site
+// owners get the crontabs recorded against them and those of their FTP users.
+func canAccessCron(r *http.Request, user string) bool {
+	return canAccess(r, kindCron, user) || canAccess(r, kindFTPUser, user)
+}
+
+func requireCronAccess(w http.ResponseWriter, r *http.Request, user string) bool {
+	if canAccessCron(r, user) {
+		return true
+	}
+	util.WriteErr(w, errNotOwner("you do not have access to this crontab"))
+	return false
+}
+
 func readCrontab(user string) []CronJob {
 	crontabFile := fmt.Sprintf("%s/%s", cronDir, user)
 	data, err := os.ReadFile(crontabFile)
@@ -1,28 +1,22 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"os"
 	"strings"
 
+	"blogron/store"
 	"blogron/util"
 )
 
-// MySQL credentials are read from environment variables.
-// Set MYSQL_USER and MYSQL_PASSWORD in your .env or systemd service file.
-func mysqlArgs(extraArgs ...string) []string {
-	user := os.Getenv("MYSQL_USER")
-	if user == "" {
-		user = "root"
-	}
-	pass := os.Getenv("MYSQL_PASSWORD")
-
-	base := []string{"-u" + user}
-	if pass != "" {
-		base = append(base, "-p"+pass)
-	}
-	return append(base, extraArgs...)
+// MySQL credentials come from the mysql section of panel.yaml (or
+// MYSQL_USER and MYSQL_PASSWORD). They reach the mysql client through a
+// private option file (see mysqlQuery), never through its command line.
+func mysqlCredentials() (user, pass string) {
+	return settings.MySQL.User, settings.MySQL.Password
 }
 
 type Database struct {
@@ -36,33 +30,27 @@ type createDatabaseRequest struct {
 	DBUser   string `json:"db_user"`
 	Password string `json:"password"`
 	Host     string `json:"host"`
+	Owner    string `json:"owner"`
 }
 
 // ListDatabases godoc
 // GET /api/databases
 func ListDatabases(w http.ResponseWriter, r *http.Request) {
-	out, err := util.RunCmd("mysql", append(mysqlArgs("-e", "SHOW DATABASES;", "--skip-column-names", "-s"))...)
+	out, err := mysqlQuery(r.Context(), "", "SHOW DATABASES;")
 	if err != nil {
-		util.WriteError(w, http.StatusInternalServerError, "mysql query failed: "+err.Error())
+		util.WriteErr(w, util.Wrap(err, "mysql query failed"))
 		return
 	}
 
-	skipSystem := map[string]bool{
-		"information_schema": true,
-		"performance_schema": true,
-		"mysql":              true,
-		"sys":                true,
-	}
-
 	var dbs []Database
 	for _, line := range strings.Split(out, "\n") {
 		name := strings.TrimSpace(line)
-		if name == "" || skipSystem[name] {
+		if name == "" || systemDatabases[name] || !canAccess(r, kindDatabase, name) {
 			continue
 		}
 		db := Database{Name: name}
-		db.Size = getDatabaseSize(name)
-		db.Tables = getDatabaseTableCount(name)
+		db.Size = getDatabaseSize(r.Context(), name)
+		db.Tables = getDatabaseTableCount(r.Context(), name)
 		dbs = append(dbs, db)
 	}
 	util.WriteJSON(w, http.StatusOK, dbs)
@@ -73,14 +61,14 @@ func ListDatabases(w http.ResponseWriter, r *http.Request) {
 func CreateDatabase(w http.ResponseWriter, r *http.Request) {
 	var req createDatabaseRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		util.WriteError(w, http.StatusBadRequest, "invalid request body")
+		util.WriteErr(w, util.ErrInvalidBody)
 		return
 	}
 
 	dbName := util.Sanitize(req.Name)
 	dbUser := util.Sanitize(req.DBUser)
 	if dbName == "" {
-		util.WriteError(w, http.StatusBadRequest, "invalid database name")
+		util.WriteErr(w, util.Invalid("name", "invalid database name"))
 		return
 	}
 
@@ -91,19 +79,30 @@ func CreateDatabase(w http.ResponseWriter, r *http.Request) {
 	host = util.Sanitize(host)
 
 	// Create database
-	if _, err := util.RunCmd("mysql", append(mysqlArgs("-e", "CREATE DATABASE `"+dbName+"` CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;"))...); err != nil {
-		util.WriteError(w, http.StatusInternalServerError, "failed to create database: "+err.Error())
+	if _, err := mysqlQuery(r.Context(), "", "CREATE DATABASE `"+dbName+"` CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;"); err != nil {
+		util.WriteErr(w, util.Wrap(err, "failed to create database"))
 		return
 	}
 
 	// Create user and grant privileges if requested
 	if dbUser != "" && req.Password != "" {
-		grantSQL := "CREATE USER '" + dbUser + "'@'" + host + "' IDENTIFIED BY '" + req.Password + "'; " +
+		grantSQL := "CREATE USER '" + dbUser + "'@'" + host + "' IDENTIFIED BY " + sqlString(req.Password) + "; " +
 			"GRANT ALL PRIVILEGES ON `" + dbName + "`.* TO '" + dbUser + "'@'" + host + "'; " +
 			"FLUSH PRIVILEGES;"
-		util.RunCmd("mysql", append(mysqlArgs("-e", grantSQL))...)
+		if _, err := mysqlQuery(r.Context(), "", grantSQL); err != nil {
+			util.WriteErr(w, util.Wrap(err, "database created but user setup failed"))
+			return
+		}
 	}
 
+	owner := ownerForCreate(r, req.Owner)
+	recordResource(kindDatabase, dbName, func(res *store.Resource) {
+		res.Owner = owner
+		if dbUser != "" {
+			setMeta(res, "db_user", dbUser)
+		}
+	})
+
 	util.WriteJSON(w, http.StatusCreated, map[string]string{
 		"status":   "created",
 		"database": dbName,
@@ -116,21 +115,24 @@ func CreateDatabase(w http.ResponseWriter, r *http.Request) {
 func DropDatabase(w http.ResponseWriter, r *http.Request) {
 	name := util.Sanitize(chi_urlParam(r, "name"))
 	if name == "" {
-		util.WriteError(w, http.StatusBadRequest, "invalid database name")
+		util.WriteErr(w, util.Invalid("name", "invalid database name"))
 		return
 	}
 
 	// Safety: refuse to drop system databases
-	protected := map[string]bool{"mysql": true, "information_schema": true, "performance_schema": true, "sys": true}
-	if protected[name] {
-		util.WriteError(w, http.StatusForbidden, "cannot drop system database")
+	if systemDatabases[name] {
+		util.WriteErr(w, errProtectedDatabase)
+		return
+	}
+	if !requireOwner(w, r, kindDatabase, name) {
 		return
 	}
 
-	if _, err := util.RunCmd("mysql", append(mysqlArgs("-e", "DROP DATABASE `"+name+"`;"))...); err != nil {
-		util.WriteError(w, http.StatusInternalServerError, "failed to drop database: "+err.Error())
+	if _, err := mysqlQuery(r.Context(), "", "DROP DATABASE `"+name+"`;"); err != nil {
+		util.WriteErr(w, util.Wrap(err, "failed to drop database"))
 		return
 	}
+	forgetResource(kindDatabase, name)
 	util.WriteJSON(w, http.StatusOK, map[string]string{"status": "dropped", "database": name})
 }
 
@@ -139,13 +141,16 @@ func DropDatabase(w http.ResponseWriter, r *http.Request) {
 func ListTables(w http.ResponseWriter, r *http.Request) {
 	name := util.Sanitize(chi_urlParam(r, "name"))
 	if name == "" {
-		util.WriteError(w, http.StatusBadRequest, "invalid database name")
+		util.WriteErr(w, util.Invalid("name", "invalid database name"))
+		return
+	}
+	if !requireOwner(w, r, kindDatabase, name) {
 		return
 	}
 
-	out, err := util.RunCmd("mysql", append(mysqlArgs(name, "-e", "SHOW TABLES;", "--skip-column-names", "-s"))...)
+	out, err := mysqlQuery(r.Context(), name, "SHOW TABLES;")
 	if err != nil {
-		util.WriteError(w, http.StatusInternalServerError, err.Error())
+		util.WriteErr(w, err)
 		return
 	}
 
@@ -160,18 +165,35 @@ func ListTables(w http.ResponseWriter, r *http.Request) {
 
 // ── helpers ───────────────────────────────────────────────────────────────────
 
-func getDatabaseSize(name string) string {
+// systemDatabases are MariaDB's own schemas, never listed or managed.
+var systemDatabases = map[string]bool{
+	"information_schema": true,
+	"performance_schema": true,
+	"mysql":              true,
+	"sys":                true,
+}
+
+// protectedDBUsers are MariaDB's own accounts, never dropped.
+var protectedDBUsers = map[string]bool{
+	"root":             true,
+	"mysql":            true,
+	"mariadb.sys":      true,
+	"mysql.sys":        true,
+	"debian-sys-maint": true,
+}
+
+func getDatabaseSize(ctx context.Context, name string) string {
 	query := `SELECT ROUND(SUM(data_length + index_length) / 1024 / 1024, 1) AS 'MB'
               FROM information_schema.tables WHERE table_schema = '` + name + `';`
-	out, err := util.RunCmd("mysql", append(mysqlArgs("-e", query, "--skip-column-names", "-s"))...)
+	out, err := mysqlQuery(ctx, "", query)
 	if err != nil || strings.TrimSpace(out) == "NULL" {
 		return "0 MB"
 	}
 	return strings.TrimSpace(out) + " MB"
 }
 
-func getDatabaseTableCount(name string) int {
-	out, err := util.RunCmd("mysql", append(mysqlArgs(name, "-e", "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = '"+name+"';", "--skip-column-names", "-s"))...)
+func getDatabaseTableCount(ctx context.Context, name string) int {
+	out, err := mysqlQuery(ctx, name, "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = '"+name+"';")
 	if err != nil {
 		return 0
 	}
@@ -181,3 +203,44 @@ func getDatabaseTableCount(name string) int {
 	}
 	return count
 }
+
+// mysqlQuery runs sql with the mysql client, against db if set, and returns
+// the raw rows. The SQL is fed on stdin and the credentials come from a 0600
+// option file that only lives for the duration of the call.
+func mysqlQuery(ctx context.Context, db, sql string) (string, error) {
+	user, pass := mysqlCredentials()
+	var args []string
+	if pass != "" {
+		f, err := os.CreateTemp("", "blogron-mysql-*.cnf")
+		if err != nil {
+			return "", err
+		}
+		defer os.Remove(f.Name())
+		_, err = fmt.Fprintf(f, "[client]\nuser=%s\npassword=%s\n", optionValue(user), optionValue(pass))
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return "", err
+		}
+		// --defaults-extra-file must come first
+		args = append(args, "--defaults-extra-file="+f.Name())
+	} else {
+		args = append(args, "-u"+user)
+	}
+	args = append(args, "--skip-column-names", "-s")
+	if db != "" {
+		args = append(args, db)
+	}
+	return util.RunCommand(ctx, executor, util.Command{Name: "mysql", Args: args, Stdin: []byte(sql)})
+}
+
+// sqlString quotes s as a MySQL string literal.
+func sqlString(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`, "\x00", `\0`, "\n", `\n`, "\r", `\r`).Replace(s) + "'"
+}
+
+// optionValue quotes v for a my.cnf option file.
+func optionValue(v string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v) + `"`
+}
@@ -13,19 +13,16 @@ import (
 	"blogron/util"
 )
 
-const (
+var (
 	bindZonesDir   = "/etc/bind/zones"
 	bindNamedLocal = "/etc/bind/named.conf.local"
 )
 
 // bindService returns the correct systemd unit name for BIND9.
 // Ubuntu uses 'named'; Debian may use 'bind9'. The installer writes
-// the detected name into the BIND_SERVICE environment variable.
+// the detected name into services.bind (or BIND_SERVICE).
 func bindService() string {
-	if svc := os.Getenv("BIND_SERVICE"); svc != "" {
-		return svc
-	}
-	return "named" // safe default — works on Ubuntu 22.04/24.04
+	return settings.Services.Bind
 }
 
 type DNSZone struct {
@@ -66,13 +63,13 @@ func ListDNSZones(w http.ResponseWriter, r *http.Request) {
 func GetDNSZone(w http.ResponseWriter, r *http.Request) {
 	domain := util.Sanitize(chi_urlParam(r, "domain"))
 	if domain == "" {
-		util.WriteError(w, http.StatusBadRequest, "invalid domain")
+		util.WriteErr(w, util.Invalid("domain", "invalid domain"))
 		return
 	}
 
 	zone, err := parseZoneFile(domain)
 	if err != nil {
-		util.WriteError(w, http.StatusNotFound, "zone not found: "+err.Error())
+		util.WriteErr(w, errZoneNotFound)
 		return
 	}
 	util.WriteJSON(w, http.StatusOK, zone)
@@ -86,14 +83,14 @@ func CreateDNSZone(w http.ResponseWriter, r *http.Request) {
 		IPAddress string `json:"ip"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
-		util.WriteError(w, http.StatusBadRequest, "invalid body")
+		util.WriteErr(w, util.ErrInvalidBody)
 		return
 	}
 
 	domain := util.Sanitize(body.Domain)
 	ip := body.IPAddress
 	if domain == "" || ip == "" {
-		util.WriteError(w, http.StatusBadRequest, "domain and ip are required")
+		util.WriteErr(w, util.InvalidFields("domain and ip are required", map[string]string{"domain": "required", "ip": "required"}))
 		return
 	}
 
@@ -102,24 +99,24 @@ func CreateDNSZone(w http.ResponseWriter, r *http.Request) {
 	zoneFile := filepath.Join(bindZonesDir, domain+".db")
 
 	if err := os.MkdirAll(bindZonesDir, 0755); err != nil {
-		util.WriteError(w, http.StatusInternalServerError, "cannot create zones dir")
+		util.WriteErr(w, util.Wrap(err, "cannot create zones dir"))
 		return
 	}
 
 	if err := os.WriteFile(zoneFile, []byte(zoneContent), 0644); err != nil {
-		util.WriteError(w, http.StatusInternalServerError, "failed to write zone file: "+err.Error())
+		util.WriteErr(w, util.Wrap(err, "failed to write zone file"))
 		return
 	}
 
 	// Add zone to named.conf.local
 	if err := addZoneToNamedConf(domain, zoneFile); err != nil {
-		util.WriteError(w, http.StatusInternalServerError, "failed to update named.conf.local: "+err.Error())
+		util.WriteErr(w, util.Wrap(err, "failed to update named.conf.local"))
 		return
 	}
 
 	// Reload BIND
-	if _, err := util.RunCmd("systemctl", "reload", bindService()); err != nil {
-		util.WriteError(w, http.StatusInternalServerError, "DNS service reload failed: "+err.Error())
+	if _, err := runCmd(r.Context(), "systemctl", "reload", bindService()); err != nil {
+		util.WriteErr(w, util.Wrap(err, "DNS service reload failed"))
 		return
 	}
 
@@ -131,14 +128,14 @@ func CreateDNSZone(w http.ResponseWriter, r *http.Request) {
 func DeleteDNSZone(w http.ResponseWriter, r *http.Request) {
 	domain := util.Sanitize(chi_urlParam(r, "domain"))
 	if domain == "" {
-		util.WriteError(w, http.StatusBadRequest, "invalid domain")
+		util.WriteErr(w, util.Invalid("domain", "invalid domain"))
 		return
 	}
 
 	zoneFile := filepath.Join(bindZonesDir, domain+".db")
 	os.Remove(zoneFile)
 	removeZoneFromNamedConf(domain)
-	util.RunCmd("systemctl", "reload", bindService())
+	runCmd(r.Context(), "systemctl", "reload", bindService())
 	util.WriteJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
 }
 
@@ -148,7 +145,7 @@ func AddDNSRecord(w http.ResponseWriter, r *http.Request) {
 	domain := util.Sanitize(chi_urlParam(r, "domain"))
 	var rec DNSRecord
 	if err := json.NewDecoder(r.Body).Decode(&rec); err != nil {
-		util.WriteError(w, http.StatusBadRequest, "invalid body")
+		util.WriteErr(w, util.ErrInvalidBody)
 		return
 	}
 
@@ -156,7 +153,7 @@ func AddDNSRecord(w http.ResponseWriter, r *http.Request) {
 	allowedTypes := map[string]bool{"A": true, "AAAA": true, "CNAME": true, "MX": true, "TXT": true, "NS": true, "PTR": true, "SRV": true}
 	rec.Type = strings.ToUpper(rec.Type)
 	if !allowedTypes[rec.Type] {
-		util.WriteError(w, http.StatusBadRequest, "unsupported record type")
+		util.WriteErr(w, util.Invalid("type", "unsupported record type"))
 		return
 	}
 	if rec.TTL == "" {
@@ -166,7 +163,7 @@ func AddDNSRecord(w http.ResponseWriter, r *http.Request) {
 	zoneFile := filepath.Join(bindZonesDir, domain+".db")
 	data, err := os.ReadFile(zoneFile)
 	if err != nil {
-		util.WriteError(w, http.StatusNotFound, "zone not found")
+		util.WriteErr(w, errZoneNotFound)
 		return
 	}
 
@@ -178,11 +175,11 @@ func AddDNSRecord(w http.ResponseWriter, r *http.Request) {
 	updated = bumpSerial(updated)
 
 	if err := os.WriteFile(zoneFile, []byte(updated), 0644); err != nil {
-		util.WriteError(w, http.StatusInternalServerError, "failed to write zone file")
+		util.WriteErr(w, util.Wrap(err, "failed to write zone file"))
 		return
 	}
 
-	util.RunCmd("systemctl", "reload", bindService())
+	runCmd(r.Context(), "systemctl", "reload", bindService())
 	util.WriteJSON(w, http.StatusCreated, map[string]string{"status": "added"})
 }
 
@@ -197,14 +194,14 @@ func DeleteDNSRecord(w http.ResponseWriter, r *http.Request) {
 		Value string `json:"value"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
-		util.WriteError(w, http.StatusBadRequest, "invalid body")
+		util.WriteErr(w, util.ErrInvalidBody)
 		return
 	}
 
 	zoneFile := filepath.Join(bindZonesDir, domain+".db")
 	data, err := os.ReadFile(zoneFile)
 	if err != nil {
-		util.WriteError(w, http.StatusNotFound, "zone not found")
+		util.WriteErr(w, errZoneNotFound)
 		return
 	}
 
@@ -217,7 +214,7 @@ func DeleteDNSRecord(w http.ResponseWriter, r *http.Request) {
 	}
 	updated := bumpSerial(strings.Join(kept, "\n"))
 	os.WriteFile(zoneFile, []byte(updated), 0644)
-	util.RunCmd("systemctl", "reload", bindService())
+	runCmd(r.Context(), "systemctl", "reload", bindService())
 	util.WriteJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
 }
 
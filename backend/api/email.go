@@ -2,6 +2,7 @@ package api
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -9,11 +10,12 @@ import (
 	"path/filepath"
 	"strings"
 
+	"blogron/store"
 	"blogron/util"
 )
 
-// Postfix virtual mailbox paths — adjust to your Postfix config
-const (
+// Postfix virtual mailbox paths — adjust in the paths section of panel.yaml
+var (
 	postfixVirtualMailboxDir  = "/etc/postfix/virtual_mailbox"
 	postfixVirtualDomainsFile = "/etc/postfix/virtual_mailbox_domains"
 	postfixVirtualMapsFile    = "/etc/postfix/virtual_mailbox_maps"
@@ -38,7 +40,12 @@ type Mailbox struct {
 // ListMailDomains godoc
 // GET /api/email/domains
 func ListMailDomains(w http.ResponseWriter, r *http.Request) {
-	domains := readMailDomains()
+	domains := []MailDomain{}
+	for _, d := range readMailDomains() {
+		if canAccess(r, kindMailDomain, d.Domain) {
+			domains = append(domains, d)
+		}
+	}
 	util.WriteJSON(w, http.StatusOK, domains)
 }
 
@@ -47,30 +54,41 @@ func ListMailDomains(w http.ResponseWriter, r *http.Request) {
 func AddMailDomain(w http.ResponseWriter, r *http.Request) {
 	var body struct {
 		Domain string `json:"domain"`
+		Owner  string `json:"owner"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
-		util.WriteError(w, http.StatusBadRequest, "invalid body")
+		util.WriteErr(w, util.ErrInvalidBody)
 		return
 	}
 
 	domain := util.Sanitize(body.Domain)
 	if domain == "" {
-		util.WriteError(w, http.StatusBadRequest, "invalid domain")
+		util.WriteErr(w, util.Invalid("domain", "invalid domain"))
 		return
 	}
+	if isScoped(r) {
+		for _, d := range readMailDomains() {
+			if d.Domain == domain {
+				util.WriteErr(w, errMailDomainExists)
+				return
+			}
+		}
+	}
 
 	// Add to virtual_mailbox_domains
 	if err := appendLine(postfixVirtualDomainsFile, domain); err != nil {
-		util.WriteError(w, http.StatusInternalServerError, "failed to add domain: "+err.Error())
+		util.WriteErr(w, util.Wrap(err, "failed to add domain"))
 		return
 	}
 
 	// Create mail storage directory
 	mailDir := filepath.Join(mailStorageBase, domain)
-	util.RunCmd("mkdir", "-p", mailDir)
-	util.RunCmd("chown", "-R", "vmail:vmail", mailDir)
+	runCmd(r.Context(), "mkdir", "-p", mailDir)
+	runCmd(r.Context(), "chown", "-R", "vmail:vmail", mailDir)
 
-	reloadPostfix()
+	reloadPostfix(r.Context())
+	owner := ownerForCreate(r, body.Owner)
+	recordResource(kindMailDomain, domain, func(res *store.Resource) { res.Owner = owner })
 	util.WriteJSON(w, http.StatusCreated, map[string]string{"status": "created", "domain": domain})
 }
 
@@ -79,11 +97,15 @@ func AddMailDomain(w http.ResponseWriter, r *http.Request) {
 func DeleteMailDomain(w http.ResponseWriter, r *http.Request) {
 	domain := util.Sanitize(chi_urlParam(r, "domain"))
 	if domain == "" {
-		util.WriteError(w, http.StatusBadRequest, "invalid domain")
+		util.WriteErr(w, util.Invalid("domain", "invalid domain"))
+		return
+	}
+	if !requireOwner(w, r, kindMailDomain, domain) {
 		return
 	}
 	removeLine(postfixVirtualDomainsFile, domain)
-	reloadPostfix()
+	reloadPostfix(r.Context())
+	forgetResource(kindMailDomain, domain)
 	util.WriteJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
 }
 
@@ -91,7 +113,12 @@ func DeleteMailDomain(w http.ResponseWriter, r *http.Request) {
 // GET /api/email/mailboxes?domain=example.com
 func ListMailboxes(w http.ResponseWriter, r *http.Request) {
 	domain := util.Sanitize(r.URL.Query().Get("domain"))
-	mailboxes := readMailboxes(domain)
+	mailboxes := []Mailbox{}
+	for _, mb := range readMailboxes(domain) {
+		if canAccess(r, kindMailDomain, mb.Domain) {
+			mailboxes = append(mailboxes, mb)
+		}
+	}
 	util.WriteJSON(w, http.StatusOK, mailboxes)
 }
 
@@ -104,16 +131,16 @@ func CreateMailbox(w http.ResponseWriter, r *http.Request) {
 		Quota    string `json:"quota"` // e.g. "1G", "500M"
 	}
 	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
-		util.WriteError(w, http.StatusBadRequest, "invalid body")
+		util.WriteErr(w, util.ErrInvalidBody)
 		return
 	}
 
 	if !strings.Contains(body.Email, "@") {
-		util.WriteError(w, http.StatusBadRequest, "invalid email address")
+		util.WriteErr(w, util.Invalid("email", "invalid email address"))
 		return
 	}
 	if len(body.Password) < 8 {
-		util.WriteError(w, http.StatusBadRequest, "password must be at least 8 characters")
+		util.WriteErr(w, util.Invalid("password", "password must be at least 8 characters"))
 		return
 	}
 
@@ -121,36 +148,73 @@ func CreateMailbox(w http.ResponseWriter, r *http.Request) {
 	user := util.Sanitize(parts[0])
 	domain := util.Sanitize(parts[1])
 	email := user + "@" + domain
+	if !requireOwner(w, r, kindMailDomain, domain) {
+		return
+	}
 
 	quota := body.Quota
 	if quota == "" {
 		quota = "1G"
 	}
 
+	for _, mb := range readMailboxes(domain) {
+		if mb.Email == email {
+			util.WriteErr(w, errMailboxExists)
+			return
+		}
+	}
+
+	p := newProvision(r.Context())
+
 	// Add to virtual_mailbox_maps: email -> domain/user/
 	mapLine := fmt.Sprintf("%s %s/%s/", email, domain, user)
-	if err := appendLine(postfixVirtualMapsFile, mapLine); err != nil {
-		util.WriteError(w, http.StatusInternalServerError, "failed to add mailbox map")
-		return
-	}
+	err := p.Step("add mailbox map", func(ctx context.Context) error {
+		return appendLine(postfixVirtualMapsFile, mapLine)
+	}, func(ctx context.Context) error {
+		if err := removeExactLine(postfixVirtualMapsFile, mapLine); err != nil {
+			return err
+		}
+		_, err := runCmd(ctx, "postmap", postfixVirtualMapsFile)
+		return err
+	})
 
 	// Create mail directory
-	mailDir := filepath.Join(mailStorageBase, domain, user)
-	for _, sub := range []string{"", "/cur", "/new", "/tmp"} {
-		util.RunCmd("mkdir", "-p", mailDir+sub)
+	if err == nil {
+		mailDir := filepath.Join(mailStorageBase, domain, user)
+		err = p.StepUndo("create mail directory", func(ctx context.Context) (undoFunc, error) {
+			undo := removeCreatedDir(missingRoot(mailDir, filepath.Join(mailStorageBase, domain)))
+			for _, sub := range []string{"", "/cur", "/new", "/tmp"} {
+				if _, err := runCmd(ctx, "mkdir", "-p", mailDir+sub); err != nil {
+					return nil, cleanUp(ctx, undo, err)
+				}
+			}
+			_, err := runCmd(ctx, "chown", "-R", "vmail:vmail", filepath.Join(mailStorageBase, domain))
+			return undo, cleanUp(ctx, undo, err)
+		})
 	}
-	util.RunCmd("chown", "-R", "vmail:vmail", filepath.Join(mailStorageBase, domain))
 
-	// Add Dovecot passwd entry (SHA512-CRYPT hash)
-	passwdLine := fmt.Sprintf("%s:{PLAIN}%s:::::userdb_quota_rule=*:storage=%s", email, body.Password, quota)
-	if err := appendLine(dovecotPasswdFile, passwdLine); err != nil {
-		util.WriteError(w, http.StatusInternalServerError, "failed to add dovecot user")
-		return
+	// Add Dovecot passwd entry
+	if err == nil {
+		passwdLine := fmt.Sprintf("%s:{PLAIN}%s:::::userdb_quota_rule=*:storage=%s", email, body.Password, quota)
+		err = p.Step("add dovecot user", func(ctx context.Context) error {
+			return appendLine(dovecotPasswdFile, passwdLine)
+		}, func(ctx context.Context) error {
+			return removeExactLine(dovecotPasswdFile, passwdLine)
+		})
 	}
 
 	// Rebuild postfix maps
-	util.RunCmd("postmap", postfixVirtualMapsFile)
-	reloadPostfix()
+	if err == nil {
+		err = p.Step("rebuild postfix maps", func(ctx context.Context) error {
+			_, err := runCmd(ctx, "postmap", postfixVirtualMapsFile)
+			return err
+		}, nil)
+	}
+	if err != nil {
+		writeProvisionError(w, err)
+		return
+	}
+	reloadPostfix(r.Context())
 
 	util.WriteJSON(w, http.StatusCreated, map[string]string{
 		"status": "created",
@@ -164,7 +228,7 @@ func CreateMailbox(w http.ResponseWriter, r *http.Request) {
 func DeleteMailbox(w http.ResponseWriter, r *http.Request) {
 	email := chi_urlParam(r, "email")
 	if !strings.Contains(email, "@") {
-		util.WriteError(w, http.StatusBadRequest, "invalid email")
+		util.WriteErr(w, util.Invalid("email", "invalid email"))
 		return
 	}
 
@@ -172,23 +236,26 @@ func DeleteMailbox(w http.ResponseWriter, r *http.Request) {
 	user := util.Sanitize(parts[0])
 	domain := util.Sanitize(parts[1])
 	email = user + "@" + domain
+	if !requireOwner(w, r, kindMailDomain, domain) {
+		return
+	}
 
 	// Remove from virtual map
 	removeLine(postfixVirtualMapsFile, email)
 	// Remove from dovecot passwd
 	removeLine(dovecotPasswdFile, email)
 
-	util.RunCmd("postmap", postfixVirtualMapsFile)
-	reloadPostfix()
+	runCmd(r.Context(), "postmap", postfixVirtualMapsFile)
+	reloadPostfix(r.Context())
 	util.WriteJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
 }
 
 // GetMailQueue godoc
 // GET /api/email/queue
 func GetMailQueue(w http.ResponseWriter, r *http.Request) {
-	out, err := util.RunCmd("postqueue", "-p")
+	out, err := runCmd(r.Context(), "postqueue", "-p")
 	if err != nil {
-		util.WriteError(w, http.StatusInternalServerError, err.Error())
+		util.WriteErr(w, err)
 		return
 	}
 	util.WriteJSON(w, http.StatusOK, map[string]string{"queue": out})
@@ -197,7 +264,7 @@ func GetMailQueue(w http.ResponseWriter, r *http.Request) {
 // FlushMailQueue godoc
 // POST /api/email/queue/flush
 func FlushMailQueue(w http.ResponseWriter, r *http.Request) {
-	util.RunCmd("postqueue", "-f")
+	runCmd(r.Context(), "postqueue", "-f")
 	util.WriteJSON(w, http.StatusOK, map[string]string{"status": "flushed"})
 }
 
@@ -280,8 +347,8 @@ func countMailboxesForDomain(domain string) int {
 	return count
 }
 
-func reloadPostfix() {
-	util.RunCmd("systemctl", "reload", "postfix")
+func reloadPostfix(ctx context.Context) {
+	runCmd(ctx, "systemctl", "reload", "postfix")
 }
 
 func appendLine(file, line string) error {
@@ -294,6 +361,24 @@ func appendLine(file, line string) error {
 	return err
 }
 
+// removeExactLine removes the lines equal to line from file.
+func removeExactLine(file, line string) error {
+	data, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var kept []string
+	for _, l := range strings.Split(string(data), "\n") {
+		if l != line {
+			kept = append(kept, l)
+		}
+	}
+	return os.WriteFile(file, []byte(strings.Join(kept, "\n")), 0644)
+}
+
 func removeLine(file, prefix string) {
 	data, err := os.ReadFile(file)
 	if err != nil {
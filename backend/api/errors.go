@@ -0,0 +1,49 @@
+package api
+
+import (
+	"net/http"
+
+	"blogron/util"
+)
+
+// Errors the handlers answer with. See util/errors.go for the codes; errors
+// from commands and the file system are mapped by util.ErrorFrom.
+var (
+	errInvalidRefresh  = util.NewError(http.StatusUnauthorized, util.CodeInvalidToken, "invalid refresh token")
+	errRefreshReused   = util.NewError(http.StatusUnauthorized, util.CodeTokenReused, "refresh token reuse detected; session revoked")
+	errAccountInactive = util.NewError(http.StatusUnauthorized, util.CodeAccountInactive, "account is no longer active")
+	errInvalidPreAuth  = util.NewError(http.StatusUnauthorized, util.CodeInvalidToken, "invalid or expired pre-auth token")
+
+	errVhostExists       = util.NewError(http.StatusConflict, util.CodeVhostExists, "vhost already exists")
+	errVhostNotFound     = util.NewError(http.StatusNotFound, util.CodeVhostNotFound, "vhost not found")
+	errSiteDirExists     = util.NewError(http.StatusConflict, util.CodeSiteExists, "site directory already exists")
+	errWPSiteNotFound    = util.NewError(http.StatusNotFound, util.CodeSiteNotFound, "wordpress site not found")
+	errMailDomainExists  = util.NewError(http.StatusConflict, util.CodeMailDomainExists, "mail domain already exists")
+	errMailboxExists     = util.NewError(http.StatusConflict, util.CodeMailboxExists, "mailbox already exists")
+	errSystemUserExists  = util.NewError(http.StatusConflict, util.CodeSystemUserExists, "system user already exists")
+	errProtectedDatabase = util.NewError(http.StatusForbidden, util.CodeProtectedDatabase, "cannot drop system database")
+	errZoneNotFound      = util.NewError(http.StatusNotFound, util.CodeZoneNotFound, "zone not found")
+	errCronJobNotFound   = util.NewError(http.StatusNotFound, util.CodeCronJobNotFound, "cron job not found")
+	errFileNotFound      = util.NewError(http.StatusNotFound, util.CodeFileNotFound, "file not found")
+	errServiceNotManaged = util.NewError(http.StatusForbidden, util.CodeServiceNotManaged, "service not managed by this panel")
+	errUserNotManaged    = util.NewError(http.StatusForbidden, util.CodeUserNotManaged, "only crontabs of accounts in the "+siteUsersGroup+" group can be run from the panel")
+	errSessionNotFound   = util.NewError(http.StatusNotFound, util.CodeSessionNotFound, "session not found")
+	errAPITokenNotFound  = util.NewError(http.StatusNotFound, util.CodeAPITokenNotFound, "token not found")
+	errPanelUserExists   = util.NewError(http.StatusConflict, util.CodePanelUserExists, "panel user already exists")
+	errPanelUserNotFound = util.NewError(http.StatusNotFound, util.CodePanelUserNotFound, "panel user not found")
+	errLastAdmin         = util.NewError(http.StatusConflict, util.CodeLastAdmin, "at least one active admin account must remain")
+	errJobNotFound       = util.NewError(http.StatusNotFound, util.CodeJobNotFound, "job not found")
+	errJobNotRunning     = util.NewError(http.StatusConflict, util.CodeJobNotRunning, "job is not running")
+	errProfileNotFound   = util.NewError(http.StatusNotFound, util.CodeProfileNotFound, "vhost profile not found")
+	errProfileBuiltin    = util.NewError(http.StatusConflict, util.CodeProfileBuiltin, "built-in vhost profiles cannot be changed")
+)
+
+// errPath rejects a file manager path outside the caller's reach.
+func errPath(msg string) *util.Error {
+	return util.NewError(http.StatusForbidden, util.CodePathForbidden, msg)
+}
+
+// errNotOwner rejects access to a resource owned by someone else.
+func errNotOwner(msg string) *util.Error {
+	return util.NewError(http.StatusForbidden, util.CodeNotOwner, msg)
+}
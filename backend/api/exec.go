@@ -0,0 +1,21 @@
+package api
+
+import (
+	"context"
+
+	"blogron/util"
+)
+
+// executor runs every system command issued by the handlers. Tests swap in a
+// util.FakeExecutor; dry_run (PANEL_DRY_RUN=1) swaps in a util.DryRunExecutor.
+var executor util.Executor = util.SudoExecutor{}
+
+// SetExecutor replaces the executor used by the api package.
+func SetExecutor(e util.Executor) {
+	executor = e
+}
+
+// runCmd runs an allowlisted command through the package executor.
+func runCmd(ctx context.Context, name string, args ...string) (string, error) {
+	return util.RunCommand(ctx, executor, util.Command{Name: name, Args: args})
+}
@@ -2,7 +2,9 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"io"
+	"io/fs"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -13,8 +15,8 @@ import (
 )
 
 // File manager is restricted to this base path for safety.
-// Adjust for your environment — typical values: "/var/www", "/home"
-const fileManagerRoot = "/var/www"
+// Set paths.file_manager_root in panel.yaml — typical values: "/var/www", "/home"
+var fileManagerRoot = "/var/www"
 
 type FileEntry struct {
 	Name        string    `json:"name"`
@@ -46,18 +48,26 @@ func ListFiles(w http.ResponseWriter, r *http.Request) {
 	reqPath := r.URL.Query().Get("path")
 	absPath, err := safePath(reqPath)
 	if err != nil {
-		util.WriteError(w, http.StatusForbidden, "path traversal detected")
+		util.WriteErr(w, errPath("path traversal detected"))
+		return
+	}
+	atRoot := absPath == fileManagerRoot
+	if !atRoot && !ownedSiteDir(r, absPath) {
+		util.WriteErr(w, errNotOwner("you do not have access to this path"))
 		return
 	}
 
 	entries, err := os.ReadDir(absPath)
 	if err != nil {
-		util.WriteError(w, http.StatusInternalServerError, "cannot read directory: "+err.Error())
+		util.WriteErr(w, fileError(err, "cannot read directory"))
 		return
 	}
 
 	var files []FileEntry
 	for _, e := range entries {
+		if atRoot && !ownedSiteDir(r, filepath.Join(absPath, e.Name())) {
+			continue
+		}
 		info, err := e.Info()
 		if err != nil {
 			continue
@@ -86,18 +96,18 @@ func MakeDirectory(w http.ResponseWriter, r *http.Request) {
 		Path string `json:"path"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
-		util.WriteError(w, http.StatusBadRequest, "invalid body")
+		util.WriteErr(w, util.ErrInvalidBody)
 		return
 	}
 
 	absPath, err := safePath(body.Path)
-	if err != nil {
-		util.WriteError(w, http.StatusForbidden, "invalid path")
+	if err != nil || !ownedSiteDir(r, absPath) {
+		util.WriteErr(w, errPath("invalid path"))
 		return
 	}
 
 	if err := os.MkdirAll(absPath, 0755); err != nil {
-		util.WriteError(w, http.StatusInternalServerError, "mkdir failed: "+err.Error())
+		util.WriteErr(w, fileError(err, "mkdir failed"))
 		return
 	}
 	util.WriteJSON(w, http.StatusCreated, map[string]string{"status": "created", "path": body.Path})
@@ -108,19 +118,19 @@ func MakeDirectory(w http.ResponseWriter, r *http.Request) {
 func DeleteFile(w http.ResponseWriter, r *http.Request) {
 	reqPath := r.URL.Query().Get("path")
 	absPath, err := safePath(reqPath)
-	if err != nil {
-		util.WriteError(w, http.StatusForbidden, "invalid path")
+	if err != nil || !ownedSiteDir(r, absPath) {
+		util.WriteErr(w, errPath("invalid path"))
 		return
 	}
 
 	// Refuse to delete the root itself
 	if absPath == fileManagerRoot {
-		util.WriteError(w, http.StatusForbidden, "cannot delete root directory")
+		util.WriteErr(w, errPath("cannot delete root directory"))
 		return
 	}
 
 	if err := os.RemoveAll(absPath); err != nil {
-		util.WriteError(w, http.StatusInternalServerError, "delete failed: "+err.Error())
+		util.WriteErr(w, fileError(err, "delete failed"))
 		return
 	}
 	util.WriteJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
@@ -135,23 +145,23 @@ func RenameFile(w http.ResponseWriter, r *http.Request) {
 		To   string `json:"to"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
-		util.WriteError(w, http.StatusBadRequest, "invalid body")
+		util.WriteErr(w, util.ErrInvalidBody)
 		return
 	}
 
 	src, err := safePath(body.From)
-	if err != nil {
-		util.WriteError(w, http.StatusForbidden, "invalid source path")
+	if err != nil || !ownedSiteDir(r, src) {
+		util.WriteErr(w, errPath("invalid source path"))
 		return
 	}
 	dst, err := safePath(body.To)
-	if err != nil {
-		util.WriteError(w, http.StatusForbidden, "invalid destination path")
+	if err != nil || !ownedSiteDir(r, dst) {
+		util.WriteErr(w, errPath("invalid destination path"))
 		return
 	}
 
 	if err := os.Rename(src, dst); err != nil {
-		util.WriteError(w, http.StatusInternalServerError, "rename failed: "+err.Error())
+		util.WriteErr(w, fileError(err, "rename failed"))
 		return
 	}
 	util.WriteJSON(w, http.StatusOK, map[string]string{"status": "renamed"})
@@ -162,18 +172,18 @@ func RenameFile(w http.ResponseWriter, r *http.Request) {
 func ReadFile(w http.ResponseWriter, r *http.Request) {
 	reqPath := r.URL.Query().Get("path")
 	absPath, err := safePath(reqPath)
-	if err != nil {
-		util.WriteError(w, http.StatusForbidden, "invalid path")
+	if err != nil || !ownedSiteDir(r, absPath) {
+		util.WriteErr(w, errPath("invalid path"))
 		return
 	}
 
 	info, err := os.Stat(absPath)
 	if err != nil {
-		util.WriteError(w, http.StatusNotFound, "file not found")
+		util.WriteErr(w, errFileNotFound)
 		return
 	}
 	if info.IsDir() {
-		util.WriteError(w, http.StatusBadRequest, "path is a directory")
+		util.WriteErr(w, util.Invalid("path", "path is a directory"))
 		return
 	}
 	// Limit reads to 2 MB to prevent accidental large file reads
@@ -184,7 +194,7 @@ func ReadFile(w http.ResponseWriter, r *http.Request) {
 
 	data, err := os.ReadFile(absPath)
 	if err != nil {
-		util.WriteError(w, http.StatusInternalServerError, "read failed: "+err.Error())
+		util.WriteErr(w, fileError(err, "read failed"))
 		return
 	}
 	util.WriteJSON(w, http.StatusOK, map[string]string{"path": reqPath, "content": string(data)})
@@ -199,18 +209,18 @@ func WriteFile(w http.ResponseWriter, r *http.Request) {
 		Content string `json:"content"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
-		util.WriteError(w, http.StatusBadRequest, "invalid body")
+		util.WriteErr(w, util.ErrInvalidBody)
 		return
 	}
 
 	absPath, err := safePath(body.Path)
-	if err != nil {
-		util.WriteError(w, http.StatusForbidden, "invalid path")
+	if err != nil || !ownedSiteDir(r, absPath) {
+		util.WriteErr(w, errPath("invalid path"))
 		return
 	}
 
 	if err := os.WriteFile(absPath, []byte(body.Content), 0644); err != nil {
-		util.WriteError(w, http.StatusInternalServerError, "write failed: "+err.Error())
+		util.WriteErr(w, fileError(err, "write failed"))
 		return
 	}
 	util.WriteJSON(w, http.StatusOK, map[string]string{"status": "saved"})
@@ -230,14 +240,14 @@ func UploadFile(w http.ResponseWriter, r *http.Request) {
 
 	destDir := r.FormValue("path")
 	absDir, err := safePath(destDir)
-	if err != nil {
-		util.WriteError(w, http.StatusForbidden, "invalid destination path")
+	if err != nil || !ownedSiteDir(r, absDir) {
+		util.WriteErr(w, errPath("invalid destination path"))
 		return
 	}
 
 	file, header, err := r.FormFile("file")
 	if err != nil {
-		util.WriteError(w, http.StatusBadRequest, "missing file field")
+		util.WriteErr(w, util.Invalid("file", "missing file field"))
 		return
 	}
 	defer file.Close()
@@ -248,19 +258,19 @@ func UploadFile(w http.ResponseWriter, r *http.Request) {
 
 	// Ensure dest is still within root
 	if !strings.HasPrefix(destPath, fileManagerRoot) {
-		util.WriteError(w, http.StatusForbidden, "invalid destination")
+		util.WriteErr(w, errPath("invalid destination"))
 		return
 	}
 
 	out, err := os.Create(destPath)
 	if err != nil {
-		util.WriteError(w, http.StatusInternalServerError, "cannot create file: "+err.Error())
+		util.WriteErr(w, fileError(err, "cannot create file"))
 		return
 	}
 	defer out.Close()
 
 	if _, err = io.Copy(out, file); err != nil {
-		util.WriteError(w, http.StatusInternalServerError, "upload failed: "+err.Error())
+		util.WriteErr(w, fileError(err, "upload failed"))
 		return
 	}
 
@@ -270,3 +280,17 @@ func UploadFile(w http.ResponseWriter, r *http.Request) {
 		"path":     strings.TrimPrefix(destPath, fileManagerRoot),
 	})
 }
+
+// ── helpers ───────────────────────────────────────────────────────────────────
+
+// fileError maps a file system error to FILE_NOT_FOUND or CONFLICT where the
+// caller can act on it; other errors keep their util.ErrorFrom mapping.
+func fileError(err error, msg string) *util.Error {
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return util.Wrap(errFileNotFound, msg)
+	case errors.Is(err, fs.ErrExist):
+		return util.Wrap(util.NewError(http.StatusConflict, util.CodeConflict, "file already exists"), msg)
+	}
+	return util.Wrap(err, msg)
+}
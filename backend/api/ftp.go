@@ -2,16 +2,19 @@ package api
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
-	"fmt"
 	"net/http"
 	"os"
+	"os/user"
+	"path/filepath"
 	"strings"
 
+	"blogron/store"
 	"blogron/util"
 )
 
-const (
+var (
 	vsftpdUserListFile = "/etc/vsftpd.userlist"
 	vsftpdPasswdFile   = "/etc/vsftpd.passwd"
 )
@@ -25,7 +28,12 @@ type FTPUser struct {
 // ListFTPUsers godoc
 // GET /api/ftp
 func ListFTPUsers(w http.ResponseWriter, r *http.Request) {
-	users := readFTPUsers()
+	users := []FTPUser{}
+	for _, u := range readFTPUsers() {
+		if canAccess(r, kindFTPUser, u.Username) {
+			users = append(users, u)
+		}
+	}
 	util.WriteJSON(w, http.StatusOK, users)
 }
 
@@ -36,35 +44,106 @@ func CreateFTPUser(w http.ResponseWriter, r *http.Request) {
 		Username string `json:"username"`
 		Password string `json:"password"`
 		HomeDir  string `json:"home_dir"`
+		Owner    string `json:"owner"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
-		util.WriteError(w, http.StatusBadRequest, "invalid body")
+		util.WriteErr(w, util.ErrInvalidBody)
 		return
 	}
 
 	username := util.Sanitize(body.Username)
 	if username == "" || len(body.Password) < 8 {
-		util.WriteError(w, http.StatusBadRequest, "invalid username or password too short")
+		fields := map[string]string{}
+		if username == "" {
+			fields["username"] = "invalid username"
+		}
+		if len(body.Password) < 8 {
+			fields["password"] = "password too short"
+		}
+		util.WriteErr(w, util.InvalidFields("invalid username or password too short", fields))
 		return
 	}
 
 	homeDir := body.HomeDir
 	if homeDir == "" {
-		homeDir = fmt.Sprintf("/var/www/%s", username)
+		homeDir = filepath.Join(fileManagerRoot, username)
+	}
+	homeDir, _ = safePath(strings.TrimPrefix(homeDir, fileManagerRoot))
+	if isScoped(r) {
+		if _, err := user.Lookup(username); err == nil {
+			util.WriteErr(w, errSystemUserExists)
+			return
+		}
+		if !ownedSiteDir(r, homeDir) {
+			util.WriteErr(w, errNotOwner("home_dir must be inside one of your sites"))
+			return
+		}
 	}
-	homeDir, _ = safePath(strings.TrimPrefix(homeDir, "/var/www"))
 
-	// Create system user with no login shell for FTP-only access
-	util.RunCmd("useradd", "-m", "-d", homeDir, "-s", "/usr/sbin/nologin", username)
-	util.RunCmd("chpasswd", username+":"+body.Password)
-	util.RunCmd("chown", username+":"+username, homeDir)
+	p := newProvision(r.Context())
+
+	// Create system user with no login shell for FTP-only access. useradd
+	// fails for an existing user, so the undo only removes ours; the home
+	// directory is removed only if useradd created it.
+	createdHome := missingRoot(homeDir, filepath.Dir(homeDir))
+	err := p.Step("create system user", func(ctx context.Context) error {
+		_, err := runCmd(ctx, "useradd", "-m", "-d", homeDir, "-s", "/usr/sbin/nologin", "-G", siteUsersGroup, username)
+		return err
+	}, func(ctx context.Context) error {
+		if _, err := runCmd(ctx, "userdel", username); err != nil {
+			return err
+		}
+		if undo := removeCreatedDir(createdHome); undo != nil {
+			return undo(ctx)
+		}
+		return nil
+	})
+	if err == nil {
+		err = p.Step("set password", func(ctx context.Context) error {
+			return setSystemPassword(ctx, username, body.Password)
+		}, nil)
+	}
+	if err == nil {
+		// An existing home (e.g. a site directory) gets its owner back.
+		var undo undoFunc
+		if prev, ok := util.FileOwner(homeDir); ok && createdHome == "" {
+			undo = func(ctx context.Context) error {
+				_, err := runCmd(ctx, "chown", prev, homeDir)
+				return err
+			}
+		}
+		err = p.Step("chown home directory", func(ctx context.Context) error {
+			_, err := runCmd(ctx, "chown", username+":"+username, homeDir)
+			return err
+		}, undo)
+	}
 
 	// Add to vsftpd user list
-	appendLine(vsftpdUserListFile, username)
+	if err == nil {
+		err = p.Step("add to vsftpd user list", func(ctx context.Context) error {
+			return appendLine(vsftpdUserListFile, username)
+		}, func(ctx context.Context) error {
+			return removeExactLine(vsftpdUserListFile, username)
+		})
+	}
 
 	// Restart vsftpd
-	util.RunCmd("systemctl", "restart", "vsftpd")
+	if err == nil {
+		err = p.Step("restart vsftpd", func(ctx context.Context) error {
+			_, err := runCmd(ctx, "systemctl", "restart", "vsftpd")
+			return err
+		}, nil)
+	}
+	if err != nil {
+		writeProvisionError(w, err)
+		return
+	}
 
+	owner := ownerForCreate(r, body.Owner)
+	recordResource(kindFTPUser, username, func(res *store.Resource) {
+		res.Owner = owner
+		setMeta(res, "home_dir", homeDir)
+	})
 	util.WriteJSON(w, http.StatusCreated, map[string]string{
 		"status":   "created",
 		"username": username,
@@ -77,13 +156,17 @@ func CreateFTPUser(w http.ResponseWriter, r *http.Request) {
 func DeleteFTPUser(w http.ResponseWriter, r *http.Request) {
 	username := util.Sanitize(chi_urlParam(r, "username"))
 	if username == "" {
-		util.WriteError(w, http.StatusBadRequest, "invalid username")
+		util.WriteErr(w, util.Invalid("username", "invalid username"))
+		return
+	}
+	if !requireOwner(w, r, kindFTPUser, username) {
 		return
 	}
 
 	removeLine(vsftpdUserListFile, username)
-	util.RunCmd("userdel", username) // don't use -r to preserve files
-	util.RunCmd("systemctl", "restart", "vsftpd")
+	runCmd(r.Context(), "userdel", username) // don't use -r to preserve files
+	runCmd(r.Context(), "systemctl", "restart", "vsftpd")
+	forgetResource(kindFTPUser, username)
 
 	util.WriteJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
 }
@@ -92,18 +175,24 @@ func DeleteFTPUser(w http.ResponseWriter, r *http.Request) {
 // PUT /api/ftp/{username}
 func UpdateFTPPassword(w http.ResponseWriter, r *http.Request) {
 	username := util.Sanitize(chi_urlParam(r, "username"))
+	if !requireOwner(w, r, kindFTPUser, username) {
+		return
+	}
 	var body struct {
 		Password string `json:"password"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
-		util.WriteError(w, http.StatusBadRequest, "invalid body")
+		util.WriteErr(w, util.ErrInvalidBody)
 		return
 	}
 	if len(body.Password) < 8 {
-		util.WriteError(w, http.StatusBadRequest, "password too short")
+		util.WriteErr(w, util.Invalid("password", "password too short"))
+		return
+	}
+	if err := setSystemPassword(r.Context(), username, body.Password); err != nil {
+		util.WriteErr(w, util.Wrap(err, "failed to update password"))
 		return
 	}
-	util.RunCmd("chpasswd", username+":"+body.Password)
 	util.WriteJSON(w, http.StatusOK, map[string]string{"status": "updated"})
 }
 
@@ -123,7 +212,7 @@ func readFTPUsers() []FTPUser {
 		if username == "" || strings.HasPrefix(username, "#") {
 			continue
 		}
-		homeDir := "/var/www/" + username
+		homeDir := filepath.Join(fileManagerRoot, username)
 		if info, err := os.Stat("/home/" + username); err == nil && info.IsDir() {
 			homeDir = "/home/" + username
 		}
@@ -0,0 +1,664 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"blogron/middleware"
+	"blogron/util"
+)
+
+// Long-running operations (WordPress installs, certbot, core updates, manual
+// cron runs) run as jobs: the request returns 202 Accepted with the job and
+// clients poll GET /api/jobs/{id}. Jobs are kept in <data dir>/jobs.json
+// and the output of finished ones in <data dir>/jobs/<id>.log. On shutdown
+// running jobs get a grace period (see DrainJobs), and anything still
+// running when the panel stops comes back as "interrupted".
+const (
+	jobsFile       = "jobs.json"
+	jobOutputDir   = "jobs"
+	maxJobs        = 200      // finished jobs beyond this are dropped, oldest first
+	maxJobOutput   = 64 << 10 // only the tail of a job's output is kept
+	jobKeepalive   = 15 * time.Second
+	jobCancelGrace = 20 * time.Second // for jobs cancelled at shutdown to roll back
+)
+
+// errJobsDraining refuses new jobs once the panel is shutting down.
+var errJobsDraining = util.NewError(http.StatusServiceUnavailable, util.CodeUnavailable, "the panel is shutting down; retry in a moment")
+
+// Job states.
+const (
+	JobRunning     = "running"
+	JobSucceeded   = "succeeded"
+	JobFailed      = "failed"
+	JobCancelled   = "cancelled"
+	JobInterrupted = "interrupted"
+)
+
+// JobStep is one named stage of a job.
+type JobStep struct {
+	Name       string     `json:"name"`
+	Status     string     `json:"status"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	Error      string     `json:"error,omitempty"`
+}
+
+// Job is the public view of a background operation.
+type Job struct {
+	ID         string            `json:"id"`
+	Kind       string            `json:"kind"`
+	Target     string            `json:"target"`
+	Owner      string            `json:"owner"`
+	Status     string            `json:"status"`
+	Steps      []JobStep         `json:"steps"`
+	Output     string            `json:"output"`
+	Error      string            `json:"error,omitempty"`
+	ErrorCode  string            `json:"error_code,omitempty"` // see util/errors.go
+	Result     map[string]string `json:"result,omitempty"`
+	CreatedAt  time.Time         `json:"created_at"`
+	FinishedAt *time.Time        `json:"finished_at,omitempty"`
+}
+
+// jobRecord is a Job plus what only lives in memory: the cancel func,
+// results too sensitive for jobs.json (shown to the job's owner only) and
+// the bookkeeping for streaming (see StreamJob).
+type jobRecord struct {
+	Job
+	cancel  context.CancelFunc
+	secrets map[string]string
+
+	written int64         // output bytes ever appended; Output holds the tail
+	rev     int           // bumped whenever more than the output changes
+	changed chan struct{} // closed and replaced on every change
+}
+
+var jobs struct {
+	sync.Mutex
+	loaded   bool
+	records  map[string]*jobRecord
+	draining bool
+	running  sync.WaitGroup
+}
+
+// jobsStopping is closed by DrainJobs; event streams end so their clients
+// reconnect to the next process.
+var jobsStopping = make(chan struct{})
+
+// jobRun is handed to a job's function to report progress. Commands run
+// with j.ctx stream their output into the job.
+type jobRun struct {
+	ctx context.Context
+	id  string
+	out *jobOutput
+}
+
+// ListJobs godoc
+// GET /api/jobs?status=running&kind=wordpress.create
+// Site owners only see their own jobs.
+func ListJobs(w http.ResponseWriter, r *http.Request) {
+	status, kind := r.URL.Query().Get("status"), r.URL.Query().Get("kind")
+
+	jobs.Lock()
+	defer jobs.Unlock()
+	if err := loadJobsLocked(); err != nil {
+		util.WriteErr(w, util.Wrap(err, "cannot read jobs"))
+		return
+	}
+	list := []Job{}
+	for _, rec := range jobs.records {
+		if !canSeeJob(r, rec) || (status != "" && rec.Status != status) || (kind != "" && rec.Kind != kind) {
+			continue
+		}
+		list = append(list, jobView(r, rec))
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.After(list[j].CreatedAt) })
+	util.WriteJSON(w, http.StatusOK, list)
+}
+
+// GetJob godoc
+// GET /api/jobs/{id}
+func GetJob(w http.ResponseWriter, r *http.Request) {
+	id := chi_urlParam(r, "id")
+
+	jobs.Lock()
+	defer jobs.Unlock()
+	if err := loadJobsLocked(); err != nil {
+		util.WriteErr(w, util.Wrap(err, "cannot read jobs"))
+		return
+	}
+	rec, ok := jobs.records[id]
+	if !ok || !canSeeJob(r, rec) {
+		util.WriteErr(w, errJobNotFound)
+		return
+	}
+	util.WriteJSON(w, http.StatusOK, jobView(r, rec))
+}
+
+// StreamJob godoc
+// GET /api/jobs/{id}/stream
+// Server-Sent Events: "output" events carry the job's output a line at a
+// time (the event ID is the output offset, so a reconnecting client resumes
+// via Last-Event-ID), "job" events the job whenever its steps or status
+// change, and a final "done" event the finished job.
+func StreamJob(w http.ResponseWriter, r *http.Request) {
+	id := chi_urlParam(r, "id")
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		util.WriteError(w, http.StatusInternalServerError, "streaming not supported")
+		return
+	}
+
+	jobs.Lock()
+	if err := loadJobsLocked(); err != nil {
+		jobs.Unlock()
+		util.WriteErr(w, util.Wrap(err, "cannot read jobs"))
+		return
+	}
+	rec, ok := jobs.records[id]
+	if !ok || !canSeeJob(r, rec) {
+		jobs.Unlock()
+		util.WriteErr(w, errJobNotFound)
+		return
+	}
+	jobs.Unlock()
+
+	var sent int64
+	if v, err := strconv.ParseInt(r.Header.Get("Last-Event-ID"), 10, 64); err == nil && v > 0 {
+		sent = v
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // the panel sits behind nginx
+	w.WriteHeader(http.StatusOK)
+
+	keepalive := time.NewTicker(jobKeepalive)
+	defer keepalive.Stop()
+	rev := -1
+	for {
+		jobs.Lock()
+		base := rec.written - int64(len(rec.Output))
+		from := sent - base
+		if from < 0 {
+			from = 0 // the client fell behind the kept tail
+		}
+		if from > int64(len(rec.Output)) {
+			from = int64(len(rec.Output))
+		}
+		chunk, offset := rec.Output[from:], base+from
+		sent = rec.written
+		var view Job
+		newRev := rec.rev != rev
+		if newRev {
+			rev = rec.rev
+			view = jobView(r, rec)
+		}
+		done := rec.Status != JobRunning
+		changed := rec.changed
+		jobs.Unlock()
+
+		for _, line := range strings.SplitAfter(chunk, "\n") {
+			if line == "" {
+				continue
+			}
+			offset += int64(len(line))
+			fmt.Fprintf(w, "id: %d\nevent: output\ndata: %s\n\n", offset, strings.TrimSuffix(line, "\n"))
+		}
+		if done {
+			if !newRev {
+				jobs.Lock()
+				view = jobView(r, rec)
+				jobs.Unlock()
+			}
+			writeEvent(w, "done", view)
+			flusher.Flush()
+			return
+		}
+		if newRev {
+			writeEvent(w, "job", view)
+		}
+		flusher.Flush()
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-jobsStopping:
+			return
+		case <-keepalive.C:
+			fmt.Fprint(w, ": keepalive\n\n")
+			flusher.Flush()
+		case <-changed:
+		}
+	}
+}
+
+// CancelJob godoc
+// DELETE /api/jobs/{id}
+// Cancels a running job; its current command is terminated.
+func CancelJob(w http.ResponseWriter, r *http.Request) {
+	id := chi_urlParam(r, "id")
+
+	jobs.Lock()
+	defer jobs.Unlock()
+	if err := loadJobsLocked(); err != nil {
+		util.WriteErr(w, util.Wrap(err, "cannot read jobs"))
+		return
+	}
+	rec, ok := jobs.records[id]
+	if !ok || !canSeeJob(r, rec) {
+		util.WriteErr(w, errJobNotFound)
+		return
+	}
+	if rec.Status != JobRunning || rec.cancel == nil {
+		util.WriteErr(w, errJobNotRunning)
+		return
+	}
+	rec.cancel()
+	util.WriteJSON(w, http.StatusAccepted, map[string]string{"status": "cancelling", "id": id})
+}
+
+// ── helpers ───────────────────────────────────────────────────────────────────
+
+func writeEvent(w http.ResponseWriter, event string, v any) {
+	data, _ := json.Marshal(v)
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+}
+
+// startJob registers a job for the calling account and runs fn in the
+// background. fn's error, if any, fails the job; the job is marked
+// cancelled when it was cancelled through DELETE /api/jobs/{id}.
+func startJob(r *http.Request, kind, target string, fn func(j *jobRun) error) (Job, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	rec := &jobRecord{
+		Job: Job{
+			ID:        randomToken(8),
+			Kind:      kind,
+			Target:    target,
+			Owner:     middleware.Subject(r),
+			Status:    JobRunning,
+			Steps:     []JobStep{},
+			CreatedAt: time.Now().UTC(),
+		},
+		cancel:  cancel,
+		changed: make(chan struct{}),
+	}
+
+	jobs.Lock()
+	if err := loadJobsLocked(); err != nil {
+		jobs.Unlock()
+		cancel()
+		return Job{}, err
+	}
+	if jobs.draining {
+		jobs.Unlock()
+		cancel()
+		return Job{}, errJobsDraining
+	}
+	jobs.records[rec.ID] = rec
+	jobs.running.Add(1)
+	pruneJobsLocked()
+	err := saveJobsLocked()
+	view := rec.Job
+	jobs.Unlock()
+	if err != nil {
+		log.Printf("warning: could not persist jobs: %v", err)
+	}
+
+	go func() {
+		defer jobs.running.Done()
+		defer cancel()
+		j := &jobRun{id: rec.ID}
+		j.out = &jobOutput{job: j}
+		j.ctx = util.WithCmdOutput(ctx, j.out)
+		var err error
+		func() {
+			defer func() {
+				if p := recover(); p != nil {
+					log.Printf("job %s panicked: %v", rec.ID, p)
+					err = errors.New("internal error")
+				}
+			}()
+			err = fn(j)
+		}()
+		j.out.flush()
+		j.finish(err)
+	}()
+	return view, nil
+}
+
+// DrainJobs stops new jobs from starting and waits until the running ones
+// finish or ctx expires. Jobs still running then are cancelled, which rolls
+// back provisioning, and get jobCancelGrace to wind down before they are
+// recorded as interrupted. It returns how many jobs had to be cancelled.
+func DrainJobs(ctx context.Context) int {
+	jobs.Lock()
+	if jobs.draining {
+		jobs.Unlock()
+		return 0
+	}
+	jobs.draining = true
+	jobs.Unlock()
+	close(jobsStopping)
+
+	idle := make(chan struct{})
+	go func() {
+		jobs.running.Wait()
+		close(idle)
+	}()
+	select {
+	case <-idle:
+		return 0
+	case <-ctx.Done():
+	}
+
+	jobs.Lock()
+	cancelled := 0
+	for _, rec := range jobs.records {
+		if rec.Status == JobRunning && rec.cancel != nil {
+			rec.cancel()
+			cancelled++
+		}
+	}
+	jobs.Unlock()
+	select {
+	case <-idle:
+	case <-time.After(jobCancelGrace):
+		log.Printf("warning: %d job(s) did not stop in time", cancelled)
+	}
+	return cancelled
+}
+
+// writeJobAccepted starts a job and answers 202 with it, or writes an error.
+func writeJobAccepted(w http.ResponseWriter, r *http.Request, kind, target string, fn func(j *jobRun) error) {
+	job, err := startJob(r, kind, target, fn)
+	if errors.Is(err, errJobsDraining) {
+		w.Header().Set("Retry-After", "5")
+		util.WriteErr(w, err)
+		return
+	}
+	if err != nil {
+		util.WriteErr(w, util.Wrap(err, "cannot start job"))
+		return
+	}
+	w.Header().Set("Location", "/api/v1/jobs/"+job.ID)
+	util.WriteJSON(w, http.StatusAccepted, job)
+}
+
+// Step closes the current step as done and opens a new one.
+func (j *jobRun) Step(name string) {
+	j.update(func(rec *jobRecord) {
+		now := time.Now().UTC()
+		closeStep(rec, "done", "", now)
+		rec.Steps = append(rec.Steps, JobStep{Name: name, Status: JobRunning, StartedAt: now})
+	}, true)
+}
+
+// Log appends a progress message to the job output.
+func (j *jobRun) Log(s string) {
+	s = strings.TrimRight(s, "\n")
+	if s == "" {
+		return
+	}
+	j.appendOutput(s + "\n")
+}
+
+// appendOutput adds complete lines to the job output, keeping its tail.
+func (j *jobRun) appendOutput(lines string) {
+	j.update(func(rec *jobRecord) {
+		out := rec.Output + lines
+		if len(out) > maxJobOutput {
+			out = out[len(out)-maxJobOutput:]
+			if i := strings.IndexByte(out, '\n'); i >= 0 {
+				out = out[i+1:] // drop the partial first line
+			}
+		}
+		rec.Output = out
+		rec.written += int64(len(lines))
+	}, false)
+}
+
+// SetResult records a result value that is shown with the job.
+func (j *jobRun) SetResult(key, value string) {
+	j.update(func(rec *jobRecord) {
+		if rec.Result == nil {
+			rec.Result = map[string]string{}
+		}
+		rec.Result[key] = value
+	}, true)
+}
+
+// SetSecretResult records a result value that is never written to disk and
+// only shown to the job's owner.
+func (j *jobRun) SetSecretResult(key, value string) {
+	j.update(func(rec *jobRecord) {
+		if rec.secrets == nil {
+			rec.secrets = map[string]string{}
+		}
+		rec.secrets[key] = value
+	}, false)
+}
+
+func (j *jobRun) finish(err error) {
+	j.update(func(rec *jobRecord) {
+		now := time.Now().UTC()
+		rec.FinishedAt = &now
+		rec.cancel = nil
+		switch {
+		case err == nil:
+			rec.Status = JobSucceeded
+			closeStep(rec, "done", "", now)
+		case j.ctx.Err() != nil && jobs.draining:
+			rec.Status = JobInterrupted
+			rec.Error = "panel shut down while the job was running"
+			closeStep(rec, JobInterrupted, "", now)
+		case j.ctx.Err() != nil && !util.IsTimeout(err):
+			rec.Status = JobCancelled
+			rec.Error = "cancelled"
+			closeStep(rec, JobCancelled, "", now)
+		default:
+			rec.Status = JobFailed
+			rec.Error = err.Error()
+			rec.ErrorCode = util.ErrorFrom(err).Code
+			closeStep(rec, JobFailed, err.Error(), now)
+		}
+		if err := saveJobOutput(rec); err != nil {
+			log.Printf("warning: could not save output of job %s: %v", rec.ID, err)
+		}
+	}, true)
+}
+
+// update applies fn to the job and wakes its streams. persist marks a
+// change to anything but the output, which is also saved to disk.
+func (j *jobRun) update(fn func(rec *jobRecord), persist bool) {
+	jobs.Lock()
+	defer jobs.Unlock()
+	rec, ok := jobs.records[j.id]
+	if !ok {
+		return
+	}
+	fn(rec)
+	if persist {
+		rec.rev++
+		if err := saveJobsLocked(); err != nil {
+			log.Printf("warning: could not persist jobs: %v", err)
+		}
+	}
+	if rec.changed != nil {
+		close(rec.changed)
+		rec.changed = make(chan struct{})
+	}
+}
+
+// jobOutput is the io.Writer commands in a job stream to. It passes output
+// on to the job a line at a time; a carriage return (progress bars) also
+// ends a line.
+type jobOutput struct {
+	job     *jobRun
+	mu      sync.Mutex
+	partial []byte
+}
+
+func (o *jobOutput) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.partial = append(o.partial, p...)
+	end := bytes.LastIndexAny(o.partial, "\r\n")
+	if end < 0 {
+		if len(o.partial) > maxJobOutput {
+			o.emit(o.partial)
+			o.partial = o.partial[:0]
+		}
+		return len(p), nil
+	}
+	o.emit(o.partial[:end])
+	o.partial = append(o.partial[:0], o.partial[end+1:]...)
+	return len(p), nil
+}
+
+// flush passes on a trailing line without a newline.
+func (o *jobOutput) flush() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.emit(o.partial)
+	o.partial = o.partial[:0]
+}
+
+func (o *jobOutput) emit(b []byte) {
+	var lines strings.Builder
+	for _, line := range strings.FieldsFunc(string(b), func(r rune) bool { return r == '\r' || r == '\n' }) {
+		if strings.TrimSpace(line) != "" {
+			lines.WriteString(line + "\n")
+		}
+	}
+	if lines.Len() > 0 {
+		o.job.appendOutput(lines.String())
+	}
+}
+
+func closeStep(rec *jobRecord, status, errMsg string, at time.Time) {
+	if n := len(rec.Steps); n > 0 && rec.Steps[n-1].FinishedAt == nil {
+		rec.Steps[n-1].Status = status
+		rec.Steps[n-1].Error = errMsg
+		rec.Steps[n-1].FinishedAt = &at
+	}
+}
+
+// canSeeJob: server staff see every job, site owners only their own.
+func canSeeJob(r *http.Request, rec *jobRecord) bool {
+	return !isScoped(r) || rec.Owner == middleware.Subject(r)
+}
+
+func jobView(r *http.Request, rec *jobRecord) Job {
+	job := rec.Job
+	job.Steps = append([]JobStep(nil), rec.Steps...)
+	if len(rec.Result) > 0 || len(rec.secrets) > 0 {
+		job.Result = map[string]string{}
+		for k, v := range rec.Result {
+			job.Result[k] = v
+		}
+		if rec.Owner == middleware.Subject(r) {
+			for k, v := range rec.secrets {
+				job.Result[k] = v
+			}
+		}
+	}
+	return job
+}
+
+func pruneJobsLocked() {
+	if len(jobs.records) <= maxJobs {
+		return
+	}
+	var finished []*jobRecord
+	for _, rec := range jobs.records {
+		if rec.Status != JobRunning {
+			finished = append(finished, rec)
+		}
+	}
+	sort.Slice(finished, func(i, j int) bool { return finished[i].CreatedAt.Before(finished[j].CreatedAt) })
+	for _, rec := range finished {
+		if len(jobs.records) <= maxJobs {
+			break
+		}
+		delete(jobs.records, rec.ID)
+		os.Remove(jobOutputPath(rec.ID))
+	}
+}
+
+// loadJobsLocked reads jobs.json once. Jobs that were running when the
+// panel last stopped cannot be resumed and are marked interrupted.
+func loadJobsLocked() error {
+	if jobs.loaded {
+		return nil
+	}
+	stored := map[string]*Job{}
+	if err := util.ReadState(jobsFile, &stored); err != nil {
+		return err
+	}
+	jobs.records = map[string]*jobRecord{}
+	interrupted := false
+	for id, job := range stored {
+		rec := &jobRecord{Job: *job}
+		if rec.Output != "" {
+			// Written by a version that kept the output in jobs.json.
+			if err := saveJobOutput(rec); err != nil {
+				return err
+			}
+		} else if data, err := os.ReadFile(jobOutputPath(id)); err == nil {
+			rec.Output = string(data)
+		}
+		rec.written = int64(len(rec.Output))
+		if rec.Status == JobRunning {
+			now := time.Now().UTC()
+			rec.Status = JobInterrupted
+			rec.Error = "panel restarted while the job was running"
+			rec.FinishedAt = &now
+			closeStep(rec, JobInterrupted, "", now)
+			interrupted = true
+		}
+		jobs.records[id] = rec
+	}
+	jobs.loaded = true
+	if interrupted {
+		return saveJobsLocked()
+	}
+	return nil
+}
+
+// saveJobsLocked writes everything but the output to jobs.json. Output
+// changes too often to rewrite every job's with it; saveJobOutput writes it
+// once the job is done.
+func saveJobsLocked() error {
+	stored := make(map[string]*Job, len(jobs.records))
+	for id, rec := range jobs.records {
+		job := rec.Job
+		job.Output = ""
+		stored[id] = &job
+	}
+	return util.WriteState(jobsFile, stored)
+}
+
+// saveJobOutput writes the output of a job to its own file.
+func saveJobOutput(rec *jobRecord) error {
+	if err := os.MkdirAll(filepath.Join(util.DataDir(), jobOutputDir), 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(jobOutputPath(rec.ID), []byte(rec.Output), 0600)
+}
+
+func jobOutputPath(id string) string {
+	return filepath.Join(util.DataDir(), jobOutputDir, id+".log")
+}
@@ -0,0 +1,59 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"blogron/util"
+)
+
+func TestJobOutputKeptOutOfJobsFile(t *testing.T) {
+	prevDataDir := util.DataDir()
+	t.Cleanup(func() {
+		util.SetDataDir(prevDataDir)
+		jobs.loaded, jobs.records = false, nil
+	})
+	dir := t.TempDir()
+	util.SetDataDir(dir)
+	jobs.loaded, jobs.records = false, nil
+
+	job, err := startJob(httptest.NewRequest(http.MethodPost, "/", nil), "test", "target", func(j *jobRun) error {
+		j.Step("first")
+		j.Log("hello from the job")
+		j.Step("second")
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	jobs.running.Wait()
+
+	data, err := os.ReadFile(filepath.Join(dir, jobsFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "hello from the job") {
+		t.Errorf("output written to %s:\n%s", jobsFile, data)
+	}
+	out, err := os.ReadFile(filepath.Join(dir, jobOutputDir, job.ID+".log"))
+	if err != nil || string(out) != "hello from the job\n" {
+		t.Errorf("output file = %q, %v", out, err)
+	}
+
+	// A restarted panel shows the job with its output again.
+	jobs.loaded, jobs.records = false, nil
+	jobs.Lock()
+	err = loadJobsLocked()
+	rec := jobs.records[job.ID]
+	jobs.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec == nil || rec.Status != JobSucceeded || rec.Output != "hello from the job\n" || len(rec.Steps) != 2 {
+		t.Errorf("reloaded job = %+v", rec)
+	}
+}
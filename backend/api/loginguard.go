@@ -0,0 +1,205 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"blogron/util"
+)
+
+// Login throttling. Failed attempts are counted in a sliding window per
+// client IP and per username+IP; crossing a limit locks that key out, and
+// each repeated lockout doubles the duration up to maxLockout. The client IP
+// is the connection's, or what a trusted proxy reports (middleware.RealIP),
+// so rotating forwarding headers neither escapes the limits nor frames
+// another address.
+//
+// A username is never locked out outright, since anyone could then lock the
+// admin out: failures against it from all IPs together only slow down its
+// answers, by a delay that doubles per failure up to maxUserBackoff.
+//
+// Every failure and lockout is logged in a fixed format that the fail2ban
+// filter shipped in blogron-fail2ban.conf matches:
+//
+//	auth failure ip=203.0.113.7 user="admin"
+//	auth lockout ip=203.0.113.7 user="admin" duration=2m0s scope=user_ip
+const (
+	loginWindow          = 15 * time.Minute
+	maxFailuresPerIP     = 20
+	maxFailuresPerUserIP = 5
+	userBackoffAfter     = 5 // failures on one username before its answers slow down
+	baseUserBackoff      = 250 * time.Millisecond
+	maxUserBackoff       = 5 * time.Second
+	baseLockout          = time.Minute
+	maxLockout           = time.Hour
+	lockoutMemory        = 24 * time.Hour // repeat-offence counter resets after this
+)
+
+// loginNow is the clock attempts are counted against; tests move it.
+var loginNow = time.Now
+
+type attemptLog struct {
+	failures    []time.Time
+	lockouts    int
+	lockedUntil time.Time
+	lastSeen    time.Time
+}
+
+var loginGuard = struct {
+	sync.Mutex
+	byIP     map[string]*attemptLog
+	byUserIP map[string]*attemptLog
+	byUser   map[string]*attemptLog // failures only, never locked
+}{
+	byIP:     map[string]*attemptLog{},
+	byUserIP: map[string]*attemptLog{},
+	byUser:   map[string]*attemptLog{},
+}
+
+// loginBlocked writes a 429 and returns true when the client IP, or the
+// username from this IP, is currently locked out. Otherwise it waits out
+// the username's backoff (or until the client goes away) and returns false.
+func loginBlocked(w http.ResponseWriter, r *http.Request, username string) bool {
+	ip := clientIP(r)
+	now := loginNow()
+
+	loginGuard.Lock()
+	wait := lockRemaining(loginGuard.byIP[ip], now)
+	if d := lockRemaining(loginGuard.byUserIP[userIPKey(username, ip)], now); d > wait {
+		wait = d
+	}
+	backoff := userBackoff(loginGuard.byUser[username], now)
+	loginGuard.Unlock()
+
+	if wait <= 0 {
+		if backoff > 0 {
+			select {
+			case <-time.After(backoff):
+			case <-r.Context().Done():
+			}
+		}
+		return false
+	}
+	secs := int(wait.Round(time.Second) / time.Second)
+	if secs < 1 {
+		secs = 1
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(secs))
+	util.WriteErr(w, util.NewError(http.StatusTooManyRequests, util.CodeLoginThrottled, "too many failed login attempts; try again in "+strconv.Itoa(secs)+"s"))
+	return true
+}
+
+// recordLoginFailure counts a failed attempt and locks out keys over their limit.
+func recordLoginFailure(r *http.Request, username string) {
+	ip := clientIP(r)
+	now := loginNow()
+	log.Printf("auth failure ip=%s user=%q", ip, username)
+
+	loginGuard.Lock()
+	defer loginGuard.Unlock()
+	pruneLoginGuardLocked(now)
+
+	if d := addFailure(loginGuard.byIP, ip, maxFailuresPerIP, now); d > 0 {
+		log.Printf("auth lockout ip=%s user=%q duration=%s scope=ip", ip, username, d)
+	}
+	if username != "" {
+		if d := addFailure(loginGuard.byUserIP, userIPKey(username, ip), maxFailuresPerUserIP, now); d > 0 {
+			log.Printf("auth lockout ip=%s user=%q duration=%s scope=user_ip", ip, username, d)
+		}
+		countFailure(loginGuard.byUser, username, now)
+	}
+}
+
+// recordLoginSuccess forgets the username's failures. IP counters are left
+// alone so one valid account cannot be used to reset a spraying attack.
+func recordLoginSuccess(r *http.Request, username string) {
+	loginGuard.Lock()
+	delete(loginGuard.byUserIP, userIPKey(username, clientIP(r)))
+	delete(loginGuard.byUser, username)
+	loginGuard.Unlock()
+}
+
+// ── helpers ───────────────────────────────────────────────────────────────────
+
+func lockRemaining(a *attemptLog, now time.Time) time.Duration {
+	if a == nil {
+		return 0
+	}
+	return a.lockedUntil.Sub(now)
+}
+
+func userIPKey(username, ip string) string {
+	return username + "\x00" + ip
+}
+
+// userBackoff is how long to delay an attempt on a username with a's
+// recent failures.
+func userBackoff(a *attemptLog, now time.Time) time.Duration {
+	if a == nil {
+		return 0
+	}
+	n := 0
+	for _, t := range a.failures {
+		if now.Sub(t) < loginWindow {
+			n++
+		}
+	}
+	if n < userBackoffAfter {
+		return 0
+	}
+	d := baseUserBackoff << (n - userBackoffAfter)
+	if d > maxUserBackoff || d <= 0 {
+		d = maxUserBackoff
+	}
+	return d
+}
+
+// countFailure records a failure for key and returns how many fell in the
+// window.
+func countFailure(m map[string]*attemptLog, key string, now time.Time) int {
+	a := m[key]
+	if a == nil {
+		a = &attemptLog{}
+		m[key] = a
+	}
+	a.lastSeen = now
+
+	kept := a.failures[:0]
+	for _, t := range a.failures {
+		if now.Sub(t) < loginWindow {
+			kept = append(kept, t)
+		}
+	}
+	a.failures = append(kept, now)
+	return len(a.failures)
+}
+
+// addFailure records a failure for key and returns the lockout duration if
+// this failure triggered one.
+func addFailure(m map[string]*attemptLog, key string, limit int, now time.Time) time.Duration {
+	if countFailure(m, key, now) < limit {
+		return 0
+	}
+	a := m[key]
+	d := baseLockout << a.lockouts
+	if d > maxLockout || d <= 0 {
+		d = maxLockout
+	}
+	a.lockouts++
+	a.lockedUntil = now.Add(d)
+	a.failures = nil
+	return d
+}
+
+func pruneLoginGuardLocked(now time.Time) {
+	for _, m := range []map[string]*attemptLog{loginGuard.byIP, loginGuard.byUserIP, loginGuard.byUser} {
+		for key, a := range m {
+			if now.Sub(a.lastSeen) > lockoutMemory && now.After(a.lockedUntil) {
+				delete(m, key)
+			}
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package api
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// fakeLoginClock fixes the login guard's clock and returns a function that
+// moves it forward. Failure log lines are discarded for the test.
+func fakeLoginClock(t *testing.T) func(time.Duration) {
+	t.Helper()
+	resetLoginGuard(t)
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	loginNow = func() time.Time { return now }
+	out := log.Writer()
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() {
+		loginNow = time.Now
+		log.SetOutput(out)
+	})
+	return func(d time.Duration) { now = now.Add(d) }
+}
+
+// loginAttempt is a login request from ip whose context is already done,
+// so a username backoff returns at once instead of sleeping.
+func loginAttempt(ip string) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, "/api/auth/login", nil)
+	r.RemoteAddr = ip + ":40000"
+	ctx, cancel := context.WithCancel(r.Context())
+	cancel()
+	return r.WithContext(ctx)
+}
+
+func fail(n int, ip, username string) {
+	for i := 0; i < n; i++ {
+		recordLoginFailure(loginAttempt(ip), username)
+	}
+}
+
+// blocked reports whether a login for username from ip is refused, and the
+// Retry-After it was refused with.
+func blocked(t *testing.T, ip, username string) (bool, string) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	if !loginBlocked(rec, loginAttempt(ip), username) {
+		return false, ""
+	}
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("blocked with status %d, want 429", rec.Code)
+	}
+	return true, rec.Header().Get("Retry-After")
+}
+
+func TestLoginGuardUserIPLimit(t *testing.T) {
+	advance := fakeLoginClock(t)
+
+	fail(maxFailuresPerUserIP-1, "203.0.113.7", "admin")
+	if ok, _ := blocked(t, "203.0.113.7", "admin"); ok {
+		t.Fatal("locked out below the per-user+IP limit")
+	}
+	fail(1, "203.0.113.7", "admin")
+	if ok, retry := blocked(t, "203.0.113.7", "admin"); !ok || retry != "60" {
+		t.Fatalf("blocked = %v, Retry-After %q; want locked out for 60s", ok, retry)
+	}
+
+	// The lockout is for this username from this IP only: the account stays
+	// reachable from elsewhere and the IP can still try other accounts.
+	if ok, _ := blocked(t, "198.51.100.2", "admin"); ok {
+		t.Error("username locked out from every IP")
+	}
+	if ok, _ := blocked(t, "203.0.113.7", "alice"); ok {
+		t.Error("IP locked out for every username")
+	}
+
+	advance(baseLockout + time.Second)
+	if ok, _ := blocked(t, "203.0.113.7", "admin"); ok {
+		t.Fatal("still locked out after the lockout ended")
+	}
+
+	// A repeated lockout lasts twice as long.
+	fail(maxFailuresPerUserIP, "203.0.113.7", "admin")
+	if ok, retry := blocked(t, "203.0.113.7", "admin"); !ok || retry != "120" {
+		t.Errorf("blocked = %v, Retry-After %q; want locked out for 120s", ok, retry)
+	}
+}
+
+func TestLoginGuardIPLimit(t *testing.T) {
+	fakeLoginClock(t)
+
+	// Spraying many usernames stays under each per-user+IP limit but adds
+	// up against the IP.
+	users := []string{"admin", "root", "alice", "bob", "carol"}
+	for i := 0; i < maxFailuresPerIP-1; i++ {
+		fail(1, "203.0.113.7", users[i%len(users)])
+	}
+	if ok, _ := blocked(t, "203.0.113.7", "dave"); ok {
+		t.Fatal("locked out below the per-IP limit")
+	}
+	fail(1, "203.0.113.7", "")
+	for _, u := range []string{"dave", "admin", ""} {
+		if ok, retry := blocked(t, "203.0.113.7", u); !ok || retry != "60" {
+			t.Errorf("user %q: blocked = %v, Retry-After %q; want locked out for 60s", u, ok, retry)
+		}
+	}
+	if ok, _ := blocked(t, "198.51.100.2", "dave"); ok {
+		t.Error("another IP locked out")
+	}
+}
+
+func TestLoginGuardWindow(t *testing.T) {
+	advance := fakeLoginClock(t)
+
+	// Failures older than the window no longer count towards a limit.
+	fail(maxFailuresPerUserIP-1, "203.0.113.7", "admin")
+	advance(loginWindow)
+	fail(maxFailuresPerUserIP-1, "203.0.113.7", "admin")
+	if ok, _ := blocked(t, "203.0.113.7", "admin"); ok {
+		t.Fatal("failures from outside the window counted")
+	}
+
+	// Inside the window they do.
+	advance(loginWindow - time.Minute)
+	fail(1, "203.0.113.7", "admin")
+	if ok, _ := blocked(t, "203.0.113.7", "admin"); !ok {
+		t.Fatal("not locked out at the limit within one window")
+	}
+}
+
+func TestLoginGuardSuccessKeepsIPCount(t *testing.T) {
+	fakeLoginClock(t)
+
+	fail(maxFailuresPerUserIP-1, "203.0.113.7", "admin")
+	fail(maxFailuresPerIP-maxFailuresPerUserIP, "203.0.113.7", "")
+	recordLoginSuccess(loginAttempt("203.0.113.7"), "admin")
+
+	// The username's count is forgotten...
+	fail(maxFailuresPerUserIP-1, "198.51.100.2", "admin")
+	if ok, _ := blocked(t, "198.51.100.2", "admin"); ok {
+		t.Fatal("unexpected lockout")
+	}
+	// ...but the IP's is not, so one more failure locks it out.
+	fail(1, "203.0.113.7", "mallory")
+	if ok, _ := blocked(t, "203.0.113.7", "admin"); !ok {
+		t.Error("successful login reset the per-IP count")
+	}
+}
+
+func TestUserBackoff(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	failures := func(n int, age time.Duration) *attemptLog {
+		a := &attemptLog{}
+		for i := 0; i < n; i++ {
+			a.failures = append(a.failures, now.Add(-age))
+		}
+		return a
+	}
+
+	for _, tc := range []struct {
+		name string
+		log  *attemptLog
+		want time.Duration
+	}{
+		{"no failures", nil, 0},
+		{"below threshold", failures(userBackoffAfter-1, time.Minute), 0},
+		{"at threshold", failures(userBackoffAfter, time.Minute), baseUserBackoff},
+		{"doubles", failures(userBackoffAfter+2, time.Minute), 4 * baseUserBackoff},
+		{"capped", failures(userBackoffAfter+20, time.Minute), maxUserBackoff},
+		{"shift overflow capped", failures(userBackoffAfter+200, time.Minute), maxUserBackoff},
+		{"outside window", failures(userBackoffAfter+2, loginWindow), 0},
+	} {
+		if got := userBackoff(tc.log, now); got != tc.want {
+			t.Errorf("%s: backoff = %s, want %s", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestAddFailureLockoutCapped(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	m := map[string]*attemptLog{}
+	var last time.Duration
+	for i := 0; i < 10; i++ {
+		for j := 0; j < 2; j++ {
+			last = addFailure(m, "k", 3, now)
+		}
+		if last != 0 {
+			t.Fatalf("round %d: locked out below the limit", i)
+		}
+		if last = addFailure(m, "k", 3, now); last == 0 {
+			t.Fatalf("round %d: not locked out at the limit", i)
+		}
+		now = now.Add(last)
+	}
+	if last != maxLockout {
+		t.Errorf("lockout after 10 rounds = %s, want %s", last, maxLockout)
+	}
+}
@@ -0,0 +1,493 @@
+package api
+
+import (
+	"net/http"
+	"path"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"blogron/config"
+	"blogron/middleware"
+	"blogron/store"
+	"blogron/util"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// The OpenAPI document is built from the router itself, so every route is in
+// it; apiDocs adds summaries, query parameters and the Go types of request
+// and response bodies, which are turned into JSON schemas by reflection.
+// Paths are relative to /api/v1 (/api is an alias for v1).
+
+const apiVersionPrefix = "/api/v1"
+
+// apiDoc describes one route, keyed by "METHOD /path" below /api/v1.
+type apiDoc struct {
+	Summary     string
+	Query       []string // query parameter names
+	Request     any      // JSON body; multipart is described by Upload
+	Upload      bool     // multipart/form-data with "path" and "file"
+	Response    any      // JSON body of the success response
+	Status      int      // success status, 200 when zero
+	ContentType string   // when the success response is not JSON
+	Public      bool     // no bearer token needed
+}
+
+// statusResponse stands for the {"status": "...", ...} objects most mutating
+// endpoints answer with.
+type statusResponse map[string]string
+
+// errorResponse is what util.WriteErr writes. Failed commands add the
+// program, exit code and stderr; provisioning failures add the step that
+// failed and what was rolled back (see writeProvisionError).
+type errorResponse struct {
+	Error          string            `json:"error"`
+	Code           string            `json:"code"`
+	Fields         map[string]string `json:"fields,omitempty"`
+	RequestID      string            `json:"request_id,omitempty"`
+	Command        string            `json:"command,omitempty"`
+	ExitCode       int               `json:"exit_code,omitempty"`
+	Stderr         string            `json:"stderr,omitempty"`
+	Step           string            `json:"step,omitempty"`
+	RolledBack     []string          `json:"rolled_back,omitempty"`
+	RollbackFailed map[string]string `json:"rollback_failed,omitempty"`
+}
+
+type (
+	pathRequest struct {
+		Path string `json:"path"`
+	}
+	passwordRequest struct {
+		Password string `json:"password"`
+	}
+	codeRequest struct {
+		Code string `json:"code"`
+	}
+	actionRequest struct {
+		Action string `json:"action"` // "activate" or "deactivate"
+	}
+	installRequest struct {
+		Name     string `json:"name"`
+		Activate bool   `json:"activate"`
+	}
+	settingsResponse struct {
+		ConfigFile string         `json:"config_file"`
+		Settings   *config.Config `json:"settings"`
+	}
+	filesResponse struct {
+		Path  string      `json:"path"`
+		Files []FileEntry `json:"files"`
+	}
+	tablesResponse struct {
+		Database string   `json:"database"`
+		Tables   []string `json:"tables"`
+	}
+)
+
+var apiDocs = map[string]apiDoc{
+	"GET /health":       {Summary: "Health check", Response: statusResponse{}, Public: true},
+	"GET /openapi.json": {Summary: "This OpenAPI document", Response: map[string]any{}, Public: true},
+	"POST /auth/login":  {Summary: "Log in; accounts with two-factor get a pre-auth token instead", Request: loginRequest{}, Response: loginResponse{}, Public: true},
+	"POST /auth/login/verify": {Summary: "Finish a two-factor login", Request: struct {
+		PreAuthToken string `json:"pre_auth_token"`
+		Code         string `json:"code"`
+	}{}, Response: loginResponse{}, Public: true},
+	"POST /auth/refresh": {Summary: "Exchange a refresh token for new tokens", Request: struct {
+		RefreshToken string `json:"refresh_token"`
+	}{}, Response: loginResponse{}, Public: true},
+	"POST /auth/logout":                    {Summary: "Revoke the current session", Response: statusResponse{}},
+	"GET /auth/sessions":                   {Summary: "List sessions of the calling account", Query: []string{"user"}, Response: []Session{}},
+	"DELETE /auth/sessions/{id}":           {Summary: "Revoke a session", Response: statusResponse{}},
+	"GET /auth/tokens":                     {Summary: "List API tokens", Query: []string{"user"}, Response: []APIToken{}},
+	"POST /auth/tokens":                    {Summary: "Create an API token; the secret is only shown once", Request: createAPITokenRequest{}, Response: createAPITokenResponse{}, Status: http.StatusCreated},
+	"DELETE /auth/tokens/{id}":             {Summary: "Revoke an API token", Response: statusResponse{}},
+	"POST /auth/totp/setup":                {Summary: "Start two-factor enrollment", Response: totpSetupResponse{}},
+	"POST /auth/totp/enable":               {Summary: "Confirm two-factor enrollment; ends the account's sessions, including this one", Request: codeRequest{}, Response: recoveryCodesResponse{}},
+	"POST /auth/totp/disable":              {Summary: "Turn two-factor login off", Request: passwordRequest{}, Response: statusResponse{}},
+	"POST /auth/totp/recovery-codes":       {Summary: "Replace the recovery codes", Request: passwordRequest{}, Response: recoveryCodesResponse{}},
+	"GET /system/stats":                    {Summary: "CPU, memory, disk and uptime", Response: SystemStats{}},
+	"GET /system/services":                 {Summary: "Status of the managed services", Response: []Service{}},
+	"POST /system/services/{name}/restart": {Summary: "Restart a service", Response: statusResponse{}},
+	"POST /system/services/{name}/stop":    {Summary: "Stop a service", Response: statusResponse{}},
+	"POST /system/services/{name}/start":   {Summary: "Start a service", Response: statusResponse{}},
+	"GET /system/logs":                     {Summary: "Recent journal entries", Query: []string{"unit", "lines"}, Response: []LogEntry{}},
+	"GET /users":                           {Summary: "List system users", Response: []User{}},
+	"POST /users":                          {Summary: "Create a system user", Request: createUserRequest{}, Response: statusResponse{}, Status: http.StatusCreated},
+	"PUT /users/{username}": {Summary: "Change a system user's password or shell", Request: struct {
+		Password string `json:"password"`
+		Shell    string `json:"shell"`
+	}{}, Response: statusResponse{}},
+	"DELETE /users/{username}":        {Summary: "Delete a system user", Response: statusResponse{}},
+	"POST /users/{username}/suspend":  {Summary: "Lock a system user", Response: statusResponse{}},
+	"POST /users/{username}/activate": {Summary: "Unlock a system user", Response: statusResponse{}},
+	"GET /email/queue":                {Summary: "Postfix mail queue (mailq output in \"queue\")", Response: statusResponse{}},
+	"POST /email/queue/flush":         {Summary: "Flush the mail queue", Response: statusResponse{}},
+	"GET /dns":                        {Summary: "List DNS zones", Response: []DNSZone{}},
+	"POST /dns": {Summary: "Create a DNS zone", Request: struct {
+		Domain    string `json:"domain"`
+		IPAddress string `json:"ip"`
+	}{}, Response: statusResponse{}, Status: http.StatusCreated},
+	"GET /dns/{domain}":            {Summary: "Get a DNS zone", Response: DNSZone{}},
+	"DELETE /dns/{domain}":         {Summary: "Delete a DNS zone", Response: statusResponse{}},
+	"POST /dns/{domain}/records":   {Summary: "Add a DNS record", Request: DNSRecord{}, Response: statusResponse{}, Status: http.StatusCreated},
+	"DELETE /dns/{domain}/records": {Summary: "Delete a DNS record", Request: DNSRecord{}, Response: statusResponse{}},
+	"GET /ownership":               {Summary: "List resource owners", Query: []string{"owner", "kind"}, Response: []Ownership{}},
+	"PUT /ownership/{kind}/{name}": {Summary: "Assign a resource to a site owner", Request: struct {
+		Owner string `json:"owner"`
+	}{}, Response: Ownership{}},
+	"GET /audit":                     {Summary: "Search the audit log (format=jsonl exports JSON Lines)", Query: []string{"actor", "resource", "since", "until", "outcome", "limit", "format"}, Response: []middleware.AuditEntry{}},
+	"GET /settings":                  {Summary: "Panel configuration with secrets masked", Response: settingsResponse{}},
+	"GET /panel-users":               {Summary: "List panel accounts", Response: []PanelUser{}},
+	"POST /panel-users":              {Summary: "Create a panel account", Request: panelUserRequest{}, Response: PanelUser{}, Status: http.StatusCreated},
+	"PUT /panel-users/{username}":    {Summary: "Update a panel account", Request: panelUserRequest{}, Response: PanelUser{}},
+	"DELETE /panel-users/{username}": {Summary: "Delete a panel account", Response: statusResponse{}},
+	"GET /resources":                 {Summary: "List resource metadata", Query: []string{"kind", "owner"}, Response: []store.Resource{}},
+	"PUT /resources/{kind}/{name}": {Summary: "Update a resource's notes", Request: struct {
+		Notes *string `json:"notes"`
+	}{}, Response: store.Resource{}},
+	"GET /jobs":                     {Summary: "List background jobs", Query: []string{"status", "kind"}, Response: []Job{}},
+	"GET /jobs/{id}":                {Summary: "Get a background job", Response: Job{}},
+	"GET /jobs/{id}/stream":         {Summary: "Follow a job as Server-Sent Events (output, job, done)", ContentType: "text/event-stream"},
+	"DELETE /jobs/{id}":             {Summary: "Cancel a running job", Response: statusResponse{}, Status: http.StatusAccepted},
+	"GET /vhosts":                   {Summary: "List nginx virtual hosts", Response: []Vhost{}},
+	"POST /vhosts":                  {Summary: "Create a virtual host; an existing one is changed with PUT", Request: createVhostRequest{}, Response: statusResponse{}, Status: http.StatusCreated},
+	"PUT /vhosts/{domain}":          {Summary: "Change a vhost's docroot, PHP version, aliases, redirects or custom directives; checked with nginx -t", Request: updateVhostRequest{}, Response: Vhost{}},
+	"DELETE /vhosts/{domain}":       {Summary: "Delete a virtual host", Response: statusResponse{}},
+	"POST /vhosts/{domain}/enable":  {Summary: "Enable a virtual host", Response: statusResponse{}},
+	"POST /vhosts/{domain}/disable": {Summary: "Disable a virtual host", Response: statusResponse{}},
+	"POST /vhosts/{domain}/ssl": {Summary: "Issue a Let's Encrypt certificate, then apply the recorded redirects and force_https (job)", Request: struct {
+		Email string `json:"email"`
+	}{}, Response: Job{}, Status: http.StatusAccepted},
+	"GET /vhosts/profiles":           {Summary: "List vhost profiles (nginx config templates)", Response: []VhostProfile{}},
+	"GET /vhosts/profiles/{name}":    {Summary: "Get a vhost profile with its template", Response: VhostProfile{}},
+	"DELETE /vhosts/profiles/{name}": {Summary: "Delete a custom vhost profile no vhost uses", Response: statusResponse{}},
+	"PUT /vhosts/profiles/{name}": {Summary: "Create or replace a custom vhost profile; checked with nginx -t", Request: struct {
+		Template string `json:"template"`
+	}{}, Response: VhostProfile{}},
+	"GET /databases":               {Summary: "List databases", Response: []Database{}},
+	"POST /databases":              {Summary: "Create a database and optionally a user", Request: createDatabaseRequest{}, Response: statusResponse{}, Status: http.StatusCreated},
+	"DELETE /databases/{name}":     {Summary: "Drop a database", Response: statusResponse{}},
+	"GET /databases/{name}/tables": {Summary: "List a database's tables", Response: tablesResponse{}},
+	"GET /files":                   {Summary: "List a directory", Query: []string{"path"}, Response: filesResponse{}},
+	"POST /files/mkdir":            {Summary: "Create a directory", Request: pathRequest{}, Response: statusResponse{}, Status: http.StatusCreated},
+	"DELETE /files":                {Summary: "Delete a file or directory", Query: []string{"path"}, Response: statusResponse{}},
+	"POST /files/rename": {Summary: "Rename or move a file", Request: struct {
+		From string `json:"from"`
+		To   string `json:"to"`
+	}{}, Response: statusResponse{}},
+	"GET /files/read": {Summary: "Read a text file", Query: []string{"path"}, Response: statusResponse{}},
+	"POST /files/write": {Summary: "Write a text file", Request: struct {
+		Path    string `json:"path"`
+		Content string `json:"content"`
+	}{}, Response: statusResponse{}},
+	"POST /files/upload": {Summary: "Upload a file", Upload: true, Response: statusResponse{}, Status: http.StatusCreated},
+	"GET /email/domains": {Summary: "List mail domains", Response: []MailDomain{}},
+	"POST /email/domains": {Summary: "Add a mail domain", Request: struct {
+		Domain string `json:"domain"`
+		Owner  string `json:"owner"`
+	}{}, Response: statusResponse{}, Status: http.StatusCreated},
+	"DELETE /email/domains/{domain}": {Summary: "Remove a mail domain", Response: statusResponse{}},
+	"GET /email/mailboxes":           {Summary: "List mailboxes", Query: []string{"domain"}, Response: []Mailbox{}},
+	"POST /email/mailboxes": {Summary: "Create a mailbox", Request: struct {
+		Email    string `json:"email"`
+		Password string `json:"password"`
+		Quota    string `json:"quota"`
+	}{}, Response: statusResponse{}, Status: http.StatusCreated},
+	"DELETE /email/mailboxes/{email}": {Summary: "Delete a mailbox", Response: statusResponse{}},
+	"GET /cron":                       {Summary: "List cron jobs", Query: []string{"user"}, Response: []CronJob{}},
+	"POST /cron":                      {Summary: "Create a cron job", Request: createCronRequest{}, Response: statusResponse{}, Status: http.StatusCreated},
+	"PUT /cron/{id}":                  {Summary: "Update a cron job", Request: createCronRequest{}, Response: statusResponse{}},
+	"DELETE /cron/{id}":               {Summary: "Delete a cron job", Query: []string{"user"}, Response: statusResponse{}},
+	"POST /cron/{id}/run":             {Summary: "Run a cron job now (job); the crontab user must be in the blogron-sites group", Query: []string{"user"}, Response: Job{}, Status: http.StatusAccepted},
+	"GET /ftp":                        {Summary: "List FTP users", Response: []FTPUser{}},
+	"POST /ftp": {Summary: "Create an FTP user", Request: struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+		HomeDir  string `json:"home_dir"`
+		Owner    string `json:"owner"`
+	}{}, Response: statusResponse{}, Status: http.StatusCreated},
+	"PUT /ftp/{username}":    {Summary: "Change an FTP user's password", Request: passwordRequest{}, Response: statusResponse{}},
+	"DELETE /ftp/{username}": {Summary: "Delete an FTP user", Response: statusResponse{}},
+	"GET /wordpress":         {Summary: "List WordPress sites", Response: []WPSite{}},
+	"POST /wordpress":        {Summary: "Install a WordPress site (job)", Request: createWPRequest{}, Response: Job{}, Status: http.StatusAccepted},
+	"DELETE /wordpress/{domain}": {Summary: "Delete a WordPress site; delete_db drops only the database and user the panel created for it", Request: struct {
+		DeleteDB bool `json:"delete_db"`
+	}{}, Response: statusResponse{}},
+	"GET /wordpress/{domain}/plugins":          {Summary: "List plugins", Response: []WPPlugin{}},
+	"POST /wordpress/{domain}/plugins":         {Summary: "Install a plugin", Request: installRequest{}, Response: statusResponse{}, Status: http.StatusCreated},
+	"PUT /wordpress/{domain}/plugins/{plugin}": {Summary: "Activate or deactivate a plugin", Request: actionRequest{}, Response: statusResponse{}},
+	"GET /wordpress/{domain}/themes":           {Summary: "List themes", Response: []WPTheme{}},
+	"POST /wordpress/{domain}/themes":          {Summary: "Install a theme", Request: installRequest{}, Response: statusResponse{}, Status: http.StatusCreated},
+	"PUT /wordpress/{domain}/themes/{theme}":   {Summary: "Activate a theme", Request: actionRequest{}, Response: statusResponse{}},
+	"POST /wordpress/{domain}/update":          {Summary: "Update core, plugins and themes (job)", Response: Job{}, Status: http.StatusAccepted},
+	"POST /wordpress/{domain}/maintenance": {Summary: "Toggle maintenance mode", Request: struct {
+		Enable bool `json:"enable"`
+	}{}, Response: statusResponse{}},
+	"POST /wordpress/{domain}/search-replace": {Summary: "Search and replace in the database", Request: struct {
+		Search  string `json:"search"`
+		Replace string `json:"replace"`
+	}{}, Response: statusResponse{}},
+	"POST /wordpress/{domain}/cache-flush": {Summary: "Flush the object cache", Response: statusResponse{}},
+}
+
+var openAPI struct {
+	once   sync.Once
+	routes chi.Routes
+	doc    map[string]any
+}
+
+// SetRoutes hands the router to the OpenAPI handler; call it once all routes
+// are registered.
+func SetRoutes(routes chi.Routes) {
+	openAPI.routes = routes
+}
+
+// OpenAPISpec godoc
+// GET /api/openapi.json
+// OpenAPI 3 description of every route under /api/v1.
+func OpenAPISpec(w http.ResponseWriter, r *http.Request) {
+	openAPI.once.Do(func() { openAPI.doc = buildOpenAPI(openAPI.routes) })
+	util.WriteJSON(w, http.StatusOK, openAPI.doc)
+}
+
+// ── helpers ───────────────────────────────────────────────────────────────────
+
+var pathParamRe = regexp.MustCompile(`\{([^}:]+)(:[^}]*)?\}`)
+
+func buildOpenAPI(routes chi.Routes) map[string]any {
+	b := &schemaBuilder{components: map[string]any{}}
+	errorRef := b.schema(reflect.TypeOf(errorResponse{}))
+	paths := map[string]map[string]any{}
+
+	chi.Walk(routes, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		path, ok := strings.CutPrefix(route, apiVersionPrefix)
+		if !ok || method == http.MethodHead || method == http.MethodOptions {
+			return nil
+		}
+		path = strings.TrimSuffix(path, "/")
+		doc := apiDocs[method+" "+path]
+		path = pathParamRe.ReplaceAllString(path, "{$1}")
+
+		op := map[string]any{
+			"operationId": operationID(method, path),
+			"tags":        []string{strings.Split(strings.TrimPrefix(path, "/"), "/")[0]},
+		}
+		if doc.Summary != "" {
+			op["summary"] = doc.Summary
+		}
+		var params []map[string]any
+		for _, m := range pathParamRe.FindAllStringSubmatch(path, -1) {
+			params = append(params, map[string]any{
+				"name": m[1], "in": "path", "required": true, "schema": map[string]any{"type": "string"},
+			})
+		}
+		for _, q := range doc.Query {
+			params = append(params, map[string]any{"name": q, "in": "query", "schema": map[string]any{"type": "string"}})
+		}
+		if params != nil {
+			op["parameters"] = params
+		}
+		switch {
+		case doc.Upload:
+			op["requestBody"] = map[string]any{"required": true, "content": map[string]any{
+				"multipart/form-data": map[string]any{"schema": map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"path": map[string]any{"type": "string"},
+						"file": map[string]any{"type": "string", "format": "binary"},
+					},
+				}},
+			}}
+		case doc.Request != nil:
+			op["requestBody"] = map[string]any{"required": true, "content": map[string]any{
+				"application/json": map[string]any{"schema": b.schema(reflect.TypeOf(doc.Request))},
+			}}
+		}
+
+		status := doc.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		success := map[string]any{"description": http.StatusText(status)}
+		switch {
+		case doc.ContentType != "":
+			success["content"] = map[string]any{doc.ContentType: map[string]any{"schema": map[string]any{"type": "string"}}}
+		case doc.Response != nil:
+			success["content"] = map[string]any{"application/json": map[string]any{"schema": b.schema(reflect.TypeOf(doc.Response))}}
+		}
+		op["responses"] = map[string]any{
+			strconv.Itoa(status): success,
+			"default": map[string]any{
+				"description": "Error",
+				"content":     map[string]any{"application/json": map[string]any{"schema": errorRef}},
+			},
+		}
+		if doc.Public {
+			op["security"] = []any{}
+		}
+
+		if paths[path] == nil {
+			paths[path] = map[string]any{}
+		}
+		paths[path][strings.ToLower(method)] = op
+		return nil
+	})
+
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":       "BLOGRON Panel API",
+			"version":     "1",
+			"description": "Served under /api/v1; /api is an alias for v1.",
+		},
+		"servers":  []map[string]any{{"url": apiVersionPrefix}},
+		"security": []map[string]any{{"bearerAuth": []string{}}},
+		"paths":    paths,
+		"components": map[string]any{
+			"schemas": b.components,
+			"securitySchemes": map[string]any{
+				"bearerAuth": map[string]any{
+					"type": "http", "scheme": "bearer",
+					"description": "Access token from /auth/login, or an API token",
+				},
+			},
+		},
+	}
+}
+
+// operationID turns "POST /vhosts/{domain}/ssl" into "postVhostsDomainSsl".
+func operationID(method, path string) string {
+	var sb strings.Builder
+	sb.WriteString(strings.ToLower(method))
+	upper := true
+	for _, c := range path {
+		if unicode.IsLetter(c) || unicode.IsDigit(c) {
+			if upper {
+				c = unicode.ToUpper(c)
+			}
+			sb.WriteRune(c)
+			upper = false
+		} else {
+			upper = true
+		}
+	}
+	return sb.String()
+}
+
+// schemaBuilder turns Go types into JSON schemas, collecting named structs
+// under components/schemas.
+type schemaBuilder struct {
+	components map[string]any
+}
+
+var (
+	timeType   = reflect.TypeOf(time.Time{})
+	statusType = reflect.TypeOf(statusResponse{})
+)
+
+func (b *schemaBuilder) schema(t reflect.Type) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch {
+	case t == timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case t == statusType:
+		if _, ok := b.components["Status"]; !ok {
+			b.components["Status"] = map[string]any{
+				"type":                 "object",
+				"properties":           map[string]any{"status": map[string]any{"type": "string"}},
+				"additionalProperties": map[string]any{"type": "string"},
+			}
+		}
+		return map[string]any{"$ref": "#/components/schemas/Status"}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string", "format": "byte"}
+		}
+		return map[string]any{"type": "array", "items": b.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": b.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return b.object(t)
+		}
+		name := componentName(t)
+		if _, ok := b.components[name]; !ok {
+			b.components[name] = map[string]any{} // placeholder for recursive types
+			b.components[name] = b.object(t)
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + name}
+	}
+	return map[string]any{}
+}
+
+// object describes a struct's JSON fields; embedded structs are flattened as
+// encoding/json does.
+func (b *schemaBuilder) object(t reflect.Type) map[string]any {
+	props := map[string]any{}
+	var walk func(t reflect.Type)
+	walk = func(t reflect.Type) {
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			tag := f.Tag.Get("json")
+			if tag == "-" {
+				continue
+			}
+			name, _, _ := strings.Cut(tag, ",")
+			if f.Anonymous && name == "" {
+				ft := f.Type
+				if ft.Kind() == reflect.Pointer {
+					ft = ft.Elem()
+				}
+				if ft.Kind() == reflect.Struct {
+					walk(ft)
+					continue
+				}
+			}
+			if !f.IsExported() {
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			props[name] = b.schema(f.Type)
+		}
+	}
+	walk(t)
+	return map[string]any{"type": "object", "properties": props}
+}
+
+// componentName is the exported form of t's name, prefixed with its package
+// outside api (e.g. StoreResource for store.Resource).
+func componentName(t reflect.Type) string {
+	name := capitalize(t.Name())
+	if pkg := path.Base(t.PkgPath()); pkg != "api" && pkg != "." && !strings.EqualFold(pkg, name) {
+		name = capitalize(pkg) + name
+	}
+	return name
+}
+
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
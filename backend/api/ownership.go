@@ -0,0 +1,158 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"blogron/middleware"
+	"blogron/store"
+	"blogron/util"
+)
+
+// Every resource a customer can own has its owner (a panel username)
+// recorded in the resource store (see resources.go). Site-owner accounts
+// only see and touch resources recorded against their own "sub" claim; all
+// other roles keep the global view.
+
+// Resource kinds tracked by the ownership registry.
+const (
+	kindVhost      = "vhost"
+	kindDatabase   = "database"
+	kindMailDomain = "mail-domain"
+	kindFTPUser    = "ftp-user"
+	kindCron       = "cron" // keyed by the system user whose crontab it is
+	kindWordPress  = "wordpress"
+)
+
+var resourceKinds = map[string]bool{
+	kindVhost: true, kindDatabase: true, kindMailDomain: true,
+	kindFTPUser: true, kindCron: true, kindWordPress: true,
+}
+
+// Ownership is one registry entry.
+type Ownership struct {
+	Kind  string `json:"kind"`
+	Name  string `json:"name"`
+	Owner string `json:"owner"`
+}
+
+// ListOwnership godoc
+// GET /api/ownership?owner=jane&kind=vhost
+func ListOwnership(w http.ResponseWriter, r *http.Request) {
+	owner := util.Sanitize(r.URL.Query().Get("owner"))
+	kind := r.URL.Query().Get("kind")
+
+	list, err := resources.List(store.Filter{Kind: kind, Owner: owner})
+	if err != nil {
+		util.WriteErr(w, util.Wrap(err, "cannot read ownership registry"))
+		return
+	}
+
+	entries := []Ownership{}
+	for _, res := range list {
+		if res.Owner != "" {
+			entries = append(entries, Ownership{Kind: res.Kind, Name: res.Name, Owner: res.Owner})
+		}
+	}
+	util.WriteJSON(w, http.StatusOK, entries)
+}
+
+// SetOwnership godoc
+// PUT /api/ownership/{kind}/{name}
+// Body: { "owner": "jane" } — an empty owner removes the entry
+func SetOwnership(w http.ResponseWriter, r *http.Request) {
+	kind := chi_urlParam(r, "kind")
+	name := chi_urlParam(r, "name")
+	if !resourceKinds[kind] {
+		util.WriteErr(w, util.Invalid("kind", "unknown resource kind"))
+		return
+	}
+	if name == "" || strings.ContainsAny(name, "/\\") {
+		util.WriteErr(w, util.Invalid("name", "invalid resource name"))
+		return
+	}
+
+	var body struct {
+		Owner string `json:"owner"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		util.WriteErr(w, util.ErrInvalidBody)
+		return
+	}
+	owner := util.Sanitize(body.Owner)
+
+	if err := setResourceOwner(kind, name, owner); err != nil {
+		util.WriteErr(w, util.Wrap(err, "failed to save ownership registry"))
+		return
+	}
+	util.WriteJSON(w, http.StatusOK, Ownership{Kind: kind, Name: name, Owner: owner})
+}
+
+// ── helpers ───────────────────────────────────────────────────────────────────
+
+// isScoped reports whether the caller is restricted to their own resources.
+func isScoped(r *http.Request) bool {
+	return middleware.Role(r) == middleware.RoleSiteOwner
+}
+
+// canAccess reports whether the caller may see or change the resource.
+func canAccess(r *http.Request, kind, name string) bool {
+	if !isScoped(r) {
+		return true
+	}
+	return resourceOwner(kind, name) == middleware.Subject(r)
+}
+
+// requireOwner writes a 403 and returns false when the caller may not touch
+// the resource.
+func requireOwner(w http.ResponseWriter, r *http.Request, kind, name string) bool {
+	if canAccess(r, kind, name) {
+		return true
+	}
+	util.WriteErr(w, errNotOwner("you do not have access to this "+kind))
+	return false
+}
+
+// ownerForCreate picks the owner of a newly created resource: site owners
+// always own what they create, other roles may assign it to anyone.
+func ownerForCreate(r *http.Request, requested string) string {
+	if isScoped(r) {
+		return middleware.Subject(r)
+	}
+	return util.Sanitize(requested)
+}
+
+// resourceOwner returns the panel username owning the resource, or "".
+func resourceOwner(kind, name string) string {
+	res, _, err := resources.Get(kind, name)
+	if err != nil {
+		return ""
+	}
+	return res.Owner
+}
+
+// setResourceOwner records owner for the resource; an empty owner clears it.
+func setResourceOwner(kind, name, owner string) error {
+	if owner == "" {
+		if res, ok, err := resources.Get(kind, name); err != nil || !ok || res.Owner == "" {
+			return err
+		}
+	}
+	return resources.Update(kind, name, func(res *store.Resource) { res.Owner = owner })
+}
+
+// ownedSiteDir reports whether the first path component below
+// fileManagerRoot is a vhost or WordPress site the caller owns.
+func ownedSiteDir(r *http.Request, absPath string) bool {
+	if !isScoped(r) {
+		return true
+	}
+	rel, err := filepath.Rel(fileManagerRoot, absPath)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return false
+	}
+	site := strings.SplitN(rel, string(filepath.Separator), 2)[0]
+	return canAccess(r, kindVhost, site) || canAccess(r, kindWordPress, site)
+}
@@ -0,0 +1,373 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"blogron/middleware"
+	"blogron/util"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Panel accounts are stored in <data dir>/panel-users.json. On first start
+// the store is seeded with auth.admin_user / auth.admin_password from
+// panel.yaml (or ADMIN_USER / ADMIN_PASSWORD) so existing installs keep working.
+const panelUsersFile = "panel-users.json"
+
+// PanelUser is the public view of a panel account (never includes the hash).
+type PanelUser struct {
+	Username    string    `json:"username"`
+	Role        string    `json:"role"`
+	Disabled    bool      `json:"disabled"`
+	TOTPEnabled bool      `json:"totp_enabled"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// panelAccount is the persisted form of a panel account.
+type panelAccount struct {
+	PanelUser
+	PasswordHash string `json:"password_hash"`
+
+	// Two-factor authentication (see totp.go)
+	TOTPSecret    string   `json:"totp_secret,omitempty"`
+	TOTPPending   string   `json:"totp_pending,omitempty"`
+	TOTPLastStep  int64    `json:"totp_last_step,omitempty"`
+	RecoveryCodes []string `json:"recovery_codes,omitempty"` // bcrypt hashes
+}
+
+type panelUserRequest struct {
+	Username  string `json:"username"`
+	Password  string `json:"password"`
+	Role      string `json:"role"`
+	Disabled  *bool  `json:"disabled"`
+	ResetTOTP bool   `json:"reset_totp"`
+}
+
+var panelUsers struct {
+	sync.Mutex
+	loaded   bool
+	accounts map[string]*panelAccount
+}
+
+// ListPanelUsers godoc
+// GET /api/panel-users
+func ListPanelUsers(w http.ResponseWriter, r *http.Request) {
+	panelUsers.Lock()
+	defer panelUsers.Unlock()
+	if err := loadPanelUsersLocked(); err != nil {
+		util.WriteErr(w, util.Wrap(err, "cannot read panel accounts"))
+		return
+	}
+
+	users := make([]PanelUser, 0, len(panelUsers.accounts))
+	for _, acc := range panelUsers.accounts {
+		users = append(users, acc.PanelUser)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].Username < users[j].Username })
+	util.WriteJSON(w, http.StatusOK, users)
+}
+
+// CreatePanelUser godoc
+// POST /api/panel-users
+// Body: { "username": "jane", "password": "...", "role": "operator" }
+func CreatePanelUser(w http.ResponseWriter, r *http.Request) {
+	var req panelUserRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		util.WriteErr(w, util.ErrInvalidBody)
+		return
+	}
+
+	username := util.Sanitize(req.Username)
+	if username == "" || username != req.Username || len(username) > 64 {
+		util.WriteErr(w, util.Invalid("username", "invalid username"))
+		return
+	}
+	if !middleware.ValidRole(req.Role) {
+		util.WriteErr(w, util.Invalid("role", "role must be admin, operator, read-only or site-owner"))
+		return
+	}
+	if len(req.Password) < 12 {
+		util.WriteErr(w, util.Invalid("password", "password must be at least 12 characters"))
+		return
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, "could not hash password")
+		return
+	}
+
+	panelUsers.Lock()
+	defer panelUsers.Unlock()
+	if err := loadPanelUsersLocked(); err != nil {
+		util.WriteErr(w, util.Wrap(err, "cannot read panel accounts"))
+		return
+	}
+	if _, exists := panelUsers.accounts[username]; exists {
+		util.WriteErr(w, errPanelUserExists)
+		return
+	}
+
+	now := time.Now().UTC()
+	acc := &panelAccount{
+		PanelUser: PanelUser{
+			Username:  username,
+			Role:      req.Role,
+			Disabled:  req.Disabled != nil && *req.Disabled,
+			CreatedAt: now,
+			UpdatedAt: now,
+		},
+		PasswordHash: string(hash),
+	}
+	panelUsers.accounts[username] = acc
+	if err := savePanelUsersLocked(); err != nil {
+		delete(panelUsers.accounts, username)
+		util.WriteErr(w, util.Wrap(err, "failed to save panel accounts"))
+		return
+	}
+	util.WriteJSON(w, http.StatusCreated, acc.PanelUser)
+}
+
+// UpdatePanelUser godoc
+// PUT /api/panel-users/{username}
+// Body: { "password": "...", "role": "read-only", "disabled": true, "reset_totp": true } — all optional
+func UpdatePanelUser(w http.ResponseWriter, r *http.Request) {
+	username := util.Sanitize(chi_urlParam(r, "username"))
+	var req panelUserRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		util.WriteErr(w, util.ErrInvalidBody)
+		return
+	}
+	if req.Role != "" && !middleware.ValidRole(req.Role) {
+		util.WriteErr(w, util.Invalid("role", "role must be admin, operator, read-only or site-owner"))
+		return
+	}
+	var newHash []byte
+	if req.Password != "" {
+		if len(req.Password) < 12 {
+			util.WriteErr(w, util.Invalid("password", "password must be at least 12 characters"))
+			return
+		}
+		var err error
+		if newHash, err = bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost); err != nil {
+			util.WriteError(w, http.StatusInternalServerError, "could not hash password")
+			return
+		}
+	}
+
+	panelUsers.Lock()
+	defer panelUsers.Unlock()
+	if err := loadPanelUsersLocked(); err != nil {
+		util.WriteErr(w, util.Wrap(err, "cannot read panel accounts"))
+		return
+	}
+	acc, ok := panelUsers.accounts[username]
+	if !ok {
+		util.WriteErr(w, errPanelUserNotFound)
+		return
+	}
+
+	prev := *acc
+	if req.Role != "" {
+		acc.Role = req.Role
+	}
+	if req.Disabled != nil {
+		acc.Disabled = *req.Disabled
+	}
+	if newHash != nil {
+		acc.PasswordHash = string(newHash)
+	}
+	if req.ResetTOTP {
+		clearTOTP(acc)
+	}
+	if countActiveAdminsLocked() == 0 {
+		*acc = prev
+		util.WriteErr(w, errLastAdmin)
+		return
+	}
+	acc.UpdatedAt = time.Now().UTC()
+	if err := savePanelUsersLocked(); err != nil {
+		*acc = prev
+		util.WriteErr(w, util.Wrap(err, "failed to save panel accounts"))
+		return
+	}
+	// Tokens carry the role, so any security-relevant change logs the account out everywhere.
+	if acc.Role != prev.Role || acc.Disabled || newHash != nil || req.ResetTOTP {
+		revokeUserSessions(username)
+	}
+	util.WriteJSON(w, http.StatusOK, acc.PanelUser)
+}
+
+// DeletePanelUser godoc
+// DELETE /api/panel-users/{username}
+func DeletePanelUser(w http.ResponseWriter, r *http.Request) {
+	username := util.Sanitize(chi_urlParam(r, "username"))
+	if username == middleware.Subject(r) {
+		util.WriteError(w, http.StatusBadRequest, "cannot delete your own account")
+		return
+	}
+
+	panelUsers.Lock()
+	defer panelUsers.Unlock()
+	if err := loadPanelUsersLocked(); err != nil {
+		util.WriteErr(w, util.Wrap(err, "cannot read panel accounts"))
+		return
+	}
+	acc, ok := panelUsers.accounts[username]
+	if !ok {
+		util.WriteErr(w, errPanelUserNotFound)
+		return
+	}
+
+	delete(panelUsers.accounts, username)
+	if countActiveAdminsLocked() == 0 {
+		panelUsers.accounts[username] = acc
+		util.WriteErr(w, errLastAdmin)
+		return
+	}
+	if err := savePanelUsersLocked(); err != nil {
+		panelUsers.accounts[username] = acc
+		util.WriteErr(w, util.Wrap(err, "failed to save panel accounts"))
+		return
+	}
+	revokeUserSessions(username)
+	deleteUserAPITokens(username)
+	util.WriteJSON(w, http.StatusOK, map[string]string{"status": "deleted", "username": username})
+}
+
+// ── helpers ───────────────────────────────────────────────────────────────────
+
+// errInvalidCredentials is returned by authenticatePanelUser for unknown or
+// disabled accounts and wrong passwords alike.
+var errInvalidCredentials = util.NewError(http.StatusUnauthorized, util.CodeInvalidCredentials, "invalid credentials")
+
+// dummyHash is compared against when the username does not exist so that
+// response timing does not reveal which usernames are valid.
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("blogron-timing-equaliser"), bcrypt.DefaultCost)
+
+// authenticatePanelUser checks a username/password pair against the store.
+func authenticatePanelUser(username, password string) (PanelUser, error) {
+	panelUsers.Lock()
+	if err := loadPanelUsersLocked(); err != nil {
+		panelUsers.Unlock()
+		return PanelUser{}, err
+	}
+	acc, ok := panelUsers.accounts[username]
+	var hash []byte
+	var user PanelUser
+	if ok {
+		hash = []byte(acc.PasswordHash)
+		user = acc.PanelUser
+	}
+	panelUsers.Unlock()
+
+	if !ok {
+		bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		return PanelUser{}, errInvalidCredentials
+	}
+	if err := bcrypt.CompareHashAndPassword(hash, []byte(password)); err != nil || user.Disabled {
+		return PanelUser{}, errInvalidCredentials
+	}
+	return user, nil
+}
+
+// getPanelAccount returns a copy of the stored account.
+func getPanelAccount(username string) (panelAccount, bool) {
+	panelUsers.Lock()
+	defer panelUsers.Unlock()
+	if err := loadPanelUsersLocked(); err != nil {
+		return panelAccount{}, false
+	}
+	acc, ok := panelUsers.accounts[username]
+	if !ok {
+		return panelAccount{}, false
+	}
+	return *acc, true
+}
+
+// updatePanelAccount applies fn to the stored account and persists the
+// result. Nothing is saved if fn returns an error.
+func updatePanelAccount(username string, fn func(acc *panelAccount) error) error {
+	panelUsers.Lock()
+	defer panelUsers.Unlock()
+	if err := loadPanelUsersLocked(); err != nil {
+		return err
+	}
+	acc, ok := panelUsers.accounts[username]
+	if !ok {
+		return errInvalidCredentials
+	}
+	prev := *acc
+	if err := fn(acc); err != nil {
+		*acc = prev
+		return err
+	}
+	acc.UpdatedAt = time.Now().UTC()
+	if err := savePanelUsersLocked(); err != nil {
+		*acc = prev
+		return err
+	}
+	return nil
+}
+
+// loadPanelUsersLocked reads the account store once, seeding it with the
+// installer-provided admin account when empty. Caller holds panelUsers.
+func loadPanelUsersLocked() error {
+	if panelUsers.loaded {
+		return nil
+	}
+	accounts := map[string]*panelAccount{}
+	if err := util.ReadState(panelUsersFile, &accounts); err != nil {
+		return err
+	}
+	panelUsers.accounts = accounts
+	panelUsers.loaded = true
+
+	if len(accounts) > 0 {
+		return nil
+	}
+	username := settings.Auth.AdminUser
+	pass := settings.Auth.AdminPassword
+	if pass == "" {
+		return errors.New("no panel accounts yet: set auth.admin_password (or ADMIN_PASSWORD) for the first admin")
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(pass), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	accounts[username] = &panelAccount{
+		PanelUser: PanelUser{
+			Username:  username,
+			Role:      middleware.RoleAdmin,
+			CreatedAt: now,
+			UpdatedAt: now,
+		},
+		PasswordHash: string(hash),
+	}
+	if err := savePanelUsersLocked(); err != nil {
+		// Keep the seeded account in memory so the panel stays usable.
+		log.Printf("warning: could not persist panel accounts: %v", err)
+	}
+	return nil
+}
+
+func savePanelUsersLocked() error {
+	return util.WriteState(panelUsersFile, panelUsers.accounts)
+}
+
+func countActiveAdminsLocked() int {
+	n := 0
+	for _, acc := range panelUsers.accounts {
+		if acc.Role == middleware.RoleAdmin && !acc.Disabled {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,189 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"blogron/util"
+)
+
+// Provisioning handlers (CreateWPSite, CreateVhost, CreateMailbox,
+// CreateFTPUser) run as a sequence of named steps. Each step may register an
+// undo; when a step fails, the steps before it are undone newest first and
+// the caller gets a provisionError naming the failed step.
+//
+// A failing step must clean up after itself — only completed steps are
+// undone — and an undo must never remove what existed before the step ran.
+
+// rollbackTimeout bounds the whole rollback. It runs even when the request
+// or job was cancelled.
+const rollbackTimeout = 2 * time.Minute
+
+type undoFunc func(ctx context.Context) error
+
+type provision struct {
+	ctx    context.Context
+	onStep func(name string) // called before each step, e.g. jobRun.Step
+	logf   func(format string, args ...any)
+	done   []provisionStep
+}
+
+type provisionStep struct {
+	name string
+	undo undoFunc
+}
+
+// provisionError reports which step failed and how the rollback went.
+type provisionError struct {
+	Step           string
+	Err            error
+	RolledBack     []string
+	RollbackFailed map[string]string // step -> undo error
+}
+
+func (e *provisionError) Error() string {
+	msg := fmt.Sprintf("step %q failed: %v", e.Step, e.Err)
+	if len(e.RolledBack) > 0 {
+		msg += "; rolled back: " + strings.Join(e.RolledBack, ", ")
+	}
+	if len(e.RollbackFailed) > 0 {
+		failed := make([]string, 0, len(e.RollbackFailed))
+		for step := range e.RollbackFailed {
+			failed = append(failed, step)
+		}
+		sort.Strings(failed)
+		msg += "; rollback failed for: " + strings.Join(failed, ", ")
+	}
+	return msg
+}
+
+func (e *provisionError) Unwrap() error { return e.Err }
+
+func newProvision(ctx context.Context) *provision {
+	return &provision{ctx: ctx, onStep: func(string) {}, logf: log.Printf}
+}
+
+// forJob reports the steps and rollback of p as progress of j.
+func (p *provision) forJob(j *jobRun) *provision {
+	p.onStep = j.Step
+	p.logf = func(format string, args ...any) { j.Log(fmt.Sprintf(format, args...)) }
+	return p
+}
+
+// Step runs do as the named step. If it succeeds, undo (which may be nil) is
+// kept for a later rollback; if it fails, everything done so far is rolled
+// back and a *provisionError is returned.
+func (p *provision) Step(name string, do func(ctx context.Context) error, undo undoFunc) error {
+	return p.StepUndo(name, func(ctx context.Context) (undoFunc, error) {
+		return undo, do(ctx)
+	})
+}
+
+// StepUndo is Step for steps whose undo depends on what they found, e.g. a
+// config file to restore; do returns it.
+func (p *provision) StepUndo(name string, do func(ctx context.Context) (undoFunc, error)) error {
+	p.onStep(name)
+	undo, err := do(p.ctx)
+	if err != nil {
+		return p.rollback(name, err)
+	}
+	p.done = append(p.done, provisionStep{name: name, undo: undo})
+	return nil
+}
+
+func (p *provision) rollback(failed string, cause error) error {
+	perr := &provisionError{Step: failed, Err: cause}
+	// Keep the context's values (command log, job output) but not its
+	// cancellation: a cancelled job still has to clean up.
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(p.ctx), rollbackTimeout)
+	defer cancel()
+	for i := len(p.done) - 1; i >= 0; i-- {
+		step := p.done[i]
+		if step.undo == nil {
+			continue
+		}
+		if err := step.undo(ctx); err != nil {
+			p.logf("rollback of %q failed: %v", step.name, err)
+			if perr.RollbackFailed == nil {
+				perr.RollbackFailed = map[string]string{}
+			}
+			perr.RollbackFailed[step.name] = err.Error()
+			continue
+		}
+		p.logf("rolled back %q", step.name)
+		perr.RolledBack = append(perr.RolledBack, step.name)
+	}
+	p.done = nil
+	return perr
+}
+
+// writeProvisionError answers with the failed step and the rollback outcome,
+// under the status and code of the step's error.
+func writeProvisionError(w http.ResponseWriter, err error) {
+	var perr *provisionError
+	if !errors.As(err, &perr) {
+		util.WriteErr(w, err)
+		return
+	}
+	cause := util.ErrorFrom(perr.Err)
+	e := *cause
+	e.Message = perr.Error()
+	e.Details = map[string]any{"step": perr.Step, "rolled_back": perr.RolledBack}
+	for k, v := range cause.Details {
+		e.Details[k] = v
+	}
+	if perr.RolledBack == nil {
+		e.Details["rolled_back"] = []string{}
+	}
+	if len(perr.RollbackFailed) > 0 {
+		e.Details["rollback_failed"] = perr.RollbackFailed
+	}
+	util.WriteErr(w, &e)
+}
+
+// ── helpers ───────────────────────────────────────────────────────────────────
+
+// cleanUp runs undo when err is set, for a failing step to remove what it
+// had done so far. It returns err.
+func cleanUp(ctx context.Context, undo undoFunc, err error) error {
+	if err != nil && undo != nil {
+		undo(context.WithoutCancel(ctx))
+	}
+	return err
+}
+
+// missingRoot returns the topmost directory of path below floor that does
+// not exist yet — what "mkdir -p path" creates there and an undo may remove
+// — or "" when path already exists or is not below floor. The floor keeps a
+// rollback from removing a shared parent another request may be using.
+func missingRoot(path, floor string) string {
+	path, floor = filepath.Clean(path), filepath.Clean(floor)
+	root := ""
+	for p := path; strings.HasPrefix(p, floor+"/"); p = filepath.Dir(p) {
+		if _, err := os.Stat(p); err == nil {
+			break
+		}
+		root = p
+	}
+	return root
+}
+
+// removeCreatedDir undoes "mkdir -p" of a directory whose missingRoot was
+// root; it does nothing when the directory already existed.
+func removeCreatedDir(root string) undoFunc {
+	if root == "" {
+		return nil
+	}
+	return func(ctx context.Context) error {
+		_, err := runCmd(ctx, "rm", "-rf", root)
+		return err
+	}
+}
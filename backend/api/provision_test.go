@@ -0,0 +1,221 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"blogron/util"
+)
+
+// fakeProvision runs p's commands on a fake executor and keeps its log quiet.
+func fakeProvision(t *testing.T, ctx context.Context) (*provision, *util.FakeExecutor) {
+	t.Helper()
+	prev := executor
+	t.Cleanup(func() { executor = prev })
+	fake := &util.FakeExecutor{}
+	SetExecutor(fake)
+	p := newProvision(ctx)
+	p.logf = t.Logf
+	return p, fake
+}
+
+// rmStep is a step that creates dir and whose undo removes it.
+func rmStep(p *provision, dir string) error {
+	return p.Step("create "+dir, func(ctx context.Context) error {
+		_, err := runCmd(ctx, "mkdir", "-p", dir)
+		return err
+	}, func(ctx context.Context) error {
+		_, err := runCmd(ctx, "rm", "-rf", dir)
+		return err
+	})
+}
+
+func nginxTestStep(p *provision) error {
+	return p.Step("test config", func(ctx context.Context) error {
+		_, err := runCmd(ctx, "nginx", "-t")
+		return err
+	}, nil)
+}
+
+func TestProvisionUndoesInReverseOrder(t *testing.T) {
+	p, fake := fakeProvision(t, context.Background())
+	fake.On("nginx -t", util.Result{Stderr: "nginx: [emerg] bad", ExitCode: 1}, errors.New("exit status 1"))
+	var started []string
+	p.onStep = func(name string) { started = append(started, name) }
+
+	for _, dir := range []string{"/srv/a", "/srv/b"} {
+		if err := rmStep(p, dir); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := p.Step("no undo", func(context.Context) error { return nil }, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := rmStep(p, "/srv/c"); err != nil {
+		t.Fatal(err)
+	}
+	err := nginxTestStep(p)
+
+	var perr *provisionError
+	if !errors.As(err, &perr) {
+		t.Fatalf("err = %v, want a *provisionError", err)
+	}
+	var cmdErr *util.CmdError
+	if !errors.As(err, &cmdErr) {
+		t.Errorf("cause not kept: %v", err)
+	}
+	if perr.Step != "test config" {
+		t.Errorf("failed step = %q", perr.Step)
+	}
+	if want := []string{"create /srv/c", "create /srv/b", "create /srv/a"}; !reflect.DeepEqual(perr.RolledBack, want) {
+		t.Errorf("rolled back = %q, want %q", perr.RolledBack, want)
+	}
+	if want := []string{"create /srv/a", "create /srv/b", "no undo", "create /srv/c", "test config"}; !reflect.DeepEqual(started, want) {
+		t.Errorf("steps started = %q, want %q", started, want)
+	}
+
+	want := []string{
+		"mkdir -p /srv/a", "mkdir -p /srv/b", "mkdir -p /srv/c", "nginx -t",
+		"rm -rf /srv/c", "rm -rf /srv/b", "rm -rf /srv/a",
+	}
+	if got := commandLines(fake); !reflect.DeepEqual(got, want) {
+		t.Errorf("commands = %q, want %q", got, want)
+	}
+	if len(p.done) != 0 {
+		t.Errorf("%d steps left to undo after the rollback", len(p.done))
+	}
+}
+
+func TestProvisionRollsBackAfterCancel(t *testing.T) {
+	type key struct{}
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), key{}, "job output"))
+	defer cancel()
+	p, fake := fakeProvision(t, ctx)
+
+	var undoErr error
+	var undoValue any
+	var undoDeadline bool
+	p.Step("create", func(context.Context) error { return nil }, func(ctx context.Context) error {
+		undoErr, undoValue = ctx.Err(), ctx.Value(key{})
+		_, undoDeadline = ctx.Deadline()
+		_, err := runCmd(ctx, "rm", "-rf", "/srv/a")
+		return err
+	})
+	// The job is cancelled while a step runs.
+	err := p.Step("wait", func(ctx context.Context) error {
+		cancel()
+		return ctx.Err()
+	}, nil)
+
+	var perr *provisionError
+	if !errors.As(err, &perr) || !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want a cancelled *provisionError", err)
+	}
+	if !reflect.DeepEqual(perr.RolledBack, []string{"create"}) {
+		t.Errorf("rolled back = %q, want [create]", perr.RolledBack)
+	}
+	if undoErr != nil {
+		t.Errorf("undo ran with a done context: %v", undoErr)
+	}
+	if undoValue != "job output" {
+		t.Errorf("undo lost the job's context values: %v", undoValue)
+	}
+	if !undoDeadline {
+		t.Error("rollback has no timeout")
+	}
+	if !hasCommand(commandLines(fake), "rm -rf /srv/a") {
+		t.Errorf("undo command not run; commands: %q", commandLines(fake))
+	}
+}
+
+func TestProvisionRollbackFailure(t *testing.T) {
+	p, fake := fakeProvision(t, context.Background())
+	fake.On("rm -rf /srv/b", util.Result{Stderr: "rm: cannot remove '/srv/b': Device or resource busy", ExitCode: 1}, errors.New("exit status 1"))
+	fake.On("nginx -t", util.Result{Stderr: "nginx: [emerg] bad", ExitCode: 1}, errors.New("exit status 1"))
+
+	rmStep(p, "/srv/a")
+	rmStep(p, "/srv/b")
+	err := nginxTestStep(p)
+
+	var perr *provisionError
+	if !errors.As(err, &perr) {
+		t.Fatalf("err = %v, want a *provisionError", err)
+	}
+	// The failing undo does not stop the ones before it.
+	if !reflect.DeepEqual(perr.RolledBack, []string{"create /srv/a"}) {
+		t.Errorf("rolled back = %q, want [create /srv/a]", perr.RolledBack)
+	}
+	if msg := perr.RollbackFailed["create /srv/b"]; !strings.Contains(msg, "Device or resource busy") {
+		t.Errorf("rollback failures = %q", perr.RollbackFailed)
+	}
+	if !strings.Contains(err.Error(), "rollback failed for: create /srv/b") {
+		t.Errorf("message = %q", err)
+	}
+	if !hasCommand(commandLines(fake), "rm -rf /srv/a") {
+		t.Errorf("earlier undo skipped; commands: %q", commandLines(fake))
+	}
+}
+
+func TestWriteProvisionError(t *testing.T) {
+	p, fake := fakeProvision(t, context.Background())
+	fake.On("rm -rf /srv/b", util.Result{Stderr: "rm: busy", ExitCode: 1}, errors.New("exit status 1"))
+	fake.On("nginx -t", util.Result{Stderr: "nginx: [emerg] unknown directive", ExitCode: 1}, errors.New("exit status 1"))
+	rmStep(p, "/srv/a")
+	rmStep(p, "/srv/b")
+
+	rec := httptest.NewRecorder()
+	writeProvisionError(rec, nginxTestStep(p))
+
+	// The status and code are the failed step's.
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("status = %d, want 422; body: %s", rec.Code, rec.Body)
+	}
+	var body struct {
+		Code           string            `json:"code"`
+		Error          string            `json:"error"`
+		Step           string            `json:"step"`
+		RolledBack     []string          `json:"rolled_back"`
+		RollbackFailed map[string]string `json:"rollback_failed"`
+		Stderr         string            `json:"stderr"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Code != util.CodeNginxConfigInvalid {
+		t.Errorf("code = %q, want %q", body.Code, util.CodeNginxConfigInvalid)
+	}
+	if body.Step != "test config" {
+		t.Errorf("step = %q", body.Step)
+	}
+	if !reflect.DeepEqual(body.RolledBack, []string{"create /srv/a"}) {
+		t.Errorf("rolled_back = %q", body.RolledBack)
+	}
+	if _, ok := body.RollbackFailed["create /srv/b"]; !ok {
+		t.Errorf("rollback_failed = %q", body.RollbackFailed)
+	}
+	if !strings.Contains(body.Error, `step "test config" failed`) {
+		t.Errorf("error = %q", body.Error)
+	}
+
+	// Nothing to roll back still gives an empty list, not null.
+	rec = httptest.NewRecorder()
+	p, fake = fakeProvision(t, context.Background())
+	fake.On("nginx -t", util.Result{ExitCode: 1}, errors.New("exit status 1"))
+	writeProvisionError(rec, nginxTestStep(p))
+	if !strings.Contains(rec.Body.String(), `"rolled_back":[]`) || strings.Contains(rec.Body.String(), "rollback_failed") {
+		t.Errorf("body = %s", rec.Body)
+	}
+
+	// Errors from outside a provision are written as they are.
+	rec = httptest.NewRecorder()
+	writeProvisionError(rec, errVhostExists)
+	if rec.Code != http.StatusConflict || strings.Contains(rec.Body.String(), "step") {
+		t.Errorf("status = %d; body: %s", rec.Code, rec.Body)
+	}
+}
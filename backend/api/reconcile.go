@@ -0,0 +1,213 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"blogron/store"
+)
+
+// ReconcileReport lists what Reconcile changed, as "kind/name" entries.
+type ReconcileReport struct {
+	Added   []string          `json:"added"`
+	Updated []string          `json:"updated"`
+	Removed []string          `json:"removed"`
+	Skipped map[string]string `json:"skipped,omitempty"` // kind -> why it was not reconciled
+}
+
+// discovered is what the server itself says about one resource; it only
+// fills in fields that are still empty in the store.
+type discovered struct {
+	php  string
+	meta map[string]string
+}
+
+// Reconcile brings the resource store in line with the server: resources
+// found on disk (nginx vhosts, WordPress sites, databases, mail domains, FTP
+// users, crontabs) but not recorded are added, empty PHP versions and
+// metadata are filled in, and records whose resource is gone are removed.
+// Owners and notes are never touched. A kind whose source cannot be read is
+// skipped rather than emptied. With dryRun the store is left unchanged.
+func Reconcile(ctx context.Context, dryRun bool) (ReconcileReport, error) {
+	report := ReconcileReport{Added: []string{}, Updated: []string{}, Removed: []string{}}
+	for _, kind := range []string{kindVhost, kindWordPress, kindDatabase, kindMailDomain, kindFTPUser, kindCron} {
+		found, err := discover(ctx, kind)
+		if err != nil {
+			if report.Skipped == nil {
+				report.Skipped = map[string]string{}
+			}
+			report.Skipped[kind] = err.Error()
+			continue
+		}
+		recorded, err := resources.List(store.Filter{Kind: kind})
+		if err != nil {
+			return report, err
+		}
+		known := map[string]store.Resource{}
+		for _, res := range recorded {
+			known[res.Name] = res
+		}
+
+		names := make([]string, 0, len(found))
+		for name := range found {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			d := found[name]
+			res, ok := known[name]
+			if ok && !d.fills(res) {
+				continue
+			}
+			if ok {
+				report.Updated = append(report.Updated, kind+"/"+name)
+			} else {
+				report.Added = append(report.Added, kind+"/"+name)
+			}
+			if dryRun {
+				continue
+			}
+			if err := resources.Update(kind, name, d.apply); err != nil {
+				return report, err
+			}
+		}
+
+		for _, res := range recorded {
+			if _, ok := found[res.Name]; ok {
+				continue
+			}
+			report.Removed = append(report.Removed, kind+"/"+res.Name)
+			if dryRun {
+				continue
+			}
+			if err := resources.Delete(kind, res.Name); err != nil {
+				return report, err
+			}
+		}
+	}
+	return report, nil
+}
+
+// ── helpers ───────────────────────────────────────────────────────────────────
+
+func (d discovered) fills(res store.Resource) bool {
+	if d.php != "" && res.PHPVersion == "" {
+		return true
+	}
+	for k, v := range d.meta {
+		if v != "" && res.Meta[k] == "" {
+			return true
+		}
+	}
+	return false
+}
+
+func (d discovered) apply(res *store.Resource) {
+	if res.PHPVersion == "" {
+		res.PHPVersion = d.php
+	}
+	for k, v := range d.meta {
+		if v != "" && res.Meta[k] == "" {
+			setMeta(res, k, v)
+		}
+	}
+}
+
+// discover lists the resources of kind that exist on this server.
+func discover(ctx context.Context, kind string) (map[string]discovered, error) {
+	found := map[string]discovered{}
+	switch kind {
+	case kindVhost:
+		entries, err := os.ReadDir(nginxSitesAvailable)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			if e.IsDir() || !strings.HasSuffix(e.Name(), ".conf") {
+				continue
+			}
+			vh := parseVhostConf(strings.TrimSuffix(e.Name(), ".conf"))
+			found[vh.Domain] = discovered{php: vh.PHP, meta: map[string]string{"docroot": vh.DocRoot}}
+		}
+
+	case kindWordPress:
+		entries, err := os.ReadDir(wpRoot)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			if !e.IsDir() {
+				continue
+			}
+			docroot := resolveWPDocroot(e.Name())
+			if docroot == "" {
+				continue
+			}
+			site := probeWPSite(e.Name(), filepath.Join(docroot, "wp-config.php"))
+			found[site.Domain] = discovered{
+				php: parseVhostConf(site.Domain).PHP,
+				// Read from wp-config.php, which the site can edit: shown, but
+				// never trusted for DeleteWPSite's delete_db.
+				meta: map[string]string{"docroot": docroot, "db_name": site.DBName, "db_user": site.DBUser, "db_source": dbSourceWPConfig},
+			}
+		}
+
+	case kindDatabase:
+		out, err := mysqlQuery(ctx, "", "SHOW DATABASES;")
+		if err != nil {
+			return nil, err
+		}
+		if !strings.Contains(out, "information_schema") {
+			// Always listed; anything else is not a real answer (dry run).
+			return nil, fmt.Errorf("unexpected SHOW DATABASES output")
+		}
+		wpByDB := map[string]string{}
+		if sites, err := discover(ctx, kindWordPress); err == nil {
+			for domain, d := range sites {
+				wpByDB[d.meta["db_name"]] = domain
+			}
+		}
+		for _, line := range strings.Split(out, "\n") {
+			name := strings.TrimSpace(line)
+			if name == "" || systemDatabases[name] {
+				continue
+			}
+			found[name] = discovered{meta: map[string]string{"wordpress": wpByDB[name]}}
+		}
+
+	case kindMailDomain:
+		if _, err := os.Stat(postfixVirtualDomainsFile); err != nil {
+			return nil, err
+		}
+		for _, d := range readMailDomains() {
+			found[d.Domain] = discovered{}
+		}
+
+	case kindFTPUser:
+		if _, err := os.Stat(vsftpdUserListFile); err != nil {
+			return nil, err
+		}
+		for _, u := range readFTPUsers() {
+			found[u.Username] = discovered{meta: map[string]string{"home_dir": u.HomeDir}}
+		}
+
+	case kindCron:
+		entries, err := os.ReadDir(cronDir)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			if !e.IsDir() {
+				found[e.Name()] = discovered{}
+			}
+		}
+
+	default:
+		return nil, fmt.Errorf("unknown resource kind %q", kind)
+	}
+	return found, nil
+}
@@ -0,0 +1,117 @@
+package api
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"blogron/store"
+)
+
+func TestReconcile(t *testing.T) {
+	setupVhostTest(t) // the fake executor answers SHOW DATABASES with nothing
+	dir := t.TempDir()
+	wpRoot = filepath.Join(dir, "missing-wordpress")
+	postfixVirtualDomainsFile = filepath.Join(dir, "missing-virtual-domains")
+	vsftpdUserListFile = filepath.Join(dir, "missing-user-list")
+	cronDir = filepath.Join(dir, "crontabs")
+
+	writeFile := func(path, data string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	vhostConf := func(domain string) string {
+		return "server {\n    listen 80;\n    server_name " + domain + ";\n    root /var/www/" + domain + ";\n" +
+			"    location ~ \\.php$ {\n        fastcgi_pass unix:/run/php/php8.2-fpm.sock;\n    }\n}\n"
+	}
+	writeFile(filepath.Join(nginxSitesAvailable, "new.com.conf"), vhostConf("new.com"))
+	writeFile(filepath.Join(nginxSitesAvailable, "known.com.conf"), vhostConf("known.com"))
+	writeFile(filepath.Join(nginxSitesAvailable, "full.com.conf"), vhostConf("full.com"))
+	writeFile(filepath.Join(cronDir, "jane"), "0 * * * * /bin/true\n")
+
+	// known.com lacks its docroot and PHP version; full.com has them (one
+	// of them different from the config, which is kept); gone.com has no
+	// config any more.
+	resources.Update(kindVhost, "known.com", func(r *store.Resource) {
+		r.Owner = "jane"
+		r.Notes = "keep me"
+	})
+	resources.Update(kindVhost, "full.com", func(r *store.Resource) {
+		r.PHPVersion = "8.1"
+		setMeta(r, "docroot", "/srv/full")
+	})
+	resources.Update(kindVhost, "gone.com", func(r *store.Resource) { r.Owner = "bob" })
+
+	want := ReconcileReport{
+		Added:   []string{"vhost/new.com", "cron/jane"},
+		Updated: []string{"vhost/known.com"},
+		Removed: []string{"vhost/gone.com"},
+	}
+	wantSkipped := []string{kindWordPress, kindDatabase, kindMailDomain, kindFTPUser}
+	check := func(report ReconcileReport) {
+		t.Helper()
+		if !slices.Equal(report.Added, want.Added) || !slices.Equal(report.Updated, want.Updated) || !slices.Equal(report.Removed, want.Removed) {
+			t.Errorf("report = %+v, want %+v", report, want)
+		}
+		for _, kind := range wantSkipped {
+			if report.Skipped[kind] == "" {
+				t.Errorf("%s not skipped: %v", kind, report.Skipped)
+			}
+		}
+		if len(report.Skipped) != len(wantSkipped) {
+			t.Errorf("skipped = %v, want %v", report.Skipped, wantSkipped)
+		}
+	}
+
+	before, _ := resources.List(store.Filter{})
+	report, err := Reconcile(context.Background(), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(report)
+	after, _ := resources.List(store.Filter{})
+	if len(after) != len(before) {
+		t.Fatalf("dry run changed the store: %d resources before, %d after", len(before), len(after))
+	}
+	if r, _, _ := resources.Get(kindVhost, "known.com"); r.PHPVersion != "" {
+		t.Errorf("dry run filled in known.com: %+v", r)
+	}
+
+	report, err = Reconcile(context.Background(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(report)
+
+	if r, ok, _ := resources.Get(kindVhost, "new.com"); !ok || r.PHPVersion != "8.2" || r.Meta["docroot"] != "/var/www/new.com" {
+		t.Errorf("new.com = %+v, %v", r, ok)
+	}
+	if r, _, _ := resources.Get(kindVhost, "known.com"); r.PHPVersion != "8.2" || r.Meta["docroot"] != "/var/www/known.com" || r.Owner != "jane" || r.Notes != "keep me" {
+		t.Errorf("known.com = %+v", r)
+	}
+	if r, _, _ := resources.Get(kindVhost, "full.com"); r.PHPVersion != "8.1" || r.Meta["docroot"] != "/srv/full" {
+		t.Errorf("full.com overwritten: %+v", r)
+	}
+	if _, ok, _ := resources.Get(kindVhost, "gone.com"); ok {
+		t.Error("gone.com not removed")
+	}
+	if _, ok, _ := resources.Get(kindCron, "jane"); !ok {
+		t.Error("crontab of jane not added")
+	}
+
+	// Once in line, there is nothing left to do.
+	report, err = Reconcile(context.Background(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Added)+len(report.Updated)+len(report.Removed) != 0 {
+		t.Errorf("second run changed %+v", report)
+	}
+}
@@ -0,0 +1,142 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"blogron/middleware"
+	"blogron/store"
+	"blogron/util"
+)
+
+// resources holds what the OS does not: owners, creation dates, notes, PHP
+// versions and which database belongs to which WordPress site. main swaps
+// in the store opened at startup.
+var resources store.Store = store.OpenFile()
+
+// legacyOwnershipFile is where owners were kept before the resource store.
+const legacyOwnershipFile = "ownership.json"
+
+// SetStore replaces the resource store and moves owners recorded in the old
+// ownership.json into it.
+func SetStore(s store.Store) error {
+	resources = s
+	return importLegacyOwnership()
+}
+
+// ListResources godoc
+// GET /api/resources?kind=wordpress&owner=jane
+// Site owners only see their own resources.
+func ListResources(w http.ResponseWriter, r *http.Request) {
+	f := store.Filter{
+		Kind:  r.URL.Query().Get("kind"),
+		Owner: util.Sanitize(r.URL.Query().Get("owner")),
+	}
+	if isScoped(r) {
+		f.Owner = middleware.Subject(r)
+	}
+	list, err := resources.List(f)
+	if err != nil {
+		util.WriteErr(w, util.Wrap(err, "cannot read resource store"))
+		return
+	}
+	util.WriteJSON(w, http.StatusOK, list)
+}
+
+// UpdateResource godoc
+// PUT /api/resources/{kind}/{name}
+// Body: { "notes": "..." }
+func UpdateResource(w http.ResponseWriter, r *http.Request) {
+	kind := chi_urlParam(r, "kind")
+	name := chi_urlParam(r, "name")
+	if !resourceKinds[kind] {
+		util.WriteErr(w, util.Invalid("kind", "unknown resource kind"))
+		return
+	}
+	if name == "" || strings.ContainsAny(name, "/\\") {
+		util.WriteErr(w, util.Invalid("name", "invalid resource name"))
+		return
+	}
+	if !requireOwner(w, r, kind, name) {
+		return
+	}
+
+	var body struct {
+		Notes *string `json:"notes"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		util.WriteErr(w, util.ErrInvalidBody)
+		return
+	}
+	if body.Notes != nil && len(*body.Notes) > 4096 {
+		util.WriteErr(w, util.Invalid("notes", "notes are limited to 4096 bytes"))
+		return
+	}
+
+	err := resources.Update(kind, name, func(res *store.Resource) {
+		if body.Notes != nil {
+			res.Notes = *body.Notes
+		}
+	})
+	if err != nil {
+		util.WriteErr(w, util.Wrap(err, "failed to save resource"))
+		return
+	}
+	res, _, _ := resources.Get(kind, name)
+	util.WriteJSON(w, http.StatusOK, res)
+}
+
+// ── helpers ───────────────────────────────────────────────────────────────────
+
+// recordResource notes metadata for a resource the panel just created or
+// changed. The resource itself already exists, so failures are only logged.
+func recordResource(kind, name string, fn func(res *store.Resource)) {
+	if err := resources.Update(kind, name, fn); err != nil {
+		log.Printf("warning: could not record %s %s: %v", kind, name, err)
+	}
+}
+
+// forgetResource drops everything recorded about a deleted resource.
+func forgetResource(kind, name string) {
+	if err := resources.Delete(kind, name); err != nil {
+		log.Printf("warning: could not forget %s %s: %v", kind, name, err)
+	}
+}
+
+// setMeta sets a metadata value, creating the map as needed.
+func setMeta(res *store.Resource, key, value string) {
+	if res.Meta == nil {
+		res.Meta = map[string]string{}
+	}
+	res.Meta[key] = value
+}
+
+// importLegacyOwnership copies ownership.json (kind -> name -> owner) into
+// the store once and renames it so it is not imported again.
+func importLegacyOwnership() error {
+	owners := map[string]map[string]string{}
+	if err := util.ReadState(legacyOwnershipFile, &owners); err != nil {
+		return err
+	}
+	if len(owners) == 0 {
+		return nil
+	}
+	for kind, names := range owners {
+		for name, owner := range names {
+			if err := setResourceOwner(kind, name, owner); err != nil {
+				return err
+			}
+		}
+	}
+	path := filepath.Join(util.DataDir(), legacyOwnershipFile)
+	if err := os.Rename(path, path+".imported"); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	log.Printf("imported %s into the resource store", legacyOwnershipFile)
+	return nil
+}
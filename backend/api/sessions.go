@@ -0,0 +1,369 @@
+package api
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"blogron/middleware"
+	"blogron/util"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Every login opens a session in <data dir>/sessions.json. A session holds a
+// rotating refresh token (stored hashed) and the jti of the one access token
+// currently issued for it, so killing the session cuts off both.
+const (
+	sessionsFile    = "sessions.json"
+	accessTokenTTL  = 15 * time.Minute
+	refreshTokenTTL = 30 * 24 * time.Hour
+)
+
+// Session is the public view of a login session.
+type Session struct {
+	ID         string    `json:"id"`
+	Username   string    `json:"username"`
+	IP         string    `json:"ip"`
+	UserAgent  string    `json:"user_agent"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Current    bool      `json:"current"`
+}
+
+type sessionRecord struct {
+	Session
+	RefreshHash string `json:"refresh_hash"`
+	AccessJTI   string `json:"access_jti"`
+	AccessExp   int64  `json:"access_exp"`
+}
+
+var sessions struct {
+	sync.Mutex
+	loaded  bool
+	records map[string]*sessionRecord
+}
+
+// RefreshToken godoc
+// POST /api/auth/refresh
+// Body: { "refresh_token": "..." } — returns a new access token and a new
+// refresh token; the old refresh token stops working.
+func RefreshToken(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		util.WriteErr(w, util.ErrInvalidBody)
+		return
+	}
+	sid, _, ok := strings.Cut(body.RefreshToken, ".")
+	if !ok {
+		util.WriteErr(w, errInvalidRefresh)
+		return
+	}
+
+	sessions.Lock()
+	if err := loadSessionsLocked(); err != nil {
+		sessions.Unlock()
+		util.WriteErr(w, util.Wrap(err, "cannot read sessions"))
+		return
+	}
+	rec, ok := sessions.records[sid]
+	if !ok || time.Now().After(rec.ExpiresAt) {
+		sessions.Unlock()
+		util.WriteErr(w, errInvalidRefresh)
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(hashToken(body.RefreshToken)), []byte(rec.RefreshHash)) != 1 {
+		// An old refresh token was replayed — assume it leaked and end the session.
+		endSessionLocked(rec)
+		saveSessionsLocked()
+		sessions.Unlock()
+		util.WriteErr(w, errRefreshReused)
+		return
+	}
+	// Rotate before letting go of the lock, so the token can be used once:
+	// a concurrent refresh with it is a replay.
+	tokens, err := rotateSessionLocked(r, rec.Username, sid)
+	sessions.Unlock()
+	if err != nil {
+		util.WriteErr(w, util.Wrap(err, "failed to save sessions"))
+		return
+	}
+
+	acc, ok := getPanelAccount(tokens.username)
+	if !ok || acc.Disabled {
+		killSession(sid)
+		util.WriteErr(w, errAccountInactive)
+		return
+	}
+	writeTokens(w, acc.PanelUser, tokens)
+}
+
+// Logout godoc
+// POST /api/auth/logout
+// Ends the caller's session and revokes the access token used for this call.
+func Logout(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.Claims(r)
+	if sid, _ := claims["sid"].(string); sid != "" {
+		killSession(sid)
+	}
+	if jti, _ := claims["jti"].(string); jti != "" {
+		exp, _ := claims.GetExpirationTime()
+		expires := time.Now().Add(accessTokenTTL)
+		if exp != nil {
+			expires = exp.Time
+		}
+		middleware.RevokeToken(jti, expires)
+	}
+	util.WriteJSON(w, http.StatusOK, map[string]string{"status": "logged_out"})
+}
+
+// ListSessions godoc
+// GET /api/auth/sessions?user=jane — admins may list another account's sessions
+func ListSessions(w http.ResponseWriter, r *http.Request) {
+	username := middleware.Subject(r)
+	if u := r.URL.Query().Get("user"); u != "" && u != username {
+		if middleware.Role(r) != middleware.RoleAdmin {
+			util.WriteErr(w, util.NewError(http.StatusForbidden, util.CodeRoleForbidden, "only admins can view other accounts' sessions"))
+			return
+		}
+		username = util.Sanitize(u)
+	}
+	current, _ := middleware.Claims(r)["sid"].(string)
+
+	sessions.Lock()
+	defer sessions.Unlock()
+	if err := loadSessionsLocked(); err != nil {
+		util.WriteErr(w, util.Wrap(err, "cannot read sessions"))
+		return
+	}
+
+	list := []Session{}
+	for _, rec := range sessions.records {
+		if rec.Username != username || time.Now().After(rec.ExpiresAt) {
+			continue
+		}
+		s := rec.Session
+		s.Current = s.ID == current
+		list = append(list, s)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].LastUsedAt.After(list[j].LastUsedAt) })
+	util.WriteJSON(w, http.StatusOK, list)
+}
+
+// DeleteSession godoc
+// DELETE /api/auth/sessions/{id}
+func DeleteSession(w http.ResponseWriter, r *http.Request) {
+	sid := chi_urlParam(r, "id")
+
+	sessions.Lock()
+	if err := loadSessionsLocked(); err != nil {
+		sessions.Unlock()
+		util.WriteErr(w, util.Wrap(err, "cannot read sessions"))
+		return
+	}
+	rec, ok := sessions.records[sid]
+	if !ok || (rec.Username != middleware.Subject(r) && middleware.Role(r) != middleware.RoleAdmin) {
+		sessions.Unlock()
+		util.WriteErr(w, errSessionNotFound)
+		return
+	}
+	endSessionLocked(rec)
+	err := saveSessionsLocked()
+	sessions.Unlock()
+
+	if err != nil {
+		util.WriteErr(w, util.Wrap(err, "failed to save sessions"))
+		return
+	}
+	util.WriteJSON(w, http.StatusOK, map[string]string{"status": "revoked", "id": sid})
+}
+
+// ── helpers ───────────────────────────────────────────────────────────────────
+
+// sessionTokens are the tokens just issued for a session; the access token
+// is signed with them once the account has been checked.
+type sessionTokens struct {
+	username       string
+	sid            string
+	jti            string
+	refreshToken   string
+	accessExpiry   time.Time
+	refreshExpires time.Time
+}
+
+// writeSessionTokens opens a new session for user and answers with its
+// access and refresh tokens.
+func writeSessionTokens(w http.ResponseWriter, r *http.Request, user PanelUser) {
+	sessions.Lock()
+	if err := loadSessionsLocked(); err != nil {
+		sessions.Unlock()
+		util.WriteErr(w, util.Wrap(err, "cannot read sessions"))
+		return
+	}
+	tokens, err := rotateSessionLocked(r, user.Username, "")
+	sessions.Unlock()
+	if err != nil {
+		util.WriteErr(w, util.Wrap(err, "failed to save sessions"))
+		return
+	}
+	writeTokens(w, user, tokens)
+}
+
+// rotateSessionLocked issues a fresh access token id and refresh token for
+// session sid, opening a new session for username when sid is empty or
+// unknown, and saves the sessions. The previous tokens stop working.
+func rotateSessionLocked(r *http.Request, username, sid string) (sessionTokens, error) {
+	now := time.Now()
+	rec, ok := sessions.records[sid]
+	if !ok {
+		sid = randomToken(12)
+		rec = &sessionRecord{Session: Session{
+			ID:        sid,
+			Username:  username,
+			CreatedAt: now.UTC(),
+		}}
+		sessions.records[sid] = rec
+	} else if rec.AccessJTI != "" {
+		// Only the newest access token of a session stays valid.
+		middleware.RevokeToken(rec.AccessJTI, time.Unix(rec.AccessExp, 0))
+	}
+	tokens := sessionTokens{
+		username:     username,
+		sid:          sid,
+		jti:          randomToken(16),
+		refreshToken: sid + "." + randomToken(32),
+		accessExpiry: now.Add(accessTokenTTL),
+	}
+
+	rec.IP = clientIP(r)
+	rec.UserAgent = r.UserAgent()
+	rec.LastUsedAt = now.UTC()
+	rec.ExpiresAt = now.Add(refreshTokenTTL).UTC()
+	rec.RefreshHash = hashToken(tokens.refreshToken)
+	rec.AccessJTI = tokens.jti
+	rec.AccessExp = tokens.accessExpiry.Unix()
+	tokens.refreshExpires = rec.ExpiresAt
+	pruneSessionsLocked()
+	return tokens, saveSessionsLocked()
+}
+
+// writeTokens signs the access token of tokens for user and answers with it
+// and the refresh token.
+func writeTokens(w http.ResponseWriter, user PanelUser, tokens sessionTokens) {
+	signed, err := signToken(jwt.MapClaims{
+		"sub":  user.Username,
+		"role": user.Role,
+		"typ":  tokenTypeAccess,
+		"jti":  tokens.jti,
+		"sid":  tokens.sid,
+		"exp":  tokens.accessExpiry.Unix(),
+		"iat":  time.Now().Unix(),
+	})
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, "could not sign token")
+		return
+	}
+
+	util.WriteJSON(w, http.StatusOK, loginResponse{
+		Token:          signed,
+		Expires:        tokens.accessExpiry.Unix(),
+		RefreshToken:   tokens.refreshToken,
+		RefreshExpires: tokens.refreshExpires.Unix(),
+		User:           user.Username,
+		Role:           user.Role,
+	})
+}
+
+// killSession ends one session, revoking its current access token.
+func killSession(sid string) {
+	sessions.Lock()
+	defer sessions.Unlock()
+	if err := loadSessionsLocked(); err != nil {
+		return
+	}
+	if rec, ok := sessions.records[sid]; ok {
+		endSessionLocked(rec)
+		saveSessionsLocked()
+	}
+}
+
+// revokeUserSessions ends every session of username — used when an account is
+// deleted, disabled or has its password or role changed.
+func revokeUserSessions(username string) {
+	sessions.Lock()
+	defer sessions.Unlock()
+	if err := loadSessionsLocked(); err != nil {
+		return
+	}
+	for _, rec := range sessions.records {
+		if rec.Username == username {
+			endSessionLocked(rec)
+		}
+	}
+	saveSessionsLocked()
+}
+
+func endSessionLocked(rec *sessionRecord) {
+	middleware.RevokeToken(rec.AccessJTI, time.Unix(rec.AccessExp, 0))
+	delete(sessions.records, rec.ID)
+}
+
+func pruneSessionsLocked() {
+	now := time.Now()
+	for id, rec := range sessions.records {
+		if now.After(rec.ExpiresAt) {
+			delete(sessions.records, id)
+		}
+	}
+}
+
+func loadSessionsLocked() error {
+	if sessions.loaded {
+		return nil
+	}
+	records := map[string]*sessionRecord{}
+	if err := util.ReadState(sessionsFile, &records); err != nil {
+		return err
+	}
+	sessions.records = records
+	sessions.loaded = true
+	return nil
+}
+
+func saveSessionsLocked() error {
+	return util.WriteState(sessionsFile, sessions.records)
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// randomToken returns n random bytes, hex encoded.
+func randomToken(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic("crypto/rand failed: " + err.Error())
+	}
+	return hex.EncodeToString(b)
+}
+
+// clientIP returns the caller's address as resolved by middleware.RealIP:
+// forwarding headers count only from the configured trusted proxies.
+func clientIP(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"blogron/middleware"
+	"blogron/util"
+)
+
+// setupSessionTest gives the api package an empty session list and one
+// admin account kept in a temporary data directory.
+func setupSessionTest(t *testing.T) {
+	t.Helper()
+	prevDataDir, prevSecret := util.DataDir(), util.JWTSecret()
+	t.Cleanup(func() {
+		util.SetDataDir(prevDataDir)
+		util.SetJWTSecret(string(prevSecret))
+		sessions.loaded, sessions.records = false, nil
+		panelUsers.loaded, panelUsers.accounts = false, nil
+	})
+
+	util.SetDataDir(t.TempDir())
+	util.SetJWTSecret("0123456789abcdef0123456789abcdef")
+	sessions.loaded, sessions.records = true, map[string]*sessionRecord{}
+	panelUsers.loaded, panelUsers.accounts = true, map[string]*panelAccount{
+		"admin": {PanelUser: PanelUser{Username: "admin", Role: middleware.RoleAdmin}},
+	}
+}
+
+func TestRefreshTokenIsSingleUse(t *testing.T) {
+	setupSessionTest(t)
+
+	sessions.Lock()
+	tokens, err := rotateSessionLocked(httptest.NewRequest(http.MethodPost, "/api/auth/login", nil), "admin", "")
+	sessions.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// However the requests interleave, only one of them gets new tokens.
+	const n = 20
+	codes := make([]int, n)
+	var wg sync.WaitGroup
+	for i := range codes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			body := strings.NewReader(`{"refresh_token":"` + tokens.refreshToken + `"}`)
+			rec := httptest.NewRecorder()
+			RefreshToken(rec, httptest.NewRequest(http.MethodPost, "/api/auth/refresh", body))
+			codes[i] = rec.Code
+		}()
+	}
+	wg.Wait()
+
+	ok := 0
+	for _, code := range codes {
+		if code == http.StatusOK {
+			ok++
+		}
+	}
+	if ok != 1 {
+		t.Errorf("%d of %d refreshes with the same token succeeded, want 1 (statuses %v)", ok, n, codes)
+	}
+}
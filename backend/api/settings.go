@@ -0,0 +1,55 @@
+package api
+
+import (
+	"net/http"
+
+	"blogron/config"
+	"blogron/util"
+)
+
+// settings is the validated panel configuration; main swaps in the one
+// loaded from panel.yaml at startup.
+var settings = config.Default()
+
+// Configure applies cfg to the API: service paths, MySQL credentials, the
+// default PHP version and the BIND unit all come from it.
+func Configure(cfg *config.Config) {
+	settings = cfg
+	p := cfg.Paths
+	nginxSitesAvailable = p.NginxSitesAvailable
+	nginxSitesEnabled = p.NginxSitesEnabled
+	nginxTemplatesDir = p.NginxTemplates
+	webRoot = p.WebRoot
+	fileManagerRoot = p.FileManagerRoot
+	wpRoot = p.WPRoot
+	wpCliPath = p.WPCli
+	bindZonesDir = p.BindZonesDir
+	bindNamedLocal = p.BindNamedLocal
+	postfixVirtualMailboxDir = p.PostfixVirtualMailbox
+	postfixVirtualDomainsFile = p.PostfixVirtualDomains
+	postfixVirtualMapsFile = p.PostfixVirtualMaps
+	dovecotPasswdFile = p.DovecotUsers
+	mailStorageBase = p.MailStorage
+	vsftpdUserListFile = p.VsftpdUserList
+	vsftpdPasswdFile = p.VsftpdPasswd
+	cronDir = p.CronDir
+}
+
+// SeedPanelUsers loads the panel accounts, creating the first admin from
+// auth.admin_user / auth.admin_password when there are none, so a missing
+// password stops the panel at startup rather than at the first login.
+func SeedPanelUsers() error {
+	panelUsers.Lock()
+	defer panelUsers.Unlock()
+	return loadPanelUsersLocked()
+}
+
+// GetSettings godoc
+// GET /api/settings
+// Secrets are masked.
+func GetSettings(w http.ResponseWriter, r *http.Request) {
+	util.WriteJSON(w, http.StatusOK, map[string]any{
+		"config_file": config.Path(),
+		"settings":    settings.Masked(),
+	})
+}
@@ -2,6 +2,7 @@ package api
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"net/http"
 	"os"
@@ -148,7 +149,7 @@ func getRAMStat() RAMStat {
 }
 
 func getDiskStat() DiskStat {
-	out, err := util.RunCmd("df", "-BG", "--output=size,used,avail,pcent", "/")
+	out, err := runCmd(context.Background(), "df", "-BG", "--output=size,used,avail,pcent", "/")
 	if err != nil {
 		return DiskStat{}
 	}
@@ -242,7 +243,7 @@ func GetServices(w http.ResponseWriter, r *http.Request) {
 }
 
 func queryService(name string) Service {
-	out, err := util.RunCmd("systemctl", "show", name, "--property=ActiveState,MainPID,ActiveEnterTimestamp")
+	out, err := runCmd(context.Background(), "systemctl", "show", name, "--property=ActiveState,MainPID,ActiveEnterTimestamp")
 	svc := Service{Name: name}
 	if err != nil {
 		svc.Status = "unknown"
@@ -282,7 +283,7 @@ func serviceAction(w http.ResponseWriter, r *http.Request, action string) {
 	name := chi_urlParam(r, "name")
 	name = util.Sanitize(name)
 	if name == "" {
-		util.WriteError(w, http.StatusBadRequest, "invalid service name")
+		util.WriteErr(w, util.Invalid("name", "invalid service name"))
 		return
 	}
 
@@ -291,12 +292,12 @@ func serviceAction(w http.ResponseWriter, r *http.Request, action string) {
 		allowed[s] = true
 	}
 	if !allowed[name] {
-		util.WriteError(w, http.StatusForbidden, "service not managed by this panel")
+		util.WriteErr(w, errServiceNotManaged)
 		return
 	}
 
-	if _, err := util.RunCmd("systemctl", action, name); err != nil {
-		util.WriteError(w, http.StatusInternalServerError, err.Error())
+	if _, err := runCmd(r.Context(), "systemctl", action, name); err != nil {
+		util.WriteErr(w, err)
 		return
 	}
 	util.WriteJSON(w, http.StatusOK, map[string]string{"status": "ok", "service": name, "action": action})
@@ -324,9 +325,9 @@ func GetLogs(w http.ResponseWriter, r *http.Request) {
 		args = append(args, "-u", unit)
 	}
 
-	out, err := util.RunCmd("journalctl", args...)
+	out, err := runCmd(r.Context(), "journalctl", args...)
 	if err != nil {
-		util.WriteError(w, http.StatusInternalServerError, err.Error())
+		util.WriteErr(w, err)
 		return
 	}
 
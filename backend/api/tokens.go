@@ -0,0 +1,302 @@
+package api
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"blogron/middleware"
+	"blogron/util"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// API tokens let scripts and CI call the panel without a password. A token
+// looks like blg_<id>_<secret>; only a sha256 of the whole string is kept in
+// <data dir>/api-tokens.json. Requests made with a token act as the owning
+// panel account, further limited to the token's scopes.
+const (
+	apiTokensFile    = "api-tokens.json"
+	tokenTouchPeriod = time.Minute // how often last_used is written to disk
+)
+
+// APIToken is the public view of an API token (never includes the secret).
+type APIToken struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Owner      string     `json:"owner"`
+	Scopes     []string   `json:"scopes"`
+	AllowedIPs []string   `json:"allowed_ips,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	LastUsedIP string     `json:"last_used_ip,omitempty"`
+}
+
+type apiTokenRecord struct {
+	APIToken
+	Hash string `json:"hash"`
+}
+
+type createAPITokenRequest struct {
+	Name          string   `json:"name"`
+	Scopes        []string `json:"scopes"`
+	ExpiresInDays int      `json:"expires_in_days"` // 0 = never
+	AllowedIPs    []string `json:"allowed_ips"`     // IPs or CIDRs; empty = any
+}
+
+type createAPITokenResponse struct {
+	APIToken
+	Token string `json:"token"`
+}
+
+var apiTokens struct {
+	sync.Mutex
+	loaded  bool
+	records map[string]*apiTokenRecord
+}
+
+// ListAPITokens godoc
+// GET /api/auth/tokens?user=jane — admins may list another account's tokens
+func ListAPITokens(w http.ResponseWriter, r *http.Request) {
+	owner := middleware.Subject(r)
+	if u := r.URL.Query().Get("user"); u != "" && u != owner {
+		if middleware.Role(r) != middleware.RoleAdmin {
+			util.WriteErr(w, util.NewError(http.StatusForbidden, util.CodeRoleForbidden, "only admins can view other accounts' tokens"))
+			return
+		}
+		owner = util.Sanitize(u)
+	}
+
+	apiTokens.Lock()
+	defer apiTokens.Unlock()
+	if err := loadAPITokensLocked(); err != nil {
+		util.WriteErr(w, util.Wrap(err, "cannot read API tokens"))
+		return
+	}
+
+	list := []APIToken{}
+	for _, rec := range apiTokens.records {
+		if rec.Owner == owner {
+			list = append(list, rec.APIToken)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.Before(list[j].CreatedAt) })
+	util.WriteJSON(w, http.StatusOK, list)
+}
+
+// CreateAPIToken godoc
+// POST /api/auth/tokens
+// Body: { "name": "deploy", "scopes": ["vhosts:write", "wordpress:cache"], "expires_in_days": 90, "allowed_ips": ["203.0.113.0/24"] }
+// The token is only returned in this response.
+func CreateAPIToken(w http.ResponseWriter, r *http.Request) {
+	var req createAPITokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		util.WriteErr(w, util.ErrInvalidBody)
+		return
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" || len(name) > 64 {
+		util.WriteErr(w, util.Invalid("name", "name is required (max 64 characters)"))
+		return
+	}
+	if len(req.Scopes) == 0 {
+		util.WriteErr(w, util.Invalid("scopes", "at least one scope is required"))
+		return
+	}
+	for _, s := range req.Scopes {
+		if !middleware.ValidScope(s) {
+			util.WriteErr(w, util.Invalid("scopes", "unknown scope: "+s))
+			return
+		}
+	}
+	for _, ip := range req.AllowedIPs {
+		if !validIPOrCIDR(ip) {
+			util.WriteErr(w, util.Invalid("allowed_ips", "invalid IP or CIDR in allowed_ips: "+ip))
+			return
+		}
+	}
+	if req.ExpiresInDays < 0 {
+		util.WriteErr(w, util.Invalid("expires_in_days", "expires_in_days cannot be negative"))
+		return
+	}
+
+	now := time.Now().UTC()
+	id := randomToken(6)
+	token := middleware.APITokenPrefix + id + "_" + randomToken(32)
+	rec := &apiTokenRecord{
+		APIToken: APIToken{
+			ID:         id,
+			Name:       name,
+			Owner:      middleware.Subject(r),
+			Scopes:     req.Scopes,
+			AllowedIPs: req.AllowedIPs,
+			CreatedAt:  now,
+		},
+		Hash: hashToken(token),
+	}
+	if req.ExpiresInDays > 0 {
+		exp := now.AddDate(0, 0, req.ExpiresInDays)
+		rec.ExpiresAt = &exp
+	}
+
+	apiTokens.Lock()
+	defer apiTokens.Unlock()
+	if err := loadAPITokensLocked(); err != nil {
+		util.WriteErr(w, util.Wrap(err, "cannot read API tokens"))
+		return
+	}
+	apiTokens.records[id] = rec
+	if err := saveAPITokensLocked(); err != nil {
+		delete(apiTokens.records, id)
+		util.WriteErr(w, util.Wrap(err, "failed to save API tokens"))
+		return
+	}
+	util.WriteJSON(w, http.StatusCreated, createAPITokenResponse{APIToken: rec.APIToken, Token: token})
+}
+
+// DeleteAPIToken godoc
+// DELETE /api/auth/tokens/{id}
+func DeleteAPIToken(w http.ResponseWriter, r *http.Request) {
+	id := chi_urlParam(r, "id")
+
+	apiTokens.Lock()
+	defer apiTokens.Unlock()
+	if err := loadAPITokensLocked(); err != nil {
+		util.WriteErr(w, util.Wrap(err, "cannot read API tokens"))
+		return
+	}
+	rec, ok := apiTokens.records[id]
+	if !ok || (rec.Owner != middleware.Subject(r) && middleware.Role(r) != middleware.RoleAdmin) {
+		util.WriteErr(w, errAPITokenNotFound)
+		return
+	}
+	delete(apiTokens.records, id)
+	if err := saveAPITokensLocked(); err != nil {
+		apiTokens.records[id] = rec
+		util.WriteErr(w, util.Wrap(err, "failed to save API tokens"))
+		return
+	}
+	util.WriteJSON(w, http.StatusOK, map[string]string{"status": "revoked", "id": id})
+}
+
+// VerifyAPIToken is installed as middleware.APITokenVerifier. It checks the
+// token's hash, expiry and IP allowlist, and returns claims for the owning
+// account with the token's scopes attached.
+func VerifyAPIToken(r *http.Request, token string) (jwt.MapClaims, error) {
+	errInvalid := errors.New("invalid or expired API token")
+	id, _, ok := strings.Cut(strings.TrimPrefix(token, middleware.APITokenPrefix), "_")
+	if !ok {
+		return nil, errInvalid
+	}
+	ip := clientIP(r)
+	now := time.Now().UTC()
+
+	apiTokens.Lock()
+	if err := loadAPITokensLocked(); err != nil {
+		apiTokens.Unlock()
+		return nil, errInvalid
+	}
+	rec, ok := apiTokens.records[id]
+	if !ok || subtle.ConstantTimeCompare([]byte(hashToken(token)), []byte(rec.Hash)) != 1 ||
+		(rec.ExpiresAt != nil && now.After(*rec.ExpiresAt)) {
+		apiTokens.Unlock()
+		return nil, errInvalid
+	}
+	if !ipAllowed(rec.AllowedIPs, ip) {
+		apiTokens.Unlock()
+		return nil, errors.New("API token is not allowed from " + ip)
+	}
+	if rec.LastUsedAt == nil || now.Sub(*rec.LastUsedAt) > tokenTouchPeriod || rec.LastUsedIP != ip {
+		rec.LastUsedAt = &now
+		rec.LastUsedIP = ip
+		if err := saveAPITokensLocked(); err != nil {
+			log.Printf("warning: could not record API token use: %v", err)
+		}
+	}
+	owner, scopes := rec.Owner, append([]string(nil), rec.Scopes...)
+	apiTokens.Unlock()
+
+	acc, ok := getPanelAccount(owner)
+	if !ok || acc.Disabled {
+		return nil, errors.New("API token owner is no longer active")
+	}
+	return jwt.MapClaims{
+		"sub":    acc.Username,
+		"role":   acc.Role,
+		"typ":    "api",
+		"tid":    id,
+		"scopes": scopes,
+	}, nil
+}
+
+// ── helpers ───────────────────────────────────────────────────────────────────
+
+// deleteUserAPITokens removes every token owned by username.
+func deleteUserAPITokens(username string) {
+	apiTokens.Lock()
+	defer apiTokens.Unlock()
+	if err := loadAPITokensLocked(); err != nil {
+		return
+	}
+	for id, rec := range apiTokens.records {
+		if rec.Owner == username {
+			delete(apiTokens.records, id)
+		}
+	}
+	saveAPITokensLocked()
+}
+
+func validIPOrCIDR(s string) bool {
+	if net.ParseIP(s) != nil {
+		return true
+	}
+	_, _, err := net.ParseCIDR(s)
+	return err == nil
+}
+
+// ipAllowed reports whether ip matches the allowlist; an empty list allows all.
+func ipAllowed(allowed []string, ip string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return false
+	}
+	for _, a := range allowed {
+		if _, n, err := net.ParseCIDR(a); err == nil {
+			if n.Contains(addr) {
+				return true
+			}
+		} else if other := net.ParseIP(a); other != nil && other.Equal(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+func loadAPITokensLocked() error {
+	if apiTokens.loaded {
+		return nil
+	}
+	records := map[string]*apiTokenRecord{}
+	if err := util.ReadState(apiTokensFile, &records); err != nil {
+		return err
+	}
+	apiTokens.records = records
+	apiTokens.loaded = true
+	return nil
+}
+
+func saveAPITokensLocked() error {
+	return util.WriteState(apiTokensFile, apiTokens.records)
+}
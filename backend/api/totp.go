@@ -0,0 +1,322 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"blogron/middleware"
+	"blogron/util"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// RFC 6238 parameters — the defaults every authenticator app understands.
+const (
+	totpIssuer    = "BLOGRON Panel"
+	totpPeriod    = 30
+	totpDigits    = 6
+	totpSkew      = 1 // accept one step either side for clock drift
+	recoveryCodes = 10
+)
+
+var errInvalidCode = util.NewError(http.StatusBadRequest, util.CodeInvalidTOTPCode, "invalid verification code")
+
+// totpNow is the clock codes are checked against; tests fix it.
+var totpNow = time.Now
+
+type totpSetupResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+}
+
+type recoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// SetupTOTP godoc
+// POST /api/auth/totp/setup
+// Generates a new secret for the caller. Two-factor is not active until the
+// secret is confirmed with POST /api/auth/totp/enable.
+func SetupTOTP(w http.ResponseWriter, r *http.Request) {
+	username := middleware.Subject(r)
+
+	raw := make([]byte, 20)
+	if _, err := rand.Read(raw); err != nil {
+		util.WriteError(w, http.StatusInternalServerError, "could not generate secret")
+		return
+	}
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(raw)
+
+	err := updatePanelAccount(username, func(acc *panelAccount) error {
+		if acc.TOTPEnabled {
+			return util.NewError(http.StatusConflict, util.CodeTOTPState, "two-factor authentication is already enabled")
+		}
+		acc.TOTPPending = secret
+		return nil
+	})
+	if err != nil {
+		util.WriteErr(w, err)
+		return
+	}
+
+	util.WriteJSON(w, http.StatusOK, totpSetupResponse{
+		Secret:     secret,
+		OTPAuthURI: otpauthURI(username, secret),
+	})
+}
+
+// EnableTOTP godoc
+// POST /api/auth/totp/enable
+// Body: { "code": "123456" } — returns one-time recovery codes. Sessions
+// opened before, with the password alone, are ended.
+func EnableTOTP(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		util.WriteErr(w, util.ErrInvalidBody)
+		return
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, "could not generate recovery codes")
+		return
+	}
+
+	username := middleware.Subject(r)
+	err = updatePanelAccount(username, func(acc *panelAccount) error {
+		if acc.TOTPPending == "" {
+			return util.NewError(http.StatusConflict, util.CodeTOTPState, "call /api/auth/totp/setup first")
+		}
+		step, ok := verifyTOTP(acc.TOTPPending, body.Code, 0)
+		if !ok {
+			return errInvalidCode
+		}
+		acc.TOTPSecret = acc.TOTPPending
+		acc.TOTPPending = ""
+		acc.TOTPLastStep = step
+		acc.TOTPEnabled = true
+		acc.RecoveryCodes = hashes
+		return nil
+	})
+	if err != nil {
+		util.WriteErr(w, err)
+		return
+	}
+	revokeUserSessions(username)
+	util.WriteJSON(w, http.StatusOK, recoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// DisableTOTP godoc
+// POST /api/auth/totp/disable
+// Body: { "password": "..." }
+func DisableTOTP(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		util.WriteErr(w, util.ErrInvalidBody)
+		return
+	}
+
+	username := middleware.Subject(r)
+	if _, err := authenticatePanelUser(username, body.Password); err != nil {
+		util.WriteErr(w, errInvalidCredentials)
+		return
+	}
+	if err := updatePanelAccount(username, func(acc *panelAccount) error {
+		clearTOTP(acc)
+		return nil
+	}); err != nil {
+		util.WriteErr(w, util.Wrap(err, "failed to save panel accounts"))
+		return
+	}
+	util.WriteJSON(w, http.StatusOK, map[string]string{"status": "disabled"})
+}
+
+// RegenerateRecoveryCodes godoc
+// POST /api/auth/totp/recovery-codes
+// Body: { "password": "..." } — invalidates all previous recovery codes
+func RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		util.WriteErr(w, util.ErrInvalidBody)
+		return
+	}
+
+	username := middleware.Subject(r)
+	if _, err := authenticatePanelUser(username, body.Password); err != nil {
+		util.WriteErr(w, errInvalidCredentials)
+		return
+	}
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		util.WriteError(w, http.StatusInternalServerError, "could not generate recovery codes")
+		return
+	}
+	err = updatePanelAccount(username, func(acc *panelAccount) error {
+		if !acc.TOTPEnabled {
+			return util.NewError(http.StatusConflict, util.CodeTOTPState, "two-factor authentication is not enabled")
+		}
+		acc.RecoveryCodes = hashes
+		return nil
+	})
+	if err != nil {
+		util.WriteErr(w, err)
+		return
+	}
+	util.WriteJSON(w, http.StatusOK, recoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// VerifyLogin godoc
+// POST /api/auth/login/verify
+// Body: { "pre_auth_token": "...", "code": "123456" } — code may also be a recovery code
+func VerifyLogin(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		PreAuthToken string `json:"pre_auth_token"`
+		Code         string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		util.WriteErr(w, util.ErrInvalidBody)
+		return
+	}
+
+	claims, err := parseToken(body.PreAuthToken, tokenTypePreAuth)
+	if err != nil {
+		util.WriteErr(w, errInvalidPreAuth)
+		return
+	}
+	username, _ := claims["sub"].(string)
+	if loginBlocked(w, r, username) {
+		return
+	}
+
+	var user PanelUser
+	err = updatePanelAccount(username, func(acc *panelAccount) error {
+		if acc.Disabled || !acc.TOTPEnabled {
+			return errInvalidCredentials
+		}
+		if step, ok := verifyTOTP(acc.TOTPSecret, body.Code, acc.TOTPLastStep); ok {
+			acc.TOTPLastStep = step
+		} else if i := matchRecoveryCode(acc.RecoveryCodes, body.Code); i >= 0 {
+			acc.RecoveryCodes = append(acc.RecoveryCodes[:i:i], acc.RecoveryCodes[i+1:]...)
+		} else {
+			return errInvalidCode
+		}
+		user = acc.PanelUser
+		return nil
+	})
+	if err != nil {
+		recordLoginFailure(r, username)
+		util.WriteErr(w, util.NewError(http.StatusUnauthorized, util.CodeInvalidCredentials, "invalid verification code"))
+		return
+	}
+	recordLoginSuccess(r, username)
+
+	writeSessionTokens(w, r, user)
+}
+
+// ── helpers ───────────────────────────────────────────────────────────────────
+
+func clearTOTP(acc *panelAccount) {
+	acc.TOTPEnabled = false
+	acc.TOTPSecret = ""
+	acc.TOTPPending = ""
+	acc.TOTPLastStep = 0
+	acc.RecoveryCodes = nil
+}
+
+func otpauthURI(username, secret string) string {
+	label := url.PathEscape(totpIssuer + ":" + username)
+	q := url.Values{}
+	q.Set("secret", secret)
+	q.Set("issuer", totpIssuer)
+	q.Set("algorithm", "SHA1")
+	q.Set("digits", fmt.Sprint(totpDigits))
+	q.Set("period", fmt.Sprint(totpPeriod))
+	return "otpauth://totp/" + label + "?" + q.Encode()
+}
+
+// verifyTOTP checks code against secret within the allowed clock skew and
+// returns the matching time step. Steps at or before lastStep are rejected so
+// a code cannot be replayed.
+func verifyTOTP(secret, code string, lastStep int64) (int64, bool) {
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(strings.ToUpper(secret))
+	if err != nil || len(code) != totpDigits {
+		return 0, false
+	}
+	now := totpNow().Unix() / totpPeriod
+	for step := now - totpSkew; step <= now+totpSkew; step++ {
+		if step <= lastStep {
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(totpCode(key, step)), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// totpCode computes the HOTP value (RFC 4226) for the given counter.
+func totpCode(key []byte, counter int64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(counter))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", totpDigits, value%1000000)
+}
+
+// newRecoveryCodes returns plaintext codes for the user and their bcrypt hashes for storage.
+func newRecoveryCodes() ([]string, []string, error) {
+	const alphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+	codes := make([]string, 0, recoveryCodes)
+	hashes := make([]string, 0, recoveryCodes)
+	for i := 0; i < recoveryCodes; i++ {
+		raw := make([]byte, 10)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, nil, err
+		}
+		for j, b := range raw {
+			raw[j] = alphabet[int(b)%len(alphabet)]
+		}
+		code := string(raw[:5]) + "-" + string(raw[5:])
+		hash, err := bcrypt.GenerateFromPassword([]byte(code), bcrypt.DefaultCost)
+		if err != nil {
+			return nil, nil, err
+		}
+		codes = append(codes, code)
+		hashes = append(hashes, string(hash))
+	}
+	return codes, hashes, nil
+}
+
+// matchRecoveryCode returns the index of the hash matching code, or -1.
+func matchRecoveryCode(hashes []string, code string) int {
+	code = strings.ToLower(strings.TrimSpace(code))
+	if len(code) != 11 {
+		return -1
+	}
+	for i, h := range hashes {
+		if bcrypt.CompareHashAndPassword([]byte(h), []byte(code)) == nil {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,184 @@
+package api
+
+import (
+	"context"
+	"encoding/base32"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"blogron/middleware"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// The shared secret of the RFC 4226 and RFC 6238 test vectors.
+var rfcKey = []byte("12345678901234567890")
+
+func TestTOTPCode(t *testing.T) {
+	// RFC 4226 appendix D: HOTP values for counters 0 to 9.
+	for counter, want := range []string{"755224", "287082", "359152", "969429", "338314", "254676", "287922", "162583", "399871", "520489"} {
+		if got := totpCode(rfcKey, int64(counter)); got != want {
+			t.Errorf("HOTP(%d) = %s, want %s", counter, got, want)
+		}
+	}
+	// RFC 6238 appendix B (SHA-1), last six of the eight digits.
+	for _, tc := range []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	} {
+		if got := totpCode(rfcKey, tc.unix/totpPeriod); got != tc.want {
+			t.Errorf("TOTP(%d) = %s, want %s", tc.unix, got, tc.want)
+		}
+	}
+}
+
+func TestVerifyTOTP(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	totpNow = func() time.Time { return now }
+	t.Cleanup(func() { totpNow = time.Now })
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(rfcKey)
+	step := now.Unix() / totpPeriod
+
+	for _, tc := range []struct {
+		offset int64
+		ok     bool
+	}{
+		{-2, false}, {-1, true}, {0, true}, {1, true}, {2, false},
+	} {
+		got, ok := verifyTOTP(secret, totpCode(rfcKey, step+tc.offset), 0)
+		if ok != tc.ok || (ok && got != step+tc.offset) {
+			t.Errorf("code of step now%+d: step %d, ok %v; want ok %v", tc.offset, got, ok, tc.ok)
+		}
+	}
+
+	// A code is good once: its step, and those before it, are used up.
+	code := totpCode(rfcKey, step)
+	used, ok := verifyTOTP(secret, code, 0)
+	if !ok {
+		t.Fatal("current code rejected")
+	}
+	if _, ok := verifyTOTP(secret, code, used); ok {
+		t.Error("code accepted twice")
+	}
+	if _, ok := verifyTOTP(secret, totpCode(rfcKey, step-1), used); ok {
+		t.Error("code older than the last used one accepted")
+	}
+	if _, ok := verifyTOTP(secret, totpCode(rfcKey, step+1), used); !ok {
+		t.Error("next code rejected")
+	}
+
+	if _, ok := verifyTOTP(strings.ToLower(secret), code, 0); !ok {
+		t.Error("lowercase secret rejected")
+	}
+	for _, bad := range []string{"", "12345", "1234567", "abcdef"} {
+		if _, ok := verifyTOTP(secret, bad, 0); ok {
+			t.Errorf("code %q accepted", bad)
+		}
+	}
+	if _, ok := verifyTOTP("not base32!", code, 0); ok {
+		t.Error("invalid secret accepted")
+	}
+}
+
+// asUser returns r as sent with an access token of username.
+func asUser(r *http.Request, username, role string) *http.Request {
+	claims := jwt.MapClaims{"sub": username, "role": role}
+	return r.WithContext(context.WithValue(r.Context(), middleware.UserContextKey, &claims))
+}
+
+func resetLoginGuard(t *testing.T) {
+	t.Helper()
+	clear := func() {
+		loginGuard.Lock()
+		loginGuard.byIP = map[string]*attemptLog{}
+		loginGuard.byUserIP = map[string]*attemptLog{}
+		loginGuard.byUser = map[string]*attemptLog{}
+		loginGuard.Unlock()
+	}
+	clear()
+	t.Cleanup(clear)
+}
+
+func TestVerifyLoginRecoveryCodeSingleUse(t *testing.T) {
+	setupSessionTest(t)
+	resetLoginGuard(t)
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	acc := panelUsers.accounts["admin"]
+	acc.TOTPEnabled = true
+	acc.TOTPSecret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(rfcKey)
+	acc.RecoveryCodes = hashes
+
+	preAuth, err := signToken(jwt.MapClaims{"sub": "admin", "typ": tokenTypePreAuth, "exp": time.Now().Add(time.Minute).Unix()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify := func(code string) int {
+		body := strings.NewReader(`{"pre_auth_token":"` + preAuth + `","code":"` + code + `"}`)
+		rec := httptest.NewRecorder()
+		VerifyLogin(rec, httptest.NewRequest(http.MethodPost, "/api/auth/login/verify", body))
+		return rec.Code
+	}
+
+	// Recovery codes are accepted in upper case and with spaces around.
+	if code := verify(" " + strings.ToUpper(codes[3]) + " "); code != http.StatusOK {
+		t.Fatalf("first use of a recovery code: status %d", code)
+	}
+	if n := len(panelUsers.accounts["admin"].RecoveryCodes); n != recoveryCodes-1 {
+		t.Errorf("%d recovery codes left, want %d", n, recoveryCodes-1)
+	}
+	if code := verify(codes[3]); code != http.StatusUnauthorized {
+		t.Errorf("second use of a recovery code: status %d, want 401", code)
+	}
+	if code := verify(codes[4]); code != http.StatusOK {
+		t.Errorf("another recovery code: status %d", code)
+	}
+}
+
+func TestEnableTOTP(t *testing.T) {
+	setupSessionTest(t)
+	enable := func(code string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/api/auth/totp/enable", strings.NewReader(`{"code":"`+code+`"}`))
+		rec := httptest.NewRecorder()
+		EnableTOTP(rec, asUser(r, "admin", middleware.RoleAdmin))
+		return rec
+	}
+
+	// Without a secret from /totp/setup there is nothing to confirm.
+	if rec := enable("123456"); rec.Code != http.StatusConflict || !strings.Contains(rec.Body.String(), "TOTP_STATE_CONFLICT") {
+		t.Errorf("enable without setup: %d %s", rec.Code, rec.Body)
+	}
+
+	// A session opened with the password alone ends once two-factor is on.
+	sessions.Lock()
+	if _, err := rotateSessionLocked(httptest.NewRequest(http.MethodPost, "/api/auth/login", nil), "admin", ""); err != nil {
+		t.Fatal(err)
+	}
+	sessions.Unlock()
+
+	panelUsers.accounts["admin"].TOTPPending = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(rfcKey)
+	if rec := enable("000000"); rec.Code != http.StatusBadRequest {
+		t.Errorf("enable with a wrong code: %d %s", rec.Code, rec.Body)
+	}
+	if rec := enable(totpCode(rfcKey, time.Now().Unix()/totpPeriod)); rec.Code != http.StatusOK {
+		t.Fatalf("enable: %d %s", rec.Code, rec.Body)
+	}
+	if acc := panelUsers.accounts["admin"]; !acc.TOTPEnabled || acc.TOTPPending != "" || len(acc.RecoveryCodes) != recoveryCodes {
+		t.Errorf("account after enable: %+v", acc)
+	}
+	if n := len(sessions.records); n != 0 {
+		t.Errorf("%d sessions left after enabling two-factor, want 0", n)
+	}
+}
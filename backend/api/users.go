@@ -2,9 +2,13 @@ package api
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"os"
+	"os/user"
+	"slices"
 	"strings"
 
 	"blogron/util"
@@ -26,6 +30,14 @@ type createUserRequest struct {
 	Groups   string `json:"groups"` // comma-separated
 }
 
+// useraddExists is useradd's exit status when the username is taken.
+const useraddExists = 9
+
+// siteUsersGroup holds the system accounts the panel creates (CreateUser,
+// FTP users). blogron.sudoers lets the panel run commands as its members
+// only, e.g. for running a cron job now.
+const siteUsersGroup = "blogron-sites"
+
 // ListUsers godoc
 // GET /api/users
 // Reads /etc/passwd and returns non-system users (UID >= 1000).
@@ -52,17 +64,17 @@ func ListUsers(w http.ResponseWriter, r *http.Request) {
 func CreateUser(w http.ResponseWriter, r *http.Request) {
 	var req createUserRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		util.WriteError(w, http.StatusBadRequest, "invalid request body")
+		util.WriteErr(w, util.ErrInvalidBody)
 		return
 	}
 
 	username := util.Sanitize(req.Username)
 	if username == "" || len(username) > 32 {
-		util.WriteError(w, http.StatusBadRequest, "invalid username")
+		util.WriteErr(w, util.Invalid("username", "invalid username"))
 		return
 	}
 	if req.Password == "" || len(req.Password) < 8 {
-		util.WriteError(w, http.StatusBadRequest, "password must be at least 8 characters")
+		util.WriteErr(w, util.Invalid("password", "password must be at least 8 characters"))
 		return
 	}
 
@@ -72,27 +84,33 @@ func CreateUser(w http.ResponseWriter, r *http.Request) {
 	}
 	allowedShells := map[string]bool{"/bin/bash": true, "/bin/sh": true, "/usr/sbin/nologin": true}
 	if !allowedShells[shell] {
-		util.WriteError(w, http.StatusBadRequest, "invalid shell")
+		util.WriteErr(w, util.Invalid("shell", "invalid shell"))
 		return
 	}
 
 	// Create the user
-	args := []string{"-m", "-s", shell, username}
-	if req.Groups != "" {
-		args = append([]string{"-G", util.Sanitize(req.Groups)}, args...)
+	groups := []string{siteUsersGroup}
+	for _, g := range strings.Split(req.Groups, ",") {
+		if g = util.Sanitize(g); g != "" {
+			groups = append(groups, g)
+		}
 	}
-	if _, err := util.RunCmd("useradd", args...); err != nil {
-		util.WriteError(w, http.StatusInternalServerError, "useradd failed: "+err.Error())
+	args := []string{"-m", "-s", shell, "-G", strings.Join(groups, ","), username}
+	if _, err := runCmd(r.Context(), "useradd", args...); err != nil {
+		var ce *util.CmdError
+		if errors.As(err, &ce) && ce.ExitCode == useraddExists {
+			util.WriteErr(w, errSystemUserExists)
+			return
+		}
+		util.WriteErr(w, util.Wrap(err, "useradd failed"))
 		return
 	}
 
 	// Set password via chpasswd
-	// chpasswd reads "user:password" from stdin — we write to a temp file approach
-	// For real production, use `echo "user:pass" | sudo chpasswd`
-	if _, err := util.RunCmd("chpasswd", username+":"+req.Password); err != nil {
+	if err := setSystemPassword(r.Context(), username, req.Password); err != nil {
 		// Attempt to clean up the created user
-		util.RunCmd("userdel", "-r", username)
-		util.WriteError(w, http.StatusInternalServerError, "failed to set password")
+		runCmd(r.Context(), "userdel", "-r", username)
+		util.WriteErr(w, util.Wrap(err, "failed to set password"))
 		return
 	}
 
@@ -104,12 +122,12 @@ func CreateUser(w http.ResponseWriter, r *http.Request) {
 func DeleteUser(w http.ResponseWriter, r *http.Request) {
 	username := util.Sanitize(chi_urlParam(r, "username"))
 	if username == "" || username == "root" {
-		util.WriteError(w, http.StatusBadRequest, "invalid or protected username")
+		util.WriteErr(w, util.NewError(http.StatusBadRequest, util.CodeProtectedUser, "invalid or protected username"))
 		return
 	}
 
-	if _, err := util.RunCmd("userdel", "-r", username); err != nil {
-		util.WriteError(w, http.StatusInternalServerError, err.Error())
+	if _, err := runCmd(r.Context(), "userdel", "-r", username); err != nil {
+		util.WriteErr(w, err)
 		return
 	}
 	util.WriteJSON(w, http.StatusOK, map[string]string{"status": "deleted", "username": username})
@@ -124,17 +142,17 @@ func UpdateUser(w http.ResponseWriter, r *http.Request) {
 		Shell    string `json:"shell"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
-		util.WriteError(w, http.StatusBadRequest, "invalid body")
+		util.WriteErr(w, util.ErrInvalidBody)
 		return
 	}
 
 	if body.Password != "" {
 		if len(body.Password) < 8 {
-			util.WriteError(w, http.StatusBadRequest, "password too short")
+			util.WriteErr(w, util.Invalid("password", "password too short"))
 			return
 		}
-		if _, err := util.RunCmd("chpasswd", username+":"+body.Password); err != nil {
-			util.WriteError(w, http.StatusInternalServerError, "failed to update password")
+		if err := setSystemPassword(r.Context(), username, body.Password); err != nil {
+			util.WriteErr(w, util.Wrap(err, "failed to update password"))
 			return
 		}
 	}
@@ -142,11 +160,11 @@ func UpdateUser(w http.ResponseWriter, r *http.Request) {
 	if body.Shell != "" {
 		allowedShells := map[string]bool{"/bin/bash": true, "/bin/sh": true, "/usr/sbin/nologin": true}
 		if !allowedShells[body.Shell] {
-			util.WriteError(w, http.StatusBadRequest, "invalid shell")
+			util.WriteErr(w, util.Invalid("shell", "invalid shell"))
 			return
 		}
-		if _, err := util.RunCmd("usermod", "-s", body.Shell, username); err != nil {
-			util.WriteError(w, http.StatusInternalServerError, err.Error())
+		if _, err := runCmd(r.Context(), "usermod", "-s", body.Shell, username); err != nil {
+			util.WriteErr(w, err)
 			return
 		}
 	}
@@ -158,11 +176,11 @@ func UpdateUser(w http.ResponseWriter, r *http.Request) {
 func SuspendUser(w http.ResponseWriter, r *http.Request) {
 	username := util.Sanitize(chi_urlParam(r, "username"))
 	if username == "root" {
-		util.WriteError(w, http.StatusForbidden, "cannot suspend root")
+		util.WriteErr(w, util.NewError(http.StatusForbidden, util.CodeProtectedUser, "cannot suspend root"))
 		return
 	}
-	if _, err := util.RunCmd("usermod", "-L", username); err != nil {
-		util.WriteError(w, http.StatusInternalServerError, err.Error())
+	if _, err := runCmd(r.Context(), "usermod", "-L", username); err != nil {
+		util.WriteErr(w, err)
 		return
 	}
 	util.WriteJSON(w, http.StatusOK, map[string]string{"status": "suspended"})
@@ -171,8 +189,8 @@ func SuspendUser(w http.ResponseWriter, r *http.Request) {
 // ActivateUser unlocks the account with usermod -U
 func ActivateUser(w http.ResponseWriter, r *http.Request) {
 	username := util.Sanitize(chi_urlParam(r, "username"))
-	if _, err := util.RunCmd("usermod", "-U", username); err != nil {
-		util.WriteError(w, http.StatusInternalServerError, err.Error())
+	if _, err := runCmd(r.Context(), "usermod", "-U", username); err != nil {
+		util.WriteErr(w, err)
 		return
 	}
 	util.WriteJSON(w, http.StatusOK, map[string]string{"status": "active"})
@@ -180,6 +198,34 @@ func ActivateUser(w http.ResponseWriter, r *http.Request) {
 
 // ── helpers ───────────────────────────────────────────────────────────────────
 
+// isSiteUser reports whether the system account name is a member of
+// siteUsersGroup, as its primary group or a supplementary one.
+func isSiteUser(name string) bool {
+	u, err := user.Lookup(name)
+	if err != nil {
+		return false
+	}
+	g, err := user.LookupGroup(siteUsersGroup)
+	if err != nil {
+		return false
+	}
+	gids, err := u.GroupIds()
+	return err == nil && slices.Contains(gids, g.Gid)
+}
+
+// setSystemPassword sets a Unix account password. chpasswd reads
+// "user:password" lines from stdin, which also keeps the password out of argv.
+func setSystemPassword(ctx context.Context, username, password string) error {
+	if strings.ContainsAny(password, "\r\n") {
+		return errors.New("password cannot contain line breaks")
+	}
+	_, err := util.RunCommand(ctx, executor, util.Command{
+		Name:  "chpasswd",
+		Stdin: []byte(username + ":" + password + "\n"),
+	})
+	return err
+}
+
 func parsePasswd() ([]User, error) {
 	f, err := os.Open("/etc/passwd")
 	if err != nil {
@@ -0,0 +1,231 @@
+package api
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"blogron/nginx"
+)
+
+// phpFPMRe finds the PHP version in a PHP-FPM socket path, e.g.
+// unix:/run/php/php8.2-fpm.sock.
+var phpFPMRe = regexp.MustCompile(`php(\d+(?:\.\d+)?)-fpm`)
+
+// vhostFile is a site config in sites-available or sites-enabled.
+type vhostFile struct {
+	domain  string
+	path    string
+	enabled bool
+}
+
+// vhostFiles lists the site configs nginx knows about: every file in
+// sites-available, enabled when something in sites-enabled points at it,
+// plus files placed directly in sites-enabled. "<domain>.conf" is named
+// after the domain, anything else (e.g. "default") after the file.
+func vhostFiles() ([]vhostFile, error) {
+	available, err := os.ReadDir(nginxSitesAvailable)
+	if err != nil {
+		return nil, err
+	}
+	enabled, _ := os.ReadDir(nginxSitesEnabled)
+
+	linked := map[string]bool{} // resolved sites-enabled targets
+	for _, e := range enabled {
+		if target, err := filepath.EvalSymlinks(filepath.Join(nginxSitesEnabled, e.Name())); err == nil {
+			linked[target] = true
+		}
+	}
+
+	var files []vhostFile
+	seen := map[string]bool{}
+	add := func(dir, name string, isEnabled func(path string) bool) {
+		path := filepath.Join(dir, name)
+		if strings.HasPrefix(name, ".") {
+			return
+		}
+		if fi, err := os.Stat(path); err != nil || !fi.Mode().IsRegular() {
+			return
+		}
+		real, err := filepath.EvalSymlinks(path)
+		if err != nil || seen[real] {
+			return
+		}
+		seen[real] = true
+		files = append(files, vhostFile{domain: strings.TrimSuffix(name, ".conf"), path: path, enabled: isEnabled(real)})
+	}
+	for _, e := range available {
+		add(nginxSitesAvailable, e.Name(), func(real string) bool { return linked[real] })
+	}
+	for _, e := range enabled {
+		add(nginxSitesEnabled, e.Name(), func(string) bool { return true })
+	}
+	return files, nil
+}
+
+// parseVhostConf describes the vhost of domain from its config in
+// sites-available.
+func parseVhostConf(domain string) Vhost {
+	return readVhostConf(domain, filepath.Join(nginxSitesAvailable, domain+".conf"))
+}
+
+// readVhostConf describes the site config at path, following its includes.
+// All server blocks of the file count: certbot, for one, adds a second
+// block that only redirects port 80. A config that cannot be read or
+// parsed is returned with Error set.
+func readVhostConf(domain, path string) Vhost {
+	vh := Vhost{Domain: domain, File: path}
+	conf, err := nginx.ParseFile(path, filepath.Dir(nginxSitesAvailable))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			vh.Error = err.Error()
+		}
+		return vh
+	}
+
+	groups := map[string][]string{} // upstream block name → servers
+	for _, u := range nginx.Find(conf, "upstream") {
+		var servers []string
+		for _, s := range nginx.Find(u.Block, "server") {
+			servers = append(servers, s.Arg(0))
+		}
+		groups[u.Arg(0)] = servers
+	}
+
+	for _, srv := range nginx.Find(conf, "server") {
+		if !srv.IsBlock() {
+			continue
+		}
+		for _, l := range nginx.Find(srv.Block, "listen") {
+			port, ip, ssl := parseListen(l.Args)
+			if port > 0 {
+				vh.Ports = appendUnique(vh.Ports, port)
+			}
+			if ip != "" && vh.IP == "" {
+				vh.IP = ip
+			}
+			vh.SSL = vh.SSL || ssl
+		}
+		if len(nginx.Find(srv.Block, "listen")) == 0 {
+			vh.Ports = appendUnique(vh.Ports, 80)
+		}
+		for _, name := range nginx.Args(srv.Block, "server_name") {
+			vh.ServerNames = appendUnique(vh.ServerNames, name)
+		}
+		if vh.DocRoot == "" {
+			vh.DocRoot = serverRoot(srv.Block)
+		}
+
+		nginx.Walk(srv.Block, func(d *nginx.Directive) bool {
+			switch d.Name {
+			case "fastcgi_pass":
+				if m := phpFPMRe.FindStringSubmatch(d.Arg(0)); m != nil && vh.PHPSocket == "" {
+					vh.PHPSocket = strings.TrimPrefix(d.Arg(0), "unix:")
+					vh.PHP = m[1]
+				}
+			case "proxy_pass":
+				target := proxyTarget(d.Arg(0))
+				servers, ok := groups[target]
+				if !ok {
+					servers = []string{target}
+				}
+				for _, s := range servers {
+					vh.Upstreams = appendUnique(vh.Upstreams, s)
+				}
+			case "ssl_certificate":
+				if vh.Certificate == "" {
+					vh.Certificate = d.Arg(0)
+				}
+				vh.SSL = true
+			case "ssl_certificate_key":
+				if vh.CertificateKey == "" {
+					vh.CertificateKey = d.Arg(0)
+				}
+			}
+			return true
+		})
+	}
+	slices.Sort(vh.Ports)
+
+	for _, name := range vh.ServerNames {
+		if name != domain && name != "_" && name != "" {
+			vh.Aliases = append(vh.Aliases, name)
+		}
+	}
+	return vh
+}
+
+// parseListen returns the port, the address when it is not a wildcard and
+// whether SSL is on for "listen [addr:]port [ssl] ...". Unix sockets give
+// port 0.
+func parseListen(args []string) (port int, ip string, ssl bool) {
+	if len(args) == 0 {
+		return 80, "", false
+	}
+	ssl = slices.Contains(args[1:], "ssl")
+	addr := args[0]
+	if strings.HasPrefix(addr, "unix:") {
+		return 0, "", ssl
+	}
+	host, p, err := net.SplitHostPort(addr)
+	if err != nil {
+		// A bare port or a bare address.
+		if n, err := strconv.Atoi(addr); err == nil {
+			return n, "", ssl
+		}
+		host, p = strings.Trim(addr, "[]"), "80"
+	}
+	port, _ = strconv.Atoi(p)
+	if host == "*" || host == "" || host == "0.0.0.0" || host == "::" {
+		host = ""
+	}
+	return port, host, ssl
+}
+
+// serverRoot returns the root of a server block: its own, else that of
+// "location /", else the first one found in a location.
+func serverRoot(block []*nginx.Directive) string {
+	if root := nginx.Args(block, "root"); len(root) > 0 {
+		return root[0]
+	}
+	var first string
+	for _, loc := range nginx.Find(block, "location") {
+		root := nginx.Args(loc.Block, "root")
+		if len(root) == 0 {
+			continue
+		}
+		if slices.Equal(loc.Args, []string{"/"}) {
+			return root[0]
+		}
+		if first == "" {
+			first = root[0]
+		}
+	}
+	return first
+}
+
+// proxyTarget returns the host:port, unix:/path or upstream name of a
+// proxy_pass URL.
+func proxyTarget(url string) string {
+	_, rest, ok := strings.Cut(url, "://")
+	if !ok {
+		return url
+	}
+	if path, ok := strings.CutPrefix(rest, "unix:"); ok {
+		path, _, _ = strings.Cut(path, ":")
+		return "unix:" + path
+	}
+	host, _, _ := strings.Cut(rest, "/")
+	return host
+}
+
+func appendUnique[T comparable](list []T, v T) []T {
+	if slices.Contains(list, v) {
+		return list
+	}
+	return append(list, v)
+}
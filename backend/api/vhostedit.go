@@ -0,0 +1,294 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"blogron/config"
+	"blogron/nginx"
+	"blogron/util"
+)
+
+// updateVhostRequest changes an existing vhost; fields left out (null) stay
+// as they are.
+type updateVhostRequest struct {
+	DocRoot    *string   `json:"docroot"`
+	PHP        *string   `json:"php"`
+	Aliases    *[]string `json:"aliases"`    // server names besides the domain, e.g. ["www.example.com"]
+	Directives *string   `json:"directives"` // extra nginx directives for the server block; "" removes them
+
+	ForceHTTPS *bool           `json:"force_https"` // redirect http:// to https://; needs a certificate
+	Canonical  *string         `json:"canonical"`   // "www", "non-www" or "" for both
+	Redirects  *[]redirectRule `json:"redirects"`   // replaces all rules; [] removes them
+}
+
+// Custom directives live between these lines in the first server block, so
+// they can be replaced without touching the rest of the file.
+const (
+	directivesBegin = "# BEGIN custom directives (managed by BLOGRON)"
+	directivesEnd   = "# END custom directives"
+)
+
+var phpSocketRe = regexp.MustCompile(`php\d\.\d-fpm\.sock`)
+
+// validate checks the request for a vhost of domain.
+func (req *updateVhostRequest) validate(domain string) error {
+	fields := map[string]string{}
+	if req.DocRoot != nil {
+		if !validDocRoot(*req.DocRoot) {
+			fields["docroot"] = "must be a clean absolute path"
+		}
+	}
+	if req.PHP != nil && !config.ValidPHPVersion(*req.PHP) {
+		fields["php"] = "must be a version like 8.2"
+	}
+	if req.Aliases != nil {
+		for _, a := range *req.Aliases {
+			name := strings.TrimPrefix(a, "*.")
+			if name == "" || util.Sanitize(name) != name || !strings.Contains(name, ".") || a == domain {
+				fields["aliases"] = fmt.Sprintf("%q is not a host name", a)
+			}
+		}
+	}
+	if req.Directives != nil {
+		d := *req.Directives
+		switch {
+		case strings.Count(d, "{") != strings.Count(d, "}"):
+			fields["directives"] = "unbalanced braces"
+		case strings.Contains(d, directivesBegin) || strings.Contains(d, directivesEnd),
+			strings.Contains(d, redirectsBegin) || strings.Contains(d, redirectsEnd):
+			fields["directives"] = "must not contain the section markers"
+		}
+	}
+	validateRedirects(req.Canonical, req.Redirects, fields)
+	if len(fields) > 0 {
+		return util.InvalidFields("invalid vhost settings", fields)
+	}
+	return nil
+}
+
+// validDocRoot reports whether d can go into a root directive as is.
+func validDocRoot(d string) bool {
+	return filepath.IsAbs(d) && filepath.Clean(d) == d && !strings.ContainsAny(d, " \t\n;{}'\"$#")
+}
+
+// editVhostConf applies req to an nginx config in place, leaving everything
+// else — certbot's listen 443 and ssl_certificate lines, its redirect block,
+// hand edits — as it is. Directives are found by parsing the config, so
+// comments, quoted arguments and directives split over lines are no
+// trouble.
+func editVhostConf(conf, domain string, req updateVhostRequest) (string, error) {
+	lines := strings.Split(conf, "\n")
+	parsed, err := nginx.Parse("vhost", []byte(conf))
+	if err != nil {
+		return "", util.NewError(http.StatusUnprocessableEntity, util.CodeNginxConfigInvalid, "cannot edit the vhost config: "+err.Error())
+	}
+
+	// Line indexes taken by more than one directive, which are not
+	// rewritten.
+	shared := map[int]bool{}
+	taken := map[int]bool{}
+	take := func(line int) {
+		shared[line] = taken[line]
+		taken[line] = true
+	}
+	nginx.Walk(parsed, func(d *nginx.Directive) bool {
+		take(d.Line - 1)
+		if d.EndLine != d.Line {
+			take(d.EndLine - 1)
+		}
+		return true
+	})
+
+	edits := map[*nginx.Directive]string{} // directive → its new line
+	set := func(field string, d *nginx.Directive, line string) error {
+		for i := d.Line - 1; i < d.EndLine; i++ {
+			if shared[i] {
+				return util.Invalid(field, fmt.Sprintf("line %d holds more than one directive; edit it by hand", i+1))
+			}
+		}
+		edits[d] = line
+		return nil
+	}
+
+	if req.DocRoot != nil {
+		n := 0
+		nginx.Walk(parsed, func(d *nginx.Directive) bool {
+			if d.Name == "root" && err == nil {
+				err = set("docroot", d, rewriteDirective(lines, d, *req.DocRoot))
+				n++
+			}
+			return true
+		})
+		if err != nil {
+			return "", err
+		}
+		if n == 0 {
+			return "", util.Invalid("docroot", "this vhost has no root directive")
+		}
+	}
+
+	if req.PHP != nil {
+		n := 0
+		nginx.Walk(parsed, func(d *nginx.Directive) bool {
+			if !d.IsBlock() && err == nil && slices.ContainsFunc(d.Args, phpSocketRe.MatchString) {
+				text := strings.Join(lines[d.Line-1:d.EndLine], "\n")
+				err = set("php", d, phpSocketRe.ReplaceAllString(text, "php"+*req.PHP+"-fpm.sock"))
+				n++
+			}
+			return true
+		})
+		if err != nil {
+			return "", err
+		}
+		if n == 0 {
+			return "", util.Invalid("php", "this vhost does not use PHP-FPM")
+		}
+	}
+
+	if req.Aliases != nil {
+		names := strings.Join(append([]string{domain}, *req.Aliases...), " ")
+		for _, srv := range nginx.Find(parsed, "server") {
+			for _, d := range nginx.Find(srv.Block, "server_name") {
+				if !slices.Contains(d.Args, domain) {
+					continue
+				}
+				if err := set("aliases", d, rewriteDirective(lines, d, names)); err != nil {
+					return "", err
+				}
+			}
+		}
+	}
+
+	// Rewrite from the bottom up, so the lines of the directives still to
+	// do stay where they were parsed.
+	order := make([]*nginx.Directive, 0, len(edits))
+	for d := range edits {
+		order = append(order, d)
+	}
+	slices.SortFunc(order, func(a, b *nginx.Directive) int { return b.Line - a.Line })
+	for _, d := range order {
+		lines = slices.Replace(lines, d.Line-1, d.EndLine, edits[d])
+	}
+
+	if req.Directives != nil {
+		var body []string
+		if d := strings.Trim(*req.Directives, "\r\n"); strings.TrimSpace(d) != "" {
+			body = strings.Split(d, "\n")
+		}
+		blocks := serverBlocks(lines, "")
+		if len(blocks) == 0 {
+			return "", util.Invalid("directives", "no server block found")
+		}
+		lines = replaceSection(lines, blocks[0], directivesBegin, directivesEnd, body, false)
+	}
+
+	if req.hasRedirects() {
+		var force bool
+		var canonical string
+		var rules []redirectRule
+		if req.ForceHTTPS != nil {
+			force = *req.ForceHTTPS
+		}
+		if req.Canonical != nil {
+			canonical = *req.Canonical
+		}
+		if req.Redirects != nil {
+			rules = *req.Redirects
+		}
+		// Every block serving the domain gets them: certbot moves the
+		// site to port 443 and adds a block of its own for port 80.
+		body := redirectLines(domain, force, canonical, rules)
+		n := len(serverBlocks(lines, domain))
+		if n == 0 {
+			return "", util.Invalid("redirects", "no server block for "+domain+" found")
+		}
+		for i := 0; i < n; i++ {
+			lines = replaceSection(lines, serverBlocks(lines, domain)[i], redirectsBegin, redirectsEnd, body, true)
+		}
+	}
+
+	return strings.Join(lines, "\n"), nil
+}
+
+// replaceSection replaces the lines between begin and end in the server
+// block spanning lines block[0] to block[1] with body, indented one level;
+// an empty body removes the section. A new section goes first in the block
+// when top is set, else after its last directive.
+func replaceSection(lines []string, block [2]int, begin, end string, body []string, top bool) []string {
+	start, stop := block[0], block[1]
+	// Drop the previous section, with the blank line around it.
+	from, to := -1, -1
+	for i := start; i < stop; i++ {
+		switch strings.TrimSpace(lines[i]) {
+		case begin:
+			from = i
+		case end:
+			to = i
+		}
+	}
+	if from >= 0 && to > from {
+		if from > 0 && strings.TrimSpace(lines[from-1]) == "" {
+			from--
+		} else if to+1 < stop && strings.TrimSpace(lines[to+1]) == "" {
+			to++
+		}
+		lines = append(lines[:from], lines[to+1:]...)
+		stop -= to + 1 - from
+	}
+	if len(body) == 0 {
+		return lines
+	}
+
+	section := []string{"    " + begin}
+	for _, l := range body {
+		section = append(section, strings.TrimRight("    "+l, " \t\r"))
+	}
+	section = append(section, "    "+end)
+	if top {
+		// Before any return further down, such as certbot's return 404.
+		return slices.Insert(lines, start+1, append(section, "")...)
+	}
+	// After the last directive, before any blank lines closing the block
+	at := stop
+	for at-1 > start && strings.TrimSpace(lines[at-1]) == "" {
+		at--
+	}
+	return slices.Insert(lines, at, append([]string{""}, section...)...)
+}
+
+// serverBlocks returns the line indexes of each top-level "server {" and
+// of its closing brace; with domain set, only of those whose server_name
+// lists it. The config is parsed rather than scanned for braces, which may
+// also appear in quoted arguments such as redirect regexes.
+func serverBlocks(lines []string, domain string) [][2]int {
+	conf, err := nginx.Parse("vhost", []byte(strings.Join(lines, "\n")))
+	if err != nil {
+		return nil
+	}
+	var blocks [][2]int
+	for _, d := range nginx.Find(conf, "server") {
+		if !d.IsBlock() || (domain != "" && !slices.Contains(nginx.Args(d.Block, "server_name"), domain)) {
+			continue
+		}
+		blocks = append(blocks, [2]int{d.Line - 1, d.EndLine - 1})
+	}
+	return blocks
+}
+
+// rewriteDirective returns the line d becomes with new arguments: on one
+// line, with the indentation of its first line and the comment after its
+// ";", if any.
+func rewriteDirective(lines []string, d *nginx.Directive, args string) string {
+	first, last := lines[d.Line-1], lines[d.EndLine-1]
+	indent := first[:len(first)-len(strings.TrimLeft(first, " \t"))]
+	comment := ""
+	if i := strings.LastIndex(last, ";"); i >= 0 {
+		comment = strings.TrimRight(last[i+1:], " \t\r")
+	}
+	return indent + d.Name + " " + args + ";" + comment
+}
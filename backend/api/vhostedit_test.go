@@ -0,0 +1,215 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"blogron/nginx"
+	"blogron/util"
+)
+
+// A regex with braces or a # must not throw off where the server block
+// ends, or every later edit of the vhost fails.
+func TestEditVhostConfRedirectRegexWithBraces(t *testing.T) {
+	conf := strings.Join([]string{
+		"server {",
+		"    listen 80;",
+		"    server_name example.com;",
+		"    root /var/www/example.com;",
+		"}",
+		"",
+	}, "\n")
+
+	rules := []redirectRule{{From: `^/(a{2}|b})#x$`, To: "/new", Regex: true}}
+	conf, err := editVhostConf(conf, "example.com", updateVhostRequest{Redirects: &rules})
+	if err != nil {
+		t.Fatalf("first edit: %v", err)
+	}
+	if !strings.Contains(conf, `rewrite "^/(a{2}|b})#x$" "/new" permanent;`) {
+		t.Fatalf("rewrite missing:\n%s", conf)
+	}
+
+	rules = []redirectRule{{From: "/old", To: "/new"}}
+	conf, err = editVhostConf(conf, "example.com", updateVhostRequest{Redirects: &rules})
+	if err != nil {
+		t.Fatalf("second edit: %v", err)
+	}
+	if strings.Contains(conf, "a{2}") || strings.Count(conf, redirectsBegin) != 1 {
+		t.Errorf("redirect section not replaced:\n%s", conf)
+	}
+	if !strings.HasSuffix(strings.TrimSpace(conf), "}") {
+		t.Errorf("redirects written outside the server block:\n%s", conf)
+	}
+}
+
+func TestEditVhostConfDirectives(t *testing.T) {
+	conf := strings.Join([]string{
+		"server {",
+		"    listen 80 default_server;",
+		"    server_name _;",
+		"    return 444;",
+		"}",
+		"",
+		"server {",
+		"    listen 80;",
+		"    server_name example.com",
+		"                www.example.com; # names",
+		"    # root /var/www/old;",
+		"    root   /var/www/example.com;  # docroot",
+		"    add_header X-Note \"root /not/a/directive;\";",
+		"",
+		"    location ~ \\.php$ {",
+		"        fastcgi_pass \"unix:/run/php/php8.1-fpm.sock\";",
+		"    }",
+		"    location /static {",
+		"        root /var/www/static;",
+		"    }",
+		"}",
+	}, "\n")
+
+	docroot, php := "/var/www/example.com/public", "8.3"
+	aliases := []string{"www.example.com", "shop.example.com"}
+	got, err := editVhostConf(conf, "example.com", updateVhostRequest{DocRoot: &docroot, PHP: &php, Aliases: &aliases})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := strings.Join([]string{
+		"server {",
+		"    listen 80 default_server;",
+		"    server_name _;",
+		"    return 444;",
+		"}",
+		"",
+		"server {",
+		"    listen 80;",
+		"    server_name example.com www.example.com shop.example.com; # names",
+		"    # root /var/www/old;",
+		"    root /var/www/example.com/public;  # docroot",
+		"    add_header X-Note \"root /not/a/directive;\";",
+		"",
+		"    location ~ \\.php$ {",
+		"        fastcgi_pass \"unix:/run/php/php8.3-fpm.sock\";",
+		"    }",
+		"    location /static {",
+		"        root /var/www/example.com/public;",
+		"    }",
+		"}",
+	}, "\n")
+	if got != want {
+		t.Errorf("edited config:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestEditVhostConfErrors(t *testing.T) {
+	docroot, php := "/srv/site", "8.3"
+	for _, tc := range []struct {
+		name, conf string
+		req        updateVhostRequest
+		status     int
+		field      string
+	}{
+		{
+			name:   "unparseable",
+			conf:   "server {\n    root /var/www\n}\n",
+			req:    updateVhostRequest{DocRoot: &docroot},
+			status: http.StatusUnprocessableEntity,
+		},
+		{
+			name:   "no root",
+			conf:   "server {\n    proxy_pass http://127.0.0.1:3000;\n}\n",
+			req:    updateVhostRequest{DocRoot: &docroot},
+			status: http.StatusBadRequest,
+			field:  "docroot",
+		},
+		{
+			name:   "no PHP-FPM",
+			conf:   "server {\n    root /var/www;\n}\n",
+			req:    updateVhostRequest{PHP: &php},
+			status: http.StatusBadRequest,
+			field:  "php",
+		},
+		{
+			name:   "shared line",
+			conf:   "server {\n    location / { root /var/www; }\n}\n",
+			req:    updateVhostRequest{DocRoot: &docroot},
+			status: http.StatusBadRequest,
+			field:  "docroot",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := editVhostConf(tc.conf, "example.com", tc.req)
+			e := util.ErrorFrom(err)
+			if err == nil || e.Status != tc.status || (tc.field != "" && e.Fields[tc.field] == "") {
+				t.Errorf("err = %v (%d, %v), want %d on %q", err, e.Status, e.Fields, tc.status, tc.field)
+			}
+		})
+	}
+}
+
+// After certbot the first server block is the 443 one and the site's port
+// 80 block comes second; redirects go into both, ahead of certbot's
+// return 404, and leave a block for another name alone.
+func TestEditVhostConfRedirectsEveryServerBlock(t *testing.T) {
+	conf := strings.Join([]string{
+		"server {",
+		"    server_name example.com www.example.com;",
+		"    root /var/www/example.com;",
+		"    listen 443 ssl; # managed by Certbot",
+		"}",
+		"server {",
+		"    if ($host = example.com) {",
+		"        return 301 https://$host$request_uri;",
+		"    } # managed by Certbot",
+		"    listen 80;",
+		"    server_name example.com www.example.com;",
+		"    return 404; # managed by Certbot",
+		"}",
+		"server {",
+		"    listen 80;",
+		"    server_name other.example.org;",
+		"    return 410;",
+		"}",
+	}, "\n")
+
+	force, canonical := true, canonicalNoWWW
+	rules := []redirectRule{{From: "/old", To: "/new"}}
+	got, err := editVhostConf(conf, "example.com", updateVhostRequest{ForceHTTPS: &force, Canonical: &canonical, Redirects: &rules})
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := nginx.Parse("vhost", []byte(got))
+	if err != nil {
+		t.Fatalf("edited config does not parse: %v\n%s", err, got)
+	}
+	servers := nginx.Find(parsed, "server")
+	for i, srv := range servers[:2] {
+		if first := srv.Block[0]; first.Name != "if" || first.Arg(2) != "www.example.com)" {
+			t.Errorf("server %d does not start with the canonical redirect:\n%s", i, got)
+		}
+		if n := len(nginx.Find(srv.Block, "rewrite")); n != 1 {
+			t.Errorf("server %d has %d rewrites, want 1", i, n)
+		}
+	}
+	if len(servers[2].Block) != 3 {
+		t.Errorf("block of another name changed:\n%s", got)
+	}
+	if n := strings.Count(got, redirectsBegin); n != 2 {
+		t.Errorf("%d redirect sections, want 2:\n%s", n, got)
+	}
+
+	// Editing again replaces the sections, and removing every rule gives
+	// back the file as it was.
+	got, err = editVhostConf(got, "example.com", updateVhostRequest{ForceHTTPS: &force, Canonical: &canonical, Redirects: &rules})
+	if err != nil || strings.Count(got, redirectsBegin) != 2 {
+		t.Fatalf("second edit: %v\n%s", err, got)
+	}
+	force, canonical, rules = false, "", nil
+	got, err = editVhostConf(got, "example.com", updateVhostRequest{ForceHTTPS: &force, Canonical: &canonical, Redirects: &rules})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != conf {
+		t.Errorf("config after removing the redirects:\n%s\nwant:\n%s", got, conf)
+	}
+}
@@ -0,0 +1,441 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	"blogron/store"
+	"blogron/util"
+)
+
+// Vhost profiles are text/template files rendering one nginx server config.
+// The built-in ones are compiled in; operators add their own as
+// <name>.conf.tmpl in nginxTemplatesDir through the API, which checks them
+// with nginx -t before saving. A template may start with a {{/* comment */}}
+// describing it and define "docroot" as the directory under
+// <web root>/<domain> it serves (default public_html).
+
+//go:embed vhosttemplates/*.conf.tmpl
+var builtinTemplates embed.FS
+
+// Set from the paths section of panel.yaml (see Configure).
+var nginxTemplatesDir = "/var/lib/blogron/nginx-templates"
+
+const (
+	defaultProfile     = "php"
+	templateExt        = ".conf.tmpl"
+	defaultDocRootPath = "public_html"
+)
+
+var (
+	profileNameRe     = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,39}$`)
+	templateCommentRe = regexp.MustCompile(`^\s*\{\{-?\s*/\*\s*(.*?)\s*\*/\s*-?\}\}`)
+)
+
+// VhostProfile describes a vhost template. PHP and Upstream tell which
+// createVhostRequest fields the template uses; DocRoot is the directory
+// under <web root>/<domain> it serves, empty if it serves no files.
+type VhostProfile struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Builtin     bool   `json:"builtin"`
+	PHP         bool   `json:"php"`
+	Upstream    bool   `json:"upstream"`
+	DocRoot     string `json:"docroot,omitempty"`
+	Template    string `json:"template,omitempty"` // only when fetched by name
+}
+
+// vhostTemplateData is what vhost templates render with.
+type vhostTemplateData struct {
+	Domain      string
+	ServerNames []string // the domain and its www. alias
+	DocRoot     string
+	PHPVersion  string
+	PHPSocket   string
+	Upstream    string     // the first proxy target: host:port or unix:/path
+	Proxy       vhostProxy // all targets and the proxy settings
+	AccessLog   string
+	ErrorLog    string
+}
+
+func newVhostTemplateData(domain, docroot, phpVersion string, proxy vhostProxy) vhostTemplateData {
+	data := vhostTemplateData{
+		Domain:      domain,
+		ServerNames: []string{domain, "www." + domain},
+		DocRoot:     docroot,
+		PHPVersion:  phpVersion,
+		PHPSocket:   fmt.Sprintf("/run/php/php%s-fpm.sock", phpVersion),
+		Proxy:       proxy,
+		AccessLog:   fmt.Sprintf("/var/log/nginx/%s.access.log", domain),
+		ErrorLog:    fmt.Sprintf("/var/log/nginx/%s.error.log", domain),
+	}
+	if len(proxy.Upstreams) > 0 {
+		data.Upstream = proxy.Upstreams[0]
+	}
+	return data
+}
+
+var vhostTemplateFuncs = template.FuncMap{
+	"join": strings.Join,
+}
+
+// vhostProfile is a parsed template.
+type vhostProfile struct {
+	VhostProfile
+	tmpl *template.Template
+}
+
+// ListVhostProfiles godoc
+// GET /api/vhosts/profiles
+func ListVhostProfiles(w http.ResponseWriter, r *http.Request) {
+	profiles, err := listVhostProfiles()
+	if err != nil {
+		util.WriteErr(w, util.Wrap(err, "cannot read vhost templates"))
+		return
+	}
+	list := make([]VhostProfile, len(profiles))
+	for i, p := range profiles {
+		list[i] = p.VhostProfile
+		list[i].Template = ""
+	}
+	util.WriteJSON(w, http.StatusOK, list)
+}
+
+// GetVhostProfile godoc
+// GET /api/vhosts/profiles/{name}
+func GetVhostProfile(w http.ResponseWriter, r *http.Request) {
+	p, err := loadVhostProfile(chi_urlParam(r, "name"))
+	if err != nil {
+		util.WriteErr(w, err)
+		return
+	}
+	util.WriteJSON(w, http.StatusOK, p.VhostProfile)
+}
+
+// SaveVhostProfile godoc
+// PUT /api/vhosts/profiles/{name}
+func SaveVhostProfile(w http.ResponseWriter, r *http.Request) {
+	name := chi_urlParam(r, "name")
+	if !profileNameRe.MatchString(name) {
+		util.WriteErr(w, util.Invalid("name", "lowercase letters, digits and dashes, at most 40"))
+		return
+	}
+	if isBuiltinProfile(name) {
+		util.WriteErr(w, errProfileBuiltin)
+		return
+	}
+	var req struct {
+		Template string `json:"template"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		util.WriteErr(w, util.ErrInvalidBody)
+		return
+	}
+	if strings.TrimSpace(req.Template) == "" {
+		util.WriteErr(w, util.Invalid("template", "required"))
+		return
+	}
+
+	p, err := parseVhostProfile(name, req.Template)
+	if err == nil {
+		err = checkVhostProfile(r.Context(), p)
+	}
+	if err != nil {
+		util.WriteErr(w, err)
+		return
+	}
+
+	path := filepath.Join(nginxTemplatesDir, name+templateExt)
+	_, statErr := os.Stat(path)
+	if err := os.MkdirAll(nginxTemplatesDir, 0755); err != nil {
+		util.WriteErr(w, util.Wrap(err, "cannot create template directory"))
+		return
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(req.Template), 0644); err != nil {
+		util.WriteErr(w, util.Wrap(err, "cannot write template"))
+		return
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		util.WriteErr(w, util.Wrap(err, "cannot write template"))
+		return
+	}
+
+	status := http.StatusOK
+	if os.IsNotExist(statErr) {
+		status = http.StatusCreated
+	}
+	util.WriteJSON(w, status, p.VhostProfile)
+}
+
+// DeleteVhostProfile godoc
+// DELETE /api/vhosts/profiles/{name}
+func DeleteVhostProfile(w http.ResponseWriter, r *http.Request) {
+	name := chi_urlParam(r, "name")
+	if isBuiltinProfile(name) {
+		util.WriteErr(w, errProfileBuiltin)
+		return
+	}
+	if _, err := loadVhostProfile(name); err != nil && util.ErrorFrom(err).Code != util.CodeTemplateInvalid {
+		util.WriteErr(w, err)
+		return
+	}
+
+	vhosts, err := resources.List(store.Filter{Kind: kindVhost})
+	if err != nil {
+		util.WriteErr(w, util.Wrap(err, "cannot read resources"))
+		return
+	}
+	var users []string
+	for _, res := range vhosts {
+		if res.Meta["profile"] == name {
+			users = append(users, res.Name)
+		}
+	}
+	if len(users) > 0 {
+		util.WriteErr(w, util.NewError(http.StatusConflict, util.CodeConflict,
+			"profile is used by "+strings.Join(users, ", ")))
+		return
+	}
+
+	if err := os.Remove(filepath.Join(nginxTemplatesDir, name+templateExt)); err != nil {
+		util.WriteErr(w, util.Wrap(err, "cannot delete template"))
+		return
+	}
+	util.WriteJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+}
+
+// ── helpers ───────────────────────────────────────────────────────────────────
+
+func isBuiltinProfile(name string) bool {
+	if !profileNameRe.MatchString(name) {
+		return false
+	}
+	_, err := builtinTemplates.Open("vhosttemplates/" + name + templateExt)
+	return err == nil
+}
+
+// loadVhostProfile returns the built-in or custom profile called name.
+func loadVhostProfile(name string) (*vhostProfile, error) {
+	if !profileNameRe.MatchString(name) {
+		return nil, errProfileNotFound
+	}
+	if src, err := builtinTemplates.ReadFile("vhosttemplates/" + name + templateExt); err == nil {
+		p, err := parseVhostProfile(name, string(src))
+		if err != nil {
+			return nil, err
+		}
+		p.Builtin = true
+		return p, nil
+	}
+	src, err := os.ReadFile(filepath.Join(nginxTemplatesDir, name+templateExt))
+	if os.IsNotExist(err) {
+		return nil, errProfileNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parseVhostProfile(name, string(src))
+}
+
+// listVhostProfiles returns the built-in profiles and the custom ones, by
+// name. Custom templates that no longer parse are logged and left out.
+func listVhostProfiles() ([]*vhostProfile, error) {
+	names := map[string]bool{}
+	builtins, err := builtinTemplates.ReadDir("vhosttemplates")
+	if err != nil {
+		return nil, err
+	}
+	custom, err := os.ReadDir(nginxTemplatesDir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, e := range append(builtins, custom...) {
+		name, ok := strings.CutSuffix(e.Name(), templateExt)
+		if ok && !e.IsDir() && profileNameRe.MatchString(name) {
+			names[name] = true
+		}
+	}
+
+	var profiles []*vhostProfile
+	for name := range names {
+		p, err := loadVhostProfile(name)
+		if err != nil {
+			log.Printf("warning: vhost profile %s: %v", name, err)
+			continue
+		}
+		profiles = append(profiles, p)
+	}
+	sort.Slice(profiles, func(i, j int) bool { return profiles[i].Name < profiles[j].Name })
+	return profiles, nil
+}
+
+// parseVhostProfile parses a template and works out what it needs from the
+// fields it refers to.
+func parseVhostProfile(name, src string) (*vhostProfile, error) {
+	t, err := template.New(name).Funcs(vhostTemplateFuncs).Option("missingkey=error").Parse(src)
+	if err != nil {
+		return nil, errTemplate(err)
+	}
+	p := &vhostProfile{VhostProfile: VhostProfile{Name: name, Template: src}, tmpl: t}
+	if m := templateCommentRe.FindStringSubmatch(src); m != nil {
+		p.Description = m[1]
+	}
+
+	fields := map[string]bool{}
+	for _, tt := range t.Templates() {
+		if tt.Tree != nil {
+			templateFields(tt.Tree.Root, fields)
+		}
+	}
+	p.PHP = fields["PHPVersion"] || fields["PHPSocket"]
+	p.Upstream = fields["Upstream"] || fields["Proxy"]
+	if fields["DocRoot"] {
+		p.DocRoot = defaultDocRootPath
+		if t.Lookup("docroot") != nil {
+			var buf bytes.Buffer
+			if err := t.ExecuteTemplate(&buf, "docroot", nil); err != nil {
+				return nil, errTemplate(err)
+			}
+			dir := filepath.Clean(strings.TrimSpace(buf.String()))
+			if filepath.IsAbs(dir) || dir == ".." || strings.HasPrefix(dir, "../") {
+				return nil, util.NewError(http.StatusUnprocessableEntity, util.CodeTemplateInvalid,
+					"docroot must be a directory under the site directory")
+			}
+			p.DocRoot = dir
+		}
+	}
+	return p, nil
+}
+
+// templateFields collects the names of the data fields a template refers to
+// (.Name or $.Name).
+func templateFields(node parse.Node, fields map[string]bool) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, c := range n.Nodes {
+			templateFields(c, fields)
+		}
+	case *parse.ActionNode:
+		templateFields(n.Pipe, fields)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for _, c := range n.Cmds {
+			templateFields(c, fields)
+		}
+	case *parse.CommandNode:
+		for _, a := range n.Args {
+			templateFields(a, fields)
+		}
+	case *parse.FieldNode:
+		fields[n.Ident[0]] = true
+	case *parse.VariableNode:
+		if len(n.Ident) > 1 && n.Ident[0] == "$" {
+			fields[n.Ident[1]] = true
+		}
+	case *parse.ChainNode:
+		templateFields(n.Node, fields)
+	case *parse.IfNode:
+		templateBranchFields(&n.BranchNode, fields)
+	case *parse.RangeNode:
+		templateBranchFields(&n.BranchNode, fields)
+	case *parse.WithNode:
+		templateBranchFields(&n.BranchNode, fields)
+	case *parse.TemplateNode:
+		templateFields(n.Pipe, fields)
+	}
+}
+
+func templateBranchFields(n *parse.BranchNode, fields map[string]bool) {
+	templateFields(n.Pipe, fields)
+	templateFields(n.List, fields)
+	templateFields(n.ElseList, fields)
+}
+
+// render executes the profile's template with data.
+func (p *vhostProfile) render(data vhostTemplateData) (string, error) {
+	var buf bytes.Buffer
+	if err := p.tmpl.Execute(&buf, data); err != nil {
+		return "", errTemplate(err)
+	}
+	return buf.String(), nil
+}
+
+// checkVhostProfile renders p with sample values and checks the result with
+// nginx -t, using a throwaway nginx.conf that includes just this server next
+// to the real configuration directory's snippets and parameter files.
+func checkVhostProfile(ctx context.Context, p *vhostProfile) error {
+	docroot := ""
+	if p.DocRoot != "" {
+		docroot = filepath.Join(webRoot, "example.com", p.DocRoot)
+	}
+	proxy, err := proxyOptions{Upstreams: []string{"127.0.0.1:3000"}, HealthPath: "/healthz"}.resolve("example.com")
+	if err != nil {
+		return err
+	}
+	conf, err := p.render(newVhostTemplateData("example.com", docroot, settings.PHP.DefaultVersion, proxy))
+	if err != nil {
+		return err
+	}
+
+	dir, err := os.MkdirTemp("", "blogron-nginx-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	// Relative includes (snippets/fastcgi-php.conf, fastcgi_params, ...)
+	// resolve against the directory of nginx.conf.
+	nginxDir := filepath.Dir(nginxSitesAvailable)
+	entries, _ := os.ReadDir(nginxDir)
+	for _, e := range entries {
+		switch e.Name() {
+		case "nginx.conf", filepath.Base(nginxSitesAvailable), filepath.Base(nginxSitesEnabled):
+			continue
+		}
+		os.Symlink(filepath.Join(nginxDir, e.Name()), filepath.Join(dir, e.Name()))
+	}
+
+	site := filepath.Join(dir, "site.conf")
+	nginxConf := fmt.Sprintf(`include %[1]s/modules-enabled/*.conf;
+pid %[1]s/nginx.pid;
+error_log %[1]s/error.log;
+events {}
+http {
+    include %[2]s;
+}
+`, dir, site)
+	if err := os.WriteFile(site, []byte(conf), 0644); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, "nginx.conf"), []byte(nginxConf), 0644); err != nil {
+		return err
+	}
+	if _, err := runCmd(ctx, "nginx", "-t", "-c", filepath.Join(dir, "nginx.conf")); err != nil {
+		return fmt.Errorf("nginx config test failed: %w", err)
+	}
+	return nil
+}
+
+func errTemplate(err error) *util.Error {
+	return &util.Error{Status: http.StatusUnprocessableEntity, Code: util.CodeTemplateInvalid,
+		Message: "invalid template: " + err.Error(), Err: err}
+}
@@ -0,0 +1,157 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"blogron/util"
+)
+
+// proxyOptions are the reverse-proxy settings of a vhost created from a
+// profile that proxies (e.g. "proxy"). Zero values take the defaults.
+type proxyOptions struct {
+	Upstreams      []string `json:"upstreams"`       // port, host:port or unix:/path each
+	Balance        string   `json:"balance"`         // round_robin (default), least_conn, ip_hash or random
+	WebSocket      *bool    `json:"websocket"`       // pass Upgrade/Connection through; default true
+	ConnectTimeout int      `json:"connect_timeout"` // seconds, default 5
+	SendTimeout    int      `json:"send_timeout"`    // seconds, default 60
+	ReadTimeout    int      `json:"read_timeout"`    // seconds, default 60
+	MaxFails       int      `json:"max_fails"`       // failed attempts before an upstream is skipped, default 3
+	FailTimeout    int      `json:"fail_timeout"`    // seconds a failed upstream is skipped, default 10
+	HealthPath     string   `json:"health_path"`     // e.g. /healthz: not logged and not retried
+}
+
+// vhostProxy is the checked form of proxyOptions that templates render with.
+type vhostProxy struct {
+	Name           string // upstream block name, unique per domain
+	Upstreams      []string
+	Balance        string // the nginx directive; empty for round robin
+	WebSocket      bool
+	ConnectTimeout int
+	SendTimeout    int
+	ReadTimeout    int
+	MaxFails       int
+	FailTimeout    int
+	HealthPath     string
+}
+
+var (
+	balanceMethods = map[string]string{
+		"":            "",
+		"round_robin": "",
+		"least_conn":  "least_conn",
+		"ip_hash":     "ip_hash",
+		"random":      "random two least_conn",
+	}
+	healthPathRe = regexp.MustCompile(`^/[A-Za-z0-9._~/-]*$`)
+)
+
+// resolve checks o and fills in the defaults for a vhost of domain.
+func (o proxyOptions) resolve(domain string) (vhostProxy, error) {
+	px := vhostProxy{
+		Name:           upstreamName(domain),
+		WebSocket:      o.WebSocket == nil || *o.WebSocket,
+		ConnectTimeout: orDefault(o.ConnectTimeout, 5),
+		SendTimeout:    orDefault(o.SendTimeout, 60),
+		ReadTimeout:    orDefault(o.ReadTimeout, 60),
+		MaxFails:       orDefault(o.MaxFails, 3),
+		FailTimeout:    orDefault(o.FailTimeout, 10),
+		HealthPath:     o.HealthPath,
+	}
+	fields := map[string]string{}
+
+	if len(o.Upstreams) == 0 {
+		fields["proxy.upstreams"] = "at least one upstream is required"
+	}
+	seen := map[string]bool{}
+	for _, u := range o.Upstreams {
+		target, err := normalizeUpstream(u)
+		if err != nil {
+			fields["proxy.upstreams"] = fmt.Sprintf("%q: %v", u, err)
+			continue
+		}
+		if !seen[target] {
+			seen[target] = true
+			px.Upstreams = append(px.Upstreams, target)
+		}
+	}
+
+	balance, ok := balanceMethods[o.Balance]
+	if !ok {
+		fields["proxy.balance"] = "must be round_robin, least_conn, ip_hash or random"
+	}
+	px.Balance = balance
+
+	checkRange := func(field string, v, lo, hi int) {
+		if v < lo || v > hi {
+			fields["proxy."+field] = fmt.Sprintf("must be between %d and %d", lo, hi)
+		}
+	}
+	checkRange("connect_timeout", px.ConnectTimeout, 1, 75)
+	checkRange("send_timeout", px.SendTimeout, 1, 3600)
+	checkRange("read_timeout", px.ReadTimeout, 1, 3600)
+	checkRange("max_fails", px.MaxFails, 1, 100)
+	checkRange("fail_timeout", px.FailTimeout, 1, 3600)
+
+	if px.HealthPath != "" && !healthPathRe.MatchString(px.HealthPath) {
+		fields["proxy.health_path"] = "must be a path like /healthz"
+	}
+
+	if len(fields) > 0 {
+		return vhostProxy{}, util.InvalidFields("invalid proxy settings", fields)
+	}
+	return px, nil
+}
+
+// upstreamName names the upstream block of domain: "example_com_a379a6f6".
+// Upstream names are global in nginx, and my-site.com and my.site.com read
+// the same once dots and dashes are replaced, so a short hash of the domain
+// keeps them apart.
+func upstreamName(domain string) string {
+	sum := sha256.Sum256([]byte(domain))
+	return strings.NewReplacer(".", "_", "-", "_").Replace(domain) + "_" + hex.EncodeToString(sum[:4])
+}
+
+func orDefault(v, def int) int {
+	if v == 0 {
+		return def
+	}
+	return v
+}
+
+// normalizeUpstream checks a proxy target and returns it as nginx expects it
+// in proxy_pass or an upstream block: "3000" is 127.0.0.1:3000, "host:port"
+// is kept and "unix:/path" must name an absolute socket path.
+func normalizeUpstream(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if path, ok := strings.CutPrefix(s, "unix:"); ok {
+		if !filepath.IsAbs(path) || strings.ContainsAny(path, " \t;{}'\"$:") {
+			return "", errors.New("unix socket must be an absolute path")
+		}
+		return "unix:" + filepath.Clean(path), nil
+	}
+	host, port := "127.0.0.1", s
+	if strings.Contains(s, ":") {
+		var err error
+		if host, port, err = net.SplitHostPort(s); err != nil {
+			return "", errors.New("must be a port, host:port or unix:/path")
+		}
+		if host == "" {
+			host = "127.0.0.1"
+		}
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+		return "", errors.New("invalid port")
+	}
+	if net.ParseIP(host) == nil && util.Sanitize(host) != host {
+		return "", errors.New("invalid host")
+	}
+	return net.JoinHostPort(host, port), nil
+}
@@ -0,0 +1,12 @@
+package api
+
+import "testing"
+
+func TestUpstreamName(t *testing.T) {
+	if got, want := upstreamName("example.com"), "example_com_a379a6f6"; got != want {
+		t.Errorf("upstreamName(example.com) = %q, want %q", got, want)
+	}
+	if upstreamName("my-site.com") == upstreamName("my.site.com") {
+		t.Errorf("my-site.com and my.site.com share upstream %q", upstreamName("my.site.com"))
+	}
+}
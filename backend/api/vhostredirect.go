@@ -0,0 +1,175 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"blogron/store"
+	"blogron/util"
+)
+
+// redirectRule sends requests for a path, or for paths matching a regex,
+// to another path or URL.
+type redirectRule struct {
+	From  string `json:"from"`  // exact path like /old, or a PCRE like ^/blog/(.*)$ with regex
+	To    string `json:"to"`    // path or http(s) URL; $1... refer to regex captures
+	Regex bool   `json:"regex"` // From is a regex instead of an exact path
+	Code  int    `json:"code"`  // 301 (default) or 302
+}
+
+// Canonical host names: redirect the other of example.com and
+// www.example.com to this one.
+const (
+	canonicalWWW   = "www"
+	canonicalNoWWW = "non-www"
+)
+
+// Redirects live between these lines at the top of every server block of
+// the domain, so they run before anything else the block does.
+const (
+	redirectsBegin = "# BEGIN redirects (managed by BLOGRON)"
+	redirectsEnd   = "# END redirects"
+)
+
+var redirectTargetRe = regexp.MustCompile(`^(/|https?://)[^\s"'\\;{}]*$`)
+
+// validateRedirects checks redirect rules and a canonical setting, adding
+// problems to fields.
+func validateRedirects(canonical *string, rules *[]redirectRule, fields map[string]string) {
+	if canonical != nil {
+		switch *canonical {
+		case "", canonicalWWW, canonicalNoWWW:
+		default:
+			fields["canonical"] = `must be "www", "non-www" or ""`
+		}
+	}
+	if rules == nil {
+		return
+	}
+	for i, rule := range *rules {
+		key := fmt.Sprintf("redirects[%d]", i)
+		switch {
+		case rule.From == "":
+			fields[key+".from"] = "is required"
+		case strings.ContainsAny(rule.From, "\"\r\n"):
+			fields[key+".from"] = "must not contain quotes or line breaks"
+		case !rule.Regex && (!strings.HasPrefix(rule.From, "/") || strings.ContainsAny(rule.From, " \t")):
+			fields[key+".from"] = "must be a path like /old"
+		}
+		if !redirectTargetRe.MatchString(rule.To) {
+			fields[key+".to"] = "must be a path or an http(s) URL"
+		}
+		if rule.Code != 0 && rule.Code != 301 && rule.Code != 302 {
+			fields[key+".code"] = "must be 301 or 302"
+		}
+	}
+}
+
+// redirectLines renders the redirect section for domain: the canonical
+// host first so a request needs one hop, then HTTPS, then the rules.
+func redirectLines(domain string, forceHTTPS bool, canonical string, rules []redirectRule) []string {
+	var lines []string
+	scheme := "$scheme"
+	if forceHTTPS {
+		scheme = "https"
+	}
+	switch canonical {
+	case canonicalWWW:
+		lines = append(lines,
+			fmt.Sprintf("if ($host = %s) {", domain),
+			fmt.Sprintf("    return 301 %s://www.%s$request_uri;", scheme, domain),
+			"}")
+	case canonicalNoWWW:
+		lines = append(lines,
+			fmt.Sprintf("if ($host = www.%s) {", domain),
+			fmt.Sprintf("    return 301 %s://%s$request_uri;", scheme, domain),
+			"}")
+	}
+	if forceHTTPS {
+		lines = append(lines,
+			"if ($scheme = http) {",
+			"    return 301 https://$host$request_uri;",
+			"}")
+	}
+	for _, rule := range rules {
+		from := rule.From
+		if !rule.Regex {
+			from = "^" + regexp.QuoteMeta(from) + "$"
+		}
+		flag := "permanent"
+		if rule.Code == 302 {
+			flag = "redirect"
+		}
+		lines = append(lines, fmt.Sprintf("rewrite %s %s %s;", quoteArg(from), quoteArg(rule.To), flag))
+	}
+	return lines
+}
+
+// quoteArg quotes a directive argument for nginx, which unescapes \\ and
+// \" in quoted strings.
+func quoteArg(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// hasRedirects reports whether req changes the redirect section.
+func (req *updateVhostRequest) hasRedirects() bool {
+	return req.ForceHTTPS != nil || req.Canonical != nil || req.Redirects != nil
+}
+
+// fillRedirects takes the redirect settings req leaves out from what was
+// recorded for the vhost, since they are rendered together.
+func (req *updateVhostRequest) fillRedirects(meta map[string]string) {
+	if req.ForceHTTPS == nil {
+		force := meta["force_https"] == "true"
+		req.ForceHTTPS = &force
+	}
+	if req.Canonical == nil {
+		canonical := meta["canonical"]
+		req.Canonical = &canonical
+	}
+	if req.Redirects == nil {
+		rules := recordedRedirects(meta)
+		req.Redirects = &rules
+	}
+}
+
+// recordRedirects stores the redirect settings of req in res.
+func recordRedirects(res *store.Resource, req updateVhostRequest) {
+	if !req.hasRedirects() {
+		return
+	}
+	setMeta(res, "force_https", fmt.Sprint(req.ForceHTTPS != nil && *req.ForceHTTPS))
+	if req.Canonical != nil {
+		setMeta(res, "canonical", *req.Canonical)
+	}
+	if req.Redirects != nil {
+		data, _ := json.Marshal(*req.Redirects)
+		setMeta(res, "redirects", string(data))
+	}
+}
+
+func recordedRedirects(meta map[string]string) []redirectRule {
+	var rules []redirectRule
+	if s := meta["redirects"]; s != "" {
+		if err := json.Unmarshal([]byte(s), &rules); err != nil {
+			return nil
+		}
+	}
+	return rules
+}
+
+// checkCanonical makes sure both host names a canonical setting redirects
+// between are served by the vhost.
+func checkCanonical(domain, canonical string, aliases []string) error {
+	if canonical == "" {
+		return nil
+	}
+	for _, a := range aliases {
+		if a == "www."+domain {
+			return nil
+		}
+	}
+	return util.Invalid("canonical", "www."+domain+" must be an alias of the vhost")
+}
@@ -1,6 +1,7 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -8,49 +9,74 @@ import (
 	"path/filepath"
 	"strings"
 
+	"blogron/config"
+	"blogron/store"
 	"blogron/util"
 )
 
-const (
+// Set from the paths section of panel.yaml (see Configure).
+var (
 	nginxSitesAvailable = "/etc/nginx/sites-available"
 	nginxSitesEnabled   = "/etc/nginx/sites-enabled"
 	webRoot             = "/var/www"
 )
 
 type Vhost struct {
-	Domain  string `json:"domain"`
-	DocRoot string `json:"docroot"`
-	SSL     bool   `json:"ssl"`
-	Enabled bool   `json:"enabled"`
-	PHP     string `json:"php"`
-	IP      string `json:"ip"`
+	Domain         string   `json:"domain"`
+	DocRoot        string   `json:"docroot"`
+	SSL            bool     `json:"ssl"`
+	Enabled        bool     `json:"enabled"`
+	PHP            string   `json:"php"`
+	IP             string   `json:"ip"`
+	Profile        string   `json:"profile,omitempty"`
+	ServerNames    []string `json:"server_names"`
+	Aliases        []string `json:"aliases,omitempty"` // server names besides the domain
+	Ports          []int    `json:"ports"`
+	PHPSocket      string   `json:"php_socket,omitempty"`
+	Upstreams      []string `json:"upstreams,omitempty"` // proxy targets
+	Certificate    string   `json:"ssl_certificate,omitempty"`
+	CertificateKey string   `json:"ssl_certificate_key,omitempty"`
+	File           string   `json:"file"`
+	Managed        bool     `json:"managed"`         // created or adopted by the panel
+	Error          string   `json:"error,omitempty"` // why the config could not be read
+
+	ForceHTTPS bool           `json:"force_https"`
+	Canonical  string         `json:"canonical,omitempty"` // "www" or "non-www"
+	Redirects  []redirectRule `json:"redirects,omitempty"`
 }
 
 type createVhostRequest struct {
-	Domain  string `json:"domain"`
-	DocRoot string `json:"docroot"`
-	PHP     string `json:"php"`
-	SSL     bool   `json:"ssl"`
+	Domain   string       `json:"domain"`
+	DocRoot  string       `json:"docroot"`
+	PHP      string       `json:"php"`
+	Owner    string       `json:"owner"`
+	Profile  string       `json:"profile"`  // see GET /api/vhosts/profiles; default "php"
+	Upstream string       `json:"upstream"` // shorthand for a single proxy.upstreams entry
+	Proxy    proxyOptions `json:"proxy"`    // for profiles that proxy
+
+	Aliases    []string       `json:"aliases"`     // server names besides the domain; default www.<domain>
+	Canonical  string         `json:"canonical"`   // "www" or "non-www" to redirect the other name
+	Redirects  []redirectRule `json:"redirects"`   // path or regex redirects, see PUT /api/vhosts/{domain}
+	ForceHTTPS bool           `json:"force_https"` // redirect to https:// once a certificate is issued
 }
 
 // ListVhosts godoc
 // GET /api/vhosts
 func ListVhosts(w http.ResponseWriter, r *http.Request) {
-	entries, err := os.ReadDir(nginxSitesAvailable)
+	files, err := vhostFiles()
 	if err != nil {
 		util.WriteError(w, http.StatusInternalServerError, "cannot read nginx sites-available")
 		return
 	}
 
 	var vhosts []Vhost
-	for _, e := range entries {
-		if e.IsDir() || !strings.HasSuffix(e.Name(), ".conf") {
+	for _, f := range files {
+		if !canAccess(r, kindVhost, f.domain) {
 			continue
 		}
-		domain := strings.TrimSuffix(e.Name(), ".conf")
-		vh := parseVhostConf(domain)
-		_, errStat := os.Stat(filepath.Join(nginxSitesEnabled, e.Name()))
-		vh.Enabled = errStat == nil
+		vh := readVhostConf(f.domain, f.path)
+		vh.Enabled = f.enabled
+		setVhostProfile(&vh)
 		vhosts = append(vhosts, vh)
 	}
 	util.WriteJSON(w, http.StatusOK, vhosts)
@@ -61,64 +87,298 @@ func ListVhosts(w http.ResponseWriter, r *http.Request) {
 func CreateVhost(w http.ResponseWriter, r *http.Request) {
 	var req createVhostRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		util.WriteError(w, http.StatusBadRequest, "invalid request body")
+		util.WriteErr(w, util.ErrInvalidBody)
 		return
 	}
 
 	domain := util.Sanitize(req.Domain)
 	if domain == "" {
-		util.WriteError(w, http.StatusBadRequest, "invalid domain")
+		util.WriteErr(w, util.Invalid("domain", "invalid domain"))
 		return
 	}
 
-	phpVersion := req.PHP
-	if phpVersion == "" {
-		phpVersion = os.Getenv("PHP_VERSION")
+	// Both end up in the config as is.
+	if req.PHP != "" && !config.ValidPHPVersion(req.PHP) {
+		util.WriteErr(w, util.Invalid("php", "must be a version like 8.2"))
+		return
 	}
-	if phpVersion == "" {
-		phpVersion = "8.2"
+	if req.DocRoot != "" && !validDocRoot(req.DocRoot) {
+		util.WriteErr(w, util.Invalid("docroot", "must be a clean absolute path"))
+		return
 	}
 
-	docroot := req.DocRoot
-	if docroot == "" {
-		docroot = fmt.Sprintf("%s/%s/public_html", webRoot, domain)
+	if req.Profile == "" {
+		req.Profile = defaultProfile
+	}
+	profile, err := loadVhostProfile(req.Profile)
+	if err != nil {
+		if util.ErrorFrom(err).Code == util.CodeProfileNotFound {
+			err = util.Invalid("profile", "unknown profile")
+		}
+		util.WriteErr(w, err)
+		return
 	}
 
-	// Write nginx config
+	phpVersion := req.PHP
+	if phpVersion == "" {
+		phpVersion = settings.PHP.DefaultVersion
+	}
+
+	// Creating never overwrites a site; changes go through PUT.
 	confPath := filepath.Join(nginxSitesAvailable, domain+".conf")
-	conf := buildNginxConfig(domain, docroot, phpVersion, req.SSL)
+	if _, err := os.Stat(confPath); err == nil {
+		util.WriteErr(w, util.NewError(http.StatusConflict, util.CodeVhostExists, "vhost already exists; change it with PUT /api/vhosts/"+domain))
+		return
+	}
+	if isScoped(r) {
+		// Site owners cannot point a docroot outside their own site
+		// directory or proxy to local services.
+		if req.Upstream != "" || len(req.Proxy.Upstreams) > 0 {
+			util.WriteErr(w, util.NewError(http.StatusForbidden, util.CodeForbidden, "site owners cannot set an upstream"))
+			return
+		}
+		if err := checkOwnerAliases(domain, req.Aliases); err != nil {
+			util.WriteErr(w, err)
+			return
+		}
+		req.DocRoot = ""
+	}
 
-	if err := os.WriteFile(confPath, []byte(conf), 0644); err != nil {
-		util.WriteError(w, http.StatusInternalServerError, "failed to write nginx config: "+err.Error())
+	// Names and redirects are applied to the rendered config as PUT would.
+	edits := updateVhostRequest{}
+	if req.Aliases != nil {
+		edits.Aliases = &req.Aliases
+	}
+	if req.Canonical != "" {
+		edits.Canonical = &req.Canonical
+	}
+	if len(req.Redirects) > 0 {
+		edits.Redirects = &req.Redirects
+	}
+	if req.ForceHTTPS {
+		edits.ForceHTTPS = &req.ForceHTTPS
+	}
+	if err := edits.validate(domain); err != nil {
+		util.WriteErr(w, err)
+		return
+	}
+	aliases := []string{"www." + domain}
+	if req.Aliases != nil {
+		aliases = req.Aliases
+	}
+	if err := checkCanonical(domain, req.Canonical, aliases); err != nil {
+		util.WriteErr(w, err)
 		return
 	}
 
-	// Create document root
-	util.RunCmd("mkdir", "-p", docroot)
-	util.RunCmd("chown", "www-data:www-data", docroot)
+	var proxy vhostProxy
+	if profile.Upstream {
+		if req.Upstream != "" {
+			req.Proxy.Upstreams = append([]string{req.Upstream}, req.Proxy.Upstreams...)
+		}
+		if proxy, err = req.Proxy.resolve(domain); err != nil {
+			util.WriteErr(w, err)
+			return
+		}
+	}
 
-	// Enable by default
-	symlink := filepath.Join(nginxSitesEnabled, domain+".conf")
-	os.Symlink(confPath, symlink) // nolint: ignore if already exists
+	docroot := ""
+	if profile.DocRoot != "" {
+		docroot = req.DocRoot
+		if docroot == "" {
+			docroot = filepath.Join(webRoot, domain, profile.DocRoot)
+		}
+	}
+	// A new vhost has no certificate yet, so force_https is only recorded;
+	// EnableSSL applies it.
+	render := edits
+	render.ForceHTTPS = nil
+	conf, err := profile.render(newVhostTemplateData(domain, docroot, phpVersion, proxy))
+	if err == nil {
+		conf, err = editVhostConf(conf, domain, render)
+	}
+	if err != nil {
+		util.WriteErr(w, err)
+		return
+	}
 
-	// Test and reload nginx
-	if _, err := util.RunCmd("nginx", "-t"); err != nil {
-		os.Remove(confPath)
-		os.Remove(symlink)
-		util.WriteError(w, http.StatusInternalServerError, "nginx config test failed: "+err.Error())
+	p := newProvision(r.Context())
+	if docroot != "" { // proxies serve no files
+		err = p.StepUndo("create docroot", func(ctx context.Context) (undoFunc, error) {
+			return createDocRoot(ctx, docroot)
+		})
+	}
+	if err == nil {
+		// Written, enabled and checked with nginx -t
+		err = p.StepUndo("write nginx config", func(ctx context.Context) (undoFunc, error) {
+			return installVhostConf(ctx, domain, conf)
+		})
+	}
+	if err == nil {
+		err = p.Step("reload nginx", func(ctx context.Context) error {
+			_, err := runCmd(ctx, "systemctl", "reload", "nginx")
+			return err
+		}, nil)
+	}
+	if err != nil {
+		writeProvisionError(w, err)
 		return
 	}
-	util.RunCmd("systemctl", "reload", "nginx")
 
+	owner := ownerForCreate(r, req.Owner)
+	recordResource(kindVhost, domain, func(res *store.Resource) {
+		res.Owner = owner
+		if profile.PHP {
+			res.PHPVersion = phpVersion
+		}
+		setMeta(res, "profile", profile.Name)
+		if docroot != "" {
+			setMeta(res, "docroot", docroot)
+		}
+		if len(proxy.Upstreams) > 0 {
+			setMeta(res, "upstream", strings.Join(proxy.Upstreams, ","))
+		}
+		if req.Aliases != nil {
+			setMeta(res, "aliases", strings.Join(req.Aliases, ","))
+		}
+		recordRedirects(res, edits)
+	})
 	util.WriteJSON(w, http.StatusCreated, map[string]string{"status": "created", "domain": domain})
 }
 
+// UpdateVhost godoc
+// PUT /api/vhosts/{domain}
+func UpdateVhost(w http.ResponseWriter, r *http.Request) {
+	domain := util.Sanitize(chi_urlParam(r, "domain"))
+	if domain == "" {
+		util.WriteErr(w, util.Invalid("domain", "invalid domain"))
+		return
+	}
+	if !requireOwner(w, r, kindVhost, domain) {
+		return
+	}
+	var req updateVhostRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		util.WriteErr(w, util.ErrInvalidBody)
+		return
+	}
+	if err := req.validate(domain); err != nil {
+		util.WriteErr(w, err)
+		return
+	}
+	if isScoped(r) {
+		// Same limits as on create: no docroot outside the site directory,
+		// no names of other sites, no raw nginx directives.
+		if req.DocRoot != nil && !strings.HasPrefix(*req.DocRoot, filepath.Join(webRoot, domain)+"/") {
+			util.WriteErr(w, errPath("docroot must be inside "+filepath.Join(webRoot, domain)))
+			return
+		}
+		if req.Aliases != nil {
+			if err := checkOwnerAliases(domain, *req.Aliases); err != nil {
+				util.WriteErr(w, err)
+				return
+			}
+		}
+		if req.Directives != nil {
+			util.WriteErr(w, util.NewError(http.StatusForbidden, util.CodeForbidden, "site owners cannot set nginx directives"))
+			return
+		}
+	}
+
+	confPath := filepath.Join(nginxSitesAvailable, domain+".conf")
+	data, err := os.ReadFile(confPath)
+	if os.IsNotExist(err) {
+		util.WriteErr(w, errVhostNotFound)
+		return
+	}
+	if err != nil {
+		util.WriteErr(w, util.Wrap(err, "cannot read nginx config"))
+		return
+	}
+	if req.hasRedirects() || req.Aliases != nil {
+		res, _, _ := resources.Get(kindVhost, domain)
+		current := loadVhost(domain)
+		if req.ForceHTTPS != nil && *req.ForceHTTPS && !current.SSL {
+			util.WriteErr(w, util.NewError(http.StatusConflict, util.CodeConflict, "vhost has no SSL certificate; issue one first"))
+			return
+		}
+		aliases := current.Aliases
+		if req.Aliases != nil {
+			aliases = *req.Aliases
+		}
+		canonical := res.Meta["canonical"]
+		if req.Canonical != nil {
+			canonical = *req.Canonical
+		}
+		if err := checkCanonical(domain, canonical, aliases); err != nil {
+			util.WriteErr(w, err)
+			return
+		}
+		if req.hasRedirects() {
+			req.fillRedirects(res.Meta)
+		}
+	}
+	conf, err := editVhostConf(string(data), domain, req)
+	if err != nil {
+		util.WriteErr(w, err)
+		return
+	}
+
+	p := newProvision(r.Context())
+	if req.DocRoot != nil {
+		if _, statErr := os.Stat(*req.DocRoot); os.IsNotExist(statErr) {
+			err = p.StepUndo("create docroot", func(ctx context.Context) (undoFunc, error) {
+				return createDocRoot(ctx, *req.DocRoot)
+			})
+		}
+	}
+	if err == nil {
+		// Checked with nginx -t; the previous file is put back if it fails.
+		err = p.StepUndo("write nginx config", func(ctx context.Context) (undoFunc, error) {
+			symlink := filepath.Join(nginxSitesEnabled, domain+".conf")
+			_, linkErr := os.Lstat(symlink)
+			undo, err := installVhostConf(ctx, domain, conf)
+			if err == nil && linkErr != nil {
+				os.Remove(symlink) // tested enabled, but stays disabled
+			}
+			return undo, err
+		})
+	}
+	if err == nil {
+		err = p.Step("reload nginx", func(ctx context.Context) error {
+			_, err := runCmd(ctx, "systemctl", "reload", "nginx")
+			return err
+		}, nil)
+	}
+	if err != nil {
+		writeProvisionError(w, err)
+		return
+	}
+
+	recordResource(kindVhost, domain, func(res *store.Resource) {
+		if req.DocRoot != nil {
+			setMeta(res, "docroot", *req.DocRoot)
+		}
+		if req.PHP != nil {
+			res.PHPVersion = *req.PHP
+		}
+		if req.Aliases != nil {
+			setMeta(res, "aliases", strings.Join(*req.Aliases, ","))
+		}
+		recordRedirects(res, req)
+	})
+	util.WriteJSON(w, http.StatusOK, loadVhost(domain))
+}
+
 // DeleteVhost godoc
 // DELETE /api/vhosts/{domain}
 func DeleteVhost(w http.ResponseWriter, r *http.Request) {
 	domain := util.Sanitize(chi_urlParam(r, "domain"))
 	if domain == "" {
-		util.WriteError(w, http.StatusBadRequest, "invalid domain")
+		util.WriteErr(w, util.Invalid("domain", "invalid domain"))
+		return
+	}
+	if !requireOwner(w, r, kindVhost, domain) {
 		return
 	}
 
@@ -126,7 +386,8 @@ func DeleteVhost(w http.ResponseWriter, r *http.Request) {
 	os.Remove(filepath.Join(nginxSitesEnabled, confFile))
 	os.Remove(filepath.Join(nginxSitesAvailable, confFile))
 
-	util.RunCmd("systemctl", "reload", "nginx")
+	runCmd(r.Context(), "systemctl", "reload", "nginx")
+	forgetResource(kindVhost, domain)
 	util.WriteJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
 }
 
@@ -134,17 +395,20 @@ func DeleteVhost(w http.ResponseWriter, r *http.Request) {
 // POST /api/vhosts/{domain}/enable
 func EnableVhost(w http.ResponseWriter, r *http.Request) {
 	domain := util.Sanitize(chi_urlParam(r, "domain"))
+	if !requireOwner(w, r, kindVhost, domain) {
+		return
+	}
 	confFile := domain + ".conf"
 	src := filepath.Join(nginxSitesAvailable, confFile)
 	dst := filepath.Join(nginxSitesEnabled, confFile)
 
 	if _, err := os.Stat(src); os.IsNotExist(err) {
-		util.WriteError(w, http.StatusNotFound, "vhost not found")
+		util.WriteErr(w, errVhostNotFound)
 		return
 	}
 
 	os.Symlink(src, dst)
-	util.RunCmd("systemctl", "reload", "nginx")
+	runCmd(r.Context(), "systemctl", "reload", "nginx")
 	util.WriteJSON(w, http.StatusOK, map[string]string{"status": "enabled"})
 }
 
@@ -152,9 +416,12 @@ func EnableVhost(w http.ResponseWriter, r *http.Request) {
 // POST /api/vhosts/{domain}/disable
 func DisableVhost(w http.ResponseWriter, r *http.Request) {
 	domain := util.Sanitize(chi_urlParam(r, "domain"))
+	if !requireOwner(w, r, kindVhost, domain) {
+		return
+	}
 	symlink := filepath.Join(nginxSitesEnabled, domain+".conf")
 	os.Remove(symlink)
-	util.RunCmd("systemctl", "reload", "nginx")
+	runCmd(r.Context(), "systemctl", "reload", "nginx")
 	util.WriteJSON(w, http.StatusOK, map[string]string{"status": "disabled"})
 }
 
@@ -163,7 +430,10 @@ func DisableVhost(w http.ResponseWriter, r *http.Request) {
 func EnableSSL(w http.ResponseWriter, r *http.Request) {
 	domain := util.Sanitize(chi_urlParam(r, "domain"))
 	if domain == "" {
-		util.WriteError(w, http.StatusBadRequest, "invalid domain")
+		util.WriteErr(w, util.Invalid("domain", "invalid domain"))
+		return
+	}
+	if !requireOwner(w, r, kindVhost, domain) {
 		return
 	}
 
@@ -180,76 +450,160 @@ func EnableSSL(w http.ResponseWriter, r *http.Request) {
 		args = append(args, "--register-unsafely-without-email")
 	}
 
-	if _, err := util.RunCmd("certbot", args...); err != nil {
-		util.WriteError(w, http.StatusInternalServerError, "certbot failed: "+err.Error())
-		return
-	}
-
-	util.WriteJSON(w, http.StatusOK, map[string]string{"status": "ssl_enabled", "domain": domain})
+	writeJobAccepted(w, r, "vhost.ssl", domain, func(j *jobRun) error {
+		j.Step("certbot")
+		_, err := runCmd(j.ctx, "certbot", args...)
+		if err != nil {
+			return fmt.Errorf("certbot failed: %w", err)
+		}
+		// certbot adds a server block for port 80 without the redirects,
+		// and force_https can only work now.
+		j.Step("apply redirects")
+		return applyRecordedRedirects(j.ctx, domain)
+	})
 }
 
 // ── helpers ───────────────────────────────────────────────────────────────────
 
-func buildNginxConfig(domain, docroot, phpVersion string, ssl bool) string {
-	phpSocket := fmt.Sprintf("/run/php/php%s-fpm.sock", phpVersion)
-
-	return fmt.Sprintf(`server {
-    listen 80;
-    listen [::]:80;
-
-    server_name %s www.%s;
-    root %s;
-    index index.php index.html index.htm;
-
-    access_log /var/log/nginx/%s.access.log;
-    error_log  /var/log/nginx/%s.error.log;
+// loadVhost describes the vhost of domain from its config, enabled state
+// and recorded profile.
+func loadVhost(domain string) Vhost {
+	vh := parseVhostConf(domain)
+	setVhostProfile(&vh)
+	_, err := os.Stat(filepath.Join(nginxSitesEnabled, domain+".conf"))
+	vh.Enabled = err == nil
+	return vh
+}
 
-    location / {
-        try_files $uri $uri/ /index.php?$query_string;
-    }
+// setVhostProfile fills in what the panel recorded about vh.
+func setVhostProfile(vh *Vhost) {
+	if res, ok, _ := resources.Get(kindVhost, vh.Domain); ok {
+		vh.Managed = true
+		vh.Profile = res.Meta["profile"]
+		vh.ForceHTTPS = res.Meta["force_https"] == "true"
+		vh.Canonical = res.Meta["canonical"]
+		vh.Redirects = recordedRedirects(res.Meta)
+	}
+}
 
-    location ~ \.php$ {
-        include snippets/fastcgi-php.conf;
-        fastcgi_pass unix:%s;
-    }
+// applyRecordedRedirects renders the redirect settings recorded for domain
+// into every server block of its config again, checks it with nginx -t and
+// reloads nginx.
+func applyRecordedRedirects(ctx context.Context, domain string) error {
+	res, ok, _ := resources.Get(kindVhost, domain)
+	if !ok || (res.Meta["force_https"] != "true" && res.Meta["canonical"] == "" && len(recordedRedirects(res.Meta)) == 0) {
+		return nil
+	}
+	data, err := os.ReadFile(filepath.Join(nginxSitesAvailable, domain+".conf"))
+	if err != nil {
+		return util.Wrap(err, "cannot read nginx config")
+	}
+	var req updateVhostRequest
+	req.fillRedirects(res.Meta)
+	conf, err := editVhostConf(string(data), domain, req)
+	if err != nil {
+		return err
+	}
+	if _, err := installVhostConf(ctx, domain, conf); err != nil {
+		return err
+	}
+	_, err = runCmd(ctx, "systemctl", "reload", "nginx")
+	return err
+}
 
-    location ~ /\.ht {
-        deny all;
-    }
+// checkOwnerAliases keeps site owners to server names under their own
+// domain that no other vhost serves yet, so a subdomain that is a site of
+// its own cannot be pulled into theirs.
+func checkOwnerAliases(domain string, aliases []string) error {
+	for _, a := range aliases {
+		if !strings.HasSuffix(a, "."+domain) {
+			return util.NewError(http.StatusForbidden, util.CodeForbidden, "aliases must be subdomains of "+domain)
+		}
+	}
+	taken := otherVhostNames(domain)
+	for _, a := range aliases {
+		if other, ok := taken[strings.ToLower(a)]; ok {
+			return util.NewError(http.StatusConflict, util.CodeConflict, a+" is already served by vhost "+other)
+		}
+	}
+	return nil
+}
 
-    # Security headers
-    add_header X-Frame-Options "SAMEORIGIN" always;
-    add_header X-Content-Type-Options "nosniff" always;
-    add_header Referrer-Policy "no-referrer-when-downgrade" always;
+// otherVhostNames maps the domains and server names of every vhost but
+// domain, as found in the site configs and in the resource store, to the
+// vhost they belong to.
+func otherVhostNames(domain string) map[string]string {
+	names := map[string]string{}
+	add := func(name, owner string) {
+		if owner != domain && name != "" && name != "_" {
+			names[strings.ToLower(name)] = owner
+		}
+	}
+	files, _ := vhostFiles()
+	for _, f := range files {
+		add(f.domain, f.domain)
+		for _, name := range readVhostConf(f.domain, f.path).ServerNames {
+			add(name, f.domain)
+		}
+	}
+	recorded, _ := resources.List(store.Filter{Kind: kindVhost})
+	for _, res := range recorded {
+		add(res.Name, res.Name)
+	}
+	return names
 }
-`, domain, domain, docroot, domain, domain, phpSocket)
+
+// createDocRoot creates docroot owned by www-data. The returned undo
+// removes the directories it created.
+func createDocRoot(ctx context.Context, docroot string) (undoFunc, error) {
+	floor := webRoot
+	if !strings.HasPrefix(docroot, webRoot+"/") {
+		floor = filepath.Dir(docroot)
+	}
+	undo := removeCreatedDir(missingRoot(docroot, floor))
+	if _, err := runCmd(ctx, "mkdir", "-p", docroot); err != nil {
+		return nil, cleanUp(ctx, undo, err)
+	}
+	_, err := runCmd(ctx, "chown", "www-data:www-data", docroot)
+	return undo, cleanUp(ctx, undo, err)
 }
 
-func parseVhostConf(domain string) Vhost {
-	vh := Vhost{Domain: domain}
+// installVhostConf writes and enables the nginx config for domain and checks
+// it with nginx -t, putting back whatever was there before if the check
+// fails. The returned undo does the same (and reloads nginx) later on.
+func installVhostConf(ctx context.Context, domain, conf string) (undoFunc, error) {
 	confPath := filepath.Join(nginxSitesAvailable, domain+".conf")
-	data, err := os.ReadFile(confPath)
-	if err != nil {
-		return vh
-	}
-	content := string(data)
-	for _, line := range strings.Split(content, "\n") {
-		line = strings.TrimSpace(line)
-		if strings.HasPrefix(line, "root ") {
-			vh.DocRoot = strings.TrimSuffix(strings.TrimPrefix(line, "root "), ";")
-		}
-		if strings.Contains(line, "ssl_certificate") {
-			vh.SSL = true
-		}
-		if strings.Contains(line, "php") && strings.Contains(line, "fpm") {
-			// Extract PHP version from socket path e.g. php8.2-fpm
-			for _, v := range []string{"8.3", "8.2", "8.1", "8.0", "7.4"} {
-				if strings.Contains(line, "php"+v) {
-					vh.PHP = v
-					break
-				}
-			}
+	symlink := filepath.Join(nginxSitesEnabled, domain+".conf")
+	prev, prevErr := os.ReadFile(confPath)
+	_, linkErr := os.Lstat(symlink)
+
+	restore := func() error {
+		var err error
+		if prevErr == nil {
+			err = os.WriteFile(confPath, prev, 0644)
+		} else if rmErr := os.Remove(confPath); rmErr != nil && !os.IsNotExist(rmErr) {
+			err = rmErr
 		}
+		if linkErr != nil {
+			os.Remove(symlink)
+		}
+		return err
 	}
-	return vh
+
+	if err := os.WriteFile(confPath, []byte(conf), 0644); err != nil {
+		return nil, fmt.Errorf("failed to write nginx config: %w", err)
+	}
+	os.Symlink(confPath, symlink) // nolint: ignore if already exists
+	if _, err := runCmd(ctx, "nginx", "-t"); err != nil {
+		restore()
+		return nil, fmt.Errorf("nginx config test failed: %w", err)
+	}
+
+	return func(ctx context.Context) error {
+		if err := restore(); err != nil {
+			return err
+		}
+		_, err := runCmd(ctx, "systemctl", "reload", "nginx")
+		return err
+	}, nil
 }
@@ -0,0 +1,285 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"blogron/config"
+	"blogron/middleware"
+	"blogron/store"
+	"blogron/util"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// setupVhostTest points the api package at a temporary nginx and web root,
+// an empty resource store and a fake executor, and puts everything back
+// when the test ends.
+func setupVhostTest(t *testing.T) *util.FakeExecutor {
+	t.Helper()
+	dir := t.TempDir()
+	cfg := config.Default()
+	cfg.Paths.NginxSitesAvailable = filepath.Join(dir, "sites-available")
+	cfg.Paths.NginxSitesEnabled = filepath.Join(dir, "sites-enabled")
+	cfg.Paths.WebRoot = filepath.Join(dir, "www")
+	for _, d := range []string{cfg.Paths.NginxSitesAvailable, cfg.Paths.NginxSitesEnabled, cfg.Paths.WebRoot} {
+		if err := os.MkdirAll(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	prevSettings, prevExecutor, prevResources, prevDataDir := settings, executor, resources, util.DataDir()
+	t.Cleanup(func() {
+		Configure(prevSettings)
+		executor, resources = prevExecutor, prevResources
+		util.SetDataDir(prevDataDir)
+	})
+
+	Configure(cfg)
+	util.SetDataDir(dir)
+	resources = store.OpenFile()
+	fake := &util.FakeExecutor{}
+	SetExecutor(fake)
+	return fake
+}
+
+func postCreateVhost(t *testing.T, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/api/vhosts", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	CreateVhost(rec, req)
+	return rec
+}
+
+// commandLines returns the commands fake ran as "name arg1 arg2 ..." lines.
+func commandLines(fake *util.FakeExecutor) []string {
+	var lines []string
+	for _, c := range fake.Calls() {
+		lines = append(lines, strings.Join(append([]string{c.Name}, c.Args...), " "))
+	}
+	return lines
+}
+
+// withURLParam sets a chi URL parameter on r, as the router would.
+func withURLParam(r *http.Request, key, value string) *http.Request {
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add(key, value)
+	return r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+}
+
+func hasCommand(lines []string, prefix string) bool {
+	for _, l := range lines {
+		if strings.HasPrefix(l, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestCreateVhost(t *testing.T) {
+	fake := setupVhostTest(t)
+
+	rec := postCreateVhost(t, `{"domain":"example.com"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want 201; body: %s", rec.Code, rec.Body)
+	}
+
+	confPath := filepath.Join(nginxSitesAvailable, "example.com.conf")
+	conf, err := os.ReadFile(confPath)
+	if err != nil {
+		t.Fatalf("config not written: %v", err)
+	}
+	if !strings.Contains(string(conf), "server_name example.com") {
+		t.Errorf("config does not serve example.com:\n%s", conf)
+	}
+	if target, err := os.Readlink(filepath.Join(nginxSitesEnabled, "example.com.conf")); err != nil || target != confPath {
+		t.Errorf("config not enabled: %q, %v", target, err)
+	}
+
+	lines := commandLines(fake)
+	docroot := filepath.Join(webRoot, "example.com")
+	for _, want := range []string{"mkdir -p " + docroot, "nginx -t", "systemctl reload nginx"} {
+		if !hasCommand(lines, want) {
+			t.Errorf("%q not run; commands: %q", want, lines)
+		}
+	}
+
+	if _, ok, err := resources.Get(kindVhost, "example.com"); err != nil || !ok {
+		t.Errorf("vhost not recorded: %v, %v", ok, err)
+	}
+}
+
+func TestCreateVhostRefusesExisting(t *testing.T) {
+	setupVhostTest(t)
+	if rec := postCreateVhost(t, `{"domain":"example.com"}`); rec.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want 201; body: %s", rec.Code, rec.Body)
+	}
+	confPath := filepath.Join(nginxSitesAvailable, "example.com.conf")
+	before, _ := os.ReadFile(confPath)
+
+	// Admins get the same 409 as site owners instead of a rewritten config.
+	rec := postCreateVhost(t, `{"domain":"example.com","profile":"static"}`)
+	if rec.Code != http.StatusConflict || !strings.Contains(rec.Body.String(), util.CodeVhostExists) {
+		t.Fatalf("status = %d, want 409 %s; body: %s", rec.Code, util.CodeVhostExists, rec.Body)
+	}
+	if after, _ := os.ReadFile(confPath); string(after) != string(before) {
+		t.Errorf("config changed:\n%s", after)
+	}
+}
+
+func TestCreateVhostRollsBackWhenConfigTestFails(t *testing.T) {
+	fake := setupVhostTest(t)
+	fake.On("nginx -t", util.Result{Stderr: "nginx: [emerg] unknown directive", ExitCode: 1}, errors.New("exit status 1"))
+
+	rec := postCreateVhost(t, `{"domain":"example.com"}`)
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("status = %d, want 422; body: %s", rec.Code, rec.Body)
+	}
+
+	var body struct {
+		Code       string   `json:"code"`
+		Step       string   `json:"step"`
+		RolledBack []string `json:"rolled_back"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Code != util.CodeNginxConfigInvalid {
+		t.Errorf("code = %q, want %q", body.Code, util.CodeNginxConfigInvalid)
+	}
+	if body.Step != "write nginx config" {
+		t.Errorf("failed step = %q, want %q", body.Step, "write nginx config")
+	}
+	if len(body.RolledBack) != 1 || body.RolledBack[0] != "create docroot" {
+		t.Errorf("rolled back = %q, want [create docroot]", body.RolledBack)
+	}
+
+	// The config and its link are gone, the docroot removed again and
+	// nginx never reloaded with the broken config.
+	if _, err := os.Stat(filepath.Join(nginxSitesAvailable, "example.com.conf")); !os.IsNotExist(err) {
+		t.Errorf("config left behind: %v", err)
+	}
+	if _, err := os.Lstat(filepath.Join(nginxSitesEnabled, "example.com.conf")); !os.IsNotExist(err) {
+		t.Errorf("symlink left behind: %v", err)
+	}
+	lines := commandLines(fake)
+	if !hasCommand(lines, "rm -rf "+filepath.Join(webRoot, "example.com")) {
+		t.Errorf("docroot not removed; commands: %q", lines)
+	}
+	if hasCommand(lines, "systemctl reload nginx") {
+		t.Errorf("nginx reloaded after a failed config test; commands: %q", lines)
+	}
+	if _, ok, _ := resources.Get(kindVhost, "example.com"); ok {
+		t.Error("failed vhost recorded in the resource store")
+	}
+}
+
+func TestOwnerAliasesCannotTakeOtherVhosts(t *testing.T) {
+	setupVhostTest(t)
+	if rec := postCreateVhost(t, `{"domain":"shop.example.com"}`); rec.Code != http.StatusCreated {
+		t.Fatalf("create shop.example.com: status %d; body: %s", rec.Code, rec.Body)
+	}
+	// Recorded by the panel, config gone missing.
+	if err := resources.Update(kindVhost, "blog.example.com", func(r *store.Resource) {}); err != nil {
+		t.Fatal(err)
+	}
+
+	create := func(body string) *httptest.ResponseRecorder {
+		req := asUser(httptest.NewRequest(http.MethodPost, "/api/vhosts", strings.NewReader(body)), "alice", middleware.RoleSiteOwner)
+		rec := httptest.NewRecorder()
+		CreateVhost(rec, req)
+		return rec
+	}
+	for _, alias := range []string{"shop.example.com", "www.shop.example.com", "blog.example.com"} {
+		rec := create(`{"domain":"example.com","aliases":["www.example.com",` + strconv.Quote(alias) + `]}`)
+		if rec.Code != http.StatusConflict {
+			t.Errorf("alias %s: status %d, want 409; body: %s", alias, rec.Code, rec.Body)
+		}
+	}
+	if rec := create(`{"domain":"example.com","aliases":["www.example.com","cdn.example.com"]}`); rec.Code != http.StatusCreated {
+		t.Fatalf("create example.com: status %d; body: %s", rec.Code, rec.Body)
+	}
+
+	update := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPut, "/api/vhosts/example.com", strings.NewReader(body))
+		req = asUser(withURLParam(req, "domain", "example.com"), "alice", middleware.RoleSiteOwner)
+		rec := httptest.NewRecorder()
+		UpdateVhost(rec, req)
+		return rec
+	}
+	if rec := update(`{"aliases":["www.example.com","shop.example.com"]}`); rec.Code != http.StatusConflict {
+		t.Errorf("update taking shop.example.com: status %d, want 409; body: %s", rec.Code, rec.Body)
+	}
+	// Its own names are no conflict.
+	if rec := update(`{"aliases":["cdn.example.com"]}`); rec.Code != http.StatusOK {
+		t.Errorf("update with own names: status %d, want 200; body: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestForceHTTPSAppliedOnceCertificateIssued(t *testing.T) {
+	fake := setupVhostTest(t)
+	t.Cleanup(func() { jobs.loaded, jobs.records = false, nil })
+	jobs.loaded, jobs.records = false, nil
+
+	rec := postCreateVhost(t, `{"domain":"example.com","force_https":true,"canonical":"non-www","redirects":[{"from":"/old","to":"/new"}]}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want 201; body: %s", rec.Code, rec.Body)
+	}
+	confPath := filepath.Join(nginxSitesAvailable, "example.com.conf")
+	conf, _ := os.ReadFile(confPath)
+	if strings.Contains(string(conf), "$scheme = http") {
+		t.Errorf("HTTPS forced before there is a certificate:\n%s", conf)
+	}
+	if !strings.Contains(string(conf), `rewrite "^/old$" "/new" permanent;`) {
+		t.Errorf("redirect rule missing:\n%s", conf)
+	}
+	if vh := loadVhost("example.com"); !vh.ForceHTTPS || vh.Canonical != canonicalNoWWW || len(vh.Redirects) != 1 {
+		t.Errorf("settings not recorded: %+v", vh)
+	}
+
+	// What certbot --nginx leaves behind: the site moved to 443 and a
+	// block of its own for port 80.
+	certbot := strings.Join([]string{
+		"server {",
+		"    server_name example.com www.example.com;",
+		"    root /var/www/example.com/public_html;",
+		"    listen 443 ssl; # managed by Certbot",
+		"    ssl_certificate /etc/letsencrypt/live/example.com/fullchain.pem; # managed by Certbot",
+		"}",
+		"server {",
+		"    listen 80;",
+		"    server_name example.com www.example.com;",
+		"    return 404; # managed by Certbot",
+		"}",
+	}, "\n")
+	if err := os.WriteFile(confPath, []byte(certbot), 0644); err != nil {
+		t.Fatal(err)
+	}
+	req := withURLParam(httptest.NewRequest(http.MethodPost, "/api/vhosts/example.com/ssl", strings.NewReader(`{}`)), "domain", "example.com")
+	rec = httptest.NewRecorder()
+	EnableSSL(rec, req)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("status = %d, want 202; body: %s", rec.Code, rec.Body)
+	}
+	jobs.running.Wait()
+
+	conf, _ = os.ReadFile(confPath)
+	if n := strings.Count(string(conf), "if ($scheme = http)"); n != 2 {
+		t.Errorf("HTTPS forced in %d server blocks, want 2:\n%s", n, conf)
+	}
+	if n := strings.Count(string(conf), `rewrite "^/old$"`); n != 2 {
+		t.Errorf("redirect rule in %d server blocks, want 2:\n%s", n, conf)
+	}
+	lines := commandLines(fake)
+	if !hasCommand(lines, "certbot --nginx -d example.com") || !hasCommand(lines, "systemctl reload nginx") {
+		t.Errorf("commands: %q", lines)
+	}
+}
@@ -1,6 +1,7 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -8,11 +9,20 @@ import (
 	"path/filepath"
 	"strings"
 
+	"blogron/config"
+	"blogron/store"
 	"blogron/util"
 )
 
-const wpRoot = "/var/www"
-const wpCliPath = "/usr/local/bin/wp"
+var wpRoot = "/var/www"
+var wpCliPath = "/usr/local/bin/wp"
+
+// Where the db_name/db_user meta of a WordPress site came from. Only
+// dbSourcePanel values, created by CreateWPSite, are ever dropped.
+const (
+	dbSourcePanel    = "panel"
+	dbSourceWPConfig = "wp-config"
+)
 
 // ── Types ────────────────────────────────────────────────────────────────────
 
@@ -51,6 +61,7 @@ type createWPRequest struct {
 	DBUser    string `json:"db_user"`
 	DBPass    string `json:"db_pass"`
 	PHP       string `json:"php"`
+	Owner     string `json:"owner"`
 }
 
 // ── Routes ────────────────────────────────────────────────────────────────────
@@ -70,6 +81,9 @@ func ListWPSites(w http.ResponseWriter, r *http.Request) {
 			continue
 		}
 		domain := e.Name()
+		if !canAccess(r, kindWordPress, domain) {
+			continue
+		}
 		wpConfigPath := filepath.Join(wpRoot, domain, "public_html", "wp-config.php")
 		if _, err := os.Stat(wpConfigPath); os.IsNotExist(err) {
 			// Also check root level (some installs don't use public_html)
@@ -89,15 +103,39 @@ func ListWPSites(w http.ResponseWriter, r *http.Request) {
 func CreateWPSite(w http.ResponseWriter, r *http.Request) {
 	var req createWPRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		util.WriteError(w, http.StatusBadRequest, "invalid request body")
+		util.WriteErr(w, util.ErrInvalidBody)
 		return
 	}
 
 	domain := util.Sanitize(req.Domain)
 	if domain == "" {
-		util.WriteError(w, http.StatusBadRequest, "domain is required")
+		util.WriteErr(w, util.Invalid("domain", "domain is required"))
 		return
 	}
+	if strings.ContainsAny(req.AdminPass+req.DBPass, "\r\n") {
+		fields := map[string]string{}
+		for name, pass := range map[string]string{"admin_pass": req.AdminPass, "db_pass": req.DBPass} {
+			if strings.ContainsAny(pass, "\r\n") {
+				fields[name] = "cannot contain line breaks"
+			}
+		}
+		util.WriteErr(w, util.InvalidFields("passwords cannot contain line breaks", fields))
+		return
+	}
+	if req.PHP != "" && !config.ValidPHPVersion(req.PHP) {
+		util.WriteErr(w, util.Invalid("php", "must be a version like 8.2"))
+		return
+	}
+	if isScoped(r) {
+		if _, err := os.Stat(filepath.Join(wpRoot, domain)); err == nil {
+			util.WriteErr(w, errSiteDirExists)
+			return
+		}
+		if _, err := os.Stat(filepath.Join(nginxSitesAvailable, domain+".conf")); err == nil {
+			util.WriteErr(w, errVhostExists)
+			return
+		}
+	}
 
 	// Defaults
 	if req.SiteTitle == "" {
@@ -122,101 +160,174 @@ func CreateWPSite(w http.ResponseWriter, r *http.Request) {
 		}
 		dbUser = raw
 	}
+	if systemDatabases[dbName] {
+		util.WriteErr(w, util.Invalid("db_name", "is a MariaDB system database"))
+		return
+	}
+	if protectedDBUsers[dbUser] {
+		util.WriteErr(w, util.Invalid("db_user", "is a MariaDB system account"))
+		return
+	}
 	dbPass := req.DBPass
 	if dbPass == "" {
 		dbPass = randomPass(20)
 	}
 	phpVersion := req.PHP
 	if phpVersion == "" {
-		phpVersion = os.Getenv("PHP_VERSION")
-	}
-	if phpVersion == "" {
-		phpVersion = "8.2"
+		phpVersion = settings.PHP.DefaultVersion
 	}
 
 	docroot := filepath.Join(wpRoot, domain, "public_html")
 
-	// 1. Create MariaDB database + user
-	setupSQL := fmt.Sprintf(
-		"CREATE DATABASE IF NOT EXISTS `%s` CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;"+
-			"CREATE USER IF NOT EXISTS '%s'@'localhost' IDENTIFIED BY '%s';"+
-			"GRANT ALL PRIVILEGES ON `%s`.* TO '%s'@'localhost';"+
-			"FLUSH PRIVILEGES;",
-		dbName, dbUser, dbPass, dbName, dbUser,
-	)
-	if _, err := util.RunCmd("mysql", append(mysqlArgs("-e", setupSQL))...); err != nil {
-		util.WriteError(w, http.StatusInternalServerError, "failed to create database: "+err.Error())
-		return
-	}
+	siteURL := "http://" + domain
+	owner := ownerForCreate(r, req.Owner)
+
+	writeJobAccepted(w, r, "wordpress.create", domain, func(j *jobRun) error {
+		p := newProvision(j.ctx).forJob(j)
+
+		// 1. Create MariaDB database + user. Both fail if they already
+		// exist, so a rollback never drops something it did not create.
+		err := p.Step("create database", func(ctx context.Context) error {
+			_, err := mysqlQuery(ctx, "", fmt.Sprintf(
+				"CREATE DATABASE `%s` CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;", dbName))
+			return err
+		}, func(ctx context.Context) error {
+			_, err := mysqlQuery(ctx, "", "DROP DATABASE IF EXISTS `"+dbName+"`;")
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		err = p.Step("create database user", func(ctx context.Context) error {
+			_, err := mysqlQuery(ctx, "", fmt.Sprintf(
+				"CREATE USER '%s'@'localhost' IDENTIFIED BY %s;"+
+					"GRANT ALL PRIVILEGES ON `%s`.* TO '%s'@'localhost';"+
+					"FLUSH PRIVILEGES;",
+				dbUser, sqlString(dbPass), dbName, dbUser,
+			))
+			return err
+		}, func(ctx context.Context) error {
+			_, err := mysqlQuery(ctx, "", fmt.Sprintf("DROP USER IF EXISTS '%s'@'localhost';", dbUser))
+			return err
+		})
+		if err != nil {
+			return err
+		}
 
-	// 2. Create docroot
-	if _, err := util.RunCmd("mkdir", "-p", docroot); err != nil {
-		util.WriteError(w, http.StatusInternalServerError, "failed to create docroot: "+err.Error())
-		return
-	}
-	util.RunCmd("chown", "-R", "www-data:www-data", filepath.Join(wpRoot, domain))
+		// 2. Create docroot
+		err = p.StepUndo("create docroot", func(ctx context.Context) (undoFunc, error) {
+			undo := removeCreatedDir(missingRoot(docroot, wpRoot))
+			if _, err := runCmd(ctx, "mkdir", "-p", docroot); err != nil {
+				return nil, cleanUp(ctx, undo, err)
+			}
+			_, err := runCmd(ctx, "chown", "-R", "www-data:www-data", filepath.Join(wpRoot, domain))
+			return undo, cleanUp(ctx, undo, err)
+		})
+		if err != nil {
+			return err
+		}
 
-	// 3. Download WordPress core via WP-CLI
-	if _, err := wpCmd(docroot, "core", "download", "--locale=en_US"); err != nil {
-		util.WriteError(w, http.StatusInternalServerError, "wp core download failed: "+err.Error())
-		return
-	}
+		// 3. Download WordPress core via WP-CLI (removed with the docroot)
+		err = p.Step("download core", func(ctx context.Context) error {
+			_, err := wpCmd(ctx, docroot, "core", "download", "--locale=en_US")
+			return err
+		}, nil)
+		if err != nil {
+			return err
+		}
 
-	// 4. Create wp-config.php
-	if _, err := wpCmd(docroot,
-		"config", "create",
-		"--dbname="+dbName,
-		"--dbuser="+dbUser,
-		"--dbpass="+dbPass,
-		"--dbhost=localhost",
-		"--dbcharset=utf8mb4",
-	); err != nil {
-		util.WriteError(w, http.StatusInternalServerError, "wp config create failed: "+err.Error())
-		return
-	}
+		// 4. Create wp-config.php (the DB password is answered on stdin via --prompt)
+		err = p.Step("create wp-config.php", func(ctx context.Context) error {
+			_, err := wpCmdStdin(ctx, docroot, dbPass+"\n",
+				"config", "create",
+				"--dbname="+dbName,
+				"--dbuser="+dbUser,
+				"--dbhost=localhost",
+				"--dbcharset=utf8mb4",
+				"--prompt=dbpass",
+			)
+			return err
+		}, nil)
+		if err != nil {
+			return err
+		}
 
-	// 5. Run WP install
-	siteURL := "http://" + domain
-	if _, err := wpCmd(docroot,
-		"core", "install",
-		"--url="+siteURL,
-		"--title="+req.SiteTitle,
-		"--admin_user="+req.AdminUser,
-		"--admin_password="+req.AdminPass,
-		"--admin_email="+req.AdminEmail,
-	); err != nil {
-		util.WriteError(w, http.StatusInternalServerError, "wp core install failed: "+err.Error())
-		return
-	}
-
-	// 6. Set file ownership back to www-data
-	util.RunCmd("chown", "-R", "www-data:www-data", filepath.Join(wpRoot, domain))
-
-	// 7. Create nginx vhost for this WP site
-	confPath := filepath.Join(nginxSitesAvailable, domain+".conf")
-	conf := buildWPNginxConfig(domain, docroot, phpVersion)
-	if err := os.WriteFile(confPath, []byte(conf), 0644); err != nil {
-		util.WriteError(w, http.StatusInternalServerError, "failed to write nginx config: "+err.Error())
-		return
-	}
-	symlink := filepath.Join(nginxSitesEnabled, domain+".conf")
-	os.Symlink(confPath, symlink)
-	if _, err := util.RunCmd("nginx", "-t"); err != nil {
-		os.Remove(confPath)
-		os.Remove(symlink)
-		util.WriteError(w, http.StatusInternalServerError, "nginx config test failed: "+err.Error())
-		return
-	}
-	util.RunCmd("systemctl", "reload", "nginx")
-
-	util.WriteJSON(w, http.StatusCreated, map[string]string{
-		"status":   "created",
-		"domain":   domain,
-		"db_name":  dbName,
-		"db_user":  dbUser,
-		"db_pass":  dbPass,
-		"site_url": siteURL,
-		"wp_admin": siteURL + "/wp-admin",
+		// 5. Run WP install
+		err = p.Step("install", func(ctx context.Context) error {
+			installArgs := []string{
+				"core", "install",
+				"--url=" + siteURL,
+				"--title=" + req.SiteTitle,
+				"--admin_user=" + req.AdminUser,
+				"--admin_email=" + req.AdminEmail,
+			}
+			adminPassInput := ""
+			if req.AdminPass != "" {
+				installArgs = append(installArgs, "--prompt=admin_password")
+				adminPassInput = req.AdminPass + "\n"
+			}
+			if _, err := wpCmdStdin(ctx, docroot, adminPassInput, installArgs...); err != nil {
+				return err
+			}
+			// Set file ownership back to www-data
+			_, err := runCmd(ctx, "chown", "-R", "www-data:www-data", filepath.Join(wpRoot, domain))
+			return err
+		}, nil)
+		if err != nil {
+			return err
+		}
+
+		// 6. Create nginx vhost for this WP site
+		err = p.StepUndo("configure nginx", func(ctx context.Context) (undoFunc, error) {
+			profile, err := loadVhostProfile("wordpress")
+			if err != nil {
+				return nil, err
+			}
+			conf, err := profile.render(newVhostTemplateData(domain, docroot, phpVersion, vhostProxy{}))
+			if err != nil {
+				return nil, err
+			}
+			return installVhostConf(ctx, domain, conf)
+		})
+		if err != nil {
+			return err
+		}
+
+		// 7. Reload nginx
+		err = p.Step("reload nginx", func(ctx context.Context) error {
+			_, err := runCmd(ctx, "systemctl", "reload", "nginx")
+			return err
+		}, nil)
+		if err != nil {
+			return err
+		}
+
+		recordResource(kindWordPress, domain, func(res *store.Resource) {
+			res.Owner = owner
+			res.PHPVersion = phpVersion
+			setMeta(res, "docroot", docroot)
+			setMeta(res, "db_name", dbName)
+			setMeta(res, "db_user", dbUser)
+			setMeta(res, "db_source", dbSourcePanel)
+		})
+		recordResource(kindVhost, domain, func(res *store.Resource) {
+			res.Owner = owner
+			res.PHPVersion = phpVersion
+			setMeta(res, "profile", "wordpress")
+			setMeta(res, "docroot", docroot)
+		})
+		recordResource(kindDatabase, dbName, func(res *store.Resource) {
+			res.Owner = owner
+			setMeta(res, "db_user", dbUser)
+			setMeta(res, "wordpress", domain)
+		})
+		j.SetResult("domain", domain)
+		j.SetResult("db_name", dbName)
+		j.SetResult("db_user", dbUser)
+		j.SetSecretResult("db_pass", dbPass)
+		j.SetResult("site_url", siteURL)
+		j.SetResult("wp_admin", siteURL+"/wp-admin")
+		return nil
 	})
 }
 
@@ -225,7 +336,10 @@ func CreateWPSite(w http.ResponseWriter, r *http.Request) {
 func DeleteWPSite(w http.ResponseWriter, r *http.Request) {
 	domain := util.Sanitize(chi_urlParam(r, "domain"))
 	if domain == "" {
-		util.WriteError(w, http.StatusBadRequest, "invalid domain")
+		util.WriteErr(w, util.Invalid("domain", "invalid domain"))
+		return
+	}
+	if !requireOwner(w, r, kindWordPress, domain) {
 		return
 	}
 
@@ -234,37 +348,60 @@ func DeleteWPSite(w http.ResponseWriter, r *http.Request) {
 	}
 	json.NewDecoder(r.Body).Decode(&body)
 
+	// Only a database and user the panel created for this site are dropped:
+	// wp-config.php, and what reconcile read from it, is editable by the
+	// site and could name any other database or account.
+	dbName, dbUser := "", ""
+	if res, ok, _ := resources.Get(kindWordPress, domain); ok && res.Meta["db_source"] == dbSourcePanel {
+		dbName, dbUser = res.Meta["db_name"], res.Meta["db_user"]
+	}
+
 	// Remove files
 	siteDir := filepath.Join(wpRoot, domain)
-	util.RunCmd("rm", "-rf", siteDir)
+	runCmd(r.Context(), "rm", "-rf", siteDir)
 
 	// Remove nginx config
-	util.RunCmd("rm", "-f", filepath.Join(nginxSitesEnabled, domain+".conf"))
-	util.RunCmd("rm", "-f", filepath.Join(nginxSitesAvailable, domain+".conf"))
-	util.RunCmd("systemctl", "reload", "nginx")
+	runCmd(r.Context(), "rm", "-f", filepath.Join(nginxSitesEnabled, domain+".conf"))
+	runCmd(r.Context(), "rm", "-f", filepath.Join(nginxSitesAvailable, domain+".conf"))
+	runCmd(r.Context(), "systemctl", "reload", "nginx")
+	forgetResource(kindWordPress, domain)
+	forgetResource(kindVhost, domain)
 
 	// Optionally drop DB
+	resp := map[string]string{"status": "deleted"}
 	if body.DeleteDB {
-		dbName := "wp_" + strings.ReplaceAll(domain, ".", "_")
-		util.RunCmd("mysql", append(mysqlArgs("-e", "DROP DATABASE IF EXISTS `"+dbName+"`;"))...)
+		if dbName == "" || systemDatabases[dbName] || !canAccess(r, kindDatabase, dbName) {
+			resp["database"] = "kept: not created by the panel for this site"
+		} else {
+			dropSQL := "DROP DATABASE IF EXISTS `" + util.Sanitize(dbName) + "`;"
+			if dbUser != "" && !protectedDBUsers[dbUser] {
+				dropSQL += "DROP USER IF EXISTS '" + util.Sanitize(dbUser) + "'@'localhost';"
+			}
+			mysqlQuery(r.Context(), "", dropSQL)
+			forgetResource(kindDatabase, dbName)
+			resp["database"] = "dropped"
+		}
 	}
 
-	util.WriteJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+	util.WriteJSON(w, http.StatusOK, resp)
 }
 
 // ListWPPlugins godoc
 // GET /api/wordpress/{domain}/plugins
 func ListWPPlugins(w http.ResponseWriter, r *http.Request) {
 	domain := util.Sanitize(chi_urlParam(r, "domain"))
+	if !requireOwner(w, r, kindWordPress, domain) {
+		return
+	}
 	docroot := resolveWPDocroot(domain)
 	if docroot == "" {
-		util.WriteError(w, http.StatusNotFound, "wordpress site not found")
+		util.WriteErr(w, errWPSiteNotFound)
 		return
 	}
 
-	out, err := wpCmd(docroot, "plugin", "list", "--format=json")
+	out, err := wpCmd(r.Context(), docroot, "plugin", "list", "--format=json")
 	if err != nil {
-		util.WriteError(w, http.StatusInternalServerError, "wp plugin list failed: "+err.Error())
+		util.WriteErr(w, util.Wrap(err, "wp plugin list failed"))
 		return
 	}
 
@@ -282,9 +419,12 @@ func ListWPPlugins(w http.ResponseWriter, r *http.Request) {
 // Body: { "name": "woocommerce", "activate": true }
 func InstallWPPlugin(w http.ResponseWriter, r *http.Request) {
 	domain := util.Sanitize(chi_urlParam(r, "domain"))
+	if !requireOwner(w, r, kindWordPress, domain) {
+		return
+	}
 	docroot := resolveWPDocroot(domain)
 	if docroot == "" {
-		util.WriteError(w, http.StatusNotFound, "wordpress site not found")
+		util.WriteErr(w, errWPSiteNotFound)
 		return
 	}
 
@@ -293,7 +433,7 @@ func InstallWPPlugin(w http.ResponseWriter, r *http.Request) {
 		Activate bool   `json:"activate"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Name == "" {
-		util.WriteError(w, http.StatusBadRequest, "plugin name is required")
+		util.WriteErr(w, util.Invalid("name", "plugin name is required"))
 		return
 	}
 
@@ -302,11 +442,11 @@ func InstallWPPlugin(w http.ResponseWriter, r *http.Request) {
 	if body.Activate {
 		args = append(args, "--activate")
 	}
-	if _, err := wpCmd(docroot, args...); err != nil {
-		util.WriteError(w, http.StatusInternalServerError, "plugin install failed: "+err.Error())
+	if _, err := wpCmd(r.Context(), docroot, args...); err != nil {
+		util.WriteErr(w, util.Wrap(err, "plugin install failed"))
 		return
 	}
-	util.RunCmd("chown", "-R", "www-data:www-data", filepath.Join(wpRoot, domain))
+	runCmd(r.Context(), "chown", "-R", "www-data:www-data", filepath.Join(wpRoot, domain))
 	util.WriteJSON(w, http.StatusCreated, map[string]string{"status": "installed", "plugin": name})
 }
 
@@ -316,9 +456,12 @@ func InstallWPPlugin(w http.ResponseWriter, r *http.Request) {
 func ToggleWPPlugin(w http.ResponseWriter, r *http.Request) {
 	domain := util.Sanitize(chi_urlParam(r, "domain"))
 	plugin := util.Sanitize(chi_urlParam(r, "plugin"))
+	if !requireOwner(w, r, kindWordPress, domain) {
+		return
+	}
 	docroot := resolveWPDocroot(domain)
 	if docroot == "" {
-		util.WriteError(w, http.StatusNotFound, "wordpress site not found")
+		util.WriteErr(w, errWPSiteNotFound)
 		return
 	}
 
@@ -329,12 +472,12 @@ func ToggleWPPlugin(w http.ResponseWriter, r *http.Request) {
 
 	allowed := map[string]bool{"activate": true, "deactivate": true, "delete": true, "update": true}
 	if !allowed[body.Action] {
-		util.WriteError(w, http.StatusBadRequest, "action must be activate, deactivate, delete, or update")
+		util.WriteErr(w, util.Invalid("action", "action must be activate, deactivate, delete, or update"))
 		return
 	}
 
-	if _, err := wpCmd(docroot, "plugin", body.Action, plugin); err != nil {
-		util.WriteError(w, http.StatusInternalServerError, "plugin "+body.Action+" failed: "+err.Error())
+	if _, err := wpCmd(r.Context(), docroot, "plugin", body.Action, plugin); err != nil {
+		util.WriteErr(w, util.Wrap(err, "plugin "+body.Action+" failed"))
 		return
 	}
 	util.WriteJSON(w, http.StatusOK, map[string]string{"status": body.Action + "d", "plugin": plugin})
@@ -344,15 +487,18 @@ func ToggleWPPlugin(w http.ResponseWriter, r *http.Request) {
 // GET /api/wordpress/{domain}/themes
 func ListWPThemes(w http.ResponseWriter, r *http.Request) {
 	domain := util.Sanitize(chi_urlParam(r, "domain"))
+	if !requireOwner(w, r, kindWordPress, domain) {
+		return
+	}
 	docroot := resolveWPDocroot(domain)
 	if docroot == "" {
-		util.WriteError(w, http.StatusNotFound, "wordpress site not found")
+		util.WriteErr(w, errWPSiteNotFound)
 		return
 	}
 
-	out, err := wpCmd(docroot, "theme", "list", "--format=json")
+	out, err := wpCmd(r.Context(), docroot, "theme", "list", "--format=json")
 	if err != nil {
-		util.WriteError(w, http.StatusInternalServerError, "wp theme list failed: "+err.Error())
+		util.WriteErr(w, util.Wrap(err, "wp theme list failed"))
 		return
 	}
 
@@ -366,9 +512,12 @@ func ListWPThemes(w http.ResponseWriter, r *http.Request) {
 // Body: { "name": "astra", "activate": true }
 func InstallWPTheme(w http.ResponseWriter, r *http.Request) {
 	domain := util.Sanitize(chi_urlParam(r, "domain"))
+	if !requireOwner(w, r, kindWordPress, domain) {
+		return
+	}
 	docroot := resolveWPDocroot(domain)
 	if docroot == "" {
-		util.WriteError(w, http.StatusNotFound, "wordpress site not found")
+		util.WriteErr(w, errWPSiteNotFound)
 		return
 	}
 
@@ -377,7 +526,7 @@ func InstallWPTheme(w http.ResponseWriter, r *http.Request) {
 		Activate bool   `json:"activate"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Name == "" {
-		util.WriteError(w, http.StatusBadRequest, "theme name is required")
+		util.WriteErr(w, util.Invalid("name", "theme name is required"))
 		return
 	}
 
@@ -386,11 +535,11 @@ func InstallWPTheme(w http.ResponseWriter, r *http.Request) {
 	if body.Activate {
 		args = append(args, "--activate")
 	}
-	if _, err := wpCmd(docroot, args...); err != nil {
-		util.WriteError(w, http.StatusInternalServerError, "theme install failed: "+err.Error())
+	if _, err := wpCmd(r.Context(), docroot, args...); err != nil {
+		util.WriteErr(w, util.Wrap(err, "theme install failed"))
 		return
 	}
-	util.RunCmd("chown", "-R", "www-data:www-data", filepath.Join(wpRoot, domain))
+	runCmd(r.Context(), "chown", "-R", "www-data:www-data", filepath.Join(wpRoot, domain))
 	util.WriteJSON(w, http.StatusCreated, map[string]string{"status": "installed", "theme": name})
 }
 
@@ -399,9 +548,12 @@ func InstallWPTheme(w http.ResponseWriter, r *http.Request) {
 func ToggleWPTheme(w http.ResponseWriter, r *http.Request) {
 	domain := util.Sanitize(chi_urlParam(r, "domain"))
 	theme := util.Sanitize(chi_urlParam(r, "theme"))
+	if !requireOwner(w, r, kindWordPress, domain) {
+		return
+	}
 	docroot := resolveWPDocroot(domain)
 	if docroot == "" {
-		util.WriteError(w, http.StatusNotFound, "wordpress site not found")
+		util.WriteErr(w, errWPSiteNotFound)
 		return
 	}
 
@@ -412,12 +564,12 @@ func ToggleWPTheme(w http.ResponseWriter, r *http.Request) {
 
 	allowed := map[string]bool{"activate": true, "delete": true, "update": true}
 	if !allowed[body.Action] {
-		util.WriteError(w, http.StatusBadRequest, "action must be activate, delete, or update")
+		util.WriteErr(w, util.Invalid("action", "action must be activate, delete, or update"))
 		return
 	}
 
-	if _, err := wpCmd(docroot, "theme", body.Action, theme); err != nil {
-		util.WriteError(w, http.StatusInternalServerError, "theme "+body.Action+" failed: "+err.Error())
+	if _, err := wpCmd(r.Context(), docroot, "theme", body.Action, theme); err != nil {
+		util.WriteErr(w, util.Wrap(err, "theme "+body.Action+" failed"))
 		return
 	}
 	util.WriteJSON(w, http.StatusOK, map[string]string{"status": body.Action + "d", "theme": theme})
@@ -427,18 +579,23 @@ func ToggleWPTheme(w http.ResponseWriter, r *http.Request) {
 // POST /api/wordpress/{domain}/update
 func WPUpdateCore(w http.ResponseWriter, r *http.Request) {
 	domain := util.Sanitize(chi_urlParam(r, "domain"))
+	if !requireOwner(w, r, kindWordPress, domain) {
+		return
+	}
 	docroot := resolveWPDocroot(domain)
 	if docroot == "" {
-		util.WriteError(w, http.StatusNotFound, "wordpress site not found")
+		util.WriteErr(w, errWPSiteNotFound)
 		return
 	}
 
-	out, err := wpCmd(docroot, "core", "update")
-	if err != nil {
-		util.WriteError(w, http.StatusInternalServerError, "wp core update failed: "+err.Error())
-		return
-	}
-	util.WriteJSON(w, http.StatusOK, map[string]string{"status": "updated", "output": out})
+	writeJobAccepted(w, r, "wordpress.update", domain, func(j *jobRun) error {
+		j.Step("wp core update")
+		_, err := wpCmd(j.ctx, docroot, "core", "update")
+		if err != nil {
+			return fmt.Errorf("wp core update failed: %w", err)
+		}
+		return nil
+	})
 }
 
 // WPMaintenanceMode godoc
@@ -446,9 +603,12 @@ func WPUpdateCore(w http.ResponseWriter, r *http.Request) {
 // Body: { "enable": true }
 func WPMaintenanceMode(w http.ResponseWriter, r *http.Request) {
 	domain := util.Sanitize(chi_urlParam(r, "domain"))
+	if !requireOwner(w, r, kindWordPress, domain) {
+		return
+	}
 	docroot := resolveWPDocroot(domain)
 	if docroot == "" {
-		util.WriteError(w, http.StatusNotFound, "wordpress site not found")
+		util.WriteErr(w, errWPSiteNotFound)
 		return
 	}
 
@@ -461,7 +621,7 @@ func WPMaintenanceMode(w http.ResponseWriter, r *http.Request) {
 	if body.Enable {
 		mode = "activate"
 	}
-	wpCmd(docroot, "maintenance-mode", mode)
+	wpCmd(r.Context(), docroot, "maintenance-mode", mode)
 	util.WriteJSON(w, http.StatusOK, map[string]string{"status": map[bool]string{true: "enabled", false: "disabled"}[body.Enable]})
 }
 
@@ -470,9 +630,12 @@ func WPMaintenanceMode(w http.ResponseWriter, r *http.Request) {
 // Body: { "search": "http://old.com", "replace": "https://new.com" }
 func WPSearchReplace(w http.ResponseWriter, r *http.Request) {
 	domain := util.Sanitize(chi_urlParam(r, "domain"))
+	if !requireOwner(w, r, kindWordPress, domain) {
+		return
+	}
 	docroot := resolveWPDocroot(domain)
 	if docroot == "" {
-		util.WriteError(w, http.StatusNotFound, "wordpress site not found")
+		util.WriteErr(w, errWPSiteNotFound)
 		return
 	}
 
@@ -481,13 +644,13 @@ func WPSearchReplace(w http.ResponseWriter, r *http.Request) {
 		Replace string `json:"replace"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Search == "" {
-		util.WriteError(w, http.StatusBadRequest, "search and replace are required")
+		util.WriteErr(w, util.InvalidFields("search and replace are required", map[string]string{"search": "required", "replace": "required"}))
 		return
 	}
 
-	out, err := wpCmd(docroot, "search-replace", body.Search, body.Replace, "--all-tables")
+	out, err := wpCmd(r.Context(), docroot, "search-replace", body.Search, body.Replace, "--all-tables")
 	if err != nil {
-		util.WriteError(w, http.StatusInternalServerError, "search-replace failed: "+err.Error())
+		util.WriteErr(w, util.Wrap(err, "search-replace failed"))
 		return
 	}
 	util.WriteJSON(w, http.StatusOK, map[string]string{"status": "done", "output": out})
@@ -497,24 +660,36 @@ func WPSearchReplace(w http.ResponseWriter, r *http.Request) {
 // POST /api/wordpress/{domain}/cache-flush
 func WPCacheFlush(w http.ResponseWriter, r *http.Request) {
 	domain := util.Sanitize(chi_urlParam(r, "domain"))
+	if !requireOwner(w, r, kindWordPress, domain) {
+		return
+	}
 	docroot := resolveWPDocroot(domain)
 	if docroot == "" {
-		util.WriteError(w, http.StatusNotFound, "wordpress site not found")
+		util.WriteErr(w, errWPSiteNotFound)
 		return
 	}
 
-	wpCmd(docroot, "cache", "flush")
-	wpCmd(docroot, "rewrite", "flush")
+	wpCmd(r.Context(), docroot, "cache", "flush")
+	wpCmd(r.Context(), docroot, "rewrite", "flush")
 	util.WriteJSON(w, http.StatusOK, map[string]string{"status": "flushed"})
 }
 
 // ── helpers ───────────────────────────────────────────────────────────────────
 
 // wpCmd runs a WP-CLI command as www-data in the given docroot.
-func wpCmd(docroot string, args ...string) (string, error) {
+func wpCmd(ctx context.Context, docroot string, args ...string) (string, error) {
+	return wpCmdStdin(ctx, docroot, "", args...)
+}
+
+// wpCmdStdin is wpCmd with stdin, for answering --prompt=<arg> with secrets.
+func wpCmdStdin(ctx context.Context, docroot, stdin string, args ...string) (string, error) {
 	// Build: sudo -u www-data wp --path=<docroot> --allow-root <args...>
 	cmdArgs := append([]string{"-u", "www-data", wpCliPath, "--path=" + docroot, "--allow-root"}, args...)
-	return util.RunCmd("sudo", cmdArgs...)
+	cmd := util.Command{Name: "sudo", Args: cmdArgs}
+	if stdin != "" {
+		cmd.Stdin = []byte(stdin)
+	}
+	return util.RunCommand(ctx, executor, cmd)
 }
 
 func resolveWPDocroot(domain string) string {
@@ -580,50 +755,6 @@ func probeWPSite(domain, wpConfigPath string) WPSite {
 	return site
 }
 
-func buildWPNginxConfig(domain, docroot, phpVersion string) string {
-	phpSocket := fmt.Sprintf("/run/php/php%s-fpm.sock", phpVersion)
-	return fmt.Sprintf(`server {
-    listen 80;
-    listen [::]:80;
-
-    server_name %s www.%s;
-    root %s;
-    index index.php index.html;
-
-    access_log /var/log/nginx/%s.access.log;
-    error_log  /var/log/nginx/%s.error.log;
-
-    client_max_body_size 64M;
-
-    location / {
-        try_files $uri $uri/ /index.php?$args;
-    }
-
-    location ~ \.php$ {
-        include snippets/fastcgi-php.conf;
-        fastcgi_pass unix:%s;
-        fastcgi_param SCRIPT_FILENAME $document_root$fastcgi_script_name;
-        fastcgi_read_timeout 300;
-    }
-
-    # WordPress security rules
-    location ~* /(?:uploads|files)/.*\.php$ { deny all; }
-    location ~ /\. { deny all; }
-    location = /xmlrpc.php { deny all; }
-    location ~* /wp-config.php { deny all; }
-
-    # Cache static assets
-    location ~* \.(js|css|png|jpg|jpeg|gif|ico|svg|woff|woff2|ttf|eot)$ {
-        expires 30d;
-        add_header Cache-Control "public, no-transform";
-    }
-
-    add_header X-Frame-Options "SAMEORIGIN" always;
-    add_header X-Content-Type-Options "nosniff" always;
-}
-`, domain, domain, docroot, domain, domain, phpSocket)
-}
-
 func randomPass(n int) string {
 	const chars = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
 	b := make([]byte, n)
@@ -0,0 +1,154 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// Login signs in with a panel account. When the account has two-factor login
+// enabled the result has MFARequired set; finish with VerifyLogin. On success
+// the client uses the new tokens for the calls that follow.
+func (c *Client) Login(ctx context.Context, username, password string) (*LoginResult, error) {
+	var res LoginResult
+	err := c.do(ctx, request{
+		method:    http.MethodPost,
+		path:      "/auth/login",
+		body:      map[string]string{"username": username, "password": password},
+		noRefresh: true,
+	}, &res)
+	if err != nil {
+		return nil, err
+	}
+	if !res.MFARequired {
+		c.useTokens(res.Tokens)
+	}
+	return &res, nil
+}
+
+// VerifyLogin completes a two-factor login with a TOTP or recovery code.
+func (c *Client) VerifyLogin(ctx context.Context, preAuthToken, code string) (*Tokens, error) {
+	var tokens Tokens
+	err := c.do(ctx, request{
+		method:    http.MethodPost,
+		path:      "/auth/login/verify",
+		body:      map[string]string{"pre_auth_token": preAuthToken, "code": code},
+		noRefresh: true,
+	}, &tokens)
+	if err != nil {
+		return nil, err
+	}
+	c.useTokens(tokens)
+	return &tokens, nil
+}
+
+// RefreshToken exchanges the client's refresh token for new tokens. The
+// client calls it itself when an access token expires.
+func (c *Client) RefreshToken(ctx context.Context) (*Tokens, error) {
+	c.mu.Lock()
+	refresh := c.refreshToken
+	c.mu.Unlock()
+
+	var tokens Tokens
+	err := c.do(ctx, request{
+		method:    http.MethodPost,
+		path:      "/auth/refresh",
+		body:      map[string]string{"refresh_token": refresh},
+		noRefresh: true,
+	}, &tokens)
+	if err != nil {
+		return nil, err
+	}
+	c.useTokens(tokens)
+	return &tokens, nil
+}
+
+// Logout ends the client's session.
+func (c *Client) Logout(ctx context.Context) error {
+	if err := c.send(ctx, http.MethodPost, "/auth/logout", nil, nil); err != nil {
+		return err
+	}
+	c.SetTokens("", "")
+	return nil
+}
+
+// ListSessions lists the caller's sessions, or user's (admins only).
+func (c *Client) ListSessions(ctx context.Context, user string) ([]Session, error) {
+	var out []Session
+	return out, c.get(ctx, "/auth/sessions", query("user", user), &out)
+}
+
+func (c *Client) DeleteSession(ctx context.Context, id string) (Status, error) {
+	var out Status
+	return out, c.send(ctx, http.MethodDelete, "/auth/sessions/"+pathEscape(id), nil, &out)
+}
+
+func (c *Client) ListAPITokens(ctx context.Context) ([]APIToken, error) {
+	var out []APIToken
+	return out, c.get(ctx, "/auth/tokens", nil, &out)
+}
+
+func (c *Client) CreateAPIToken(ctx context.Context, req CreateAPITokenRequest) (*NewAPIToken, error) {
+	var out NewAPIToken
+	if err := c.send(ctx, http.MethodPost, "/auth/tokens", req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *Client) DeleteAPIToken(ctx context.Context, id string) (Status, error) {
+	var out Status
+	return out, c.send(ctx, http.MethodDelete, "/auth/tokens/"+pathEscape(id), nil, &out)
+}
+
+// SetupTOTP starts two-factor enrolment; confirm it with EnableTOTP.
+func (c *Client) SetupTOTP(ctx context.Context) (*TOTPSetup, error) {
+	var out TOTPSetup
+	if err := c.send(ctx, http.MethodPost, "/auth/totp/setup", nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// EnableTOTP confirms enrolment with a code and returns the recovery codes.
+func (c *Client) EnableTOTP(ctx context.Context, code string) ([]string, error) {
+	var out struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+	err := c.send(ctx, http.MethodPost, "/auth/totp/enable", map[string]string{"code": code}, &out)
+	return out.RecoveryCodes, err
+}
+
+func (c *Client) DisableTOTP(ctx context.Context, password string) (Status, error) {
+	var out Status
+	return out, c.send(ctx, http.MethodPost, "/auth/totp/disable", map[string]string{"password": password}, &out)
+}
+
+func (c *Client) RegenerateRecoveryCodes(ctx context.Context, password string) ([]string, error) {
+	var out struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+	err := c.send(ctx, http.MethodPost, "/auth/totp/recovery-codes", map[string]string{"password": password}, &out)
+	return out.RecoveryCodes, err
+}
+
+// ── helpers ─────────────────────────────────────────────────────────────────
+
+func (c *Client) useTokens(t Tokens) {
+	c.SetTokens(t.Token, t.RefreshToken)
+	if c.OnRefresh != nil {
+		c.OnRefresh(t)
+	}
+}
+
+// query builds query parameters from name/value pairs, leaving out empty
+// values.
+func query(pairs ...string) url.Values {
+	q := url.Values{}
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if pairs[i+1] != "" {
+			q.Set(pairs[i], pairs[i+1])
+		}
+	}
+	return q
+}
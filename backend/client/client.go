@@ -0,0 +1,245 @@
+// Package client is a Go client for the BLOGRON Panel API (/api/v1).
+//
+//	c := client.New("https://panel.example.com:8080", os.Getenv("BLOGRON_TOKEN"))
+//	vhosts, err := c.ListVhosts(ctx)
+//
+// Methods are named after the panel's handlers. Failed requests return an
+// *Error carrying the API's error code; background operations (WordPress
+// installs, SSL issuance, ...) return a Job to follow with WaitJob.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// APIPrefix is the versioned path every method calls.
+const APIPrefix = "/api/v1"
+
+// apiTokenPrefix marks long-lived API tokens, which cannot be refreshed.
+const apiTokenPrefix = "blg_"
+
+// Client calls the panel API. Set either an access token from Login (with
+// its refresh token, so expired access tokens are renewed transparently) or
+// an API token created with CreateAPIToken.
+type Client struct {
+	BaseURL    string // e.g. "https://panel.example.com:8080"
+	HTTPClient *http.Client
+
+	// OnRefresh is called with the new tokens whenever the client logs in or
+	// refreshes its access token, e.g. to save them.
+	OnRefresh func(Tokens)
+
+	mu           sync.Mutex
+	token        string
+	refreshToken string
+}
+
+// New returns a client for the panel at baseURL using token (may be empty
+// until Login).
+func New(baseURL, token string) *Client {
+	return &Client{
+		BaseURL:    strings.TrimRight(baseURL, "/"),
+		HTTPClient: &http.Client{Timeout: 60 * time.Second},
+		token:      token,
+	}
+}
+
+// SetTokens replaces the access and refresh tokens.
+func (c *Client) SetTokens(access, refresh string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.token, c.refreshToken = access, refresh
+}
+
+// Token returns the current access token.
+func (c *Client) Token() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.token
+}
+
+// Error is an error answer from the panel. Code is stable (see
+// util/errors.go in the panel source); Message is for people.
+type Error struct {
+	StatusCode int               `json:"-"`
+	Message    string            `json:"error"`
+	Code       string            `json:"code"`
+	Fields     map[string]string `json:"fields,omitempty"`
+	RequestID  string            `json:"request_id,omitempty"`
+
+	// Failed commands
+	Command string `json:"command,omitempty"`
+	Stderr  string `json:"stderr,omitempty"`
+
+	// Failed provisioning
+	Step       string   `json:"step,omitempty"`
+	RolledBack []string `json:"rolled_back,omitempty"`
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("%s (%d %s)", e.Message, e.StatusCode, e.Code)
+	if e.RequestID != "" {
+		msg += " [request " + e.RequestID + "]"
+	}
+	return msg
+}
+
+// IsCode reports whether err is an *Error with the given code, e.g.
+// IsCode(err, "VHOST_EXISTS").
+func IsCode(err error, code string) bool {
+	var e *Error
+	return errors.As(err, &e) && e.Code == code
+}
+
+// ── requests ────────────────────────────────────────────────────────────────
+
+// request is one API call; path is relative to APIPrefix.
+type request struct {
+	method      string
+	path        string
+	query       url.Values
+	body        any       // marshalled as JSON
+	raw         io.Reader // sent as is, with contentType
+	contentType string
+	noRefresh   bool
+}
+
+// Do calls any API route, e.g. Do(ctx, "GET", "/health", nil, &out). path is
+// relative to APIPrefix and may carry a query; body is sent as JSON and the
+// answer decoded into out (if not nil).
+func (c *Client) Do(ctx context.Context, method, path string, body, out any) error {
+	req := request{method: method, path: path, body: body}
+	if p, q, ok := strings.Cut(path, "?"); ok {
+		values, err := url.ParseQuery(q)
+		if err != nil {
+			return fmt.Errorf("invalid query: %w", err)
+		}
+		req.path, req.query = p, values
+	}
+	return c.do(ctx, req, out)
+}
+
+func (c *Client) get(ctx context.Context, path string, query url.Values, out any) error {
+	return c.do(ctx, request{method: http.MethodGet, path: path, query: query}, out)
+}
+
+func (c *Client) send(ctx context.Context, method, path string, body, out any) error {
+	return c.do(ctx, request{method: method, path: path, body: body}, out)
+}
+
+// do performs req and decodes the JSON answer into out (if not nil). An
+// expired access token is refreshed once and the request retried.
+func (c *Client) do(ctx context.Context, req request, out any) error {
+	resp, err := c.roundTrip(ctx, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if out == nil {
+		io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("%s %s: decoding response: %w", req.method, req.path, err)
+	}
+	return nil
+}
+
+// roundTrip sends req and returns the response if it succeeded; the caller
+// closes its body.
+func (c *Client) roundTrip(ctx context.Context, req request) (*http.Response, error) {
+	var payload []byte
+	if req.body != nil {
+		var err error
+		if payload, err = json.Marshal(req.body); err != nil {
+			return nil, err
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		hreq, err := c.newRequest(ctx, req, payload)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := c.HTTPClient.Do(hreq)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode < 300 {
+			return resp, nil
+		}
+		apiErr := decodeError(resp)
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusUnauthorized && attempt == 0 && !req.noRefresh && req.raw == nil && c.canRefresh() {
+			if _, err := c.RefreshToken(ctx); err == nil {
+				continue
+			}
+		}
+		return nil, apiErr
+	}
+}
+
+func (c *Client) newRequest(ctx context.Context, req request, payload []byte) (*http.Request, error) {
+	u := c.BaseURL + APIPrefix + req.path
+	if len(req.query) > 0 {
+		u += "?" + req.query.Encode()
+	}
+	var body io.Reader
+	switch {
+	case req.raw != nil:
+		body = req.raw
+	case payload != nil:
+		body = bytes.NewReader(payload)
+	}
+	hreq, err := http.NewRequestWithContext(ctx, req.method, u, body)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case req.raw != nil:
+		hreq.Header.Set("Content-Type", req.contentType)
+	case payload != nil:
+		hreq.Header.Set("Content-Type", "application/json")
+	}
+	hreq.Header.Set("Accept", "application/json")
+	if token := c.Token(); token != "" {
+		hreq.Header.Set("Authorization", "Bearer "+token)
+	}
+	return hreq, nil
+}
+
+func (c *Client) canRefresh() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.refreshToken != "" && !strings.HasPrefix(c.token, apiTokenPrefix)
+}
+
+func decodeError(resp *http.Response) error {
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	e := &Error{StatusCode: resp.StatusCode}
+	if json.Unmarshal(data, e) != nil || e.Message == "" {
+		e.Message = strings.TrimSpace(string(data))
+		if e.Message == "" {
+			e.Message = http.StatusText(resp.StatusCode)
+		}
+	}
+	if e.RequestID == "" {
+		e.RequestID = resp.Header.Get("X-Request-Id")
+	}
+	return e
+}
+
+// pathEscape escapes one path segment, e.g. a domain or an email address.
+func pathEscape(s string) string {
+	return url.PathEscape(s)
+}
@@ -0,0 +1,189 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ListJobs lists recent jobs, filtered by status and kind if set.
+func (c *Client) ListJobs(ctx context.Context, status, kind string) ([]Job, error) {
+	var out []Job
+	return out, c.get(ctx, "/jobs", query("status", status, "kind", kind), &out)
+}
+
+func (c *Client) GetJob(ctx context.Context, id string) (*Job, error) {
+	var out Job
+	if err := c.get(ctx, "/jobs/"+pathEscape(id), nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// CancelJob asks a running job to stop.
+func (c *Client) CancelJob(ctx context.Context, id string) (*Job, error) {
+	var out Job
+	if err := c.send(ctx, http.MethodDelete, "/jobs/"+pathEscape(id), nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// JobEvent is one event of a job stream: a line of output, or the job
+// whenever its steps or status change. Done is set on the last event.
+type JobEvent struct {
+	Output string
+	Job    *Job
+	Done   bool
+}
+
+// StreamJob follows a job's events until it finishes, calling fn for each,
+// and returns the finished job. A dropped stream is resumed where it left
+// off.
+func (c *Client) StreamJob(ctx context.Context, id string, fn func(JobEvent)) (*Job, error) {
+	var lastID string
+	for failures := 0; ; {
+		job, err := c.streamJob(ctx, id, &lastID, fn)
+		if err == nil {
+			return job, nil
+		}
+		var apiErr *Error
+		if ctx.Err() != nil || errors.As(err, &apiErr) {
+			return nil, err
+		}
+		if failures++; failures > 5 {
+			return nil, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// streamJob reads one stream connection; it returns the job once the "done"
+// event arrived, or an error.
+func (c *Client) streamJob(ctx context.Context, id string, lastID *string, fn func(JobEvent)) (*Job, error) {
+	hreq, err := c.newRequest(ctx, request{method: http.MethodGet, path: "/jobs/" + pathEscape(id) + "/stream"}, nil)
+	if err != nil {
+		return nil, err
+	}
+	hreq.Header.Set("Accept", "text/event-stream")
+	if *lastID != "" {
+		hreq.Header.Set("Last-Event-ID", *lastID)
+	}
+	// The stream outlives the client's request timeout.
+	hc := *c.HTTPClient
+	hc.Timeout = 0
+	resp, err := hc.Do(hreq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, decodeError(resp)
+	}
+
+	var event, data string
+	sc := bufio.NewScanner(resp.Body)
+	sc.Buffer(make([]byte, 64*1024), 4<<20)
+	for sc.Scan() {
+		line := sc.Text()
+		switch {
+		case line == "":
+			if event == "" && data == "" {
+				continue
+			}
+			job, err := dispatchJobEvent(event, data, fn)
+			if err != nil || job != nil {
+				return job, err
+			}
+			event, data = "", ""
+		case strings.HasPrefix(line, ":"):
+			// comment, e.g. keepalive
+		case strings.HasPrefix(line, "id: "):
+			*lastID = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "event: "):
+			event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			if data != "" {
+				data += "\n"
+			}
+			data += strings.TrimPrefix(line, "data: ")
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.ErrUnexpectedEOF
+}
+
+// dispatchJobEvent hands one event to fn; it returns the job once the event
+// is the final one.
+func dispatchJobEvent(event, data string, fn func(JobEvent)) (*Job, error) {
+	switch event {
+	case "output":
+		fn(JobEvent{Output: data})
+	case "job", "done":
+		var job Job
+		if err := json.Unmarshal([]byte(data), &job); err != nil {
+			return nil, fmt.Errorf("decoding %s event: %w", event, err)
+		}
+		done := event == "done"
+		fn(JobEvent{Job: &job, Done: done})
+		if done {
+			return &job, nil
+		}
+	}
+	return nil, nil
+}
+
+// WaitJob waits for a job to finish, copying its output to out (if not nil).
+// It returns an error if the job did not succeed.
+func (c *Client) WaitJob(ctx context.Context, id string, out io.Writer) (*Job, error) {
+	job, err := c.StreamJob(ctx, id, func(ev JobEvent) {
+		if out != nil && ev.Job == nil {
+			fmt.Fprintln(out, ev.Output)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if job.Status != JobSucceeded {
+		return job, &JobError{Job: job}
+	}
+	return job, nil
+}
+
+// JobError is returned by WaitJob for a job that failed, was cancelled or
+// was interrupted.
+type JobError struct {
+	Job *Job
+}
+
+func (e *JobError) Error() string {
+	msg := fmt.Sprintf("job %s %s", e.Job.ID, e.Job.Status)
+	if e.Job.Error != "" {
+		msg += ": " + e.Job.Error
+	}
+	if e.Job.ErrorCode != "" {
+		msg += " (" + e.Job.ErrorCode + ")"
+	}
+	return msg
+}
+
+// startJob POSTs body to path and returns the accepted job.
+func (c *Client) startJob(ctx context.Context, path string, body any) (*Job, error) {
+	var out Job
+	if err := c.send(ctx, http.MethodPost, path, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
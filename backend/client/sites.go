@@ -0,0 +1,345 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+)
+
+// ── vhosts ──────────────────────────────────────────────────────────────────
+
+func (c *Client) ListVhosts(ctx context.Context) ([]Vhost, error) {
+	var out []Vhost
+	return out, c.get(ctx, "/vhosts", nil, &out)
+}
+
+func (c *Client) CreateVhost(ctx context.Context, req CreateVhostRequest) (Status, error) {
+	var out Status
+	return out, c.send(ctx, http.MethodPost, "/vhosts", req, &out)
+}
+
+// UpdateVhost changes a vhost in place and returns it as it is now.
+func (c *Client) UpdateVhost(ctx context.Context, domain string, req UpdateVhostRequest) (Vhost, error) {
+	var out Vhost
+	return out, c.send(ctx, http.MethodPut, "/vhosts/"+pathEscape(domain), req, &out)
+}
+
+func (c *Client) DeleteVhost(ctx context.Context, domain string) (Status, error) {
+	var out Status
+	return out, c.send(ctx, http.MethodDelete, "/vhosts/"+pathEscape(domain), nil, &out)
+}
+
+func (c *Client) EnableVhost(ctx context.Context, domain string) (Status, error) {
+	var out Status
+	return out, c.send(ctx, http.MethodPost, "/vhosts/"+pathEscape(domain)+"/enable", nil, &out)
+}
+
+func (c *Client) DisableVhost(ctx context.Context, domain string) (Status, error) {
+	var out Status
+	return out, c.send(ctx, http.MethodPost, "/vhosts/"+pathEscape(domain)+"/disable", nil, &out)
+}
+
+// EnableSSL starts a job issuing a Let's Encrypt certificate for domain;
+// email is the ACME account address.
+func (c *Client) EnableSSL(ctx context.Context, domain, email string) (*Job, error) {
+	return c.startJob(ctx, "/vhosts/"+pathEscape(domain)+"/ssl", map[string]string{"email": email})
+}
+
+func (c *Client) ListVhostProfiles(ctx context.Context) ([]VhostProfile, error) {
+	var out []VhostProfile
+	return out, c.get(ctx, "/vhosts/profiles", nil, &out)
+}
+
+// GetVhostProfile returns a profile with its template source.
+func (c *Client) GetVhostProfile(ctx context.Context, name string) (VhostProfile, error) {
+	var out VhostProfile
+	return out, c.get(ctx, "/vhosts/profiles/"+pathEscape(name), nil, &out)
+}
+
+// SaveVhostProfile creates or replaces a custom profile; the panel rejects
+// templates that do not render or fail nginx -t.
+func (c *Client) SaveVhostProfile(ctx context.Context, name, template string) (VhostProfile, error) {
+	var out VhostProfile
+	body := map[string]string{"template": template}
+	return out, c.send(ctx, http.MethodPut, "/vhosts/profiles/"+pathEscape(name), body, &out)
+}
+
+func (c *Client) DeleteVhostProfile(ctx context.Context, name string) (Status, error) {
+	var out Status
+	return out, c.send(ctx, http.MethodDelete, "/vhosts/profiles/"+pathEscape(name), nil, &out)
+}
+
+// ── databases ───────────────────────────────────────────────────────────────
+
+func (c *Client) ListDatabases(ctx context.Context) ([]Database, error) {
+	var out []Database
+	return out, c.get(ctx, "/databases", nil, &out)
+}
+
+// CreateDatabase creates a database, and a user for it if DBUser is set.
+func (c *Client) CreateDatabase(ctx context.Context, req CreateDatabaseRequest) (Status, error) {
+	var out Status
+	return out, c.send(ctx, http.MethodPost, "/databases", req, &out)
+}
+
+func (c *Client) DropDatabase(ctx context.Context, name string) (Status, error) {
+	var out Status
+	return out, c.send(ctx, http.MethodDelete, "/databases/"+pathEscape(name), nil, &out)
+}
+
+func (c *Client) ListTables(ctx context.Context, database string) ([]string, error) {
+	var out struct {
+		Tables []string `json:"tables"`
+	}
+	err := c.get(ctx, "/databases/"+pathEscape(database)+"/tables", nil, &out)
+	return out.Tables, err
+}
+
+// ── files ───────────────────────────────────────────────────────────────────
+
+// Paths are relative to the panel's file manager root.
+
+func (c *Client) ListFiles(ctx context.Context, path string) ([]FileEntry, error) {
+	var out struct {
+		Files []FileEntry `json:"files"`
+	}
+	err := c.get(ctx, "/files", query("path", path), &out)
+	return out.Files, err
+}
+
+func (c *Client) MakeDirectory(ctx context.Context, path string) (Status, error) {
+	var out Status
+	return out, c.send(ctx, http.MethodPost, "/files/mkdir", map[string]string{"path": path}, &out)
+}
+
+func (c *Client) DeleteFile(ctx context.Context, path string) (Status, error) {
+	var out Status
+	return out, c.do(ctx, request{method: http.MethodDelete, path: "/files", query: query("path", path)}, &out)
+}
+
+func (c *Client) RenameFile(ctx context.Context, from, to string) (Status, error) {
+	var out Status
+	return out, c.send(ctx, http.MethodPost, "/files/rename", map[string]string{"from": from, "to": to}, &out)
+}
+
+// ReadFile returns the content of a file of at most 2 MB.
+func (c *Client) ReadFile(ctx context.Context, path string) (string, error) {
+	var out struct {
+		Content string `json:"content"`
+	}
+	err := c.get(ctx, "/files/read", query("path", path), &out)
+	return out.Content, err
+}
+
+func (c *Client) WriteFile(ctx context.Context, path, content string) (Status, error) {
+	var out Status
+	return out, c.send(ctx, http.MethodPost, "/files/write", map[string]string{"path": path, "content": content}, &out)
+}
+
+// UploadFile uploads r as filename into the directory dir.
+func (c *Client) UploadFile(ctx context.Context, dir, filename string, r io.Reader) (Status, error) {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	if err := mw.WriteField("path", dir); err != nil {
+		return nil, err
+	}
+	part, err := mw.CreateFormFile("file", filename)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(part, r); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", filename, err)
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+
+	var out Status
+	return out, c.do(ctx, request{
+		method:      http.MethodPost,
+		path:        "/files/upload",
+		raw:         &buf,
+		contentType: mw.FormDataContentType(),
+	}, &out)
+}
+
+// ── email ───────────────────────────────────────────────────────────────────
+
+func (c *Client) ListMailDomains(ctx context.Context) ([]MailDomain, error) {
+	var out []MailDomain
+	return out, c.get(ctx, "/email/domains", nil, &out)
+}
+
+func (c *Client) AddMailDomain(ctx context.Context, domain, owner string) (Status, error) {
+	var out Status
+	return out, c.send(ctx, http.MethodPost, "/email/domains", map[string]string{"domain": domain, "owner": owner}, &out)
+}
+
+func (c *Client) DeleteMailDomain(ctx context.Context, domain string) (Status, error) {
+	var out Status
+	return out, c.send(ctx, http.MethodDelete, "/email/domains/"+pathEscape(domain), nil, &out)
+}
+
+// ListMailboxes lists the mailboxes, of one domain if domain is set.
+func (c *Client) ListMailboxes(ctx context.Context, domain string) ([]Mailbox, error) {
+	var out []Mailbox
+	return out, c.get(ctx, "/email/mailboxes", query("domain", domain), &out)
+}
+
+// CreateMailbox creates a mailbox; quota is e.g. "1G" or "500M".
+func (c *Client) CreateMailbox(ctx context.Context, email, password, quota string) (Status, error) {
+	var out Status
+	body := map[string]string{"email": email, "password": password, "quota": quota}
+	return out, c.send(ctx, http.MethodPost, "/email/mailboxes", body, &out)
+}
+
+func (c *Client) DeleteMailbox(ctx context.Context, email string) (Status, error) {
+	var out Status
+	return out, c.send(ctx, http.MethodDelete, "/email/mailboxes/"+pathEscape(email), nil, &out)
+}
+
+// ── cron ────────────────────────────────────────────────────────────────────
+
+// ListCronJobs lists user's cron jobs, or all visible ones if user is empty.
+// Job IDs are positions in the user's crontab.
+func (c *Client) ListCronJobs(ctx context.Context, user string) ([]CronJob, error) {
+	var out []CronJob
+	return out, c.get(ctx, "/cron", query("user", user), &out)
+}
+
+func (c *Client) CreateCronJob(ctx context.Context, req CronJobRequest) (Status, error) {
+	var out Status
+	return out, c.send(ctx, http.MethodPost, "/cron", req, &out)
+}
+
+func (c *Client) UpdateCronJob(ctx context.Context, id int, req CronJobRequest) (Status, error) {
+	var out Status
+	return out, c.send(ctx, http.MethodPut, "/cron/"+strconv.Itoa(id), req, &out)
+}
+
+func (c *Client) DeleteCronJob(ctx context.Context, user string, id int) (Status, error) {
+	var out Status
+	return out, c.do(ctx, request{method: http.MethodDelete, path: "/cron/" + strconv.Itoa(id), query: query("user", user)}, &out)
+}
+
+// RunCronNow starts a job running the cron job's command.
+func (c *Client) RunCronNow(ctx context.Context, user string, id int) (*Job, error) {
+	var out Job
+	err := c.do(ctx, request{method: http.MethodPost, path: "/cron/" + strconv.Itoa(id) + "/run", query: query("user", user)}, &out)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ── FTP ─────────────────────────────────────────────────────────────────────
+
+func (c *Client) ListFTPUsers(ctx context.Context) ([]FTPUser, error) {
+	var out []FTPUser
+	return out, c.get(ctx, "/ftp", nil, &out)
+}
+
+func (c *Client) CreateFTPUser(ctx context.Context, req CreateFTPUserRequest) (Status, error) {
+	var out Status
+	return out, c.send(ctx, http.MethodPost, "/ftp", req, &out)
+}
+
+func (c *Client) UpdateFTPPassword(ctx context.Context, username, password string) (Status, error) {
+	var out Status
+	return out, c.send(ctx, http.MethodPut, "/ftp/"+pathEscape(username), map[string]string{"password": password}, &out)
+}
+
+func (c *Client) DeleteFTPUser(ctx context.Context, username string) (Status, error) {
+	var out Status
+	return out, c.send(ctx, http.MethodDelete, "/ftp/"+pathEscape(username), nil, &out)
+}
+
+// ── WordPress ───────────────────────────────────────────────────────────────
+
+func (c *Client) ListWPSites(ctx context.Context) ([]WPSite, error) {
+	var out []WPSite
+	return out, c.get(ctx, "/wordpress", nil, &out)
+}
+
+// CreateWPSite starts a job installing WordPress; the job's Result carries
+// the generated credentials.
+func (c *Client) CreateWPSite(ctx context.Context, req CreateWPSiteRequest) (*Job, error) {
+	return c.startJob(ctx, "/wordpress", req)
+}
+
+// DeleteWPSite removes the site's files and, if deleteDB, the database
+// and user the panel created for it; Status["database"] tells whether
+// they were dropped.
+func (c *Client) DeleteWPSite(ctx context.Context, domain string, deleteDB bool) (Status, error) {
+	var out Status
+	body := map[string]bool{"delete_db": deleteDB}
+	return out, c.send(ctx, http.MethodDelete, "/wordpress/"+pathEscape(domain), body, &out)
+}
+
+func (c *Client) ListWPPlugins(ctx context.Context, domain string) ([]WPExtension, error) {
+	var out []WPExtension
+	return out, c.get(ctx, "/wordpress/"+pathEscape(domain)+"/plugins", nil, &out)
+}
+
+func (c *Client) InstallWPPlugin(ctx context.Context, domain, name string, activate bool) (Status, error) {
+	return c.installWPExtension(ctx, domain, "plugins", name, activate)
+}
+
+// ToggleWPPlugin runs action ("activate", "deactivate", "update" or
+// "delete") on a plugin.
+func (c *Client) ToggleWPPlugin(ctx context.Context, domain, plugin, action string) (Status, error) {
+	return c.toggleWPExtension(ctx, domain, "plugins", plugin, action)
+}
+
+func (c *Client) ListWPThemes(ctx context.Context, domain string) ([]WPExtension, error) {
+	var out []WPExtension
+	return out, c.get(ctx, "/wordpress/"+pathEscape(domain)+"/themes", nil, &out)
+}
+
+func (c *Client) InstallWPTheme(ctx context.Context, domain, name string, activate bool) (Status, error) {
+	return c.installWPExtension(ctx, domain, "themes", name, activate)
+}
+
+// ToggleWPTheme runs action ("activate", "update" or "delete") on a theme.
+func (c *Client) ToggleWPTheme(ctx context.Context, domain, theme, action string) (Status, error) {
+	return c.toggleWPExtension(ctx, domain, "themes", theme, action)
+}
+
+// WPUpdateCore starts a job updating WordPress core, plugins and themes.
+func (c *Client) WPUpdateCore(ctx context.Context, domain string) (*Job, error) {
+	return c.startJob(ctx, "/wordpress/"+pathEscape(domain)+"/update", nil)
+}
+
+func (c *Client) WPMaintenanceMode(ctx context.Context, domain string, enable bool) (Status, error) {
+	var out Status
+	body := map[string]bool{"enable": enable}
+	return out, c.send(ctx, http.MethodPost, "/wordpress/"+pathEscape(domain)+"/maintenance", body, &out)
+}
+
+// WPSearchReplace runs wp search-replace over the site's database.
+func (c *Client) WPSearchReplace(ctx context.Context, domain, search, replace string) (Status, error) {
+	var out Status
+	body := map[string]string{"search": search, "replace": replace}
+	return out, c.send(ctx, http.MethodPost, "/wordpress/"+pathEscape(domain)+"/search-replace", body, &out)
+}
+
+func (c *Client) WPCacheFlush(ctx context.Context, domain string) (Status, error) {
+	var out Status
+	return out, c.send(ctx, http.MethodPost, "/wordpress/"+pathEscape(domain)+"/cache-flush", nil, &out)
+}
+
+func (c *Client) installWPExtension(ctx context.Context, domain, kind, name string, activate bool) (Status, error) {
+	var out Status
+	body := map[string]any{"name": name, "activate": activate}
+	return out, c.send(ctx, http.MethodPost, "/wordpress/"+pathEscape(domain)+"/"+kind, body, &out)
+}
+
+func (c *Client) toggleWPExtension(ctx context.Context, domain, kind, name, action string) (Status, error) {
+	var out Status
+	body := map[string]string{"action": action}
+	return out, c.send(ctx, http.MethodPut, "/wordpress/"+pathEscape(domain)+"/"+kind+"/"+pathEscape(name), body, &out)
+}
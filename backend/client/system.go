@@ -0,0 +1,224 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ── system ──────────────────────────────────────────────────────────────────
+
+func (c *Client) GetSystemStats(ctx context.Context) (*SystemStats, error) {
+	var out SystemStats
+	if err := c.get(ctx, "/system/stats", nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *Client) GetServices(ctx context.Context) ([]Service, error) {
+	var out []Service
+	return out, c.get(ctx, "/system/services", nil, &out)
+}
+
+func (c *Client) RestartService(ctx context.Context, name string) (Status, error) {
+	return c.serviceAction(ctx, name, "restart")
+}
+
+func (c *Client) StopService(ctx context.Context, name string) (Status, error) {
+	return c.serviceAction(ctx, name, "stop")
+}
+
+func (c *Client) StartService(ctx context.Context, name string) (Status, error) {
+	return c.serviceAction(ctx, name, "start")
+}
+
+func (c *Client) serviceAction(ctx context.Context, name, action string) (Status, error) {
+	var out Status
+	return out, c.send(ctx, http.MethodPost, "/system/services/"+pathEscape(name)+"/"+action, nil, &out)
+}
+
+// GetLogs returns the last lines of the journal, of one unit if unit is set.
+// lines 0 means the panel's default.
+func (c *Client) GetLogs(ctx context.Context, unit string, lines int) ([]LogEntry, error) {
+	q := query("unit", unit)
+	if lines > 0 {
+		q.Set("lines", strconv.Itoa(lines))
+	}
+	var out []LogEntry
+	return out, c.get(ctx, "/system/logs", q, &out)
+}
+
+// ── system users ────────────────────────────────────────────────────────────
+
+func (c *Client) ListUsers(ctx context.Context) ([]User, error) {
+	var out []User
+	return out, c.get(ctx, "/users", nil, &out)
+}
+
+func (c *Client) CreateUser(ctx context.Context, req CreateUserRequest) (Status, error) {
+	var out Status
+	return out, c.send(ctx, http.MethodPost, "/users", req, &out)
+}
+
+func (c *Client) UpdateUser(ctx context.Context, username string, req UpdateUserRequest) (Status, error) {
+	var out Status
+	return out, c.send(ctx, http.MethodPut, "/users/"+pathEscape(username), req, &out)
+}
+
+func (c *Client) DeleteUser(ctx context.Context, username string) (Status, error) {
+	var out Status
+	return out, c.send(ctx, http.MethodDelete, "/users/"+pathEscape(username), nil, &out)
+}
+
+func (c *Client) SuspendUser(ctx context.Context, username string) (Status, error) {
+	var out Status
+	return out, c.send(ctx, http.MethodPost, "/users/"+pathEscape(username)+"/suspend", nil, &out)
+}
+
+func (c *Client) ActivateUser(ctx context.Context, username string) (Status, error) {
+	var out Status
+	return out, c.send(ctx, http.MethodPost, "/users/"+pathEscape(username)+"/activate", nil, &out)
+}
+
+// ── mail queue ──────────────────────────────────────────────────────────────
+
+// GetMailQueue returns the output of postqueue -p.
+func (c *Client) GetMailQueue(ctx context.Context) (string, error) {
+	var out struct {
+		Queue string `json:"queue"`
+	}
+	err := c.get(ctx, "/email/queue", nil, &out)
+	return out.Queue, err
+}
+
+func (c *Client) FlushMailQueue(ctx context.Context) (Status, error) {
+	var out Status
+	return out, c.send(ctx, http.MethodPost, "/email/queue/flush", nil, &out)
+}
+
+// ── DNS ─────────────────────────────────────────────────────────────────────
+
+// ListDNSZones lists the zones; only Domain is set on each.
+func (c *Client) ListDNSZones(ctx context.Context) ([]DNSZone, error) {
+	var out []DNSZone
+	return out, c.get(ctx, "/dns", nil, &out)
+}
+
+// CreateDNSZone creates a zone with default records pointing at ip.
+func (c *Client) CreateDNSZone(ctx context.Context, domain, ip string) (Status, error) {
+	var out Status
+	return out, c.send(ctx, http.MethodPost, "/dns", map[string]string{"domain": domain, "ip": ip}, &out)
+}
+
+func (c *Client) GetDNSZone(ctx context.Context, domain string) (*DNSZone, error) {
+	var out DNSZone
+	if err := c.get(ctx, "/dns/"+pathEscape(domain), nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *Client) DeleteDNSZone(ctx context.Context, domain string) (Status, error) {
+	var out Status
+	return out, c.send(ctx, http.MethodDelete, "/dns/"+pathEscape(domain), nil, &out)
+}
+
+func (c *Client) AddDNSRecord(ctx context.Context, domain string, rec DNSRecord) (Status, error) {
+	var out Status
+	return out, c.send(ctx, http.MethodPost, "/dns/"+pathEscape(domain)+"/records", rec, &out)
+}
+
+// DeleteDNSRecord deletes the records matching rec's name and type (and
+// value, if set).
+func (c *Client) DeleteDNSRecord(ctx context.Context, domain string, rec DNSRecord) (Status, error) {
+	var out Status
+	return out, c.send(ctx, http.MethodDelete, "/dns/"+pathEscape(domain)+"/records", rec, &out)
+}
+
+// ── ownership, audit, settings ──────────────────────────────────────────────
+
+// ListOwnership lists ownership records, filtered by owner and kind if set.
+func (c *Client) ListOwnership(ctx context.Context, owner, kind string) ([]Ownership, error) {
+	var out []Ownership
+	return out, c.get(ctx, "/ownership", query("owner", owner, "kind", kind), &out)
+}
+
+// SetOwnership assigns a resource to a site owner; an empty owner clears it.
+func (c *Client) SetOwnership(ctx context.Context, kind, name, owner string) (*Ownership, error) {
+	var out Ownership
+	err := c.send(ctx, http.MethodPut, "/ownership/"+pathEscape(kind)+"/"+pathEscape(name), map[string]string{"owner": owner}, &out)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *Client) GetAuditLog(ctx context.Context, q AuditQuery) ([]AuditEntry, error) {
+	v := query("actor", q.Actor, "resource", q.Resource, "outcome", q.Outcome)
+	if !q.Since.IsZero() {
+		v.Set("since", q.Since.Format(time.RFC3339))
+	}
+	if !q.Until.IsZero() {
+		v.Set("until", q.Until.Format(time.RFC3339))
+	}
+	if q.Limit > 0 {
+		v.Set("limit", strconv.Itoa(q.Limit))
+	}
+	var out []AuditEntry
+	return out, c.get(ctx, "/audit", v, &out)
+}
+
+func (c *Client) GetSettings(ctx context.Context) (*Settings, error) {
+	var out Settings
+	if err := c.get(ctx, "/settings", nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ── panel accounts and resources ────────────────────────────────────────────
+
+func (c *Client) ListPanelUsers(ctx context.Context) ([]PanelUser, error) {
+	var out []PanelUser
+	return out, c.get(ctx, "/panel-users", nil, &out)
+}
+
+func (c *Client) CreatePanelUser(ctx context.Context, req PanelUserRequest) (*PanelUser, error) {
+	var out PanelUser
+	if err := c.send(ctx, http.MethodPost, "/panel-users", req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *Client) UpdatePanelUser(ctx context.Context, username string, req PanelUserRequest) (*PanelUser, error) {
+	var out PanelUser
+	if err := c.send(ctx, http.MethodPut, "/panel-users/"+pathEscape(username), req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *Client) DeletePanelUser(ctx context.Context, username string) (Status, error) {
+	var out Status
+	return out, c.send(ctx, http.MethodDelete, "/panel-users/"+pathEscape(username), nil, &out)
+}
+
+// ListResources lists the panel's resource records, filtered by kind and
+// owner if set.
+func (c *Client) ListResources(ctx context.Context, kind, owner string) ([]Resource, error) {
+	var out []Resource
+	return out, c.get(ctx, "/resources", query("kind", kind, "owner", owner), &out)
+}
+
+// UpdateResource sets a resource's notes.
+func (c *Client) UpdateResource(ctx context.Context, kind, name, notes string) (*Resource, error) {
+	var out Resource
+	err := c.send(ctx, http.MethodPut, "/resources/"+pathEscape(kind)+"/"+pathEscape(name), map[string]string{"notes": notes}, &out)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
@@ -0,0 +1,457 @@
+package client
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// The types below mirror the JSON of the panel API; see GET
+// /api/openapi.json for the authoritative description.
+
+// Status is the {"status": "created", ...} answer of most changes.
+type Status map[string]string
+
+// ── auth ────────────────────────────────────────────────────────────────────
+
+// Tokens is a successful login or refresh.
+type Tokens struct {
+	Token          string `json:"token"`
+	Expires        int64  `json:"expires"`
+	RefreshToken   string `json:"refresh_token"`
+	RefreshExpires int64  `json:"refresh_expires"`
+	User           string `json:"user"`
+	Role           string `json:"role"`
+}
+
+// LoginResult is either tokens or, for accounts with two-factor login, a
+// pre-auth token to pass to VerifyLogin with the code.
+type LoginResult struct {
+	Tokens
+	MFARequired  bool   `json:"mfa_required"`
+	PreAuthToken string `json:"pre_auth_token"`
+}
+
+type Session struct {
+	ID         string    `json:"id"`
+	Username   string    `json:"username"`
+	IP         string    `json:"ip"`
+	UserAgent  string    `json:"user_agent"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Current    bool      `json:"current"`
+}
+
+type APIToken struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Owner      string     `json:"owner"`
+	Scopes     []string   `json:"scopes"`
+	AllowedIPs []string   `json:"allowed_ips,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	LastUsedIP string     `json:"last_used_ip,omitempty"`
+}
+
+type CreateAPITokenRequest struct {
+	Name          string   `json:"name"`
+	Scopes        []string `json:"scopes"`                // e.g. "vhosts:write", "wordpress:cache"
+	ExpiresInDays int      `json:"expires_in_days"`       // 0 = never
+	AllowedIPs    []string `json:"allowed_ips,omitempty"` // IPs or CIDRs; empty = any
+}
+
+// NewAPIToken is a created token; Token is only ever shown here.
+type NewAPIToken struct {
+	APIToken
+	Token string `json:"token"`
+}
+
+type TOTPSetup struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+}
+
+// ── system ──────────────────────────────────────────────────────────────────
+
+type SystemStats struct {
+	CPU struct {
+		UsedPct float64 `json:"used_pct"`
+		Cores   int     `json:"cores"`
+	} `json:"cpu"`
+	RAM struct {
+		TotalMB int64   `json:"total_mb"`
+		UsedMB  int64   `json:"used_mb"`
+		FreeMB  int64   `json:"free_mb"`
+		UsedPct float64 `json:"used_pct"`
+	} `json:"ram"`
+	Disk struct {
+		TotalGB float64 `json:"total_gb"`
+		UsedGB  float64 `json:"used_gb"`
+		FreePct float64 `json:"free_pct"`
+		UsedPct float64 `json:"used_pct"`
+	} `json:"disk"`
+	Uptime  string `json:"uptime"`
+	LoadAvg string `json:"load_avg"`
+	OS      string `json:"os"`
+}
+
+type Service struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Active bool   `json:"active"`
+	PID    string `json:"pid"`
+	Uptime string `json:"uptime"`
+}
+
+type LogEntry struct {
+	Time    string `json:"time"`
+	Level   string `json:"level"`
+	Message string `json:"message"`
+	Unit    string `json:"unit"`
+}
+
+type User struct {
+	Username string `json:"username"`
+	UID      string `json:"uid"`
+	GID      string `json:"gid"`
+	Home     string `json:"home"`
+	Shell    string `json:"shell"`
+	Locked   bool   `json:"locked"`
+}
+
+type CreateUserRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Shell    string `json:"shell,omitempty"`
+	Groups   string `json:"groups,omitempty"` // comma-separated
+}
+
+// UpdateUserRequest changes whichever fields are set.
+type UpdateUserRequest struct {
+	Password string `json:"password,omitempty"`
+	Shell    string `json:"shell,omitempty"`
+}
+
+// ── DNS ─────────────────────────────────────────────────────────────────────
+
+type DNSZone struct {
+	Domain  string      `json:"domain"`
+	Serial  int64       `json:"serial,omitempty"`
+	Records []DNSRecord `json:"records,omitempty"`
+}
+
+type DNSRecord struct {
+	Name  string `json:"name"`
+	TTL   string `json:"ttl,omitempty"`
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// ── accounts, ownership, audit ──────────────────────────────────────────────
+
+type Ownership struct {
+	Kind  string `json:"kind"`
+	Name  string `json:"name"`
+	Owner string `json:"owner"`
+}
+
+type PanelUser struct {
+	Username    string    `json:"username"`
+	Role        string    `json:"role"`
+	Disabled    bool      `json:"disabled"`
+	TOTPEnabled bool      `json:"totp_enabled"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// PanelUserRequest creates an account, or updates the fields that are set.
+type PanelUserRequest struct {
+	Username  string `json:"username,omitempty"`
+	Password  string `json:"password,omitempty"`
+	Role      string `json:"role,omitempty"` // admin, operator, read-only, site-owner
+	Disabled  *bool  `json:"disabled,omitempty"`
+	ResetTOTP bool   `json:"reset_totp,omitempty"`
+}
+
+type Resource struct {
+	Kind       string            `json:"kind"`
+	Name       string            `json:"name"`
+	Owner      string            `json:"owner,omitempty"`
+	PHPVersion string            `json:"php_version,omitempty"`
+	Notes      string            `json:"notes,omitempty"`
+	Meta       map[string]string `json:"meta,omitempty"`
+	CreatedAt  time.Time         `json:"created_at"`
+	UpdatedAt  time.Time         `json:"updated_at"`
+}
+
+// AuditQuery filters GetAuditLog; zero fields are not filtered on.
+type AuditQuery struct {
+	Actor    string
+	Resource string
+	Outcome  string // "success" or "failure"
+	Since    time.Time
+	Until    time.Time
+	Limit    int
+}
+
+type AuditEntry struct {
+	Time       time.Time      `json:"time"`
+	RequestID  string         `json:"request_id"`
+	Actor      string         `json:"actor"`
+	Role       string         `json:"role"`
+	TokenID    string         `json:"token_id,omitempty"`
+	IP         string         `json:"ip"`
+	Method     string         `json:"method"`
+	Path       string         `json:"path"`
+	Route      string         `json:"route"`
+	Resource   string         `json:"resource"`
+	Params     map[string]any `json:"params,omitempty"`
+	Status     int            `json:"status"`
+	Outcome    string         `json:"outcome"`
+	Error      string         `json:"error,omitempty"`
+	ErrorCode  string         `json:"error_code,omitempty"`
+	DurationMS int64          `json:"duration_ms"`
+	Commands   []struct {
+		Command    string   `json:"command"`
+		Args       []string `json:"args"`
+		DurationMS int64    `json:"duration_ms"`
+		Error      string   `json:"error,omitempty"`
+		Background bool     `json:"background,omitempty"`
+	} `json:"commands,omitempty"`
+}
+
+// Settings is the panel configuration with secrets masked.
+type Settings struct {
+	ConfigFile string          `json:"config_file"`
+	Settings   json.RawMessage `json:"settings"`
+}
+
+// ── jobs ────────────────────────────────────────────────────────────────────
+
+// Job statuses
+const (
+	JobRunning     = "running"
+	JobSucceeded   = "succeeded"
+	JobFailed      = "failed"
+	JobCancelled   = "cancelled"
+	JobInterrupted = "interrupted"
+)
+
+type Job struct {
+	ID         string            `json:"id"`
+	Kind       string            `json:"kind"`
+	Target     string            `json:"target"`
+	Owner      string            `json:"owner"`
+	Status     string            `json:"status"`
+	Steps      []JobStep         `json:"steps"`
+	Output     string            `json:"output"`
+	Error      string            `json:"error,omitempty"`
+	ErrorCode  string            `json:"error_code,omitempty"`
+	Result     map[string]string `json:"result,omitempty"`
+	CreatedAt  time.Time         `json:"created_at"`
+	FinishedAt *time.Time        `json:"finished_at,omitempty"`
+}
+
+type JobStep struct {
+	Name       string     `json:"name"`
+	Status     string     `json:"status"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	Error      string     `json:"error,omitempty"`
+}
+
+// ── sites ───────────────────────────────────────────────────────────────────
+
+type Vhost struct {
+	Domain         string   `json:"domain"`
+	DocRoot        string   `json:"docroot"`
+	SSL            bool     `json:"ssl"`
+	Enabled        bool     `json:"enabled"`
+	PHP            string   `json:"php"`
+	IP             string   `json:"ip"`
+	Profile        string   `json:"profile,omitempty"`
+	ServerNames    []string `json:"server_names"`
+	Aliases        []string `json:"aliases,omitempty"`
+	Ports          []int    `json:"ports"`
+	PHPSocket      string   `json:"php_socket,omitempty"`
+	Upstreams      []string `json:"upstreams,omitempty"`
+	Certificate    string   `json:"ssl_certificate,omitempty"`
+	CertificateKey string   `json:"ssl_certificate_key,omitempty"`
+	File           string   `json:"file"`
+	Managed        bool     `json:"managed"`
+	Error          string   `json:"error,omitempty"`
+
+	ForceHTTPS bool           `json:"force_https"`
+	Canonical  string         `json:"canonical,omitempty"`
+	Redirects  []RedirectRule `json:"redirects,omitempty"`
+}
+
+type CreateVhostRequest struct {
+	Domain   string        `json:"domain"`
+	DocRoot  string        `json:"docroot,omitempty"`
+	PHP      string        `json:"php,omitempty"`
+	SSL      bool          `json:"ssl,omitempty"`
+	Owner    string        `json:"owner,omitempty"`
+	Profile  string        `json:"profile,omitempty"`  // default "php"
+	Upstream string        `json:"upstream,omitempty"` // shorthand for one Proxy.Upstreams entry
+	Proxy    *ProxyOptions `json:"proxy,omitempty"`    // for profiles that proxy
+
+	Aliases   []string       `json:"aliases,omitempty"`   // default www.<domain>
+	Canonical string         `json:"canonical,omitempty"` // "www" or "non-www"
+	Redirects []RedirectRule `json:"redirects,omitempty"`
+}
+
+// UpdateVhostRequest changes an existing vhost; nil fields are left as
+// they are. Certbot's SSL settings in the config are kept.
+type UpdateVhostRequest struct {
+	DocRoot    *string   `json:"docroot,omitempty"`
+	PHP        *string   `json:"php,omitempty"`
+	Aliases    *[]string `json:"aliases,omitempty"`    // server names besides the domain
+	Directives *string   `json:"directives,omitempty"` // extra nginx directives; "" removes them
+
+	ForceHTTPS *bool           `json:"force_https,omitempty"` // needs a certificate
+	Canonical  *string         `json:"canonical,omitempty"`   // "www", "non-www" or "" for both
+	Redirects  *[]RedirectRule `json:"redirects,omitempty"`   // replaces all rules
+}
+
+// RedirectRule redirects an exact path, or with Regex the paths matching a
+// PCRE, to a path or URL ($1... for captures).
+type RedirectRule struct {
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Regex bool   `json:"regex,omitempty"`
+	Code  int    `json:"code,omitempty"` // 301 (default) or 302
+}
+
+// ProxyOptions configure a reverse-proxy vhost. Zero values take the
+// panel's defaults.
+type ProxyOptions struct {
+	Upstreams      []string `json:"upstreams,omitempty"`       // port, host:port or unix:/path each
+	Balance        string   `json:"balance,omitempty"`         // round_robin, least_conn, ip_hash or random
+	WebSocket      *bool    `json:"websocket,omitempty"`       // default true
+	ConnectTimeout int      `json:"connect_timeout,omitempty"` // seconds
+	SendTimeout    int      `json:"send_timeout,omitempty"`    // seconds
+	ReadTimeout    int      `json:"read_timeout,omitempty"`    // seconds
+	MaxFails       int      `json:"max_fails,omitempty"`
+	FailTimeout    int      `json:"fail_timeout,omitempty"` // seconds
+	HealthPath     string   `json:"health_path,omitempty"`
+}
+
+// VhostProfile is an nginx config template vhosts are created from. PHP
+// and Upstream tell whether it uses those request fields; DocRoot is the
+// directory under the site directory it serves.
+type VhostProfile struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Builtin     bool   `json:"builtin"`
+	PHP         bool   `json:"php"`
+	Upstream    bool   `json:"upstream"`
+	DocRoot     string `json:"docroot,omitempty"`
+	Template    string `json:"template,omitempty"`
+}
+
+type Database struct {
+	Name   string `json:"name"`
+	Size   string `json:"size"`
+	Tables int    `json:"tables"`
+}
+
+type CreateDatabaseRequest struct {
+	Name     string `json:"name"`
+	DBUser   string `json:"db_user,omitempty"`
+	Password string `json:"password,omitempty"`
+	Host     string `json:"host,omitempty"`
+	Owner    string `json:"owner,omitempty"`
+}
+
+type FileEntry struct {
+	Name        string    `json:"name"`
+	Path        string    `json:"path"`
+	IsDir       bool      `json:"is_dir"`
+	Size        int64     `json:"size"`
+	Permissions string    `json:"permissions"`
+	Modified    time.Time `json:"modified"`
+}
+
+type MailDomain struct {
+	Domain    string `json:"domain"`
+	Mailboxes int    `json:"mailboxes"`
+	Active    bool   `json:"active"`
+}
+
+type Mailbox struct {
+	Email  string `json:"email"`
+	Domain string `json:"domain"`
+	User   string `json:"user"`
+	Quota  string `json:"quota"`
+	Active bool   `json:"active"`
+}
+
+type CronJob struct {
+	ID       int    `json:"id"`
+	Minute   string `json:"minute"`
+	Hour     string `json:"hour"`
+	Day      string `json:"day"`
+	Month    string `json:"month"`
+	Weekday  string `json:"weekday"`
+	Command  string `json:"command"`
+	User     string `json:"user"`
+	Schedule string `json:"schedule"`
+	Enabled  bool   `json:"enabled"`
+}
+
+type CronJobRequest struct {
+	Minute  string `json:"minute"`
+	Hour    string `json:"hour"`
+	Day     string `json:"day"`
+	Month   string `json:"month"`
+	Weekday string `json:"weekday"`
+	Command string `json:"command"`
+	User    string `json:"user,omitempty"`
+}
+
+type FTPUser struct {
+	Username string `json:"username"`
+	HomeDir  string `json:"home_dir"`
+	Active   bool   `json:"active"`
+}
+
+type CreateFTPUserRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	HomeDir  string `json:"home_dir,omitempty"`
+	Owner    string `json:"owner,omitempty"`
+}
+
+type WPSite struct {
+	Domain    string `json:"domain"`
+	Path      string `json:"path"`
+	Title     string `json:"title"`
+	WPVersion string `json:"wp_version"`
+	DBName    string `json:"db_name"`
+	DBUser    string `json:"db_user"`
+	Active    bool   `json:"active"`
+	SSL       bool   `json:"ssl"`
+}
+
+type CreateWPSiteRequest struct {
+	Domain     string `json:"domain"`
+	SiteTitle  string `json:"site_title,omitempty"`
+	AdminUser  string `json:"admin_user,omitempty"`
+	AdminPass  string `json:"admin_pass,omitempty"`
+	AdminEmail string `json:"admin_email,omitempty"`
+	DBName     string `json:"db_name,omitempty"`
+	DBUser     string `json:"db_user,omitempty"`
+	DBPass     string `json:"db_pass,omitempty"`
+	PHP        string `json:"php,omitempty"`
+	Owner      string `json:"owner,omitempty"`
+}
+
+// WPExtension is a WordPress plugin or theme.
+type WPExtension struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Version string `json:"version"`
+	Title   string `json:"title"`
+}
@@ -0,0 +1,1098 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"blogron/client"
+)
+
+// commands lists every subcommand. The groups follow the API's routes; "api"
+// calls any route directly.
+var commands []command
+
+func init() {
+	commands = []command{
+		// ── auth ────────────────────────────────────────────────────────────
+		{"login", "[URL] [--user NAME] [--password-stdin]", "Log in and save the session", runLogin},
+		{"logout", "", "End the saved session", runLogout},
+		{"sessions list", "[--user NAME]", "List login sessions", func(e *env, args []string) error {
+			fs := e.flags()
+			user := fs.String("user", "", "another account's sessions (admins)")
+			c, _, err := e.setup(fs, args, 0, 0)
+			if err != nil {
+				return err
+			}
+			return e.show(c.ListSessions(e.ctx, *user))
+		}},
+		{"sessions revoke", "ID", "End a session", withArg((*client.Client).DeleteSession)},
+		{"tokens list", "", "List API tokens", noArgs((*client.Client).ListAPITokens)},
+		{"tokens create", "NAME --scope SCOPE... [--expires-days N] [--allow-ip IP...]", "Create an API token", func(e *env, args []string) error {
+			fs := e.flags()
+			var scopes, ips listFlag
+			fs.Var(&scopes, "scope", "scope, e.g. vhosts:write (repeatable or comma-separated)")
+			fs.Var(&ips, "allow-ip", "allowed IP or CIDR (repeatable or comma-separated)")
+			days := fs.Int("expires-days", 0, "lifetime in days (0 = never expires)")
+			c, pos, err := e.setup(fs, args, 1, 1)
+			if err != nil {
+				return err
+			}
+			return e.show(c.CreateAPIToken(e.ctx, client.CreateAPITokenRequest{
+				Name: pos[0], Scopes: scopes, ExpiresInDays: *days, AllowedIPs: ips,
+			}))
+		}},
+		{"tokens revoke", "ID", "Revoke an API token", withArg((*client.Client).DeleteAPIToken)},
+		{"totp setup", "", "Start two-factor enrolment", noArgs((*client.Client).SetupTOTP)},
+		{"totp enable", "CODE", "Confirm two-factor enrolment", withArg((*client.Client).EnableTOTP)},
+		{"totp disable", "", "Turn off two-factor login (asks for the password)", withPassword((*client.Client).DisableTOTP)},
+		{"totp recovery-codes", "", "Replace the recovery codes (asks for the password)", withPassword((*client.Client).RegenerateRecoveryCodes)},
+
+		// ── system ──────────────────────────────────────────────────────────
+		{"stats", "", "Show CPU, memory and disk usage", noArgs((*client.Client).GetSystemStats)},
+		{"services list", "", "List managed services", noArgs((*client.Client).GetServices)},
+		{"services restart", "NAME", "Restart a service", withArg((*client.Client).RestartService)},
+		{"services stop", "NAME", "Stop a service", withArg((*client.Client).StopService)},
+		{"services start", "NAME", "Start a service", withArg((*client.Client).StartService)},
+		{"logs", "[--unit UNIT] [--lines N]", "Show the system journal", func(e *env, args []string) error {
+			fs := e.flags()
+			unit := fs.String("unit", "", "systemd unit")
+			lines := fs.Int("lines", 0, "number of lines")
+			c, _, err := e.setup(fs, args, 0, 0)
+			if err != nil {
+				return err
+			}
+			return e.show(c.GetLogs(e.ctx, *unit, *lines))
+		}},
+		{"users list", "", "List system users", noArgs((*client.Client).ListUsers)},
+		{"users create", "USERNAME [--shell SHELL] [--groups G1,G2]", "Create a system user (asks for the password)", func(e *env, args []string) error {
+			fs := e.flags()
+			shell := fs.String("shell", "", "login shell")
+			groups := fs.String("groups", "", "comma-separated supplementary groups")
+			c, pos, err := e.setup(fs, args, 1, 1)
+			if err != nil {
+				return err
+			}
+			password, err := e.newPassword()
+			if err != nil {
+				return err
+			}
+			return e.show(c.CreateUser(e.ctx, client.CreateUserRequest{
+				Username: pos[0], Password: password, Shell: *shell, Groups: *groups,
+			}))
+		}},
+		{"users update", "USERNAME [--shell SHELL] [--password]", "Change a system user's shell or password", func(e *env, args []string) error {
+			fs := e.flags()
+			shell := fs.String("shell", "", "new login shell")
+			askPassword := fs.Bool("password", false, "ask for a new password")
+			c, pos, err := e.setup(fs, args, 1, 1)
+			if err != nil {
+				return err
+			}
+			req := client.UpdateUserRequest{Shell: *shell}
+			if *askPassword {
+				if req.Password, err = e.newPassword(); err != nil {
+					return err
+				}
+			}
+			return e.show(c.UpdateUser(e.ctx, pos[0], req))
+		}},
+		{"users delete", "USERNAME", "Delete a system user", withArg((*client.Client).DeleteUser)},
+		{"users suspend", "USERNAME", "Lock a system user", withArg((*client.Client).SuspendUser)},
+		{"users activate", "USERNAME", "Unlock a system user", withArg((*client.Client).ActivateUser)},
+
+		// ── DNS ─────────────────────────────────────────────────────────────
+		{"dns list", "", "List DNS zones", noArgs((*client.Client).ListDNSZones)},
+		{"dns show", "DOMAIN", "Show a zone's records", func(e *env, args []string) error {
+			c, pos, err := e.setup(e.flags(), args, 1, 1)
+			if err != nil {
+				return err
+			}
+			zone, err := c.GetDNSZone(e.ctx, pos[0])
+			if err != nil || e.output == "json" {
+				return e.show(zone, err)
+			}
+			fmt.Fprintf(e.stdout, "%s (serial %d)\n\n", zone.Domain, zone.Serial)
+			return e.print(zone.Records)
+		}},
+		{"dns create", "DOMAIN IP", "Create a zone", withArgs2((*client.Client).CreateDNSZone)},
+		{"dns delete", "DOMAIN", "Delete a zone", withArg((*client.Client).DeleteDNSZone)},
+		{"dns add-record", "DOMAIN NAME TYPE VALUE [--ttl SECONDS]", "Add a record", func(e *env, args []string) error {
+			fs := e.flags()
+			ttl := fs.String("ttl", "", "TTL in seconds (default 3600)")
+			c, pos, err := e.setup(fs, args, 4, 4)
+			if err != nil {
+				return err
+			}
+			return e.show(c.AddDNSRecord(e.ctx, pos[0], client.DNSRecord{Name: pos[1], Type: pos[2], Value: pos[3], TTL: *ttl}))
+		}},
+		{"dns delete-record", "DOMAIN NAME TYPE [VALUE]", "Delete matching records", func(e *env, args []string) error {
+			c, pos, err := e.setup(e.flags(), args, 3, 4)
+			if err != nil {
+				return err
+			}
+			rec := client.DNSRecord{Name: pos[1], Type: pos[2]}
+			if len(pos) == 4 {
+				rec.Value = pos[3]
+			}
+			return e.show(c.DeleteDNSRecord(e.ctx, pos[0], rec))
+		}},
+
+		// ── mail ────────────────────────────────────────────────────────────
+		{"mail domains", "", "List mail domains", noArgs((*client.Client).ListMailDomains)},
+		{"mail add-domain", "DOMAIN [--owner NAME]", "Add a mail domain", func(e *env, args []string) error {
+			fs := e.flags()
+			owner := fs.String("owner", "", "site owner account")
+			c, pos, err := e.setup(fs, args, 1, 1)
+			if err != nil {
+				return err
+			}
+			return e.show(c.AddMailDomain(e.ctx, pos[0], *owner))
+		}},
+		{"mail delete-domain", "DOMAIN", "Delete a mail domain", withArg((*client.Client).DeleteMailDomain)},
+		{"mail mailboxes", "[--domain DOMAIN]", "List mailboxes", func(e *env, args []string) error {
+			fs := e.flags()
+			domain := fs.String("domain", "", "only this domain's mailboxes")
+			c, _, err := e.setup(fs, args, 0, 0)
+			if err != nil {
+				return err
+			}
+			return e.show(c.ListMailboxes(e.ctx, *domain))
+		}},
+		{"mail create-mailbox", "EMAIL [--quota SIZE]", "Create a mailbox (asks for the password)", func(e *env, args []string) error {
+			fs := e.flags()
+			quota := fs.String("quota", "", `quota, e.g. "1G" or "500M"`)
+			c, pos, err := e.setup(fs, args, 1, 1)
+			if err != nil {
+				return err
+			}
+			password, err := e.newPassword()
+			if err != nil {
+				return err
+			}
+			return e.show(c.CreateMailbox(e.ctx, pos[0], password, *quota))
+		}},
+		{"mail delete-mailbox", "EMAIL", "Delete a mailbox", withArg((*client.Client).DeleteMailbox)},
+		{"mail queue", "", "Show the mail queue", noArgs((*client.Client).GetMailQueue)},
+		{"mail flush", "", "Flush the mail queue", noArgs((*client.Client).FlushMailQueue)},
+
+		// ── panel ───────────────────────────────────────────────────────────
+		{"ownership list", "[--owner NAME] [--kind KIND]", "List resource owners", func(e *env, args []string) error {
+			fs := e.flags()
+			owner := fs.String("owner", "", "site owner account")
+			kind := fs.String("kind", "", "resource kind, e.g. vhost")
+			c, _, err := e.setup(fs, args, 0, 0)
+			if err != nil {
+				return err
+			}
+			return e.show(c.ListOwnership(e.ctx, *owner, *kind))
+		}},
+		{"ownership set", "KIND NAME [OWNER]", "Assign a resource to a site owner (no OWNER: unassign)", func(e *env, args []string) error {
+			c, pos, err := e.setup(e.flags(), args, 2, 3)
+			if err != nil {
+				return err
+			}
+			owner := ""
+			if len(pos) == 3 {
+				owner = pos[2]
+			}
+			return e.show(c.SetOwnership(e.ctx, pos[0], pos[1], owner))
+		}},
+		{"audit", "[--actor NAME] [--resource NAME] [--outcome success|failure] [--since T] [--until T] [--limit N]", "Show the audit log", runAudit},
+		{"settings", "", "Show the panel settings", func(e *env, args []string) error {
+			c, _, err := e.setup(e.flags(), args, 0, 0)
+			if err != nil {
+				return err
+			}
+			s, err := c.GetSettings(e.ctx)
+			if err != nil || e.output == "json" {
+				return e.show(s, err)
+			}
+			var pretty strings.Builder
+			if err := indentJSON(&pretty, s.Settings); err != nil {
+				return err
+			}
+			fmt.Fprintf(e.stdout, "# %s\n%s", firstNonEmpty(s.ConfigFile, "defaults"), pretty.String())
+			return nil
+		}},
+		{"panel-users list", "", "List panel accounts", noArgs((*client.Client).ListPanelUsers)},
+		{"panel-users create", "USERNAME --role ROLE", "Create a panel account (asks for the password)", func(e *env, args []string) error {
+			fs := e.flags()
+			role := fs.String("role", "", "admin, operator, read-only or site-owner")
+			c, pos, err := e.setup(fs, args, 1, 1)
+			if err != nil {
+				return err
+			}
+			password, err := e.newPassword()
+			if err != nil {
+				return err
+			}
+			return e.show(c.CreatePanelUser(e.ctx, client.PanelUserRequest{Username: pos[0], Password: password, Role: *role}))
+		}},
+		{"panel-users update", "USERNAME [--role ROLE] [--disable|--enable] [--reset-totp] [--password]", "Change a panel account", func(e *env, args []string) error {
+			fs := e.flags()
+			role := fs.String("role", "", "new role")
+			disable := fs.Bool("disable", false, "disable the account")
+			enable := fs.Bool("enable", false, "enable the account")
+			resetTOTP := fs.Bool("reset-totp", false, "turn off the account's two-factor login")
+			askPassword := fs.Bool("password", false, "ask for a new password")
+			c, pos, err := e.setup(fs, args, 1, 1)
+			if err != nil {
+				return err
+			}
+			if *disable && *enable {
+				return e.usageError("--disable and --enable are exclusive")
+			}
+			req := client.PanelUserRequest{Role: *role, ResetTOTP: *resetTOTP}
+			if *disable || *enable {
+				req.Disabled = disable
+			}
+			if *askPassword {
+				if req.Password, err = e.newPassword(); err != nil {
+					return err
+				}
+			}
+			return e.show(c.UpdatePanelUser(e.ctx, pos[0], req))
+		}},
+		{"panel-users delete", "USERNAME", "Delete a panel account", withArg((*client.Client).DeletePanelUser)},
+		{"resources list", "[--kind KIND] [--owner NAME]", "List the panel's resource records", func(e *env, args []string) error {
+			fs := e.flags()
+			kind := fs.String("kind", "", "resource kind, e.g. wordpress")
+			owner := fs.String("owner", "", "site owner account")
+			c, _, err := e.setup(fs, args, 0, 0)
+			if err != nil {
+				return err
+			}
+			return e.show(c.ListResources(e.ctx, *kind, *owner))
+		}},
+		{"resources notes", "KIND NAME NOTES", "Set a resource's notes", func(e *env, args []string) error {
+			c, pos, err := e.setup(e.flags(), args, 3, 3)
+			if err != nil {
+				return err
+			}
+			return e.show(c.UpdateResource(e.ctx, pos[0], pos[1], pos[2]))
+		}},
+
+		// ── jobs ────────────────────────────────────────────────────────────
+		{"jobs list", "[--status STATUS] [--kind KIND]", "List background jobs", func(e *env, args []string) error {
+			fs := e.flags()
+			status := fs.String("status", "", "running, succeeded, failed, cancelled or interrupted")
+			kind := fs.String("kind", "", "job kind, e.g. wordpress.create")
+			c, _, err := e.setup(fs, args, 0, 0)
+			if err != nil {
+				return err
+			}
+			return e.show(c.ListJobs(e.ctx, *status, *kind))
+		}},
+		{"jobs show", "ID", "Show a job and its output", func(e *env, args []string) error {
+			c, pos, err := e.setup(e.flags(), args, 1, 1)
+			if err != nil {
+				return err
+			}
+			job, err := c.GetJob(e.ctx, pos[0])
+			if err != nil {
+				return err
+			}
+			return e.printJob(job)
+		}},
+		{"jobs follow", "ID", "Follow a job's output until it finishes", func(e *env, args []string) error {
+			c, pos, err := e.setup(e.flags(), args, 1, 1)
+			if err != nil {
+				return err
+			}
+			return e.job(c, &client.Job{ID: pos[0]}, nil, true)
+		}},
+		{"jobs cancel", "ID", "Cancel a running job", withArg((*client.Client).CancelJob)},
+
+		// ── vhosts ──────────────────────────────────────────────────────────
+		{"vhosts list", "", "List nginx virtual hosts", noArgs((*client.Client).ListVhosts)},
+		{"vhosts create", "DOMAIN [--profile NAME] [--upstream TARGET]... [--balance METHOD] [--docroot DIR] [--php VERSION] [--alias NAME]... [--canonical www|non-www] [--redirect FROM=TO]... [--ssl] [--owner NAME]", "Create a virtual host", func(e *env, args []string) error {
+			fs := e.flags()
+			profile := fs.String("profile", "", "vhost profile (default php; see vhosts profiles)")
+			var upstreams listFlag
+			fs.Var(&upstreams, "upstream", "proxy target: PORT, HOST:PORT or unix:/PATH (repeatable or comma-separated)")
+			balance := fs.String("balance", "", "load balancing: round_robin, least_conn, ip_hash or random")
+			noWebSocket := fs.Bool("no-websocket", false, "do not pass WebSocket upgrades through")
+			connectTimeout := fs.Int("connect-timeout", 0, "upstream connect timeout in seconds (default 5)")
+			readTimeout := fs.Int("read-timeout", 0, "upstream read timeout in seconds (default 60)")
+			sendTimeout := fs.Int("send-timeout", 0, "upstream send timeout in seconds (default 60)")
+			maxFails := fs.Int("max-fails", 0, "failed attempts before an upstream is skipped (default 3)")
+			failTimeout := fs.Int("fail-timeout", 0, "seconds a failed upstream is skipped (default 10)")
+			healthPath := fs.String("health-path", "", "health check path, not logged or retried, e.g. /healthz")
+			docroot := fs.String("docroot", "", "document root (default set by the profile)")
+			php := fs.String("php", "", "PHP-FPM version")
+			ssl := fs.Bool("ssl", false, "listen on 443 with the Let's Encrypt certificate paths")
+			owner := fs.String("owner", "", "site owner account")
+			var aliases listFlag
+			fs.Var(&aliases, "alias", "server name besides the domain (repeatable or comma-separated; default www.DOMAIN)")
+			canonical := fs.String("canonical", "", "redirect to the www or non-www name")
+			var redirects []client.RedirectRule
+			fs.Var(redirectFlag{&redirects, 301}, "redirect", "301 redirect FROM=TO; FROM is a path or ~REGEX (repeatable)")
+			fs.Var(redirectFlag{&redirects, 302}, "redirect-temp", "302 redirect FROM=TO (repeatable)")
+			c, pos, err := e.setup(fs, args, 1, 1)
+			if err != nil {
+				return err
+			}
+			req := client.CreateVhostRequest{
+				Domain: pos[0], DocRoot: *docroot, PHP: *php, SSL: *ssl, Owner: *owner, Profile: *profile,
+				Aliases: aliases, Canonical: *canonical, Redirects: redirects,
+			}
+			if len(upstreams) > 0 {
+				if req.Profile == "" {
+					req.Profile = "proxy"
+				}
+				req.Proxy = &client.ProxyOptions{
+					Upstreams: upstreams, Balance: *balance,
+					ConnectTimeout: *connectTimeout, ReadTimeout: *readTimeout, SendTimeout: *sendTimeout,
+					MaxFails: *maxFails, FailTimeout: *failTimeout, HealthPath: *healthPath,
+				}
+				if *noWebSocket {
+					req.Proxy.WebSocket = new(bool)
+				}
+			}
+			return e.show(c.CreateVhost(e.ctx, req))
+		}},
+		{"vhosts update", "DOMAIN [--docroot DIR] [--php VERSION] [--alias NAME]... [--no-aliases] [--directives FILE|-] [--force-https] [--canonical www|non-www|none] [--redirect FROM=TO]... [--no-redirects]", "Change a virtual host in place", func(e *env, args []string) error {
+			fs := e.flags()
+			docroot := fs.String("docroot", "", "new document root")
+			php := fs.String("php", "", "PHP-FPM version")
+			var aliases listFlag
+			fs.Var(&aliases, "alias", "server name besides the domain (repeatable or comma-separated; replaces the current ones)")
+			noAliases := fs.Bool("no-aliases", false, "remove all aliases")
+			directives := fs.String("directives", "", "file with extra nginx directives, - for stdin, or an empty file to remove them")
+			forceHTTPS := fs.Bool("force-https", false, "redirect http:// to https:// (--force-https=false to stop)")
+			canonical := fs.String("canonical", "", "redirect to the www or non-www name, or none")
+			var redirects []client.RedirectRule
+			fs.Var(redirectFlag{&redirects, 301}, "redirect", "301 redirect FROM=TO; FROM is a path or ~REGEX (repeatable; replaces the current rules)")
+			fs.Var(redirectFlag{&redirects, 302}, "redirect-temp", "302 redirect FROM=TO (repeatable)")
+			noRedirects := fs.Bool("no-redirects", false, "remove all redirect rules")
+			c, pos, err := e.setup(fs, args, 1, 1)
+			if err != nil {
+				return err
+			}
+			var req client.UpdateVhostRequest
+			fs.Visit(func(f *flag.Flag) { // only the flags given
+				switch f.Name {
+				case "docroot":
+					req.DocRoot = docroot
+				case "php":
+					req.PHP = php
+				case "force-https":
+					req.ForceHTTPS = forceHTTPS
+				case "canonical":
+					if *canonical == "none" {
+						*canonical = ""
+					}
+					req.Canonical = canonical
+				}
+			})
+			if len(redirects) > 0 || *noRedirects {
+				rules := append([]client.RedirectRule{}, redirects...)
+				req.Redirects = &rules
+			}
+			if len(aliases) > 0 || *noAliases {
+				list := append([]string{}, aliases...) // [] rather than null
+				req.Aliases = &list
+			}
+			if *directives != "" {
+				var data []byte
+				if *directives == "-" {
+					data, err = io.ReadAll(e.stdin)
+				} else {
+					data, err = os.ReadFile(*directives)
+				}
+				if err != nil {
+					return err
+				}
+				text := string(data)
+				req.Directives = &text
+			}
+			return e.show(c.UpdateVhost(e.ctx, pos[0], req))
+		}},
+		{"vhosts delete", "DOMAIN", "Delete a virtual host", withArg((*client.Client).DeleteVhost)},
+		{"vhosts enable", "DOMAIN", "Enable a virtual host", withArg((*client.Client).EnableVhost)},
+		{"vhosts disable", "DOMAIN", "Disable a virtual host", withArg((*client.Client).DisableVhost)},
+		{"vhosts ssl", "DOMAIN --email EMAIL [--wait]", "Issue a Let's Encrypt certificate", func(e *env, args []string) error {
+			fs := e.flags()
+			email := fs.String("email", "", "ACME account email")
+			wait := fs.Bool("wait", false, "follow the job until it finishes")
+			c, pos, err := e.setup(fs, args, 1, 1)
+			if err != nil {
+				return err
+			}
+			job, err := c.EnableSSL(e.ctx, pos[0], *email)
+			return e.job(c, job, err, *wait)
+		}},
+		{"vhosts profiles", "", "List vhost profiles", noArgs((*client.Client).ListVhostProfiles)},
+		{"vhosts profile", "NAME [--template]", "Show a vhost profile", func(e *env, args []string) error {
+			fs := e.flags()
+			source := fs.Bool("template", false, "print just the template")
+			c, pos, err := e.setup(fs, args, 1, 1)
+			if err != nil {
+				return err
+			}
+			p, err := c.GetVhostProfile(e.ctx, pos[0])
+			if err != nil || !*source {
+				return e.show(p, err)
+			}
+			_, err = io.WriteString(e.stdout, p.Template)
+			return err
+		}},
+		{"vhosts profile-save", "NAME FILE", "Create or replace a custom vhost profile (FILE - for stdin)", func(e *env, args []string) error {
+			c, pos, err := e.setup(e.flags(), args, 2, 2)
+			if err != nil {
+				return err
+			}
+			var src []byte
+			if pos[1] == "-" {
+				src, err = io.ReadAll(e.stdin)
+			} else {
+				src, err = os.ReadFile(pos[1])
+			}
+			if err != nil {
+				return err
+			}
+			return e.show(c.SaveVhostProfile(e.ctx, pos[0], string(src)))
+		}},
+		{"vhosts profile-delete", "NAME", "Delete a custom vhost profile", withArg((*client.Client).DeleteVhostProfile)},
+
+		// ── databases ───────────────────────────────────────────────────────
+		{"databases list", "", "List databases", noArgs((*client.Client).ListDatabases)},
+		{"databases create", "NAME [--user USER [--password]] [--host HOST] [--owner NAME]", "Create a database", func(e *env, args []string) error {
+			fs := e.flags()
+			user := fs.String("user", "", "also create this database user")
+			askPassword := fs.Bool("password", false, "ask for the user's password")
+			host := fs.String("host", "", "the user's host (default localhost)")
+			owner := fs.String("owner", "", "site owner account")
+			c, pos, err := e.setup(fs, args, 1, 1)
+			if err != nil {
+				return err
+			}
+			req := client.CreateDatabaseRequest{Name: pos[0], DBUser: *user, Host: *host, Owner: *owner}
+			if *askPassword {
+				if req.Password, err = e.newPassword(); err != nil {
+					return err
+				}
+			}
+			return e.show(c.CreateDatabase(e.ctx, req))
+		}},
+		{"databases drop", "NAME", "Drop a database", withArg((*client.Client).DropDatabase)},
+		{"databases tables", "NAME", "List a database's tables", withArg((*client.Client).ListTables)},
+
+		// ── files ───────────────────────────────────────────────────────────
+		{"files ls", "[PATH]", "List a directory", func(e *env, args []string) error {
+			c, pos, err := e.setup(e.flags(), args, 0, 1)
+			if err != nil {
+				return err
+			}
+			return e.show(c.ListFiles(e.ctx, strings.Join(pos, "")))
+		}},
+		{"files mkdir", "PATH", "Create a directory", withArg((*client.Client).MakeDirectory)},
+		{"files rm", "PATH", "Delete a file or directory", withArg((*client.Client).DeleteFile)},
+		{"files mv", "FROM TO", "Rename a file or directory", withArgs2((*client.Client).RenameFile)},
+		{"files cat", "PATH", "Print a file", func(e *env, args []string) error {
+			c, pos, err := e.setup(e.flags(), args, 1, 1)
+			if err != nil {
+				return err
+			}
+			content, err := c.ReadFile(e.ctx, pos[0])
+			if err != nil || e.output == "json" {
+				return e.show(content, err)
+			}
+			_, err = io.WriteString(e.stdout, content)
+			return err
+		}},
+		{"files write", "PATH", "Write stdin to a file", func(e *env, args []string) error {
+			c, pos, err := e.setup(e.flags(), args, 1, 1)
+			if err != nil {
+				return err
+			}
+			content, err := io.ReadAll(e.stdin)
+			if err != nil {
+				return err
+			}
+			return e.show(c.WriteFile(e.ctx, pos[0], string(content)))
+		}},
+		{"files upload", "LOCAL-FILE [DIR]", "Upload a file into a directory", func(e *env, args []string) error {
+			c, pos, err := e.setup(e.flags(), args, 1, 2)
+			if err != nil {
+				return err
+			}
+			f, err := os.Open(pos[0])
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			dir := "/"
+			if len(pos) == 2 {
+				dir = pos[1]
+			}
+			return e.show(c.UploadFile(e.ctx, dir, filepath.Base(pos[0]), f))
+		}},
+
+		// ── cron ────────────────────────────────────────────────────────────
+		{"cron list", "[--user USER]", "List cron jobs", func(e *env, args []string) error {
+			fs := e.flags()
+			user := fs.String("user", "", "only this user's crontab")
+			c, _, err := e.setup(fs, args, 0, 0)
+			if err != nil {
+				return err
+			}
+			return e.show(c.ListCronJobs(e.ctx, *user))
+		}},
+		{"cron create", `"SCHEDULE" COMMAND [--user USER]`, `Add a cron job, e.g. cron create "*/5 * * * *" "php cron.php"`, func(e *env, args []string) error {
+			fs := e.flags()
+			user := fs.String("user", "", "crontab owner (default root)")
+			c, pos, err := e.setup(fs, args, 2, -1)
+			if err != nil {
+				return err
+			}
+			req, err := cronRequest(pos, *user)
+			if err != nil {
+				return e.usageError(err.Error())
+			}
+			return e.show(c.CreateCronJob(e.ctx, req))
+		}},
+		{"cron update", `ID "SCHEDULE" COMMAND [--user USER]`, "Replace a cron job", func(e *env, args []string) error {
+			fs := e.flags()
+			user := fs.String("user", "", "crontab owner (default root)")
+			c, pos, err := e.setup(fs, args, 3, -1)
+			if err != nil {
+				return err
+			}
+			id, err := strconv.Atoi(pos[0])
+			if err != nil {
+				return e.usageError("ID must be a number")
+			}
+			req, err := cronRequest(pos[1:], *user)
+			if err != nil {
+				return e.usageError(err.Error())
+			}
+			return e.show(c.UpdateCronJob(e.ctx, id, req))
+		}},
+		{"cron delete", "ID [--user USER]", "Delete a cron job", func(e *env, args []string) error {
+			fs := e.flags()
+			user := fs.String("user", "", "crontab owner (default root)")
+			c, pos, err := e.setup(fs, args, 1, 1)
+			if err != nil {
+				return err
+			}
+			id, err := strconv.Atoi(pos[0])
+			if err != nil {
+				return e.usageError("ID must be a number")
+			}
+			return e.show(c.DeleteCronJob(e.ctx, *user, id))
+		}},
+		{"cron run", "ID [--user USER] [--wait]", "Run a cron job now", func(e *env, args []string) error {
+			fs := e.flags()
+			user := fs.String("user", "", "crontab owner (default root)")
+			wait := fs.Bool("wait", false, "follow the job until it finishes")
+			c, pos, err := e.setup(fs, args, 1, 1)
+			if err != nil {
+				return err
+			}
+			id, err := strconv.Atoi(pos[0])
+			if err != nil {
+				return e.usageError("ID must be a number")
+			}
+			job, err := c.RunCronNow(e.ctx, *user, id)
+			return e.job(c, job, err, *wait)
+		}},
+
+		// ── FTP ─────────────────────────────────────────────────────────────
+		{"ftp list", "", "List FTP users", noArgs((*client.Client).ListFTPUsers)},
+		{"ftp create", "USERNAME [--home DIR] [--owner NAME]", "Create an FTP user (asks for the password)", func(e *env, args []string) error {
+			fs := e.flags()
+			home := fs.String("home", "", "home directory")
+			owner := fs.String("owner", "", "site owner account")
+			c, pos, err := e.setup(fs, args, 1, 1)
+			if err != nil {
+				return err
+			}
+			password, err := e.newPassword()
+			if err != nil {
+				return err
+			}
+			return e.show(c.CreateFTPUser(e.ctx, client.CreateFTPUserRequest{
+				Username: pos[0], Password: password, HomeDir: *home, Owner: *owner,
+			}))
+		}},
+		{"ftp passwd", "USERNAME", "Change an FTP user's password (asks for it)", func(e *env, args []string) error {
+			c, pos, err := e.setup(e.flags(), args, 1, 1)
+			if err != nil {
+				return err
+			}
+			password, err := e.newPassword()
+			if err != nil {
+				return err
+			}
+			return e.show(c.UpdateFTPPassword(e.ctx, pos[0], password))
+		}},
+		{"ftp delete", "USERNAME", "Delete an FTP user", withArg((*client.Client).DeleteFTPUser)},
+
+		// ── WordPress ───────────────────────────────────────────────────────
+		{"wp list", "", "List WordPress sites", noArgs((*client.Client).ListWPSites)},
+		{"wp create", "DOMAIN [--title T] [--admin-user U] [--admin-email E] [--db-name N] [--db-user U] [--php V] [--owner NAME] [--wait]", "Install WordPress", func(e *env, args []string) error {
+			fs := e.flags()
+			var req client.CreateWPSiteRequest
+			fs.StringVar(&req.SiteTitle, "title", "", "site title")
+			fs.StringVar(&req.AdminUser, "admin-user", "", "admin username")
+			fs.StringVar(&req.AdminEmail, "admin-email", "", "admin email")
+			fs.StringVar(&req.DBName, "db-name", "", "database name")
+			fs.StringVar(&req.DBUser, "db-user", "", "database user")
+			fs.StringVar(&req.PHP, "php", "", "PHP-FPM version")
+			fs.StringVar(&req.Owner, "owner", "", "site owner account")
+			wait := fs.Bool("wait", false, "follow the job until it finishes")
+			c, pos, err := e.setup(fs, args, 1, 1)
+			if err != nil {
+				return err
+			}
+			req.Domain = pos[0]
+			job, err := c.CreateWPSite(e.ctx, req)
+			return e.job(c, job, err, *wait)
+		}},
+		{"wp delete", "DOMAIN [--delete-db]", "Remove a WordPress site", func(e *env, args []string) error {
+			fs := e.flags()
+			deleteDB := fs.Bool("delete-db", false, "also drop its database")
+			c, pos, err := e.setup(fs, args, 1, 1)
+			if err != nil {
+				return err
+			}
+			return e.show(c.DeleteWPSite(e.ctx, pos[0], *deleteDB))
+		}},
+		{"wp plugins", "DOMAIN", "List a site's plugins", withArg((*client.Client).ListWPPlugins)},
+		{"wp plugin-install", "DOMAIN NAME [--activate]", "Install a plugin", wpInstall((*client.Client).InstallWPPlugin)},
+		{"wp plugin", "DOMAIN PLUGIN activate|deactivate|update|delete", "Change a plugin", withArgs3((*client.Client).ToggleWPPlugin)},
+		{"wp themes", "DOMAIN", "List a site's themes", withArg((*client.Client).ListWPThemes)},
+		{"wp theme-install", "DOMAIN NAME [--activate]", "Install a theme", wpInstall((*client.Client).InstallWPTheme)},
+		{"wp theme", "DOMAIN THEME activate|update|delete", "Change a theme", withArgs3((*client.Client).ToggleWPTheme)},
+		{"wp update", "DOMAIN [--wait]", "Update core, plugins and themes", func(e *env, args []string) error {
+			fs := e.flags()
+			wait := fs.Bool("wait", false, "follow the job until it finishes")
+			c, pos, err := e.setup(fs, args, 1, 1)
+			if err != nil {
+				return err
+			}
+			job, err := c.WPUpdateCore(e.ctx, pos[0])
+			return e.job(c, job, err, *wait)
+		}},
+		{"wp maintenance", "DOMAIN on|off", "Turn maintenance mode on or off", func(e *env, args []string) error {
+			c, pos, err := e.setup(e.flags(), args, 2, 2)
+			if err != nil {
+				return err
+			}
+			if pos[1] != "on" && pos[1] != "off" {
+				return e.usageError("the mode must be on or off")
+			}
+			return e.show(c.WPMaintenanceMode(e.ctx, pos[0], pos[1] == "on"))
+		}},
+		{"wp search-replace", "DOMAIN SEARCH REPLACE", "Search and replace in the database", withArgs3((*client.Client).WPSearchReplace)},
+		{"wp cache-flush", "DOMAIN", "Flush the object cache", withArg((*client.Client).WPCacheFlush)},
+
+		// ── raw ─────────────────────────────────────────────────────────────
+		{"api", "METHOD PATH [-d JSON|@FILE|-]", "Call any API route, e.g. api GET /health", runAPI},
+	}
+}
+
+// ── command implementations ─────────────────────────────────────────────────
+
+func runLogin(e *env, args []string) error {
+	fs := e.flags()
+	user := fs.String("user", "", "panel account")
+	passwordStdin := fs.Bool("password-stdin", false, "read the password from stdin")
+	pos, err := e.parse(fs, args, 0, 1)
+	if err != nil {
+		return err
+	}
+	baseURL := firstNonEmpty(strings.Join(pos, ""), e.url, os.Getenv("BLOGRON_URL"), e.creds.URL)
+	if baseURL == "" {
+		return e.usageError("the panel URL is required")
+	}
+	if *user == "" {
+		if *user, err = e.readLine(promptDefault("Username", e.creds.User)); err != nil {
+			return err
+		}
+		*user = firstNonEmpty(*user, e.creds.User)
+	}
+	var password string
+	if *passwordStdin {
+		password, err = e.readLine("")
+	} else {
+		password, err = e.readSecret("Password: ")
+	}
+	if err != nil {
+		return err
+	}
+
+	c := client.New(baseURL, "")
+	res, err := c.Login(e.ctx, *user, password)
+	if err != nil {
+		return err
+	}
+	tokens := &res.Tokens
+	if res.MFARequired {
+		code, err := e.readLine("Verification code: ")
+		if err != nil {
+			return err
+		}
+		if tokens, err = c.VerifyLogin(e.ctx, res.PreAuthToken, strings.TrimSpace(code)); err != nil {
+			return err
+		}
+	}
+
+	e.creds.URL = strings.TrimRight(baseURL, "/")
+	e.creds.User = tokens.User
+	e.creds.Token, e.creds.RefreshToken = tokens.Token, tokens.RefreshToken
+	if err := e.creds.save(); err != nil {
+		return err
+	}
+	fmt.Fprintf(e.stderr, "Logged in to %s as %s (%s)\n", e.creds.URL, tokens.User, tokens.Role)
+	return nil
+}
+
+func runLogout(e *env, args []string) error {
+	c, _, err := e.setup(e.flags(), args, 0, 0)
+	if err != nil {
+		return err
+	}
+	if err := c.Logout(e.ctx); err != nil && !isStatus(err, http.StatusUnauthorized) {
+		return err
+	}
+	if os.Getenv("BLOGRON_TOKEN") != "" {
+		return nil
+	}
+	return e.creds.clear()
+}
+
+func runAudit(e *env, args []string) error {
+	fs := e.flags()
+	var q client.AuditQuery
+	fs.StringVar(&q.Actor, "actor", "", "panel account")
+	fs.StringVar(&q.Resource, "resource", "", "resource group, e.g. vhosts")
+	fs.StringVar(&q.Outcome, "outcome", "", "success or failure")
+	since := fs.String("since", "", "RFC 3339 time, or a duration ago such as 24h")
+	until := fs.String("until", "", "RFC 3339 time, or a duration ago")
+	fs.IntVar(&q.Limit, "limit", 0, "maximum number of entries")
+	c, _, err := e.setup(fs, args, 0, 0)
+	if err != nil {
+		return err
+	}
+	if q.Since, err = parseTime(*since); err != nil {
+		return e.usageError("--since: " + err.Error())
+	}
+	if q.Until, err = parseTime(*until); err != nil {
+		return e.usageError("--until: " + err.Error())
+	}
+	return e.show(c.GetAuditLog(e.ctx, q))
+}
+
+func runAPI(e *env, args []string) error {
+	fs := e.flags()
+	data := fs.String("d", "", "JSON body, @FILE to read it from a file, or - for stdin")
+	c, pos, err := e.setup(fs, args, 2, 2)
+	if err != nil {
+		return err
+	}
+	method := strings.ToUpper(pos[0])
+	path := pos[1]
+	for _, prefix := range []string{client.APIPrefix, "/api"} {
+		if strings.HasPrefix(path, prefix+"/") {
+			path = strings.TrimPrefix(path, prefix)
+			break
+		}
+	}
+
+	var body any
+	if *data != "" {
+		raw := []byte(*data)
+		switch {
+		case *data == "-":
+			raw, err = io.ReadAll(e.stdin)
+		case strings.HasPrefix(*data, "@"):
+			raw, err = os.ReadFile(strings.TrimPrefix(*data, "@"))
+		}
+		if err != nil {
+			return err
+		}
+		if !json.Valid(raw) {
+			return e.usageError("-d is not valid JSON")
+		}
+		body = json.RawMessage(raw)
+	}
+
+	var out json.RawMessage
+	if err := c.Do(e.ctx, method, path, body, &out); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil // no content
+		}
+		return err
+	}
+	return indentJSON(e.stdout, out)
+}
+
+// ── helpers ─────────────────────────────────────────────────────────────────
+
+// setup parses the command line and returns the API client.
+func (e *env) setup(fs *flag.FlagSet, args []string, minArgs, maxArgs int) (*client.Client, []string, error) {
+	pos, err := e.parse(fs, args, minArgs, maxArgs)
+	if err != nil {
+		return nil, nil, err
+	}
+	c, err := e.client()
+	if err != nil {
+		return nil, nil, err
+	}
+	return c, pos, nil
+}
+
+// show prints the result of an API call.
+func (e *env) show(v any, err error) error {
+	if err != nil {
+		return err
+	}
+	return e.print(v)
+}
+
+// job prints a started job, or with wait follows it, streaming its output to
+// stderr, and prints the finished job. A job that did not succeed is an
+// error.
+func (e *env) job(c *client.Client, job *client.Job, err error, wait bool) error {
+	if err != nil {
+		return err
+	}
+	if !wait {
+		if e.output == "table" {
+			fmt.Fprintf(e.stderr, "Started job %s; follow it with: blogronctl jobs follow %s\n", job.ID, job.ID)
+		}
+		return e.print(job)
+	}
+	out := e.stderr
+	if e.output == "table" {
+		out = e.stdout
+	}
+	finished, err := c.WaitJob(e.ctx, job.ID, out)
+	if finished == nil {
+		return err
+	}
+	if e.output == "json" {
+		if perr := e.print(finished); perr != nil {
+			return perr
+		}
+	} else if err == nil {
+		fmt.Fprintf(e.stderr, "Job %s %s\n", finished.ID, finished.Status)
+	}
+	if e.output == "table" && len(finished.Result) > 0 {
+		fmt.Fprintln(e.stdout)
+		if perr := e.print(finished.Result); perr != nil {
+			return perr
+		}
+	}
+	return err
+}
+
+// printJob prints a job with its steps and output.
+func (e *env) printJob(job *client.Job) error {
+	if err := e.print(job); err != nil || e.output == "json" {
+		return err
+	}
+	if len(job.Steps) > 0 {
+		fmt.Fprintln(e.stdout)
+		if err := e.print(job.Steps); err != nil {
+			return err
+		}
+	}
+	if job.Output != "" {
+		fmt.Fprintf(e.stdout, "\n%s", job.Output)
+		if !strings.HasSuffix(job.Output, "\n") {
+			fmt.Fprintln(e.stdout)
+		}
+	}
+	return nil
+}
+
+// newPassword asks for a password twice on a terminal, once from a pipe.
+func (e *env) newPassword() (string, error) {
+	password, err := e.readSecret("Password: ")
+	if err != nil {
+		return "", err
+	}
+	if isTerminal(os.Stdin) {
+		again, err := e.readSecret("Repeat password: ")
+		if err != nil {
+			return "", err
+		}
+		if again != password {
+			return "", errors.New("the passwords do not match")
+		}
+	}
+	return password, nil
+}
+
+func noArgs[T any](fn func(*client.Client, context.Context) (T, error)) func(*env, []string) error {
+	return func(e *env, args []string) error {
+		c, _, err := e.setup(e.flags(), args, 0, 0)
+		if err != nil {
+			return err
+		}
+		return e.show(fn(c, e.ctx))
+	}
+}
+
+func withArg[T any](fn func(*client.Client, context.Context, string) (T, error)) func(*env, []string) error {
+	return func(e *env, args []string) error {
+		c, pos, err := e.setup(e.flags(), args, 1, 1)
+		if err != nil {
+			return err
+		}
+		return e.show(fn(c, e.ctx, pos[0]))
+	}
+}
+
+func withArgs2[T any](fn func(*client.Client, context.Context, string, string) (T, error)) func(*env, []string) error {
+	return func(e *env, args []string) error {
+		c, pos, err := e.setup(e.flags(), args, 2, 2)
+		if err != nil {
+			return err
+		}
+		return e.show(fn(c, e.ctx, pos[0], pos[1]))
+	}
+}
+
+func withArgs3[T any](fn func(*client.Client, context.Context, string, string, string) (T, error)) func(*env, []string) error {
+	return func(e *env, args []string) error {
+		c, pos, err := e.setup(e.flags(), args, 3, 3)
+		if err != nil {
+			return err
+		}
+		return e.show(fn(c, e.ctx, pos[0], pos[1], pos[2]))
+	}
+}
+
+// withPassword runs fn with the account password, asked for on the terminal.
+func withPassword[T any](fn func(*client.Client, context.Context, string) (T, error)) func(*env, []string) error {
+	return func(e *env, args []string) error {
+		c, _, err := e.setup(e.flags(), args, 0, 0)
+		if err != nil {
+			return err
+		}
+		password, err := e.readSecret("Password: ")
+		if err != nil {
+			return err
+		}
+		return e.show(fn(c, e.ctx, password))
+	}
+}
+
+func wpInstall(fn func(*client.Client, context.Context, string, string, bool) (client.Status, error)) func(*env, []string) error {
+	return func(e *env, args []string) error {
+		fs := e.flags()
+		activate := fs.Bool("activate", false, "activate it after installing")
+		c, pos, err := e.setup(fs, args, 2, 2)
+		if err != nil {
+			return err
+		}
+		return e.show(fn(c, e.ctx, pos[0], pos[1], *activate))
+	}
+}
+
+// cronRequest builds a cron job from a five-field schedule followed by the
+// command, e.g. ["*/5 * * * *", "php", "cron.php"].
+func cronRequest(args []string, user string) (client.CronJobRequest, error) {
+	fields := strings.Fields(args[0])
+	command := args[1:]
+	if len(fields) != 5 {
+		// The schedule was not quoted: take its fields from the arguments.
+		all := strings.Fields(strings.Join(args, " "))
+		if len(all) < 6 {
+			return client.CronJobRequest{}, errors.New("the schedule needs five fields, e.g. \"*/5 * * * *\"")
+		}
+		fields, command = all[:5], all[5:]
+	}
+	return client.CronJobRequest{
+		Minute: fields[0], Hour: fields[1], Day: fields[2], Month: fields[3], Weekday: fields[4],
+		Command: strings.Join(command, " "),
+		User:    user,
+	}, nil
+}
+
+// parseTime reads an RFC 3339 time, or a duration before now.
+func parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+// listFlag collects a repeatable, comma-separated flag.
+type listFlag []string
+
+func (l *listFlag) String() string { return strings.Join(*l, ",") }
+
+func (l *listFlag) Set(v string) error {
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			*l = append(*l, s)
+		}
+	}
+	return nil
+}
+
+// redirectFlag collects --redirect FROM=TO rules; "~" before FROM makes it
+// a regex, as in an nginx location.
+type redirectFlag struct {
+	rules *[]client.RedirectRule
+	code  int
+}
+
+func (f redirectFlag) String() string { return "" }
+
+func (f redirectFlag) Set(v string) error {
+	from, to, ok := strings.Cut(v, "=")
+	if !ok || from == "" || to == "" {
+		return fmt.Errorf("want FROM=TO, got %q", v)
+	}
+	rule := client.RedirectRule{From: from, To: to, Code: f.code}
+	if re, ok := strings.CutPrefix(from, "~"); ok {
+		rule.From, rule.Regex = strings.TrimSpace(re), true
+	}
+	*f.rules = append(*f.rules, rule)
+	return nil
+}
+
+func indentJSON(w io.Writer, raw json.RawMessage) error {
+	if len(raw) == 0 {
+		return nil
+	}
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, raw, "", "  "); err != nil {
+		return err
+	}
+	buf.WriteByte('\n')
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+func isStatus(err error, status int) bool {
+	var apiErr *client.Error
+	return errors.As(err, &apiErr) && apiErr.StatusCode == status
+}
+
+func promptDefault(prompt, def string) string {
+	if def == "" {
+		return prompt + ": "
+	}
+	return fmt.Sprintf("%s [%s]: ", prompt, def)
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// credentials is the saved login, kept in <user config dir>/blogron/
+// credentials.json (mode 0600).
+type credentials struct {
+	URL          string `json:"url"`
+	User         string `json:"user"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+
+	path string
+}
+
+func credentialsPath() (string, error) {
+	if p := os.Getenv("BLOGRON_CREDENTIALS"); p != "" {
+		return p, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "blogron", "credentials.json"), nil
+}
+
+// loadCredentials reads the saved login; a missing file is an empty login.
+func loadCredentials() (*credentials, error) {
+	path, err := credentialsPath()
+	if err != nil {
+		return nil, err
+	}
+	creds := &credentials{path: path}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return creds, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, creds); err != nil {
+		return nil, errors.New(path + ": " + err.Error())
+	}
+	return creds, nil
+}
+
+func (c *credentials) save() error {
+	if err := os.MkdirAll(filepath.Dir(c.path), 0700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, c.path)
+}
+
+// clear forgets the saved tokens, keeping the URL and user for next login.
+func (c *credentials) clear() error {
+	c.Token, c.RefreshToken = "", ""
+	return c.save()
+}
+
+func sameURL(a, b string) bool {
+	return strings.TrimRight(a, "/") == strings.TrimRight(b, "/")
+}
@@ -0,0 +1,307 @@
+// Command blogronctl drives the BLOGRON Panel API from terminals and CI.
+//
+//	blogronctl login https://panel.example.com:8080
+//	blogronctl vhosts create example.com --php 8.2
+//	blogronctl wp create example.com --title "My Blog" --wait
+//	BLOGRON_URL=... BLOGRON_TOKEN=blg_... blogronctl -o json vhosts list
+//
+// Run "blogronctl help" for the list of commands.
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+
+	"golang.org/x/term"
+
+	"blogron/client"
+)
+
+// command is one "group action" subcommand.
+type command struct {
+	name  string // e.g. "vhosts create"
+	args  string // usage of the arguments, e.g. "DOMAIN [--php VERSION]"
+	short string
+	run   func(e *env, args []string) error
+}
+
+// env is what commands run with: global options, the API client and the
+// saved credentials.
+type env struct {
+	ctx    context.Context
+	cmd    *command
+	output string // "table" or "json"
+	url    string
+	api    *client.Client
+	creds  *credentials
+	stdin  *bufio.Reader
+	stdout io.Writer
+	stderr io.Writer
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	e := &env{
+		ctx:    ctx,
+		output: "table",
+		stdin:  bufio.NewReader(os.Stdin),
+		stdout: os.Stdout,
+		stderr: os.Stderr,
+	}
+	if err := e.main(os.Args[1:]); err != nil {
+		printError(e.stderr, err)
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(2)
+		}
+		os.Exit(1)
+	}
+}
+
+func (e *env) main(args []string) error {
+	// Global flags may come before the command, or anywhere after it.
+	fs := flag.NewFlagSet("blogronctl", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	e.globalFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return usageError(err.Error())
+	}
+	args = fs.Args()
+
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		e.usage(args)
+		return nil
+	}
+	cmd, rest := findCommand(args)
+	if cmd == nil {
+		return usageError(fmt.Sprintf("unknown command %q (see blogronctl help)", strings.Join(args[:min(2, len(args))], " ")))
+	}
+	e.cmd = cmd
+
+	creds, err := loadCredentials()
+	if err != nil {
+		return err
+	}
+	e.creds = creds
+	return cmd.run(e, rest)
+}
+
+// globalFlags registers the options every command takes.
+func (e *env) globalFlags(fs *flag.FlagSet) {
+	fs.StringVar(&e.output, "o", e.output, "output format: table or json")
+	fs.StringVar(&e.output, "output", e.output, "output format: table or json")
+	fs.StringVar(&e.url, "url", e.url, "panel URL (default $BLOGRON_URL or the saved login)")
+}
+
+// client returns the API client, built from --url, $BLOGRON_URL,
+// $BLOGRON_TOKEN and the saved login.
+func (e *env) client() (*client.Client, error) {
+	if e.api != nil {
+		return e.api, nil
+	}
+	baseURL := firstNonEmpty(e.url, os.Getenv("BLOGRON_URL"), e.creds.URL)
+	if baseURL == "" {
+		return nil, errors.New("no panel URL: run blogronctl login URL, or set BLOGRON_URL")
+	}
+	c := client.New(baseURL, "")
+	if token := os.Getenv("BLOGRON_TOKEN"); token != "" {
+		c.SetTokens(token, "")
+	} else if sameURL(baseURL, e.creds.URL) && e.creds.Token != "" {
+		c.SetTokens(e.creds.Token, e.creds.RefreshToken)
+		c.OnRefresh = func(t client.Tokens) {
+			e.creds.Token, e.creds.RefreshToken = t.Token, t.RefreshToken
+			if err := e.creds.save(); err != nil {
+				fmt.Fprintln(e.stderr, "warning: cannot save credentials:", err)
+			}
+		}
+	} else {
+		return nil, fmt.Errorf("not logged in to %s: run blogronctl login, or set BLOGRON_TOKEN", baseURL)
+	}
+	e.api = c
+	return c, nil
+}
+
+// flags returns a flag set for the running command, with the global flags.
+func (e *env) flags() *flag.FlagSet {
+	fs := flag.NewFlagSet(e.cmd.name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	e.globalFlags(fs)
+	return fs
+}
+
+// parse parses args with fs, allowing flags after positional arguments, and
+// checks that between minArgs and maxArgs positional arguments remain
+// (maxArgs < 0: no limit).
+func (e *env) parse(fs *flag.FlagSet, args []string, minArgs, maxArgs int) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, e.usageError(err.Error())
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			break
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+	if len(positional) < minArgs || maxArgs >= 0 && len(positional) > maxArgs {
+		return nil, e.usageError("wrong number of arguments")
+	}
+	if e.output != "table" && e.output != "json" {
+		return nil, e.usageError("-o must be table or json")
+	}
+	return positional, nil
+}
+
+// ── prompts ─────────────────────────────────────────────────────────────────
+
+// readLine prompts for a line of input.
+func (e *env) readLine(prompt string) (string, error) {
+	if isTerminal(os.Stdin) {
+		fmt.Fprint(e.stderr, prompt)
+	}
+	line, err := e.stdin.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", fmt.Errorf("reading %s: %w", strings.TrimSuffix(strings.TrimSpace(prompt), ":"), err)
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// readSecret prompts for a password without echoing it; piped input is read
+// a line at a time, so scripts can pass secrets on stdin.
+func (e *env) readSecret(prompt string) (string, error) {
+	if !isTerminal(os.Stdin) {
+		return e.readLine(prompt)
+	}
+	fmt.Fprint(e.stderr, prompt)
+	secret, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(e.stderr)
+	if err != nil {
+		return "", err
+	}
+	return string(secret), nil
+}
+
+func isTerminal(f *os.File) bool {
+	return term.IsTerminal(int(f.Fd()))
+}
+
+// ── usage and errors ────────────────────────────────────────────────────────
+
+type usageErr struct{ msg string }
+
+func (u *usageErr) Error() string { return u.msg }
+func (u *usageErr) Is(target error) bool {
+	return target == flag.ErrHelp
+}
+
+func usageError(msg string) error { return &usageErr{msg} }
+
+func (e *env) usageError(msg string) error {
+	return usageError(fmt.Sprintf("%s\nusage: blogronctl %s %s", msg, e.cmd.name, e.cmd.args))
+}
+
+func (e *env) usage(args []string) {
+	group := ""
+	if len(args) > 1 {
+		group = args[1]
+	}
+	fmt.Fprint(e.stdout, `Usage: blogronctl [-o table|json] [--url URL] COMMAND [ARGS]
+
+Logs in with "blogronctl login", or uses $BLOGRON_URL and $BLOGRON_TOKEN (an
+API token, e.g. for CI). Commands that start a background job take --wait to
+follow it to the end.
+
+Commands:
+`)
+	names := make([]string, 0, len(commands))
+	byName := map[string]*command{}
+	for i := range commands {
+		c := &commands[i]
+		if group != "" && c.name != group && !strings.HasPrefix(c.name, group+" ") {
+			continue
+		}
+		names = append(names, c.name)
+		byName[c.name] = c
+	}
+	sort.Strings(names)
+	tw := tabwriter.NewWriter(e.stdout, 0, 0, 2, ' ', 0)
+	for _, name := range names {
+		c := byName[name]
+		fmt.Fprintf(tw, "  %s\t%s\n", strings.TrimSpace(c.name+" "+firstWordArgs(c.args)), c.short)
+	}
+	tw.Flush()
+}
+
+// firstWordArgs returns the positional part of an argument usage string.
+func firstWordArgs(args string) string {
+	if i := strings.Index(args, "["); i >= 0 {
+		return strings.TrimSpace(args[:i])
+	}
+	return args
+}
+
+func findCommand(args []string) (*command, []string) {
+	for _, n := range []int{2, 1} {
+		if len(args) < n {
+			continue
+		}
+		name := strings.Join(args[:n], " ")
+		for i := range commands {
+			if commands[i].name == name {
+				return &commands[i], args[n:]
+			}
+		}
+	}
+	return nil, nil
+}
+
+func printError(w io.Writer, err error) {
+	var apiErr *client.Error
+	if errors.As(err, &apiErr) {
+		fmt.Fprintf(w, "error: %s [%s]\n", apiErr.Message, apiErr.Code)
+		fields := make([]string, 0, len(apiErr.Fields))
+		for f := range apiErr.Fields {
+			fields = append(fields, f)
+		}
+		sort.Strings(fields)
+		for _, f := range fields {
+			fmt.Fprintf(w, "  %s: %s\n", f, apiErr.Fields[f])
+		}
+		if apiErr.Step != "" {
+			fmt.Fprintf(w, "  failed step: %s\n", apiErr.Step)
+		}
+		if len(apiErr.RolledBack) > 0 {
+			fmt.Fprintf(w, "  rolled back: %s\n", strings.Join(apiErr.RolledBack, ", "))
+		}
+		if apiErr.Stderr != "" {
+			fmt.Fprintf(w, "  %s: %s\n", firstNonEmpty(apiErr.Command, "stderr"), strings.TrimSpace(apiErr.Stderr))
+		}
+		if apiErr.RequestID != "" {
+			fmt.Fprintf(w, "  request id: %s\n", apiErr.RequestID)
+		}
+		return
+	}
+	fmt.Fprintln(w, "error:", err)
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
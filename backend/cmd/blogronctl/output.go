@@ -0,0 +1,180 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// print writes v as indented JSON, or with -o table as a table: one row per
+// element of a slice, or one "key  value" row per field of a struct or map.
+func (e *env) print(v any) error {
+	if e.output == "json" {
+		enc := json.NewEncoder(e.stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+	if s, ok := v.(string); ok {
+		_, err := io.WriteString(e.stdout, strings.TrimSuffix(s, "\n")+"\n")
+		return err
+	}
+
+	tw := tabwriter.NewWriter(e.stdout, 0, 0, 2, ' ', 0)
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		writeRows(tw, rv)
+	case reflect.Struct:
+		writeFields(tw, "", rv)
+	case reflect.Map:
+		keys := rv.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j]) })
+		for _, k := range keys {
+			fmt.Fprintf(tw, "%s\t%s\n", strings.ToUpper(fmt.Sprint(k)), cell(rv.MapIndex(k)))
+		}
+	default:
+		fmt.Fprintln(tw, cell(rv))
+	}
+	return tw.Flush()
+}
+
+// writeFields writes a "KEY  value" row per field of a struct; nested
+// structs get a row per field, e.g. "CPU.USED_PCT".
+func writeFields(w io.Writer, prefix string, rv reflect.Value) {
+	for _, f := range reflect.VisibleFields(rv.Type()) {
+		name, ok := fieldName(f)
+		if !ok {
+			continue
+		}
+		v := rv.FieldByIndex(f.Index)
+		if f.Type.Kind() == reflect.Struct && f.Type != timeType {
+			writeFields(w, prefix+strings.ToUpper(name)+".", v)
+			continue
+		}
+		if tabular(f.Type) {
+			fmt.Fprintf(w, "%s%s\t%s\n", prefix, strings.ToUpper(name), cell(v))
+		}
+	}
+}
+
+func writeRows(w io.Writer, rv reflect.Value) {
+	elem := rv.Type().Elem()
+	for elem.Kind() == reflect.Pointer {
+		elem = elem.Elem()
+	}
+	if elem.Kind() != reflect.Struct {
+		for i := 0; i < rv.Len(); i++ {
+			fmt.Fprintln(w, cell(rv.Index(i)))
+		}
+		return
+	}
+
+	cols := columns(elem)
+	head := make([]string, len(cols))
+	for i, c := range cols {
+		head[i] = strings.ToUpper(c.name)
+	}
+	fmt.Fprintln(w, strings.Join(head, "\t"))
+	for i := 0; i < rv.Len(); i++ {
+		row := reflect.Indirect(rv.Index(i))
+		cells := make([]string, len(cols))
+		for j, c := range cols {
+			cells[j] = cell(row.FieldByIndex(c.index))
+		}
+		fmt.Fprintln(w, strings.Join(cells, "\t"))
+	}
+}
+
+type column struct {
+	name  string
+	index []int
+}
+
+// columns lists the fields of a struct type worth a table column: scalars,
+// times and lists of scalars, named by their JSON keys. Embedded structs are
+// flattened.
+func columns(t reflect.Type) []column {
+	var cols []column
+	for _, f := range reflect.VisibleFields(t) {
+		if name, ok := fieldName(f); ok && tabular(f.Type) {
+			cols = append(cols, column{name: name, index: f.Index})
+		}
+	}
+	return cols
+}
+
+// fieldName returns the JSON key of an exported, non-embedded field.
+func fieldName(f reflect.StructField) (string, bool) {
+	if !f.IsExported() || f.Anonymous {
+		return "", false
+	}
+	name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	switch name {
+	case "-":
+		return "", false
+	case "":
+		return f.Name, true
+	}
+	return name, true
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func tabular(t reflect.Type) bool {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		return t == timeType
+	case reflect.Slice, reflect.Array:
+		return t.Elem().Kind() != reflect.Struct && t.Elem().Kind() != reflect.Uint8
+	case reflect.Map:
+		return t.Elem().Kind() == reflect.String
+	}
+	return true
+}
+
+func cell(v reflect.Value) string {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+	switch {
+	case v.Type() == timeType:
+		t := v.Interface().(time.Time)
+		if t.IsZero() {
+			return ""
+		}
+		return t.Local().Format("2006-01-02 15:04:05")
+	case v.Kind() == reflect.Slice || v.Kind() == reflect.Array:
+		parts := make([]string, v.Len())
+		for i := range parts {
+			parts[i] = cell(v.Index(i))
+		}
+		return strings.Join(parts, ",")
+	case v.Kind() == reflect.Map:
+		keys := v.MapKeys()
+		parts := make([]string, len(keys))
+		for i, k := range keys {
+			parts[i] = fmt.Sprintf("%v=%s", k, cell(v.MapIndex(k)))
+		}
+		sort.Strings(parts)
+		return strings.Join(parts, ",")
+	case v.Kind() == reflect.String:
+		// Keep multi-line values (command output) on one row.
+		s := strings.TrimSpace(v.String())
+		if i := strings.IndexByte(s, '\n'); i >= 0 {
+			s = s[:i] + " …"
+		}
+		return s
+	}
+	return fmt.Sprint(v.Interface())
+}
@@ -0,0 +1,346 @@
+// Package config loads the panel's settings from a YAML file
+// (/etc/blogron/panel.yaml by default), applies environment overrides and
+// validates the result before the API starts.
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultPath is read when PANEL_CONFIG does not name another file.
+const DefaultPath = "/etc/blogron/panel.yaml"
+
+// Masked replaces secret values in Config.Masked.
+const Masked = "********"
+
+// Config is everything the panel needs to know about the server it manages.
+type Config struct {
+	Port        string `yaml:"port" json:"port"`
+	DataDir     string `yaml:"data_dir" json:"data_dir"`
+	PanelDomain string `yaml:"panel_domain" json:"panel_domain"`
+	DryRun      bool   `yaml:"dry_run" json:"dry_run"`
+
+	// ShutdownTimeout is how many seconds in-flight requests and running
+	// jobs get to finish on SIGTERM before jobs are cancelled.
+	ShutdownTimeout int `yaml:"shutdown_timeout" json:"shutdown_timeout"`
+
+	// TrustedProxies are the addresses or CIDR ranges whose X-Forwarded-For
+	// and X-Real-IP headers name the client, e.g. the local nginx. Anything
+	// else is identified by its connection address.
+	TrustedProxies []string `yaml:"trusted_proxies" json:"trusted_proxies"`
+
+	TLS      TLS      `yaml:"tls" json:"tls"`
+	Auth     Auth     `yaml:"auth" json:"auth"`
+	MySQL    MySQL    `yaml:"mysql" json:"mysql"`
+	PHP      PHP      `yaml:"php" json:"php"`
+	Services Services `yaml:"services" json:"services"`
+	Paths    Paths    `yaml:"paths" json:"paths"`
+}
+
+// TLS makes the panel serve HTTPS itself, from cert_file/key_file (reloaded
+// when they change) or from a Let's Encrypt certificate for panel_domain.
+type TLS struct {
+	CertFile   string `yaml:"cert_file" json:"cert_file"`
+	KeyFile    string `yaml:"key_file" json:"key_file"`
+	ACME       bool   `yaml:"acme" json:"acme"`
+	ACMEEmail  string `yaml:"acme_email" json:"acme_email"`
+	HTTPPort   string `yaml:"http_port" json:"http_port"`       // redirects to HTTPS; "" for none
+	HSTSMaxAge int    `yaml:"hsts_max_age" json:"hsts_max_age"` // seconds, 0 disables HSTS
+}
+
+// Enabled reports whether the panel terminates TLS itself.
+func (t TLS) Enabled() bool {
+	return t.ACME || t.CertFile != ""
+}
+
+type Auth struct {
+	JWTSecret     string `yaml:"jwt_secret" json:"jwt_secret"`
+	AdminUser     string `yaml:"admin_user" json:"admin_user"`
+	AdminPassword string `yaml:"admin_password" json:"admin_password"` // seeds the first account only
+}
+
+type MySQL struct {
+	User     string `yaml:"user" json:"user"`
+	Password string `yaml:"password" json:"password"`
+}
+
+type PHP struct {
+	DefaultVersion string `yaml:"default_version" json:"default_version"`
+}
+
+type Services struct {
+	Bind string `yaml:"bind" json:"bind"` // "named" on Ubuntu, "bind9" on Debian
+}
+
+// Paths are the files and directories of the managed services.
+type Paths struct {
+	NginxSitesAvailable   string `yaml:"nginx_sites_available" json:"nginx_sites_available"`
+	NginxSitesEnabled     string `yaml:"nginx_sites_enabled" json:"nginx_sites_enabled"`
+	NginxTemplates        string `yaml:"nginx_templates" json:"nginx_templates"`
+	WebRoot               string `yaml:"web_root" json:"web_root"`
+	FileManagerRoot       string `yaml:"file_manager_root" json:"file_manager_root"`
+	WPRoot                string `yaml:"wp_root" json:"wp_root"`
+	WPCli                 string `yaml:"wp_cli" json:"wp_cli"`
+	BindZonesDir          string `yaml:"bind_zones_dir" json:"bind_zones_dir"`
+	BindNamedLocal        string `yaml:"bind_named_local" json:"bind_named_local"`
+	PostfixVirtualMailbox string `yaml:"postfix_virtual_mailbox" json:"postfix_virtual_mailbox"`
+	PostfixVirtualDomains string `yaml:"postfix_virtual_domains" json:"postfix_virtual_domains"`
+	PostfixVirtualMaps    string `yaml:"postfix_virtual_maps" json:"postfix_virtual_maps"`
+	DovecotUsers          string `yaml:"dovecot_users" json:"dovecot_users"`
+	MailStorage           string `yaml:"mail_storage" json:"mail_storage"`
+	VsftpdUserList        string `yaml:"vsftpd_user_list" json:"vsftpd_user_list"`
+	VsftpdPasswd          string `yaml:"vsftpd_passwd" json:"vsftpd_passwd"`
+	CronDir               string `yaml:"cron_dir" json:"cron_dir"`
+}
+
+// Default returns the settings of a stock Ubuntu/Debian install. Secrets are
+// left empty; they must come from the file or the environment.
+func Default() *Config {
+	return &Config{
+		Port:            "8080",
+		DataDir:         "/var/lib/blogron",
+		ShutdownTimeout: 60,
+		TrustedProxies:  []string{"127.0.0.1", "::1"},
+		TLS:             TLS{HSTSMaxAge: 31536000},
+		Auth:            Auth{AdminUser: "admin"},
+		MySQL:           MySQL{User: "root"},
+		PHP:             PHP{DefaultVersion: "8.2"},
+		Services:        Services{Bind: "named"},
+		Paths: Paths{
+			NginxSitesAvailable:   "/etc/nginx/sites-available",
+			NginxSitesEnabled:     "/etc/nginx/sites-enabled",
+			NginxTemplates:        "/var/lib/blogron/nginx-templates",
+			WebRoot:               "/var/www",
+			FileManagerRoot:       "/var/www",
+			WPRoot:                "/var/www",
+			WPCli:                 "/usr/local/bin/wp",
+			BindZonesDir:          "/etc/bind/zones",
+			BindNamedLocal:        "/etc/bind/named.conf.local",
+			PostfixVirtualMailbox: "/etc/postfix/virtual_mailbox",
+			PostfixVirtualDomains: "/etc/postfix/virtual_mailbox_domains",
+			PostfixVirtualMaps:    "/etc/postfix/virtual_mailbox_maps",
+			DovecotUsers:          "/etc/dovecot/users",
+			MailStorage:           "/var/mail/vhosts",
+			VsftpdUserList:        "/etc/vsftpd.userlist",
+			VsftpdPasswd:          "/etc/vsftpd.passwd",
+			CronDir:               "/var/spool/cron/crontabs",
+		},
+	}
+}
+
+// Path returns the config file to read: PANEL_CONFIG or DefaultPath.
+func Path() string {
+	if p := os.Getenv("PANEL_CONFIG"); p != "" {
+		return p
+	}
+	return DefaultPath
+}
+
+// Load reads path on top of Default, applies the environment overrides and
+// validates the result. A missing file is only an error when PANEL_CONFIG
+// named it explicitly.
+func Load(path string) (*Config, error) {
+	cfg := Default()
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist) && os.Getenv("PANEL_CONFIG") == "":
+	case err != nil:
+		return nil, err
+	default:
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	cfg.applyEnv()
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// applyEnv lets the variables the panel has always read win over the file.
+func (c *Config) applyEnv() {
+	for name, dst := range map[string]*string{
+		"PORT":            &c.Port,
+		"PANEL_DATA_DIR":  &c.DataDir,
+		"PANEL_DOMAIN":    &c.PanelDomain,
+		"PANEL_TLS_CERT":  &c.TLS.CertFile,
+		"PANEL_TLS_KEY":   &c.TLS.KeyFile,
+		"PANEL_HTTP_PORT": &c.TLS.HTTPPort,
+		"JWT_SECRET":      &c.Auth.JWTSecret,
+		"ADMIN_USER":      &c.Auth.AdminUser,
+		"ADMIN_PASSWORD":  &c.Auth.AdminPassword,
+		"MYSQL_USER":      &c.MySQL.User,
+		"MYSQL_PASSWORD":  &c.MySQL.Password,
+		"PHP_VERSION":     &c.PHP.DefaultVersion,
+		"BIND_SERVICE":    &c.Services.Bind,
+	} {
+		if v, ok := os.LookupEnv(name); ok && v != "" {
+			*dst = v
+		}
+	}
+	if v := os.Getenv("PANEL_DRY_RUN"); v != "" {
+		c.DryRun = v == "1" || v == "true"
+	}
+	if v := os.Getenv("PANEL_ACME"); v != "" {
+		c.TLS.ACME = v == "1" || v == "true"
+	}
+	if v, ok := os.LookupEnv("PANEL_TRUSTED_PROXIES"); ok {
+		c.TrustedProxies = nil
+		for _, p := range strings.Split(v, ",") {
+			if p = strings.TrimSpace(p); p != "" {
+				c.TrustedProxies = append(c.TrustedProxies, p)
+			}
+		}
+	}
+}
+
+// insecureSecrets are values shipped in examples and older builds; the panel
+// refuses to start with any of them.
+var insecureSecrets = map[string]bool{
+	"change-me-in-production-use-env-var": true,
+	"replace-with-a-long-random-secret":   true,
+	"changeme":                            true,
+}
+
+var (
+	phpVersionRe = regexp.MustCompile(`^\d\.\d$`)
+	nameRe       = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._-]*$`)
+)
+
+// Validate reports every problem with c at once.
+func (c *Config) Validate() error {
+	var problems []string
+	add := func(format string, args ...any) { problems = append(problems, fmt.Sprintf(format, args...)) }
+
+	if !validPort(c.Port) {
+		add("port: %q is not a valid TCP port", c.Port)
+	}
+	if c.ShutdownTimeout < 1 {
+		add("shutdown_timeout: must be at least 1 second")
+	}
+	t := c.TLS
+	if (t.CertFile == "") != (t.KeyFile == "") {
+		add("tls: cert_file and key_file must be set together")
+	}
+	if t.ACME && t.CertFile != "" {
+		add("tls: acme and cert_file/key_file are mutually exclusive")
+	}
+	if t.ACME && c.PanelDomain == "" {
+		add("tls.acme: needs panel_domain to request a certificate for")
+	}
+	if t.HTTPPort != "" && (!validPort(t.HTTPPort) || t.HTTPPort == c.Port) {
+		add("tls.http_port: %q is not a valid TCP port other than port", t.HTTPPort)
+	}
+	if t.HTTPPort != "" && !t.Enabled() {
+		add("tls.http_port: only used with cert_file/key_file or acme")
+	}
+	if t.HSTSMaxAge < 0 {
+		add("tls.hsts_max_age: must not be negative")
+	}
+	for _, p := range c.TrustedProxies {
+		if _, err := netip.ParsePrefix(p); err != nil {
+			if _, err := netip.ParseAddr(p); err != nil {
+				add("trusted_proxies: %q is not an IP address or CIDR range", p)
+			}
+		}
+	}
+	switch {
+	case c.Auth.JWTSecret == "":
+		add("auth.jwt_secret: not set (generate one with: openssl rand -base64 48)")
+	case insecureSecrets[c.Auth.JWTSecret]:
+		add("auth.jwt_secret: still the built-in default")
+	case len(c.Auth.JWTSecret) < 32:
+		add("auth.jwt_secret: must be at least 32 characters")
+	}
+	if insecureSecrets[c.Auth.AdminPassword] {
+		add("auth.admin_password: still the built-in default")
+	}
+	if !nameRe.MatchString(c.Auth.AdminUser) {
+		add("auth.admin_user: %q is not a valid username", c.Auth.AdminUser)
+	}
+	if c.MySQL.User == "" {
+		add("mysql.user: not set")
+	}
+	if !ValidPHPVersion(c.PHP.DefaultVersion) {
+		add("php.default_version: %q is not a version like 8.2", c.PHP.DefaultVersion)
+	}
+	if !nameRe.MatchString(c.Services.Bind) {
+		add("services.bind: %q is not a systemd unit name", c.Services.Bind)
+	}
+	if c.DataDir == "" {
+		add("data_dir: not set") // relative is fine for development (./data)
+	}
+	for key, p := range c.Paths.byKey() {
+		if !filepath.IsAbs(p) {
+			add("paths.%s: %q must be an absolute path", key, p)
+		}
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+	sort.Strings(problems)
+	return errors.New("invalid configuration:\n  " + strings.Join(problems, "\n  "))
+}
+
+// Masked returns a copy of c that is safe to show: secrets that are set
+// read "********".
+func (c *Config) Masked() *Config {
+	m := *c
+	for _, s := range []*string{&m.Auth.JWTSecret, &m.Auth.AdminPassword, &m.MySQL.Password} {
+		if *s != "" {
+			*s = Masked
+		}
+	}
+	return &m
+}
+
+// ValidPHPVersion reports whether v is a PHP version like "8.2", the form
+// that goes into PHP-FPM socket paths and package names.
+func ValidPHPVersion(v string) bool {
+	return phpVersionRe.MatchString(v)
+}
+
+// ── helpers ───────────────────────────────────────────────────────────────────
+
+func validPort(port string) bool {
+	n, err := strconv.Atoi(port)
+	return err == nil && n >= 1 && n <= 65535
+}
+
+func (p Paths) byKey() map[string]string {
+	return map[string]string{
+		"nginx_sites_available":   p.NginxSitesAvailable,
+		"nginx_sites_enabled":     p.NginxSitesEnabled,
+		"nginx_templates":         p.NginxTemplates,
+		"web_root":                p.WebRoot,
+		"file_manager_root":       p.FileManagerRoot,
+		"wp_root":                 p.WPRoot,
+		"wp_cli":                  p.WPCli,
+		"bind_zones_dir":          p.BindZonesDir,
+		"bind_named_local":        p.BindNamedLocal,
+		"postfix_virtual_mailbox": p.PostfixVirtualMailbox,
+		"postfix_virtual_domains": p.PostfixVirtualDomains,
+		"postfix_virtual_maps":    p.PostfixVirtualMaps,
+		"dovecot_users":           p.DovecotUsers,
+		"mail_storage":            p.MailStorage,
+		"vsftpd_user_list":        p.VsftpdUserList,
+		"vsftpd_passwd":           p.VsftpdPasswd,
+		"cron_dir":                p.CronDir,
+	}
+}
@@ -1,13 +1,20 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"sort"
 	"time"
 
 	"blogron/api"
+	"blogron/config"
 	"blogron/middleware"
+	"blogron/store"
+	"blogron/util"
 
 	"github.com/go-chi/chi/v5"
 	chimiddleware "github.com/go-chi/chi/v5/middleware"
@@ -15,19 +22,48 @@ import (
 )
 
 func main() {
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	cfg, err := config.Load(config.Path())
+	if err != nil {
+		log.Fatalf("refusing to start: %v", err)
+	}
+	util.SetDataDir(cfg.DataDir)
+	util.SetJWTSecret(cfg.Auth.JWTSecret)
+	api.Configure(cfg)
+
+	middleware.APITokenVerifier = api.VerifyAPIToken
+	if cfg.DryRun {
+		log.Printf("dry run: system commands are logged, not executed")
+		api.SetExecutor(&util.DryRunExecutor{})
+	}
+
+	st, err := store.Open()
+	if err != nil {
+		log.Fatalf("cannot open resource store: %v", err)
+	}
+	defer st.Close()
+	if err := api.SetStore(st); err != nil {
+		log.Fatalf("cannot import ownership registry: %v", err)
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "reconcile" {
+		code := runReconcile(os.Args[2:])
+		st.Close()
+		os.Exit(code)
+	}
+	if err := api.SeedPanelUsers(); err != nil {
+		log.Fatalf("refusing to start: %v", err)
 	}
 
 	r := chi.NewRouter()
 	r.Use(chimiddleware.RequestID)
-	r.Use(chimiddleware.RealIP)
+	r.Use(middleware.RequestIDHeader)
+	r.Use(middleware.RealIP(cfg.TrustedProxies))
 	r.Use(chimiddleware.Logger)
 	r.Use(chimiddleware.Recoverer)
-	r.Use(chimiddleware.Timeout(30 * time.Second))
+	r.Use(middleware.HSTS(cfg.TLS.HSTSMaxAge))
+	r.Use(middleware.Timeout(30 * time.Second))
 	allowedOrigins := []string{"http://localhost:3000", "http://localhost:5173"}
-	if panelDomain := os.Getenv("PANEL_DOMAIN"); panelDomain != "" {
+	if panelDomain := cfg.PanelDomain; panelDomain != "" {
 		allowedOrigins = append(allowedOrigins,
 			"https://"+panelDomain,
 			"http://"+panelDomain,
@@ -38,97 +74,198 @@ func main() {
 		AllowedOrigins:   allowedOrigins,
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
 		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type"},
+		ExposedHeaders:   []string{"X-Request-Id"},
 		AllowCredentials: true,
 		MaxAge:           300,
 	}))
 
-	r.Post("/api/auth/login", api.Login)
-	r.Get("/api/health", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(200); w.Write([]byte(`{"status":"ok"}`))
-	})
-
-	r.Group(func(r chi.Router) {
-		r.Use(middleware.JWTAuth)
-
-		r.Get("/api/system/stats", api.GetSystemStats)
-		r.Get("/api/system/services", api.GetServices)
-		r.Post("/api/system/services/{name}/restart", api.RestartService)
-		r.Post("/api/system/services/{name}/stop", api.StopService)
-		r.Post("/api/system/services/{name}/start", api.StartService)
-		r.Get("/api/system/logs", api.GetLogs)
-
-		r.Get("/api/users", api.ListUsers)
-		r.Post("/api/users", api.CreateUser)
-		r.Put("/api/users/{username}", api.UpdateUser)
-		r.Delete("/api/users/{username}", api.DeleteUser)
-		r.Post("/api/users/{username}/suspend", api.SuspendUser)
-		r.Post("/api/users/{username}/activate", api.ActivateUser)
-
-		r.Get("/api/vhosts", api.ListVhosts)
-		r.Post("/api/vhosts", api.CreateVhost)
-		r.Delete("/api/vhosts/{domain}", api.DeleteVhost)
-		r.Post("/api/vhosts/{domain}/enable", api.EnableVhost)
-		r.Post("/api/vhosts/{domain}/disable", api.DisableVhost)
-		r.Post("/api/vhosts/{domain}/ssl", api.EnableSSL)
-
-		r.Get("/api/databases", api.ListDatabases)
-		r.Post("/api/databases", api.CreateDatabase)
-		r.Delete("/api/databases/{name}", api.DropDatabase)
-		r.Get("/api/databases/{name}/tables", api.ListTables)
-
-		r.Get("/api/files", api.ListFiles)
-		r.Post("/api/files/mkdir", api.MakeDirectory)
-		r.Delete("/api/files", api.DeleteFile)
-		r.Post("/api/files/rename", api.RenameFile)
-		r.Get("/api/files/read", api.ReadFile)
-		r.Post("/api/files/write", api.WriteFile)
-		r.Post("/api/files/upload", api.UploadFile)
-
-		r.Get("/api/email/domains", api.ListMailDomains)
-		r.Post("/api/email/domains", api.AddMailDomain)
-		r.Delete("/api/email/domains/{domain}", api.DeleteMailDomain)
-		r.Get("/api/email/mailboxes", api.ListMailboxes)
-		r.Post("/api/email/mailboxes", api.CreateMailbox)
-		r.Delete("/api/email/mailboxes/{email}", api.DeleteMailbox)
-		r.Get("/api/email/queue", api.GetMailQueue)
-		r.Post("/api/email/queue/flush", api.FlushMailQueue)
-
-		r.Get("/api/dns", api.ListDNSZones)
-		r.Post("/api/dns", api.CreateDNSZone)
-		r.Get("/api/dns/{domain}", api.GetDNSZone)
-		r.Delete("/api/dns/{domain}", api.DeleteDNSZone)
-		r.Post("/api/dns/{domain}/records", api.AddDNSRecord)
-		r.Delete("/api/dns/{domain}/records", api.DeleteDNSRecord)
-
-		r.Get("/api/cron", api.ListCronJobs)
-		r.Post("/api/cron", api.CreateCronJob)
-		r.Put("/api/cron/{id}", api.UpdateCronJob)
-		r.Delete("/api/cron/{id}", api.DeleteCronJob)
-		r.Post("/api/cron/{id}/run", api.RunCronNow)
-
-		r.Get("/api/ftp", api.ListFTPUsers)
-		r.Post("/api/ftp", api.CreateFTPUser)
-		r.Put("/api/ftp/{username}", api.UpdateFTPPassword)
-		r.Delete("/api/ftp/{username}", api.DeleteFTPUser)
-
-		// WordPress
-		r.Get("/api/wordpress", api.ListWPSites)
-		r.Post("/api/wordpress", api.CreateWPSite)
-		r.Delete("/api/wordpress/{domain}", api.DeleteWPSite)
-		r.Get("/api/wordpress/{domain}/plugins", api.ListWPPlugins)
-		r.Post("/api/wordpress/{domain}/plugins", api.InstallWPPlugin)
-		r.Put("/api/wordpress/{domain}/plugins/{plugin}", api.ToggleWPPlugin)
-		r.Get("/api/wordpress/{domain}/themes", api.ListWPThemes)
-		r.Post("/api/wordpress/{domain}/themes", api.InstallWPTheme)
-		r.Put("/api/wordpress/{domain}/themes/{theme}", api.ToggleWPTheme)
-		r.Post("/api/wordpress/{domain}/update", api.WPUpdateCore)
-		r.Post("/api/wordpress/{domain}/maintenance", api.WPMaintenanceMode)
-		r.Post("/api/wordpress/{domain}/search-replace", api.WPSearchReplace)
-		r.Post("/api/wordpress/{domain}/cache-flush", api.WPCacheFlush)
-	})
-
-	log.Printf("BLOGRON Panel API listening on :%s", port)
-	if err := http.ListenAndServe(":"+port, r); err != nil {
+	// Every route is served under /api/v1; /api is an alias for v1 so
+	// existing clients keep working. Breaking changes go to /api/v2.
+	routes := func(r chi.Router) {
+		r.Post("/auth/login", api.Login)
+		r.Post("/auth/login/verify", api.VerifyLogin)
+		r.Post("/auth/refresh", api.RefreshToken)
+		r.Get("/health", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(200); w.Write([]byte(`{"status":"ok"}`))
+		})
+
+		r.Group(func(r chi.Router) {
+			r.Use(middleware.JWTAuth)
+			r.Use(middleware.Audit)
+			r.Use(middleware.ReadOnlyGuard)
+
+			r.Post("/auth/logout", api.Logout)
+			r.Get("/auth/sessions", api.ListSessions)
+			r.Delete("/auth/sessions/{id}", api.DeleteSession)
+
+			// API tokens for automation, owned by the calling account
+			r.Get("/auth/tokens", api.ListAPITokens)
+			r.Post("/auth/tokens", api.CreateAPIToken)
+			r.Delete("/auth/tokens/{id}", api.DeleteAPIToken)
+
+			// Two-factor enrollment for the calling account — allowed for every role
+			r.Post("/auth/totp/setup", api.SetupTOTP)
+			r.Post("/auth/totp/enable", api.EnableTOTP)
+			r.Post("/auth/totp/disable", api.DisableTOTP)
+			r.Post("/auth/totp/recovery-codes", api.RegenerateRecoveryCodes)
+
+			// Server-wide management — not available to site owners
+			r.Group(func(r chi.Router) {
+				r.Use(middleware.RequireRole(middleware.RoleAdmin, middleware.RoleOperator, middleware.RoleReadOnly))
+
+				r.Get("/system/stats", api.GetSystemStats)
+				r.Get("/system/services", api.GetServices)
+				r.Post("/system/services/{name}/restart", api.RestartService)
+				r.Post("/system/services/{name}/stop", api.StopService)
+				r.Post("/system/services/{name}/start", api.StartService)
+				r.Get("/system/logs", api.GetLogs)
+
+				r.Get("/users", api.ListUsers)
+				r.Post("/users", api.CreateUser)
+				r.Put("/users/{username}", api.UpdateUser)
+				r.Delete("/users/{username}", api.DeleteUser)
+				r.Post("/users/{username}/suspend", api.SuspendUser)
+				r.Post("/users/{username}/activate", api.ActivateUser)
+
+				r.Get("/email/queue", api.GetMailQueue)
+				r.Post("/email/queue/flush", api.FlushMailQueue)
+
+				r.Get("/dns", api.ListDNSZones)
+				r.Post("/dns", api.CreateDNSZone)
+				r.Get("/dns/{domain}", api.GetDNSZone)
+				r.Delete("/dns/{domain}", api.DeleteDNSZone)
+				r.Post("/dns/{domain}/records", api.AddDNSRecord)
+				r.Delete("/dns/{domain}/records", api.DeleteDNSRecord)
+
+				r.Get("/ownership", api.ListOwnership)
+				r.Put("/ownership/{kind}/{name}", api.SetOwnership)
+
+				r.Get("/audit", api.GetAuditLog)
+
+				r.Get("/settings", api.GetSettings)
+
+				// Custom vhost profiles (listing them is open to every role)
+				r.Put("/vhosts/profiles/{name}", api.SaveVhostProfile)
+				r.Delete("/vhosts/profiles/{name}", api.DeleteVhostProfile)
+			})
+
+			// Panel accounts
+			r.Group(func(r chi.Router) {
+				r.Use(middleware.RequireRole(middleware.RoleAdmin))
+
+				r.Get("/panel-users", api.ListPanelUsers)
+				r.Post("/panel-users", api.CreatePanelUser)
+				r.Put("/panel-users/{username}", api.UpdatePanelUser)
+				r.Delete("/panel-users/{username}", api.DeletePanelUser)
+			})
+
+			// Resource metadata (site owners see their own)
+			r.Get("/resources", api.ListResources)
+			r.Put("/resources/{kind}/{name}", api.UpdateResource)
+
+			// Background jobs (site owners see their own)
+			r.Get("/jobs", api.ListJobs)
+			r.Get("/jobs/{id}", api.GetJob)
+			r.Get("/jobs/{id}/stream", api.StreamJob)
+			r.Delete("/jobs/{id}", api.CancelJob)
+
+			r.Get("/vhosts", api.ListVhosts)
+			r.Post("/vhosts", api.CreateVhost)
+			r.Get("/vhosts/profiles", api.ListVhostProfiles)
+			r.Get("/vhosts/profiles/{name}", api.GetVhostProfile)
+			r.Put("/vhosts/{domain}", api.UpdateVhost)
+			r.Delete("/vhosts/{domain}", api.DeleteVhost)
+			r.Post("/vhosts/{domain}/enable", api.EnableVhost)
+			r.Post("/vhosts/{domain}/disable", api.DisableVhost)
+			r.Post("/vhosts/{domain}/ssl", api.EnableSSL)
+
+			r.Get("/databases", api.ListDatabases)
+			r.Post("/databases", api.CreateDatabase)
+			r.Delete("/databases/{name}", api.DropDatabase)
+			r.Get("/databases/{name}/tables", api.ListTables)
+
+			r.Get("/files", api.ListFiles)
+			r.Post("/files/mkdir", api.MakeDirectory)
+			r.Delete("/files", api.DeleteFile)
+			r.Post("/files/rename", api.RenameFile)
+			r.Get("/files/read", api.ReadFile)
+			r.Post("/files/write", api.WriteFile)
+			r.Post("/files/upload", api.UploadFile)
+
+			r.Get("/email/domains", api.ListMailDomains)
+			r.Post("/email/domains", api.AddMailDomain)
+			r.Delete("/email/domains/{domain}", api.DeleteMailDomain)
+			r.Get("/email/mailboxes", api.ListMailboxes)
+			r.Post("/email/mailboxes", api.CreateMailbox)
+			r.Delete("/email/mailboxes/{email}", api.DeleteMailbox)
+
+			r.Get("/cron", api.ListCronJobs)
+			r.Post("/cron", api.CreateCronJob)
+			r.Put("/cron/{id}", api.UpdateCronJob)
+			r.Delete("/cron/{id}", api.DeleteCronJob)
+			r.Post("/cron/{id}/run", api.RunCronNow)
+
+			r.Get("/ftp", api.ListFTPUsers)
+			r.Post("/ftp", api.CreateFTPUser)
+			r.Put("/ftp/{username}", api.UpdateFTPPassword)
+			r.Delete("/ftp/{username}", api.DeleteFTPUser)
+
+			// WordPress
+			r.Get("/wordpress", api.ListWPSites)
+			r.Post("/wordpress", api.CreateWPSite)
+			r.Delete("/wordpress/{domain}", api.DeleteWPSite)
+			r.Get("/wordpress/{domain}/plugins", api.ListWPPlugins)
+			r.Post("/wordpress/{domain}/plugins", api.InstallWPPlugin)
+			r.Put("/wordpress/{domain}/plugins/{plugin}", api.ToggleWPPlugin)
+			r.Get("/wordpress/{domain}/themes", api.ListWPThemes)
+			r.Post("/wordpress/{domain}/themes", api.InstallWPTheme)
+			r.Put("/wordpress/{domain}/themes/{theme}", api.ToggleWPTheme)
+			r.Post("/wordpress/{domain}/update", api.WPUpdateCore)
+			r.Post("/wordpress/{domain}/maintenance", api.WPMaintenanceMode)
+			r.Post("/wordpress/{domain}/search-replace", api.WPSearchReplace)
+			r.Post("/wordpress/{domain}/cache-flush", api.WPCacheFlush)
+		})
+
+		r.Get("/openapi.json", api.OpenAPISpec)
+	}
+	r.Route("/api/v1", routes)
+	r.Route("/api", routes)
+	api.SetRoutes(r)
+
+	if err := serve(cfg, r); err != nil {
 		log.Fatal(err)
 	}
 }
+
+// runReconcile implements "blogron reconcile [-dry-run]": it imports resources
+// found on the server into the resource store and drops records of
+// resources that no longer exist.
+func runReconcile(args []string) int {
+	fs := flag.NewFlagSet("reconcile", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "report changes without saving them")
+	fs.Parse(args)
+
+	report, err := api.Reconcile(context.Background(), *dryRun)
+	for _, line := range []struct {
+		label string
+		items []string
+	}{{"added", report.Added}, {"updated", report.Updated}, {"removed", report.Removed}} {
+		for _, item := range line.items {
+			fmt.Printf("%-8s %s\n", line.label, item)
+		}
+	}
+	kinds := make([]string, 0, len(report.Skipped))
+	for kind := range report.Skipped {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	for _, kind := range kinds {
+		fmt.Printf("skipped  %s: %s\n", kind, report.Skipped[kind])
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "reconcile: %v\n", err)
+		return 1
+	}
+	fmt.Printf("%d added, %d updated, %d removed\n", len(report.Added), len(report.Updated), len(report.Removed))
+	return 0
+}
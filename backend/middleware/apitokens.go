@@ -0,0 +1,109 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"blogron/util"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// APITokenPrefix marks a Bearer credential as a long-lived API token rather
+// than a JWT.
+const APITokenPrefix = "blg_"
+
+// APITokenVerifier resolves an API token to the claims of the account that
+// owns it. It is set by main (the token store lives in package api) and must
+// put the granted scopes in a "scopes" claim of type []string.
+var APITokenVerifier func(r *http.Request, token string) (jwt.MapClaims, error)
+
+var errNoTokenVerifier = errors.New("API tokens are not enabled")
+
+// Scope resources an API token can be granted. Panel accounts and the
+// /api/auth/ endpoints (sessions, two-factor, tokens themselves) are never
+// reachable with an API token.
+var ScopeResources = []string{
+	"cron", "databases", "dns", "email", "files", "ftp",
+	"jobs", "ownership", "resources", "settings", "system", "users", "vhosts",
+	"wordpress",
+}
+
+// ValidScope reports whether scope is "*", "<resource>:read",
+// "<resource>:write", "<resource>:*" or the special "wordpress:cache".
+func ValidScope(scope string) bool {
+	if scope == "*" || scope == "wordpress:cache" {
+		return true
+	}
+	res, action, ok := strings.Cut(scope, ":")
+	if !ok || (action != "read" && action != "write" && action != "*") {
+		return false
+	}
+	for _, known := range ScopeResources {
+		if res == known {
+			return true
+		}
+	}
+	return false
+}
+
+// RequiredScope returns the scope an API token needs for r, derived from the
+// first path segment after /api/ (or /api/v1/) and the method. It returns "" for paths
+// that API tokens may not use at all.
+func RequiredScope(r *http.Request) string {
+	path := APIPath(r.URL.Path)
+	res, rest, _ := strings.Cut(path, "/")
+	switch res {
+	case "auth", "panel-users", "":
+		return ""
+	}
+	if res == "wordpress" && r.Method == http.MethodPost && strings.HasSuffix(rest, "/cache-flush") {
+		return "wordpress:cache"
+	}
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		return res + ":read"
+	}
+	return res + ":write"
+}
+
+// ScopeAllows reports whether any granted scope covers need. A write scope
+// implies read, and wordpress:write implies wordpress:cache.
+func ScopeAllows(granted []string, need string) bool {
+	if need == "" {
+		return false
+	}
+	res, action, _ := strings.Cut(need, ":")
+	for _, g := range granted {
+		switch g {
+		case "*", need, res + ":*":
+			return true
+		case res + ":write":
+			if action == "read" || action == "cache" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// apiTokenClaims verifies an API token and checks its scopes against r.
+// The error is a 401 for a bad token and a 403 for a missing scope.
+func apiTokenClaims(r *http.Request, token string) (jwt.MapClaims, *util.Error) {
+	if APITokenVerifier == nil {
+		return nil, util.NewError(http.StatusUnauthorized, util.CodeInvalidToken, errNoTokenVerifier.Error())
+	}
+	claims, err := APITokenVerifier(r, token)
+	if err != nil {
+		return nil, util.NewError(http.StatusUnauthorized, util.CodeInvalidToken, err.Error())
+	}
+	scopes, _ := claims["scopes"].([]string)
+	need := RequiredScope(r)
+	if !ScopeAllows(scopes, need) {
+		if need == "" {
+			return nil, util.NewError(http.StatusForbidden, util.CodeScopeMissing, "API tokens cannot access this endpoint")
+		}
+		return nil, util.NewError(http.StatusForbidden, util.CodeScopeMissing, "API token lacks scope "+need)
+	}
+	return claims, nil
+}
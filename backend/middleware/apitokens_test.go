@@ -0,0 +1,123 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestRequiredScope(t *testing.T) {
+	for _, tc := range []struct {
+		method, path, want string
+	}{
+		{"GET", "/api/vhosts", "vhosts:read"},
+		{"GET", "/api/v1/vhosts", "vhosts:read"},
+		{"HEAD", "/api/v1/vhosts/example.com", "vhosts:read"},
+		{"POST", "/api/vhosts", "vhosts:write"},
+		{"PUT", "/api/v1/vhosts/example.com", "vhosts:write"},
+		{"PATCH", "/api/databases/shop", "databases:write"},
+		{"DELETE", "/api/v1/cron/3", "cron:write"},
+		{"GET", "/api/jobs/abc/stream", "jobs:read"},
+		{"POST", "/api/wordpress/example.com/cache-flush", "wordpress:cache"},
+		{"POST", "/api/v1/wordpress/example.com/cache-flush", "wordpress:cache"},
+		{"GET", "/api/wordpress/example.com/cache-flush", "wordpress:read"},
+		{"POST", "/api/wordpress/example.com/update", "wordpress:write"},
+
+		// Accounts, sessions, two-factor and the tokens themselves are off
+		// limits whatever the method.
+		{"GET", "/api/auth/sessions", ""},
+		{"POST", "/api/auth/tokens", ""},
+		{"POST", "/api/v1/auth/totp/disable", ""},
+		{"GET", "/api/panel-users", ""},
+		{"PUT", "/api/v1/panel-users/admin", ""},
+		{"GET", "/api/", ""},
+		{"GET", "/api/v1/", ""},
+	} {
+		r := httptest.NewRequest(tc.method, tc.path, nil)
+		if got := RequiredScope(r); got != tc.want {
+			t.Errorf("%s %s needs %q, want %q", tc.method, tc.path, got, tc.want)
+		}
+	}
+}
+
+func TestScopeAllows(t *testing.T) {
+	for _, tc := range []struct {
+		granted []string
+		need    string
+		want    bool
+	}{
+		{[]string{"vhosts:read"}, "vhosts:read", true},
+		{[]string{"vhosts:read"}, "vhosts:write", false},
+		{[]string{"vhosts:write"}, "vhosts:read", true},
+		{[]string{"vhosts:write"}, "vhosts:write", true},
+		{[]string{"vhosts:*"}, "vhosts:write", true},
+		{[]string{"vhosts:*"}, "databases:read", false},
+		{[]string{"databases:write"}, "vhosts:read", false},
+		{[]string{"*"}, "dns:write", true},
+		{[]string{"dns:read", "cron:write"}, "cron:write", true},
+		{[]string{"wordpress:cache"}, "wordpress:cache", true},
+		{[]string{"wordpress:cache"}, "wordpress:write", false},
+		{[]string{"wordpress:cache"}, "wordpress:read", false},
+		{[]string{"wordpress:write"}, "wordpress:cache", true},
+		{[]string{"wordpress:read"}, "wordpress:cache", false},
+		{nil, "vhosts:read", false},
+
+		// Endpoints without a scope are closed to every token.
+		{[]string{"*"}, "", false},
+		{[]string{"vhosts:*"}, "", false},
+	} {
+		if got := ScopeAllows(tc.granted, tc.need); got != tc.want {
+			t.Errorf("ScopeAllows(%q, %q) = %v, want %v", tc.granted, tc.need, got, tc.want)
+		}
+	}
+}
+
+func TestValidScope(t *testing.T) {
+	for scope, want := range map[string]bool{
+		"*":               true,
+		"vhosts:read":     true,
+		"vhosts:write":    true,
+		"dns:*":           true,
+		"wordpress:cache": true,
+		"vhosts:cache":    false,
+		"vhosts":          false,
+		"auth:read":       false,
+		"panel-users:*":   false,
+		"unknown:read":    false,
+		"":                false,
+	} {
+		if got := ValidScope(scope); got != want {
+			t.Errorf("ValidScope(%q) = %v, want %v", scope, got, want)
+		}
+	}
+}
+
+func TestAPITokenClaimsScopes(t *testing.T) {
+	prev := APITokenVerifier
+	t.Cleanup(func() { APITokenVerifier = prev })
+	APITokenVerifier = func(r *http.Request, token string) (jwt.MapClaims, error) {
+		return jwt.MapClaims{"sub": "ci", "scopes": []string{"vhosts:write"}}, nil
+	}
+
+	for _, tc := range []struct {
+		method, path string
+		status       int
+	}{
+		{"GET", "/api/v1/vhosts", 0},
+		{"POST", "/api/vhosts", 0},
+		{"GET", "/api/databases", http.StatusForbidden},
+		{"GET", "/api/auth/sessions", http.StatusForbidden},
+		{"GET", "/api/v1/panel-users", http.StatusForbidden},
+	} {
+		_, err := apiTokenClaims(httptest.NewRequest(tc.method, tc.path, nil), "blg_x")
+		status := 0
+		if err != nil {
+			status = err.Status
+		}
+		if status != tc.status {
+			t.Errorf("%s %s: status %d (%v), want %d", tc.method, tc.path, status, err, tc.status)
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"blogron/util"
+
+	"github.com/go-chi/chi/v5"
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+)
+
+// AuditFile is the append-only JSON Lines audit trail inside util.DataDir.
+const AuditFile = "audit.jsonl"
+
+const (
+	auditBodyLimit  = 64 << 10 // request bodies larger than this are not parsed
+	auditValueLimit = 256      // longer string parameters are truncated
+)
+
+// AuditEntry is one line of the audit log.
+type AuditEntry struct {
+	Time       time.Time        `json:"time"`
+	RequestID  string           `json:"request_id"`
+	Actor      string           `json:"actor"`
+	Role       string           `json:"role"`
+	TokenID    string           `json:"token_id,omitempty"`
+	IP         string           `json:"ip"`
+	Method     string           `json:"method"`
+	Path       string           `json:"path"`
+	Route      string           `json:"route"`
+	Resource   string           `json:"resource"`
+	Params     map[string]any   `json:"params,omitempty"`
+	Status     int              `json:"status"`
+	Outcome    string           `json:"outcome"` // "success" or "failure"
+	Error      string           `json:"error,omitempty"`
+	ErrorCode  string           `json:"error_code,omitempty"`
+	DurationMS int64            `json:"duration_ms"`
+	Commands   []util.CmdRecord `json:"commands,omitempty"`
+}
+
+var auditMu sync.Mutex
+
+// Audit writes an AuditEntry for every mutating request (anything but
+// GET/HEAD/OPTIONS), including the commands it ran through
+// util.RunCmdContext. Must be mounted after JWTAuth so the actor is known.
+func Audit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		started := time.Now()
+		params := auditParams(r)
+		ctx, cmds := util.WithCmdLog(r.Context())
+		ww := chimiddleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		var errBody bytes.Buffer
+		ww.Tee(&limitedBuffer{buf: &errBody, max: 1024})
+
+		next.ServeHTTP(ww, r.WithContext(ctx))
+
+		claims := Claims(r)
+		tokenID, _ := claims["tid"].(string)
+		entry := AuditEntry{
+			Time:       started.UTC(),
+			RequestID:  chimiddleware.GetReqID(r.Context()),
+			Actor:      Subject(r),
+			Role:       Role(r),
+			TokenID:    tokenID,
+			IP:         remoteIP(r),
+			Method:     r.Method,
+			Path:       r.URL.Path,
+			Resource:   AuditResource(r.URL.Path),
+			Params:     params,
+			Status:     ww.Status(),
+			Outcome:    "success",
+			DurationMS: time.Since(started).Milliseconds(),
+			Commands:   cmds.Records(),
+		}
+		if entry.Status == 0 {
+			entry.Status = http.StatusOK
+		}
+		if rctx := chi.RouteContext(r.Context()); rctx != nil {
+			entry.Route = rctx.RoutePattern()
+			for i, k := range rctx.URLParams.Keys {
+				if k != "*" && i < len(rctx.URLParams.Values) {
+					if entry.Params == nil {
+						entry.Params = map[string]any{}
+					}
+					entry.Params[k] = rctx.URLParams.Values[i]
+				}
+			}
+		}
+		if entry.Status >= 400 {
+			entry.Outcome = "failure"
+			var body struct {
+				Error string `json:"error"`
+				Code  string `json:"code"`
+			}
+			if json.Unmarshal(errBody.Bytes(), &body) == nil {
+				entry.Error, entry.ErrorCode = body.Error, body.Code
+			}
+		}
+		writeAuditEntry(entry)
+	})
+}
+
+// AuditResource returns the resource an API path belongs to — the first
+// segment after /api/, e.g. "vhosts" for /api/vhosts/example.com/ssl.
+func AuditResource(path string) string {
+	res, _, _ := strings.Cut(APIPath(path), "/")
+	return res
+}
+
+// APIPath strips the /api/ or /api/v1/ prefix from path, so /api/vhosts and
+// /api/v1/vhosts both become "vhosts".
+func APIPath(path string) string {
+	path = strings.TrimPrefix(path, "/api/")
+	if rest, ok := strings.CutPrefix(path, "v1/"); ok {
+		return rest
+	}
+	return path
+}
+
+// ── helpers ───────────────────────────────────────────────────────────────────
+
+func writeAuditEntry(entry AuditEntry) {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		log.Printf("audit: cannot encode entry: %v", err)
+		return
+	}
+
+	auditMu.Lock()
+	defer auditMu.Unlock()
+	dir := util.DataDir()
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		log.Printf("audit: %v", err)
+		return
+	}
+	f, err := os.OpenFile(filepath.Join(dir, AuditFile), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		log.Printf("audit: %v", err)
+		return
+	}
+	defer f.Close()
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		log.Printf("audit: %v", err)
+	}
+}
+
+// auditParams returns the request's query and JSON body parameters with
+// secrets redacted. The body is restored for the handler.
+func auditParams(r *http.Request) map[string]any {
+	params := map[string]any{}
+	for k, v := range r.URL.Query() {
+		params[k] = redactParam(k, strings.Join(v, ","))
+	}
+
+	ct := r.Header.Get("Content-Type")
+	if r.Body != nil && (ct == "" || strings.HasPrefix(ct, "application/json")) {
+		data, err := io.ReadAll(io.LimitReader(r.Body, auditBodyLimit+1))
+		r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(data), r.Body))
+		if err == nil && len(data) <= auditBodyLimit {
+			var body map[string]any
+			if json.Unmarshal(data, &body) == nil {
+				for k, v := range body {
+					params[k] = redactParam(k, v)
+				}
+			}
+		}
+	}
+	if len(params) == 0 {
+		return nil
+	}
+	return params
+}
+
+func redactParam(key string, v any) any {
+	k := strings.ToLower(key)
+	for _, secret := range []string{"pass", "secret", "token", "code"} {
+		if strings.Contains(k, secret) {
+			return "***"
+		}
+	}
+	if s, ok := v.(string); ok && len(s) > auditValueLimit {
+		return s[:auditValueLimit] + "…"
+	}
+	return v
+}
+
+func remoteIP(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+// limitedBuffer keeps the first max bytes written to it and drops the rest.
+type limitedBuffer struct {
+	buf *bytes.Buffer
+	max int
+}
+
+func (l *limitedBuffer) Write(p []byte) (int, error) {
+	if room := l.max - l.buf.Len(); room > 0 {
+		if len(p) > room {
+			l.buf.Write(p[:room])
+		} else {
+			l.buf.Write(p)
+		}
+	}
+	return len(p), nil
+}
@@ -14,17 +14,31 @@ type contextKey string
 
 const UserContextKey contextKey = "user"
 
-// JWTAuth validates Bearer tokens on every protected route.
+var errInvalidToken = util.NewError(http.StatusUnauthorized, util.CodeInvalidToken, "invalid or expired token")
+
+// JWTAuth validates Bearer tokens on every protected route. Both panel JWTs
+// and API tokens (see apitokens.go) are accepted.
 func JWTAuth(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		authHeader := r.Header.Get("Authorization")
 		if authHeader == "" || !strings.HasPrefix(authHeader, "Bearer ") {
-			util.WriteError(w, http.StatusUnauthorized, "missing or invalid authorization header")
+			util.WriteErr(w, util.NewError(http.StatusUnauthorized, util.CodeUnauthorized, "missing or invalid authorization header"))
 			return
 		}
 
 		tokenStr := strings.TrimPrefix(authHeader, "Bearer ")
 
+		if strings.HasPrefix(tokenStr, APITokenPrefix) {
+			claims, err := apiTokenClaims(r, tokenStr)
+			if err != nil {
+				util.WriteErr(w, err)
+				return
+			}
+			ctx := context.WithValue(r.Context(), UserContextKey, &claims)
+			next.ServeHTTP(w, r.WithContext(ctx))
+			return
+		}
+
 		claims := &jwt.MapClaims{}
 		token, err := jwt.ParseWithClaims(tokenStr, claims, func(t *jwt.Token) (interface{}, error) {
 			if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
@@ -34,7 +48,18 @@ func JWTAuth(next http.Handler) http.Handler {
 		})
 
 		if err != nil || !token.Valid {
-			util.WriteError(w, http.StatusUnauthorized, "invalid or expired token")
+			util.WriteErr(w, errInvalidToken)
+			return
+		}
+
+		// Pre-auth tokens from a pending two-factor login are not access tokens
+		if typ, _ := (*claims)["typ"].(string); typ != "" && typ != "access" {
+			util.WriteErr(w, errInvalidToken)
+			return
+		}
+
+		if jti, _ := (*claims)["jti"].(string); IsRevoked(jti) {
+			util.WriteErr(w, util.NewError(http.StatusUnauthorized, util.CodeTokenRevoked, "token has been revoked"))
 			return
 		}
 
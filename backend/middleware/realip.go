@@ -0,0 +1,76 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// RealIP replaces r.RemoteAddr with the client's address as reported by a
+// reverse proxy, but only when the connection comes from one of trusted
+// (addresses or CIDR ranges, e.g. the local nginx). X-Forwarded-For is
+// read right to left, skipping trusted hops, so addresses a client adds
+// itself are never used; X-Real-IP is the fallback. Other headers such as
+// True-Client-IP are ignored. Requests from anywhere else keep the address
+// of the connection, whatever headers they carry.
+func RealIP(trusted []string) func(http.Handler) http.Handler {
+	prefixes := ParseTrustedProxies(trusted)
+	isTrusted := func(addr netip.Addr) bool {
+		for _, p := range prefixes {
+			if p.Contains(addr.Unmap()) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			host, port, err := net.SplitHostPort(r.RemoteAddr)
+			peer, perr := netip.ParseAddr(host)
+			if err != nil || perr != nil || !isTrusted(peer) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			client := ""
+			hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+			for i := len(hops) - 1; i >= 0; i-- {
+				addr, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+				if err != nil {
+					break // garbage: stop rather than trust what precedes it
+				}
+				client = addr.Unmap().String()
+				if !isTrusted(addr) {
+					break
+				}
+			}
+			if client == "" {
+				if addr, err := netip.ParseAddr(strings.TrimSpace(r.Header.Get("X-Real-IP"))); err == nil {
+					client = addr.Unmap().String()
+				}
+			}
+			if client != "" {
+				r.RemoteAddr = net.JoinHostPort(client, port)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ParseTrustedProxies parses addresses ("127.0.0.1") and CIDR ranges
+// ("10.0.0.0/8"), skipping anything else.
+func ParseTrustedProxies(list []string) []netip.Prefix {
+	var prefixes []netip.Prefix
+	for _, s := range list {
+		s = strings.TrimSpace(s)
+		if p, err := netip.ParsePrefix(s); err == nil {
+			prefixes = append(prefixes, p.Masked())
+		} else if a, err := netip.ParseAddr(s); err == nil {
+			a = a.Unmap()
+			prefixes = append(prefixes, netip.PrefixFrom(a, a.BitLen()))
+		}
+	}
+	return prefixes
+}
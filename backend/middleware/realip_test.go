@@ -0,0 +1,138 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRealIP(t *testing.T) {
+	handler := RealIP([]string{"127.0.0.1", "10.0.0.0/8", "::1"})
+
+	for _, tc := range []struct {
+		name     string
+		peer     string
+		xff      []string
+		realIP   string
+		wantAddr string
+	}{
+		{
+			name:     "no proxy headers",
+			peer:     "127.0.0.1:5000",
+			wantAddr: "127.0.0.1:5000",
+		},
+		{
+			name:     "untrusted peer keeps its own address",
+			peer:     "203.0.113.9:5000",
+			xff:      []string{"1.2.3.4"},
+			realIP:   "5.6.7.8",
+			wantAddr: "203.0.113.9:5000",
+		},
+		{
+			name:     "single hop from the local proxy",
+			peer:     "127.0.0.1:5000",
+			xff:      []string{"1.2.3.4"},
+			wantAddr: "1.2.3.4:5000",
+		},
+		{
+			name:     "trusted hops skipped right to left",
+			peer:     "127.0.0.1:5000",
+			xff:      []string{"1.2.3.4, 10.0.0.5, 10.1.2.3"},
+			wantAddr: "1.2.3.4:5000",
+		},
+		{
+			name:     "entries added by the client are ignored",
+			peer:     "127.0.0.1:5000",
+			xff:      []string{"6.6.6.6, 1.2.3.4"},
+			wantAddr: "1.2.3.4:5000",
+		},
+		{
+			name:     "repeated headers read as one list",
+			peer:     "127.0.0.1:5000",
+			xff:      []string{"6.6.6.6", "1.2.3.4, 10.0.0.5"},
+			wantAddr: "1.2.3.4:5000",
+		},
+		{
+			name:     "garbage stops the walk",
+			peer:     "127.0.0.1:5000",
+			xff:      []string{"1.2.3.4, bogus, 10.0.0.5"},
+			wantAddr: "10.0.0.5:5000",
+		},
+		{
+			name:     "all hops trusted uses the leftmost",
+			peer:     "127.0.0.1:5000",
+			xff:      []string{"10.0.0.7, 10.0.0.5"},
+			wantAddr: "10.0.0.7:5000",
+		},
+		{
+			name:     "X-Real-IP fallback",
+			peer:     "127.0.0.1:5000",
+			realIP:   "1.2.3.4",
+			wantAddr: "1.2.3.4:5000",
+		},
+		{
+			name:     "X-Real-IP fallback when X-Forwarded-For is garbage",
+			peer:     "127.0.0.1:5000",
+			xff:      []string{"bogus"},
+			realIP:   "1.2.3.4",
+			wantAddr: "1.2.3.4:5000",
+		},
+		{
+			name:     "X-Forwarded-For wins over X-Real-IP",
+			peer:     "127.0.0.1:5000",
+			xff:      []string{"1.2.3.4"},
+			realIP:   "5.6.7.8",
+			wantAddr: "1.2.3.4:5000",
+		},
+		{
+			name:     "invalid X-Real-IP ignored",
+			peer:     "127.0.0.1:5000",
+			realIP:   "bogus",
+			wantAddr: "127.0.0.1:5000",
+		},
+		{
+			name:     "IPv6 client",
+			peer:     "[::1]:5000",
+			xff:      []string{"2001:db8::1"},
+			wantAddr: "[2001:db8::1]:5000",
+		},
+		{
+			name:     "IPv4-mapped addresses unmapped",
+			peer:     "[::ffff:127.0.0.1]:5000",
+			xff:      []string{"::ffff:1.2.3.4"},
+			wantAddr: "1.2.3.4:5000",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/api/vhosts", nil)
+			r.RemoteAddr = tc.peer
+			for _, v := range tc.xff {
+				r.Header.Add("X-Forwarded-For", v)
+			}
+			if tc.realIP != "" {
+				r.Header.Set("X-Real-IP", tc.realIP)
+			}
+
+			var got string
+			handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = r.RemoteAddr
+			})).ServeHTTP(httptest.NewRecorder(), r)
+			if got != tc.wantAddr {
+				t.Errorf("RemoteAddr = %q, want %q", got, tc.wantAddr)
+			}
+		})
+	}
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	got := ParseTrustedProxies([]string{" 127.0.0.1 ", "10.1.2.3/8", "::ffff:192.168.0.1", "::1", "bogus", ""})
+	want := []string{"127.0.0.1/32", "10.0.0.0/8", "192.168.0.1/32", "::1/128"}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i].String() != want[i] {
+			t.Errorf("prefix %d = %s, want %s", i, got[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,21 @@
+package middleware
+
+import (
+	"net/http"
+
+	"blogron/util"
+
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+)
+
+// RequestIDHeader returns the request ID chi's RequestID assigned in the
+// X-Request-Id response header, so errors can be matched with the server
+// log and the audit log. Must come after chimiddleware.RequestID.
+func RequestIDHeader(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if id := chimiddleware.GetReqID(r.Context()); id != "" {
+			w.Header().Set(util.RequestIDHeader, id)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"blogron/util"
+)
+
+// Revoked access tokens are remembered by "jti" until they would have
+// expired anyway, in <data dir>/revoked-tokens.json.
+const revocationFile = "revoked-tokens.json"
+
+var revoked struct {
+	sync.Mutex
+	loaded bool
+	jtis   map[string]int64 // jti -> exp (unix)
+}
+
+// RevokeToken adds jti to the revocation list until expires.
+func RevokeToken(jti string, expires time.Time) error {
+	if jti == "" {
+		return nil
+	}
+	revoked.Lock()
+	defer revoked.Unlock()
+	loadRevokedLocked()
+
+	now := time.Now().Unix()
+	for id, exp := range revoked.jtis {
+		if exp < now {
+			delete(revoked.jtis, id)
+		}
+	}
+	revoked.jtis[jti] = expires.Unix()
+	return util.WriteState(revocationFile, revoked.jtis)
+}
+
+// IsRevoked reports whether the token with this jti has been revoked.
+func IsRevoked(jti string) bool {
+	if jti == "" {
+		return false
+	}
+	revoked.Lock()
+	defer revoked.Unlock()
+	loadRevokedLocked()
+	_, ok := revoked.jtis[jti]
+	return ok
+}
+
+func loadRevokedLocked() {
+	if revoked.loaded {
+		return
+	}
+	revoked.jtis = map[string]int64{}
+	if err := util.ReadState(revocationFile, &revoked.jtis); err != nil {
+		log.Printf("warning: cannot read token revocation list: %v", err)
+	}
+	revoked.loaded = true
+}
@@ -0,0 +1,80 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"blogron/util"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Panel account roles, carried in the "role" JWT claim.
+const (
+	RoleAdmin     = "admin"      // everything, including panel account management
+	RoleOperator  = "operator"   // full server management, no panel accounts
+	RoleReadOnly  = "read-only"  // may view everything, change nothing
+	RoleSiteOwner = "site-owner" // customer access to their own sites only
+)
+
+// ValidRole reports whether role is one of the known panel roles.
+func ValidRole(role string) bool {
+	switch role {
+	case RoleAdmin, RoleOperator, RoleReadOnly, RoleSiteOwner:
+		return true
+	}
+	return false
+}
+
+// Claims returns the JWT claims JWTAuth stored in the request context,
+// or an empty map on unauthenticated routes.
+func Claims(r *http.Request) jwt.MapClaims {
+	if c, ok := r.Context().Value(UserContextKey).(*jwt.MapClaims); ok && c != nil {
+		return *c
+	}
+	return jwt.MapClaims{}
+}
+
+// Subject returns the authenticated panel username ("sub" claim).
+func Subject(r *http.Request) string {
+	sub, _ := Claims(r)["sub"].(string)
+	return sub
+}
+
+// Role returns the authenticated account's role ("role" claim).
+func Role(r *http.Request) string {
+	role, _ := Claims(r)["role"].(string)
+	return role
+}
+
+// RequireRole only lets requests through whose role is one of roles.
+// Must be mounted after JWTAuth.
+func RequireRole(roles ...string) func(http.Handler) http.Handler {
+	allowed := map[string]bool{}
+	for _, role := range roles {
+		allowed[role] = true
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !allowed[Role(r)] {
+				util.WriteErr(w, util.NewError(http.StatusForbidden, util.CodeRoleForbidden, "insufficient role for this action"))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ReadOnlyGuard rejects every non-GET request made by a read-only account,
+// except self-service calls under /api/auth/ that only touch the caller's
+// own account.
+func ReadOnlyGuard(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if Role(r) == RoleReadOnly && r.Method != http.MethodGet && r.Method != http.MethodHead &&
+			!strings.HasPrefix(APIPath(r.URL.Path), "auth/") {
+			util.WriteErr(w, util.NewError(http.StatusForbidden, util.CodeReadOnly, "read-only accounts cannot make changes"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
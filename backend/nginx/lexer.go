@@ -0,0 +1,136 @@
+package nginx
+
+import "strings"
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokWord
+	tokSemicolon
+	tokOpen  // {
+	tokClose // }
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	line int
+}
+
+// lexer splits configuration text into tokens. Quoted strings are words
+// with the quotes removed and \" \' \\ \n \t \r unescaped; a # starts a
+// comment only at the start of a token; "${name}" stays part of its word.
+type lexer struct {
+	name string
+	data []byte
+	pos  int
+	line int
+}
+
+func newLexer(name string, data []byte) *lexer {
+	return &lexer{name: name, data: data, line: 1}
+}
+
+func (l *lexer) next() (token, error) {
+	// Skip spaces and comments.
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		switch {
+		case c == '\n':
+			l.line++
+			l.pos++
+		case c == ' ' || c == '\t' || c == '\r':
+			l.pos++
+		case c == '#':
+			for l.pos < len(l.data) && l.data[l.pos] != '\n' {
+				l.pos++
+			}
+		default:
+			goto token
+		}
+	}
+	return token{kind: tokEOF, line: l.line}, nil
+
+token:
+	line := l.line
+	switch c := l.data[l.pos]; c {
+	case ';':
+		l.pos++
+		return token{kind: tokSemicolon, text: ";", line: line}, nil
+	case '{':
+		l.pos++
+		return token{kind: tokOpen, text: "{", line: line}, nil
+	case '}':
+		l.pos++
+		return token{kind: tokClose, text: "}", line: line}, nil
+	case '"', '\'':
+		return l.quoted(c)
+	}
+
+	var b strings.Builder
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		switch c {
+		case ' ', '\t', '\r', '\n', ';', '{', '}':
+			return token{kind: tokWord, text: b.String(), line: line}, nil
+		case '$':
+			// ${name} may sit inside a word: "${scheme}://$host".
+			if l.pos+1 < len(l.data) && l.data[l.pos+1] == '{' {
+				end := strings.IndexByte(string(l.data[l.pos:]), '}')
+				if end < 0 {
+					return token{}, &SyntaxError{File: l.name, Line: line, Msg: "unterminated \"${\""}
+				}
+				b.Write(l.data[l.pos : l.pos+end+1])
+				l.pos += end + 1
+				continue
+			}
+		case '\\':
+			if l.pos+1 < len(l.data) {
+				b.WriteByte(c)
+				l.pos++
+				c = l.data[l.pos]
+			}
+		}
+		b.WriteByte(c)
+		l.pos++
+	}
+	return token{kind: tokWord, text: b.String(), line: line}, nil
+}
+
+// quoted reads a string in quote q; l.pos is at the opening quote.
+func (l *lexer) quoted(q byte) (token, error) {
+	line := l.line
+	l.pos++
+	var b strings.Builder
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		l.pos++
+		switch c {
+		case q:
+			return token{kind: tokWord, text: b.String(), line: line}, nil
+		case '\n':
+			l.line++
+		case '\\':
+			if l.pos < len(l.data) {
+				e := l.data[l.pos]
+				l.pos++
+				switch e {
+				case '"', '\'', '\\':
+					c = e
+				case 'n':
+					c = '\n'
+				case 't':
+					c = '\t'
+				case 'r':
+					c = '\r'
+				default:
+					b.WriteByte('\\')
+					c = e
+				}
+			}
+		}
+		b.WriteByte(c)
+	}
+	return token{}, &SyntaxError{File: l.name, Line: line, Msg: "unterminated quoted string"}
+}
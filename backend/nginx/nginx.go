@@ -0,0 +1,229 @@
+// Package nginx reads nginx configuration files into a tree of directives.
+//
+//	conf, err := nginx.ParseFile("/etc/nginx/sites-available/example.com.conf", "/etc/nginx")
+//	for _, srv := range nginx.Find(conf, "server") {
+//		names := nginx.Args(srv.Block, "server_name")
+//	}
+//
+// It follows the syntax of nginx's own reader: words separated by spaces,
+// "quoted strings", # comments, directives ending in ";" and blocks in
+// "{ }". It does not know which directives exist or what they mean.
+package nginx
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Directive is one directive, e.g. "listen 443 ssl;" or a "server { ... }"
+// block. Block is nil for simple directives.
+type Directive struct {
+	Name    string
+	Args    []string
+	Block   []*Directive
+	File    string // file it was read from
+	Line    int
+	EndLine int // line of the ";", or of the closing "}" of a block
+}
+
+// IsBlock reports whether d has a { } block.
+func (d *Directive) IsBlock() bool { return d.Block != nil }
+
+// Arg returns the i-th argument, or "".
+func (d *Directive) Arg(i int) string {
+	if i < len(d.Args) {
+		return d.Args[i]
+	}
+	return ""
+}
+
+// SyntaxError is a malformed configuration.
+type SyntaxError struct {
+	File string
+	Line int
+	Msg  string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%s:%d: %s", e.File, e.Line, e.Msg)
+}
+
+// maxIncludeDepth bounds nested includes, which also stops include cycles.
+const maxIncludeDepth = 10
+
+// Parse parses configuration text; name is used in errors. Includes are
+// left as include directives.
+func Parse(name string, data []byte) ([]*Directive, error) {
+	p := &parser{lex: newLexer(name, data)}
+	return p.block(false)
+}
+
+// ParseFile reads path and replaces include directives with the directives
+// of the files they name. Relative include paths are resolved against
+// prefix (nginx's configuration directory, e.g. /etc/nginx). Wildcard
+// includes expand in name order; included files that are missing or
+// unreadable (e.g. /etc/letsencrypt for an unprivileged reader) are
+// skipped, while syntax errors in them are returned.
+func ParseFile(path, prefix string) ([]*Directive, error) {
+	return parseFile(path, prefix, 0)
+}
+
+func parseFile(path, prefix string, depth int) ([]*Directive, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	list, err := Parse(path, data)
+	if err != nil {
+		return nil, err
+	}
+	return expandIncludes(list, prefix, depth)
+}
+
+func expandIncludes(list []*Directive, prefix string, depth int) ([]*Directive, error) {
+	out := make([]*Directive, 0, len(list))
+	for _, d := range list {
+		if d.IsBlock() {
+			block, err := expandIncludes(d.Block, prefix, depth)
+			if err != nil {
+				return nil, err
+			}
+			d.Block = block
+		}
+		if d.Name != "include" || d.IsBlock() || len(d.Args) != 1 {
+			out = append(out, d)
+			continue
+		}
+		if depth >= maxIncludeDepth {
+			return nil, &SyntaxError{File: d.File, Line: d.Line, Msg: "includes nested too deeply"}
+		}
+		pattern := d.Args[0]
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(prefix, pattern)
+		}
+		files, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, &SyntaxError{File: d.File, Line: d.Line, Msg: "invalid include pattern: " + d.Args[0]}
+		}
+		sort.Strings(files)
+		for _, f := range files {
+			included, err := parseFile(f, prefix, depth+1)
+			if errors.Is(err, fs.ErrNotExist) || errors.Is(err, fs.ErrPermission) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, included...)
+		}
+	}
+	return out, nil
+}
+
+// Find returns the directives called name in list (not in nested blocks).
+func Find(list []*Directive, name string) []*Directive {
+	var found []*Directive
+	for _, d := range list {
+		if d.Name == name {
+			found = append(found, d)
+		}
+	}
+	return found
+}
+
+// Args returns the arguments of the first directive called name in list,
+// or nil.
+func Args(list []*Directive, name string) []string {
+	for _, d := range list {
+		if d.Name == name {
+			return d.Args
+		}
+	}
+	return nil
+}
+
+// Walk calls fn for each directive in list and, depth first, in the blocks
+// below it. fn returning false skips the directive's block.
+func Walk(list []*Directive, fn func(d *Directive) bool) {
+	for _, d := range list {
+		if fn(d) && d.IsBlock() {
+			Walk(d.Block, fn)
+		}
+	}
+}
+
+// ── parser ──────────────────────────────────────────────────────────────────
+
+type parser struct {
+	lex   *lexer
+	close int // line of the last "}" read
+}
+
+// block reads directives up to the end of the input or, in a block, the
+// closing "}".
+func (p *parser) block(inBlock bool) ([]*Directive, error) {
+	list := []*Directive{}
+	var d *Directive
+	for {
+		tok, err := p.lex.next()
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case tok.kind == tokEOF:
+			if d != nil {
+				return nil, p.errorf(d.Line, "unexpected end of file, expecting \";\" or \"}\"")
+			}
+			if inBlock {
+				return nil, p.errorf(tok.line, "unexpected end of file, expecting \"}\"")
+			}
+			return list, nil
+
+		case tok.kind == tokWord:
+			if d == nil {
+				d = &Directive{Name: tok.text, File: p.lex.name, Line: tok.line}
+			} else {
+				d.Args = append(d.Args, tok.text)
+			}
+
+		case tok.kind == tokSemicolon:
+			if d == nil {
+				return nil, p.errorf(tok.line, "unexpected \";\"")
+			}
+			d.EndLine = tok.line
+			list = append(list, d)
+			d = nil
+
+		case tok.kind == tokOpen:
+			if d == nil {
+				return nil, p.errorf(tok.line, "unexpected \"{\"")
+			}
+			block, err := p.block(true)
+			if err != nil {
+				return nil, err
+			}
+			d.Block = block
+			d.EndLine = p.close
+			list = append(list, d)
+			d = nil
+
+		case tok.kind == tokClose:
+			if d != nil {
+				return nil, p.errorf(d.Line, "directive %q is not terminated by \";\"", d.Name)
+			}
+			if !inBlock {
+				return nil, p.errorf(tok.line, "unexpected \"}\"")
+			}
+			p.close = tok.line
+			return list, nil
+		}
+	}
+}
+
+func (p *parser) errorf(line int, format string, args ...any) error {
+	return &SyntaxError{File: p.lex.name, Line: line, Msg: fmt.Sprintf(format, args...)}
+}
@@ -0,0 +1,194 @@
+package nginx
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// dump writes directives back out compactly, one argument per [...] so
+// that spaces inside arguments show.
+func dump(list []*Directive) string {
+	var b strings.Builder
+	for _, d := range list {
+		b.WriteString(d.Name)
+		for _, a := range d.Args {
+			b.WriteString(" [" + a + "]")
+		}
+		if d.IsBlock() {
+			b.WriteString(" {" + dump(d.Block) + "}")
+		} else {
+			b.WriteString(";")
+		}
+	}
+	return b.String()
+}
+
+func TestParse(t *testing.T) {
+	for _, tc := range []struct {
+		name, conf, want string
+	}{
+		{"simple", "listen 80;\nserver_name example.com www.example.com;", "listen [80];server_name [example.com] [www.example.com];"},
+		{"single quotes", `a 'b c' 'd"e';`, `a [b c] [d"e];`},
+		{"double quotes", `a "b c" "d'e";`, `a [b c] [d'e];`},
+		{"escaped quotes", `a "b\"c" 'd\'e' "f\\g";`, `a [b"c] [d'e] [f\g];`},
+		{"control escapes", `a "b\tc\nd";`, "a [b\tc\nd];"},
+		{"unknown escape kept", `a "b\.c";`, `a [b\.c];`},
+		{"backslash in a word", `rewrite ^/a\ b /c;`, `rewrite [^/a\ b] [/c];`},
+		{"quoted braces and semicolons", `rewrite "^/(a{2}|b})$" "/x;y";`, `rewrite [^/(a{2}|b})$] [/x;y];`},
+		{"comment", "# intro\na b; # trailing ; { }\nc;", "a [b];c;"},
+		{"# inside a word", "a b#c d;", "a [b#c] [d];"},
+		{"# inside quotes", `a "#b";`, "a [#b];"},
+		{"${var} in a word", "return 301 ${scheme}://$host$request_uri;", "return [301] [${scheme}://$host$request_uri];"},
+		{"${var} with braces around", "a {set $x ${y}z;}", "a {set [$x] [${y}z];}"},
+		{"nested blocks", "http { server { location / { root /x; } location ~ \\.php$ { } } }",
+			`http {server {location [/] {root [/x];}location [~] [\.php$] {}}}`},
+		{"empty", "", ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			list, err := Parse("test.conf", []byte(tc.conf))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := dump(list); got != tc.want {
+				t.Errorf("got  %s\nwant %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name, conf string
+		line       int
+		msg        string
+	}{
+		{"missing ; at end", "a;\nb c", 2, `unexpected end of file, expecting ";" or "}"`},
+		{"missing ; before }", "server {\n    listen 80\n}", 2, `directive "listen" is not terminated by ";"`},
+		{"unclosed block", "server {\n    listen 80;\n", 3, `unexpected end of file, expecting "}"`},
+		{"stray }", "a;\n}\n", 2, `unexpected "}"`},
+		{"stray ;", "a;\n;", 2, `unexpected ";"`},
+		{"{ without a name", "a;\n\n{ b; }", 3, `unexpected "{"`},
+		{"unterminated quote", "a;\nb \"c;\n", 2, "unterminated quoted string"},
+		{"unterminated ${", "a;\nb ${c;", 2, `unterminated "${"`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Parse("test.conf", []byte(tc.conf))
+			var se *SyntaxError
+			if !errors.As(err, &se) {
+				t.Fatalf("error = %v, want a *SyntaxError", err)
+			}
+			if se.File != "test.conf" || se.Line != tc.line || se.Msg != tc.msg {
+				t.Errorf("error = %v, want test.conf:%d: %s", err, tc.line, tc.msg)
+			}
+		})
+	}
+}
+
+func TestParseLines(t *testing.T) {
+	conf := `server {
+    listen 80;
+    return 301 https://$host$request_uri;
+}
+
+server {
+    listen 443 ssl;
+    location / {
+        root /var/www;
+    }
+}
+`
+	list, err := Parse("test.conf", []byte(conf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	servers := Find(list, "server")
+	if len(servers) != 2 {
+		t.Fatalf("found %d server blocks, want 2", len(servers))
+	}
+	for i, want := range [][2]int{{1, 4}, {6, 11}} {
+		if s := servers[i]; s.Line != want[0] || s.EndLine != want[1] {
+			t.Errorf("server %d spans lines %d-%d, want %d-%d", i, s.Line, s.EndLine, want[0], want[1])
+		}
+	}
+	if got := Args(servers[1].Block, "listen"); len(got) != 2 || got[1] != "ssl" {
+		t.Errorf("listen of the second server = %q", got)
+	}
+	loc := Find(servers[1].Block, "location")[0]
+	if loc.Line != 8 || loc.EndLine != 10 || Find(loc.Block, "root")[0].Line != 9 {
+		t.Errorf("location spans %d-%d", loc.Line, loc.EndLine)
+	}
+
+	var names []string
+	Walk(list, func(d *Directive) bool {
+		names = append(names, d.Name)
+		return d.Name != "location"
+	})
+	if got := strings.Join(names, " "); got != "server listen return server listen location" {
+		t.Errorf("Walk visited %s", got)
+	}
+
+	// A simple directive ends at its ";", which may be lines further down.
+	list, err = Parse("test.conf", []byte("server_name example.com\n    www.example.com;\nroot /srv;\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := list[0]; d.Line != 1 || d.EndLine != 2 {
+		t.Errorf("server_name spans %d-%d, want 1-2", d.Line, d.EndLine)
+	}
+	if d := list[1]; d.Line != 3 || d.EndLine != 3 {
+		t.Errorf("root spans %d-%d, want 3-3", d.Line, d.EndLine)
+	}
+}
+
+func TestParseFileIncludes(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, data string) {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("sites/site.conf", "server {\n    include snippets/*.conf;\n    include /nonexistent/*.conf;\n    include "+filepath.Join(dir, "abs.conf")+";\n}\n")
+	write("snippets/b.conf", "b;\n")
+	write("snippets/a.conf", "a { include nested.conf; }\n")
+	write("nested.conf", "n;\n")
+	write("abs.conf", "abs;\n")
+
+	list, err := ParseFile(filepath.Join(dir, "sites/site.conf"), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Relative includes resolve against the prefix, globs expand in name
+	// order, patterns matching nothing are dropped.
+	if got, want := dump(list), "server {a {n;}b;abs;}"; got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+	if d := list[0].Block[1]; d.Name != "b" || d.File != filepath.Join(dir, "snippets/b.conf") || d.Line != 1 {
+		t.Errorf("included directive = %+v", d)
+	}
+
+	write("bad.conf", "ok;\nbroken\n")
+	write("includes-bad.conf", "include bad.conf;\n")
+	_, err = ParseFile(filepath.Join(dir, "includes-bad.conf"), dir)
+	var se *SyntaxError
+	if !errors.As(err, &se) || se.File != filepath.Join(dir, "bad.conf") || se.Line != 2 {
+		t.Errorf("error in an included file = %v", err)
+	}
+
+	write("loop.conf", "x;\ninclude loop.conf;\n")
+	_, err = ParseFile(filepath.Join(dir, "loop.conf"), dir)
+	if !errors.As(err, &se) || se.Msg != "includes nested too deeply" || se.Line != 2 {
+		t.Errorf("self-include error = %v", err)
+	}
+
+	if _, err := ParseFile(filepath.Join(dir, "missing.conf"), dir); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("missing file error = %v", err)
+	}
+}
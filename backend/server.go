@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"blogron/api"
+	"blogron/config"
+	"blogron/middleware"
+	"blogron/util"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// listenFDsStart is the first file descriptor systemd passes sockets on.
+const listenFDsStart = 3
+
+// serve runs the API on cfg.Port: plain HTTP, or HTTPS with the configured
+// certificate (reloaded on change and on SIGHUP) or one from Let's Encrypt.
+// With tls.http_port set, a second listener redirects HTTP to HTTPS and
+// answers ACME HTTP-01 challenges.
+//
+// Under systemd socket activation (blogron.socket) the listeners are
+// inherited instead, so connections queue in the socket while the panel
+// restarts. On SIGTERM or SIGINT the panel stops accepting connections and
+// waits up to shutdown_timeout for in-flight requests and running jobs.
+func serve(cfg *config.Config, handler http.Handler) error {
+	srv := &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	servers := []*http.Server{srv}
+
+	inherited, err := activationListeners()
+	if err != nil {
+		return err
+	}
+	apiLn, err := listener(inherited, 0, cfg.Port)
+	if err != nil {
+		return err
+	}
+
+	serveAPI := func() error { return srv.Serve(apiLn) }
+	if cfg.TLS.Enabled() {
+		redirect := middleware.RedirectHTTPS(cfg.Port)
+		tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+		if cfg.TLS.ACME {
+			m := &autocert.Manager{
+				Prompt:     autocert.AcceptTOS,
+				HostPolicy: autocert.HostWhitelist(cfg.PanelDomain),
+				Cache:      autocert.DirCache(filepath.Join(util.DataDir(), "acme")),
+				Email:      cfg.TLS.ACMEEmail,
+			}
+			tlsConfig = m.TLSConfig() // also answers TLS-ALPN-01 challenges
+			tlsConfig.MinVersion = tls.VersionTLS12
+			redirect = m.HTTPHandler(redirect)
+			log.Printf("TLS: certificate for %s from Let's Encrypt", cfg.PanelDomain)
+		} else {
+			certs, err := util.NewCertReloader(cfg.TLS.CertFile, cfg.TLS.KeyFile)
+			if err != nil {
+				return err
+			}
+			tlsConfig.GetCertificate = certs.GetCertificate
+			reloadOnSIGHUP(certs)
+			log.Printf("TLS: certificate %s", cfg.TLS.CertFile)
+		}
+		srv.TLSConfig = tlsConfig
+		serveAPI = func() error { return srv.ServeTLS(apiLn, "", "") }
+
+		if cfg.TLS.HTTPPort != "" {
+			plainLn, err := listener(inherited, 1, cfg.TLS.HTTPPort)
+			if err != nil {
+				return err
+			}
+			plain := &http.Server{Handler: redirect, ReadHeaderTimeout: 10 * time.Second}
+			servers = append(servers, plain)
+			go func() {
+				log.Printf("redirecting HTTP on %s to HTTPS", plainLn.Addr())
+				if err := plain.Serve(plainLn); err != nil && !errors.Is(err, http.ErrServerClosed) {
+					log.Fatalf("HTTP redirect listener: %v", err)
+				}
+			}()
+		}
+	}
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGTERM, syscall.SIGINT)
+	errc := make(chan error, 1)
+	go func() { errc <- serveAPI() }()
+	log.Printf("BLOGRON Panel API listening on %s", apiLn.Addr())
+
+	select {
+	case err := <-errc:
+		return err
+	case sig := <-stop:
+		log.Printf("%v: shutting down, waiting up to %ds for requests and jobs", sig, cfg.ShutdownTimeout)
+	}
+	signal.Stop(stop)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.ShutdownTimeout)*time.Second)
+	defer cancel()
+	var wg sync.WaitGroup
+	for _, s := range servers {
+		wg.Add(1)
+		go func(s *http.Server) {
+			defer wg.Done()
+			if err := s.Shutdown(ctx); err != nil {
+				log.Printf("warning: requests still running at shutdown: %v", err)
+				s.Close()
+			}
+		}(s)
+	}
+	if n := api.DrainJobs(ctx); n > 0 {
+		log.Printf("cancelled %d running job(s) at shutdown", n)
+	}
+	wg.Wait()
+	log.Printf("shutdown complete")
+	return nil
+}
+
+// activationListeners returns the sockets systemd passed in (LISTEN_FDS),
+// in the order of the ListenStream lines of blogron.socket.
+func activationListeners() ([]net.Listener, error) {
+	if os.Getenv("LISTEN_PID") != strconv.Itoa(os.Getpid()) {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || n < 1 {
+		return nil, nil
+	}
+	// Not for child processes
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+
+	listeners := make([]net.Listener, 0, n)
+	for fd := listenFDsStart; fd < listenFDsStart+n; fd++ {
+		f := os.NewFile(uintptr(fd), fmt.Sprintf("LISTEN_FD_%d", fd))
+		ln, err := net.FileListener(f) // dups fd; the original is closed below
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("socket activation: fd %d: %w", fd, err)
+		}
+		listeners = append(listeners, ln)
+	}
+	return listeners, nil
+}
+
+// listener returns the i-th inherited socket, or listens on port itself.
+func listener(inherited []net.Listener, i int, port string) (net.Listener, error) {
+	if i < len(inherited) {
+		return inherited[i], nil
+	}
+	return net.Listen("tcp", ":"+port)
+}
+
+// reloadOnSIGHUP re-reads the certificate whenever the process gets SIGHUP,
+// e.g. from a certbot deploy hook.
+func reloadOnSIGHUP(certs *util.CertReloader) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			if err := certs.Reload(); err != nil {
+				log.Printf("warning: keeping the current TLS certificate: %v", err)
+				continue
+			}
+			log.Printf("reloaded TLS certificate on SIGHUP")
+		}
+	}()
+}
@@ -0,0 +1,7 @@
+//go:build !sqlite
+
+package store
+
+// Without the "sqlite" build tag there is no database/sql driver and Open
+// falls back to the JSON file store.
+const sqliteDriver = ""
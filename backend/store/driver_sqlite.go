@@ -0,0 +1,8 @@
+//go:build sqlite
+
+package store
+
+// The pure-Go driver (no cgo), pinned in go.mod. Build with -tags sqlite.
+import _ "modernc.org/sqlite"
+
+const sqliteDriver = "sqlite"
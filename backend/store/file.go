@@ -0,0 +1,135 @@
+package store
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"blogron/util"
+)
+
+const resourcesFile = "resources.json"
+
+// fileVersion is the layout version of resources.json.
+const fileVersion = 1
+
+// fileStore is the JSON fallback, written through util.WriteState.
+type fileStore struct {
+	mu        sync.Mutex
+	loaded    bool
+	resources map[string]Resource // kind + "\x00" + name
+}
+
+type fileData struct {
+	Version   int        `json:"version"`
+	Resources []Resource `json:"resources"`
+}
+
+// OpenFile returns the JSON file store.
+func OpenFile() Store {
+	return &fileStore{}
+}
+
+func (s *fileStore) Get(kind, name string) (Resource, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.loadLocked(); err != nil {
+		return Resource{}, false, err
+	}
+	r, ok := s.resources[key(kind, name)]
+	return copyResource(r), ok, nil
+}
+
+func (s *fileStore) Update(kind, name string, fn func(r *Resource)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.loadLocked(); err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	r, ok := s.resources[key(kind, name)]
+	if !ok {
+		r = Resource{CreatedAt: now}
+	}
+	r = copyResource(r)
+	fn(&r)
+	r.Kind, r.Name, r.UpdatedAt = kind, name, now
+	s.resources[key(kind, name)] = r
+	return s.saveLocked()
+}
+
+func (s *fileStore) Delete(kind, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.loadLocked(); err != nil {
+		return err
+	}
+	if _, ok := s.resources[key(kind, name)]; !ok {
+		return nil
+	}
+	delete(s.resources, key(kind, name))
+	return s.saveLocked()
+}
+
+func (s *fileStore) List(f Filter) ([]Resource, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.loadLocked(); err != nil {
+		return nil, err
+	}
+	list := []Resource{}
+	for _, r := range s.resources {
+		if f.match(r) {
+			list = append(list, copyResource(r))
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Kind != list[j].Kind {
+			return list[i].Kind < list[j].Kind
+		}
+		return list[i].Name < list[j].Name
+	})
+	return list, nil
+}
+
+func (s *fileStore) Close() error { return nil }
+
+func (s *fileStore) loadLocked() error {
+	if s.loaded {
+		return nil
+	}
+	var data fileData
+	if err := util.ReadState(resourcesFile, &data); err != nil {
+		return err
+	}
+	s.resources = make(map[string]Resource, len(data.Resources))
+	for _, r := range data.Resources {
+		s.resources[key(r.Kind, r.Name)] = r
+	}
+	s.loaded = true
+	return nil
+}
+
+func (s *fileStore) saveLocked() error {
+	data := fileData{Version: fileVersion, Resources: make([]Resource, 0, len(s.resources))}
+	for _, r := range s.resources {
+		data.Resources = append(data.Resources, r)
+	}
+	sort.Slice(data.Resources, func(i, j int) bool {
+		return key(data.Resources[i].Kind, data.Resources[i].Name) < key(data.Resources[j].Kind, data.Resources[j].Name)
+	})
+	return util.WriteState(resourcesFile, data)
+}
+
+func key(kind, name string) string { return kind + "\x00" + name }
+
+func copyResource(r Resource) Resource {
+	if r.Meta != nil {
+		meta := make(map[string]string, len(r.Meta))
+		for k, v := range r.Meta {
+			meta[k] = v
+		}
+		r.Meta = meta
+	}
+	return r
+}
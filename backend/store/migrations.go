@@ -0,0 +1,81 @@
+package store
+
+import (
+	"database/sql"
+	"time"
+)
+
+// migration is one schema change. Migrations are applied in order, each in
+// its own transaction, and recorded in schema_migrations; never edit one
+// that has shipped — append a new one instead.
+type migration struct {
+	version    int
+	name       string
+	statements []string
+}
+
+var migrations = []migration{
+	{1, "resources", []string{
+		`CREATE TABLE resources (
+			kind        TEXT NOT NULL,
+			name        TEXT NOT NULL,
+			owner       TEXT NOT NULL DEFAULT '',
+			php_version TEXT NOT NULL DEFAULT '',
+			notes       TEXT NOT NULL DEFAULT '',
+			created_at  TEXT NOT NULL,
+			updated_at  TEXT NOT NULL,
+			PRIMARY KEY (kind, name)
+		)`,
+		`CREATE INDEX resources_owner ON resources (owner)`,
+		`CREATE TABLE resource_meta (
+			kind  TEXT NOT NULL,
+			name  TEXT NOT NULL,
+			key   TEXT NOT NULL,
+			value TEXT NOT NULL,
+			PRIMARY KEY (kind, name, key),
+			FOREIGN KEY (kind, name) REFERENCES resources (kind, name) ON DELETE CASCADE
+		)`,
+	}},
+}
+
+// migrate applies every migration newer than the database's version.
+func migrate(db *sql.DB) error {
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		name       TEXT NOT NULL,
+		applied_at TEXT NOT NULL
+	)`); err != nil {
+		return err
+	}
+	var current int
+	if err := db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current); err != nil {
+		return err
+	}
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+		if err := applyMigration(db, m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func applyMigration(db *sql.DB, m migration) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, stmt := range m.statements {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(`INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)`,
+		m.version, m.name, formatTime(time.Now())); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
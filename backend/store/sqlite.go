@@ -0,0 +1,197 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"blogron/util"
+)
+
+const sqliteFile = "panel.db"
+
+// sqlStore keeps resources in SQLite. The panel is the only writer, so a
+// single connection serialises access.
+type sqlStore struct {
+	db *sql.DB
+}
+
+func sqlitePath() string {
+	return filepath.Join(util.DataDir(), sqliteFile)
+}
+
+// OpenSQLite opens (creating if needed) the database at path and brings its
+// schema up to date.
+func OpenSQLite(path string) (Store, error) {
+	if sqliteDriver == "" {
+		return nil, ErrNoSQLite
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	dsn := "file:" + path + "?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=foreign_keys(1)"
+	db, err := sql.Open(sqliteDriver, dsn)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1)
+	if err := migrate(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("store: migrating %s: %w", path, err)
+	}
+	os.Chmod(path, 0600)
+	return &sqlStore{db: db}, nil
+}
+
+func (s *sqlStore) Get(kind, name string) (Resource, bool, error) {
+	r, err := getResource(s.db, kind, name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Resource{}, false, nil
+	}
+	return r, err == nil, err
+}
+
+func (s *sqlStore) Update(kind, name string, fn func(r *Resource)) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	r, err := getResource(tx, kind, name)
+	if errors.Is(err, sql.ErrNoRows) {
+		r, err = Resource{CreatedAt: now}, nil
+	}
+	if err != nil {
+		return err
+	}
+	fn(&r)
+	r.Kind, r.Name, r.UpdatedAt = kind, name, now
+
+	_, err = tx.Exec(`INSERT INTO resources (kind, name, owner, php_version, notes, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (kind, name) DO UPDATE SET
+			owner = excluded.owner, php_version = excluded.php_version,
+			notes = excluded.notes, updated_at = excluded.updated_at`,
+		r.Kind, r.Name, r.Owner, r.PHPVersion, r.Notes, formatTime(r.CreatedAt), formatTime(r.UpdatedAt))
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM resource_meta WHERE kind = ? AND name = ?`, kind, name); err != nil {
+		return err
+	}
+	for k, v := range r.Meta {
+		if _, err := tx.Exec(`INSERT INTO resource_meta (kind, name, key, value) VALUES (?, ?, ?, ?)`, kind, name, k, v); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *sqlStore) Delete(kind, name string) error {
+	// resource_meta rows go with it (ON DELETE CASCADE).
+	_, err := s.db.Exec(`DELETE FROM resources WHERE kind = ? AND name = ?`, kind, name)
+	return err
+}
+
+func (s *sqlStore) List(f Filter) ([]Resource, error) {
+	rows, err := s.db.Query(`SELECT kind, name, owner, php_version, notes, created_at, updated_at
+		FROM resources
+		WHERE (? = '' OR kind = ?) AND (? = '' OR owner = ?)
+		ORDER BY kind, name`, f.Kind, f.Kind, f.Owner, f.Owner)
+	if err != nil {
+		return nil, err
+	}
+	list := []Resource{}
+	index := map[string]int{}
+	for rows.Next() {
+		r, err := scanResource(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		index[key(r.Kind, r.Name)] = len(list)
+		list = append(list, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	meta, err := s.db.Query(`SELECT kind, name, key, value FROM resource_meta
+		WHERE (? = '' OR kind = ?)`, f.Kind, f.Kind)
+	if err != nil {
+		return nil, err
+	}
+	defer meta.Close()
+	for meta.Next() {
+		var kind, name, k, v string
+		if err := meta.Scan(&kind, &name, &k, &v); err != nil {
+			return nil, err
+		}
+		if i, ok := index[key(kind, name)]; ok {
+			if list[i].Meta == nil {
+				list[i].Meta = map[string]string{}
+			}
+			list[i].Meta[k] = v
+		}
+	}
+	return list, meta.Err()
+}
+
+func (s *sqlStore) Close() error { return s.db.Close() }
+
+// ── helpers ───────────────────────────────────────────────────────────────────
+
+// querier is what getResource needs from *sql.DB and *sql.Tx.
+type querier interface {
+	QueryRow(query string, args ...any) *sql.Row
+	Query(query string, args ...any) (*sql.Rows, error)
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func getResource(q querier, kind, name string) (Resource, error) {
+	r, err := scanResource(q.QueryRow(`SELECT kind, name, owner, php_version, notes, created_at, updated_at
+		FROM resources WHERE kind = ? AND name = ?`, kind, name))
+	if err != nil {
+		return Resource{}, err
+	}
+	rows, err := q.Query(`SELECT key, value FROM resource_meta WHERE kind = ? AND name = ?`, kind, name)
+	if err != nil {
+		return Resource{}, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var k, v string
+		if err := rows.Scan(&k, &v); err != nil {
+			return Resource{}, err
+		}
+		if r.Meta == nil {
+			r.Meta = map[string]string{}
+		}
+		r.Meta[k] = v
+	}
+	return r, rows.Err()
+}
+
+func scanResource(row scanner) (Resource, error) {
+	var r Resource
+	var created, updated string
+	if err := row.Scan(&r.Kind, &r.Name, &r.Owner, &r.PHPVersion, &r.Notes, &created, &updated); err != nil {
+		return Resource{}, err
+	}
+	r.CreatedAt, _ = time.Parse(time.RFC3339Nano, created)
+	r.UpdatedAt, _ = time.Parse(time.RFC3339Nano, updated)
+	return r, nil
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
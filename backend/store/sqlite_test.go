@@ -0,0 +1,66 @@
+//go:build sqlite
+
+package store
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+)
+
+func TestSQLiteStore(t *testing.T) {
+	s, err := OpenSQLite(filepath.Join(t.TempDir(), sqliteFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	testStore(t, s)
+}
+
+func TestSQLiteStorePersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), sqliteFile)
+	s, err := OpenSQLite(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Update("vhost", "example.com", func(r *Resource) {
+		r.Owner = "jane"
+		r.Meta = map[string]string{"docroot": "/var/www/example.com"}
+	}); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	s, err = OpenSQLite(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	r, ok, err := s.Get("vhost", "example.com")
+	if err != nil || !ok || r.Owner != "jane" || r.Meta["docroot"] != "/var/www/example.com" {
+		t.Errorf("reopened store has %+v, %v, %v", r, ok, err)
+	}
+}
+
+func TestMigrateIsIdempotent(t *testing.T) {
+	db, err := sql.Open(sqliteDriver, "file:"+filepath.Join(t.TempDir(), sqliteFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	for i := 0; i < 3; i++ {
+		if err := migrate(db); err != nil {
+			t.Fatalf("migrate #%d: %v", i+1, err)
+		}
+	}
+	var n, version int
+	if err := db.QueryRow(`SELECT COUNT(*), MAX(version) FROM schema_migrations`).Scan(&n, &version); err != nil {
+		t.Fatal(err)
+	}
+	last := migrations[len(migrations)-1].version
+	if n != len(migrations) || version != last {
+		t.Errorf("schema_migrations has %d rows up to version %d, want %d up to %d", n, version, len(migrations), last)
+	}
+}
@@ -0,0 +1,64 @@
+// Package store keeps what the panel knows about server resources that the
+// operating system does not record itself: owners, creation dates, notes,
+// PHP versions and links between resources, such as the database that
+// belongs to a WordPress site.
+//
+// Panels built with the "sqlite" tag keep it in an SQLite database
+// (<data dir>/panel.db) with versioned migrations; other builds fall back to
+// a JSON file (<data dir>/resources.json) with the same behaviour.
+package store
+
+import (
+	"errors"
+	"time"
+)
+
+// Resource is the metadata kept for one resource, identified by kind (see
+// the kind constants in package api) and name.
+type Resource struct {
+	Kind       string            `json:"kind"`
+	Name       string            `json:"name"`
+	Owner      string            `json:"owner,omitempty"`
+	PHPVersion string            `json:"php_version,omitempty"`
+	Notes      string            `json:"notes,omitempty"`
+	Meta       map[string]string `json:"meta,omitempty"` // kind-specific, e.g. "db_name" of a WordPress site
+	CreatedAt  time.Time         `json:"created_at"`
+	UpdatedAt  time.Time         `json:"updated_at"`
+}
+
+// Filter selects resources in List; empty fields match everything.
+type Filter struct {
+	Kind  string
+	Owner string
+}
+
+// Store is the resource metadata store.
+type Store interface {
+	// Get returns the resource, or ok=false when nothing is recorded.
+	Get(kind, name string) (r Resource, ok bool, err error)
+	// Update applies fn to the resource atomically, creating the record
+	// (CreatedAt now) when there is none.
+	Update(kind, name string, fn func(r *Resource)) error
+	// Delete forgets the resource. Deleting an unknown resource is not an
+	// error.
+	Delete(kind, name string) error
+	// List returns matching resources ordered by kind and name.
+	List(f Filter) ([]Resource, error)
+	Close() error
+}
+
+// ErrNoSQLite is returned by OpenSQLite in builds without the "sqlite" tag.
+var ErrNoSQLite = errors.New("store: panel built without SQLite support (build with -tags sqlite)")
+
+// Open opens the store in util.DataDir: SQLite when compiled in, the JSON
+// file otherwise.
+func Open() (Store, error) {
+	if sqliteDriver != "" {
+		return OpenSQLite(sqlitePath())
+	}
+	return OpenFile(), nil
+}
+
+func (f Filter) match(r Resource) bool {
+	return (f.Kind == "" || r.Kind == f.Kind) && (f.Owner == "" || r.Owner == f.Owner)
+}
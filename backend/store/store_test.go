@@ -0,0 +1,159 @@
+package store
+
+import (
+	"testing"
+
+	"blogron/util"
+)
+
+// testStore runs the behaviour every Store must share against s.
+func testStore(t *testing.T, s Store) {
+	t.Helper()
+
+	if _, ok, err := s.Get("vhost", "example.com"); err != nil || ok {
+		t.Fatalf("Get of an unknown resource = %v, %v; want not found", ok, err)
+	}
+
+	// Update creates the record and stamps it.
+	err := s.Update("vhost", "example.com", func(r *Resource) {
+		r.Owner = "jane"
+		r.PHPVersion = "8.2"
+		r.Meta = map[string]string{"docroot": "/var/www/example.com", "profile": "php"}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, ok, err := s.Get("vhost", "example.com")
+	if err != nil || !ok {
+		t.Fatalf("Get after Update = %v, %v", ok, err)
+	}
+	if r.Kind != "vhost" || r.Name != "example.com" || r.Owner != "jane" || r.PHPVersion != "8.2" {
+		t.Errorf("stored resource = %+v", r)
+	}
+	if r.CreatedAt.IsZero() || r.UpdatedAt.IsZero() {
+		t.Errorf("timestamps not set: %+v", r)
+	}
+	created := r.CreatedAt
+
+	// A later Update sees the stored record and may drop meta keys; the
+	// creation time stays.
+	err = s.Update("vhost", "example.com", func(r *Resource) {
+		if r.Meta["profile"] != "php" {
+			t.Errorf("Update got meta %v", r.Meta)
+		}
+		delete(r.Meta, "profile")
+		r.Meta["upstream"] = "127.0.0.1:3000"
+		r.Notes = "moved"
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, _, _ = s.Get("vhost", "example.com")
+	if len(r.Meta) != 2 || r.Meta["docroot"] != "/var/www/example.com" || r.Meta["upstream"] != "127.0.0.1:3000" {
+		t.Errorf("meta = %v", r.Meta)
+	}
+	if r.Notes != "moved" || !r.CreatedAt.Equal(created) {
+		t.Errorf("resource after second Update = %+v (created %v)", r, created)
+	}
+
+	// Changing what Get returned does not change the store.
+	r.Meta["docroot"] = "/elsewhere"
+	if r, _, _ := s.Get("vhost", "example.com"); r.Meta["docroot"] != "/var/www/example.com" {
+		t.Errorf("store changed through a returned resource: %v", r.Meta)
+	}
+
+	for _, res := range []struct{ kind, name, owner string }{
+		{"vhost", "a.example.com", "bob"},
+		{"database", "shop", "jane"},
+		{"cron", "jane", ""},
+	} {
+		if err := s.Update(res.kind, res.name, func(r *Resource) { r.Owner = res.owner }); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, tc := range []struct {
+		filter Filter
+		want   []string
+	}{
+		{Filter{}, []string{"cron/jane", "database/shop", "vhost/a.example.com", "vhost/example.com"}},
+		{Filter{Kind: "vhost"}, []string{"vhost/a.example.com", "vhost/example.com"}},
+		{Filter{Owner: "jane"}, []string{"database/shop", "vhost/example.com"}},
+		{Filter{Kind: "vhost", Owner: "bob"}, []string{"vhost/a.example.com"}},
+		{Filter{Kind: "mail_domain"}, []string{}},
+	} {
+		list, err := s.List(tc.filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := []string{}
+		for _, r := range list {
+			got = append(got, r.Kind+"/"+r.Name)
+		}
+		if len(got) != len(tc.want) {
+			t.Errorf("List(%+v) = %v, want %v", tc.filter, got, tc.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tc.want[i] {
+				t.Errorf("List(%+v) = %v, want %v", tc.filter, got, tc.want)
+				break
+			}
+		}
+		for _, r := range list {
+			if r.Name == "example.com" && r.Meta["docroot"] == "" {
+				t.Errorf("List(%+v) left out the meta of %s", tc.filter, r.Name)
+			}
+		}
+	}
+
+	// Delete forgets the resource and its meta; unknown ones are fine.
+	if err := s.Delete("vhost", "example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete("vhost", "example.com"); err != nil {
+		t.Errorf("second Delete: %v", err)
+	}
+	if _, ok, _ := s.Get("vhost", "example.com"); ok {
+		t.Error("resource still there after Delete")
+	}
+	err = s.Update("vhost", "example.com", func(r *Resource) {
+		if len(r.Meta) != 0 || r.Owner != "" {
+			t.Errorf("recreated resource kept old data: %+v", r)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// useDataDir points util.DataDir at a fresh directory for the test.
+func useDataDir(t *testing.T) string {
+	t.Helper()
+	prev := util.DataDir()
+	t.Cleanup(func() { util.SetDataDir(prev) })
+	dir := t.TempDir()
+	util.SetDataDir(dir)
+	return dir
+}
+
+func TestFileStore(t *testing.T) {
+	useDataDir(t)
+	testStore(t, OpenFile())
+}
+
+func TestFileStorePersists(t *testing.T) {
+	useDataDir(t)
+	s := OpenFile()
+	if err := s.Update("vhost", "example.com", func(r *Resource) {
+		r.Owner = "jane"
+		r.Meta = map[string]string{"docroot": "/var/www/example.com"}
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	r, ok, err := OpenFile().Get("vhost", "example.com")
+	if err != nil || !ok || r.Owner != "jane" || r.Meta["docroot"] != "/var/www/example.com" {
+		t.Errorf("reopened store has %+v, %v, %v", r, ok, err)
+	}
+}
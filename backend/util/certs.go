@@ -0,0 +1,82 @@
+package util
+
+import (
+	"crypto/tls"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// ── TLS certificates ────────────────────────────────────────────────────────
+
+// certCheckInterval bounds how often handshakes look at the files on disk.
+const certCheckInterval = 10 * time.Second
+
+// CertReloader serves a certificate/key pair from disk and picks up renewed
+// files without a restart: handshakes check the modification times at most
+// every certCheckInterval, and Reload forces a re-read (e.g. on SIGHUP).
+// A pair that fails to load is logged and the previous one kept.
+type CertReloader struct {
+	certFile, keyFile string
+
+	mu        sync.Mutex
+	cert      *tls.Certificate
+	certMod   time.Time
+	keyMod    time.Time
+	checkedAt time.Time
+}
+
+// NewCertReloader loads the pair once so a broken configuration fails at
+// startup rather than on the first handshake.
+func NewCertReloader(certFile, keyFile string) (*CertReloader, error) {
+	c := &CertReloader{certFile: certFile, keyFile: keyFile}
+	if err := c.Reload(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Reload reads the certificate and key again.
+func (c *CertReloader) Reload() error {
+	certMod, keyMod := modTime(c.certFile), modTime(c.keyFile)
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checkedAt = time.Now()
+	if err != nil {
+		return err
+	}
+	c.cert, c.certMod, c.keyMod = &cert, certMod, keyMod
+	return nil
+}
+
+// GetCertificate is a tls.Config.GetCertificate callback.
+func (c *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.mu.Lock()
+	stale := time.Since(c.checkedAt) >= certCheckInterval
+	if stale {
+		c.checkedAt = time.Now()
+		stale = !modTime(c.certFile).Equal(c.certMod) || !modTime(c.keyFile).Equal(c.keyMod)
+	}
+	c.mu.Unlock()
+
+	if stale {
+		if err := c.Reload(); err != nil {
+			log.Printf("warning: keeping the current TLS certificate: %v", err)
+		} else {
+			log.Printf("reloaded TLS certificate %s", c.certFile)
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.cert, nil
+}
+
+func modTime(path string) time.Time {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
@@ -0,0 +1,100 @@
+package util
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ── Command log ─────────────────────────────────────────────────────────────
+
+// CmdRecord is one command run on behalf of a request, as stored in the
+// audit log. Secrets in the arguments are redacted.
+type CmdRecord struct {
+	Command    string   `json:"command"`
+	Args       []string `json:"args"`
+	DurationMS int64    `json:"duration_ms"`
+	Error      string   `json:"error,omitempty"`
+	Background bool     `json:"background,omitempty"`
+}
+
+// CmdLog collects the commands run through RunCmdContext with a context
+// returned by WithCmdLog.
+type CmdLog struct {
+	mu      sync.Mutex
+	records []CmdRecord
+}
+
+type cmdLogKey struct{}
+
+// WithCmdLog returns a context that records every RunCmdContext call made
+// with it (or a context derived from it) into the returned log.
+func WithCmdLog(ctx context.Context) (context.Context, *CmdLog) {
+	l := &CmdLog{}
+	return context.WithValue(ctx, cmdLogKey{}, l), l
+}
+
+// Records returns a copy of the commands recorded so far.
+func (l *CmdLog) Records() []CmdRecord {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]CmdRecord(nil), l.records...)
+}
+
+func (l *CmdLog) add(rec CmdRecord) {
+	l.mu.Lock()
+	l.records = append(l.records, rec)
+	l.mu.Unlock()
+}
+
+// NoteBackgroundCmd records a command that the handler starts in the
+// background and that will outlive the request, so it still shows up in the
+// request's audit entry.
+func NoteBackgroundCmd(ctx context.Context, name string, args ...string) {
+	if l, ok := ctx.Value(cmdLogKey{}).(*CmdLog); ok {
+		l.add(CmdRecord{Command: name, Args: RedactArgs(name, args), Background: true})
+	}
+}
+
+func recordCmd(ctx context.Context, name string, args []string, started time.Time, err error) {
+	l, ok := ctx.Value(cmdLogKey{}).(*CmdLog)
+	if !ok {
+		return
+	}
+	rec := CmdRecord{
+		Command:    name,
+		Args:       RedactArgs(name, args),
+		DurationMS: time.Since(started).Milliseconds(),
+	}
+	if err != nil {
+		rec.Error = err.Error()
+	}
+	l.add(rec)
+}
+
+var (
+	secretFlag    = regexp.MustCompile(`(?i)^(--?[a-z_-]*pass[a-z_-]*=).+`)
+	identifiedBy  = regexp.MustCompile(`(?i)(IDENTIFIED BY ')[^']*(')`)
+	chpasswdEntry = regexp.MustCompile(`^([^:]*):.+$`)
+)
+
+// RedactArgs masks passwords in command arguments: --*pass*=value flags,
+// mysql -p<password>, "user:password" pairs for chpasswd and IDENTIFIED BY
+// clauses in SQL.
+func RedactArgs(name string, args []string) []string {
+	out := make([]string, len(args))
+	for i, a := range args {
+		switch {
+		case (name == "mysql" || name == "mysqladmin") && strings.HasPrefix(a, "-p") && len(a) > 2:
+			a = "-p***"
+		case secretFlag.MatchString(a):
+			a = secretFlag.ReplaceAllString(a, "${1}***")
+		case name == "chpasswd":
+			a = chpasswdEntry.ReplaceAllString(a, "${1}:***")
+		}
+		out[i] = identifiedBy.ReplaceAllString(a, "${1}***${2}")
+	}
+	return out
+}
@@ -0,0 +1,255 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"net/http"
+	"strings"
+)
+
+// ── API errors ──────────────────────────────────────────────────────────────
+
+// Error codes are part of the API: clients switch on them, so existing codes
+// keep their meaning and HTTP status. Messages are for people and may change.
+const (
+	CodeBadRequest      = "BAD_REQUEST"
+	CodeInvalidBody     = "INVALID_BODY"
+	CodeValidation      = "VALIDATION_FAILED"
+	CodeUnauthorized    = "UNAUTHORIZED"
+	CodeForbidden       = "FORBIDDEN"
+	CodeNotFound        = "NOT_FOUND"
+	CodeConflict        = "CONFLICT"
+	CodePayloadTooLarge = "PAYLOAD_TOO_LARGE"
+	CodeRateLimited     = "RATE_LIMITED"
+	CodeInternal        = "INTERNAL"
+	CodeUnavailable     = "UNAVAILABLE"
+	CodeTimeout         = "TIMEOUT"
+	CodeCancelled       = "CANCELLED"
+
+	// Authentication
+	CodeInvalidCredentials = "INVALID_CREDENTIALS"
+	CodeInvalidToken       = "INVALID_TOKEN"
+	CodeTokenRevoked       = "TOKEN_REVOKED"
+	CodeTokenReused        = "REFRESH_TOKEN_REUSED"
+	CodeInvalidTOTPCode    = "INVALID_TOTP_CODE"
+	CodeTOTPState          = "TOTP_STATE_CONFLICT"
+	CodeAccountInactive    = "ACCOUNT_INACTIVE"
+	CodeRoleForbidden      = "ROLE_FORBIDDEN"
+	CodeReadOnly           = "READ_ONLY"
+	CodeScopeMissing       = "SCOPE_MISSING"
+	CodeNotOwner           = "NOT_OWNER"
+	CodeLoginThrottled     = "LOGIN_THROTTLED"
+
+	// System commands
+	CodeCommandFailed      = "COMMAND_FAILED"
+	CodeCommandTimeout     = "COMMAND_TIMEOUT"
+	CodeCommandNotAllowed  = "COMMAND_NOT_ALLOWED"
+	CodeInvalidArgument    = "INVALID_ARGUMENT"
+	CodePermissionDenied   = "PERMISSION_DENIED"
+	CodeNginxConfigInvalid = "NGINX_CONFIG_INVALID"
+
+	// Resources
+	CodeVhostExists       = "VHOST_EXISTS"
+	CodeVhostNotFound     = "VHOST_NOT_FOUND"
+	CodeSiteExists        = "SITE_EXISTS"
+	CodeSiteNotFound      = "SITE_NOT_FOUND"
+	CodeDatabaseExists    = "DATABASE_EXISTS"
+	CodeProtectedDatabase = "PROTECTED_DATABASE"
+	CodeMailDomainExists  = "MAIL_DOMAIN_EXISTS"
+	CodeMailboxExists     = "MAILBOX_EXISTS"
+	CodeSystemUserExists  = "SYSTEM_USER_EXISTS"
+	CodeProtectedUser     = "PROTECTED_USER"
+	CodeZoneNotFound      = "DNS_ZONE_NOT_FOUND"
+	CodeCronJobNotFound   = "CRON_JOB_NOT_FOUND"
+	CodeFileNotFound      = "FILE_NOT_FOUND"
+	CodePathForbidden     = "PATH_FORBIDDEN"
+	CodeServiceNotManaged = "SERVICE_NOT_MANAGED"
+	CodeUserNotManaged    = "USER_NOT_MANAGED"
+	CodeSessionNotFound   = "SESSION_NOT_FOUND"
+	CodeAPITokenNotFound  = "API_TOKEN_NOT_FOUND"
+	CodePanelUserExists   = "PANEL_USER_EXISTS"
+	CodePanelUserNotFound = "PANEL_USER_NOT_FOUND"
+	CodeLastAdmin         = "LAST_ADMIN"
+	CodeJobNotFound       = "JOB_NOT_FOUND"
+	CodeJobNotRunning     = "JOB_NOT_RUNNING"
+	CodeProfileNotFound   = "PROFILE_NOT_FOUND"
+	CodeProfileBuiltin    = "PROFILE_BUILTIN"
+	CodeTemplateInvalid   = "TEMPLATE_INVALID"
+)
+
+// statusCodes is the code WriteError uses for a bare status.
+var statusCodes = map[int]string{
+	http.StatusBadRequest:            CodeBadRequest,
+	http.StatusUnauthorized:          CodeUnauthorized,
+	http.StatusForbidden:             CodeForbidden,
+	http.StatusNotFound:              CodeNotFound,
+	http.StatusConflict:              CodeConflict,
+	http.StatusRequestEntityTooLarge: CodePayloadTooLarge,
+	http.StatusUnprocessableEntity:   CodeValidation,
+	http.StatusTooManyRequests:       CodeRateLimited,
+	http.StatusServiceUnavailable:    CodeUnavailable,
+	http.StatusGatewayTimeout:        CodeTimeout,
+}
+
+// Error is an error with everything needed to answer a request: the HTTP
+// status, a stable code, a message and optionally the offending fields.
+// Handlers either write one directly (WriteErr) or return it from lower
+// layers, wrapped or not; ErrorFrom finds or derives it.
+type Error struct {
+	Status  int
+	Code    string
+	Message string
+	Fields  map[string]string // field name → what is wrong with it
+	Details map[string]any    // extra members of the response body
+	Err     error             // the underlying cause, if any
+}
+
+func (e *Error) Error() string { return e.Message }
+func (e *Error) Unwrap() error { return e.Err }
+
+// NewError returns an Error without a cause.
+func NewError(status int, code, msg string) *Error {
+	return &Error{Status: status, Code: code, Message: msg}
+}
+
+// Invalid reports a request field that failed validation.
+func Invalid(field, msg string) *Error {
+	return InvalidFields(msg, map[string]string{field: msg})
+}
+
+// InvalidFields reports several invalid fields at once.
+func InvalidFields(msg string, fields map[string]string) *Error {
+	return &Error{Status: http.StatusBadRequest, Code: CodeValidation, Message: msg, Fields: fields}
+}
+
+// ErrInvalidBody is the answer to a request body that is not the expected
+// JSON.
+var ErrInvalidBody = NewError(http.StatusBadRequest, CodeInvalidBody, "invalid request body")
+
+// Wrap describes err from the caller's side ("failed to drop database"),
+// keeping the status and code err maps to.
+func Wrap(err error, msg string) *Error {
+	e := *ErrorFrom(err)
+	e.Message = msg + ": " + e.Message
+	e.Err = err
+	return &e
+}
+
+// ErrorFrom returns the *Error in err's chain, or maps err to one: command
+// failures and timeouts get COMMAND_* codes, permission errors
+// PERMISSION_DENIED, and anything else is INTERNAL.
+func ErrorFrom(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		if error(e) == err {
+			return e
+		}
+		wrapped := *e
+		wrapped.Message, wrapped.Err = err.Error(), err
+		return &wrapped
+	}
+	var ce *CmdError
+	switch {
+	case errors.As(err, &ce):
+		e := ce.apiError()
+		e.Message, e.Err = err.Error(), err
+		return e
+	case IsTimeout(err):
+		return &Error{Status: http.StatusGatewayTimeout, Code: CodeCommandTimeout, Message: err.Error(), Err: err}
+	case errors.Is(err, context.DeadlineExceeded):
+		return &Error{Status: http.StatusGatewayTimeout, Code: CodeTimeout, Message: err.Error(), Err: err}
+	case errors.Is(err, context.Canceled):
+		return &Error{Status: http.StatusServiceUnavailable, Code: CodeCancelled, Message: "request cancelled", Err: err}
+	case errors.Is(err, fs.ErrPermission):
+		return &Error{Status: http.StatusForbidden, Code: CodePermissionDenied, Message: err.Error(), Err: err}
+	}
+	return &Error{Status: http.StatusInternalServerError, Code: CodeInternal, Message: err.Error(), Err: err}
+}
+
+// RequestIDHeader carries the request ID on every response (see
+// middleware.RequestIDHeader), which is how error bodies pick it up.
+const RequestIDHeader = "X-Request-Id"
+
+// WriteErr answers with err as
+//
+//	{"error": "...", "code": "...", "fields": {...}, "request_id": "..."}
+//
+// plus err's Details. "error" is the same message clients have always read.
+func WriteErr(w http.ResponseWriter, err error) {
+	e := ErrorFrom(err)
+	body := map[string]any{}
+	for k, v := range e.Details {
+		body[k] = v
+	}
+	body["error"] = e.Message
+	body["code"] = e.Code
+	if len(e.Fields) > 0 {
+		body["fields"] = e.Fields
+	}
+	if id := w.Header().Get(RequestIDHeader); id != "" {
+		body["request_id"] = id
+	}
+	WriteJSON(w, e.Status, body)
+}
+
+// WriteError answers with msg and the generic code for status.
+func WriteError(w http.ResponseWriter, status int, msg string) {
+	code, ok := statusCodes[status]
+	if !ok {
+		code = CodeInternal
+	}
+	WriteErr(w, NewError(status, code, msg))
+}
+
+// ── command errors ──────────────────────────────────────────────────────────
+
+// CmdError is a command that ran and failed. Its message keeps the first
+// line of stderr; the rest goes to the response's "stderr" member.
+type CmdError struct {
+	Command  string
+	Args     []string
+	ExitCode int
+	Stderr   string
+}
+
+func (e *CmdError) Error() string {
+	msg := "command failed"
+	if line, _, _ := strings.Cut(e.Stderr, "\n"); line != "" {
+		msg += ": " + line
+	}
+	return msg
+}
+
+func (e *CmdError) apiError() *Error {
+	ae := &Error{
+		Status:  http.StatusInternalServerError,
+		Code:    CodeCommandFailed,
+		Message: e.Error(),
+		Details: map[string]any{"command": e.Command, "exit_code": e.ExitCode},
+		Err:     e,
+	}
+	if e.Stderr != "" {
+		ae.Details["stderr"] = e.Stderr
+	}
+	lower := strings.ToLower(e.Stderr)
+	switch {
+	case e.Command == "nginx" && containsArg(e.Args, "-t"):
+		ae.Status, ae.Code = http.StatusUnprocessableEntity, CodeNginxConfigInvalid
+	case strings.Contains(lower, "permission denied"),
+		strings.Contains(lower, "operation not permitted"),
+		strings.Contains(lower, "a password is required"),
+		strings.Contains(lower, "is not allowed to execute"):
+		ae.Status, ae.Code = http.StatusForbidden, CodePermissionDenied
+	}
+	return ae
+}
+
+func containsArg(args []string, arg string) bool {
+	for _, a := range args {
+		if a == arg {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,301 @@
+package util
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ── Command executors ───────────────────────────────────────────────────────
+
+// Command is one program invocation. Name and Args are checked against the
+// allowlist by RunCommand before an Executor sees them.
+type Command struct {
+	Name    string
+	Args    []string
+	Stdin   []byte        // fed to the process on stdin, if set
+	Env     []string      // extra KEY=value pairs for the process
+	Timeout time.Duration // 0 = bounded only by the context
+	Output  io.Writer     // if set, also receives stdout and stderr as they are produced
+}
+
+type outputKey struct{}
+
+// WithCmdOutput returns a context under which RunCommand streams the output
+// of every command that sets no Output of its own to w.
+func WithCmdOutput(ctx context.Context, w io.Writer) context.Context {
+	return context.WithValue(ctx, outputKey{}, w)
+}
+
+func cmdOutput(ctx context.Context) io.Writer {
+	w, _ := ctx.Value(outputKey{}).(io.Writer)
+	return w
+}
+
+// Result is what a command produced.
+type Result struct {
+	Stdout   string
+	Stderr   string
+	ExitCode int
+}
+
+// Executor runs commands. A non-nil error means the command could not be
+// started or exited non-zero; Result is filled in as far as possible.
+type Executor interface {
+	Run(ctx context.Context, cmd Command) (Result, error)
+}
+
+// DefaultExecutor is used by RunCmd and RunCmdContext.
+var DefaultExecutor Executor = SudoExecutor{}
+
+// Default timeouts applied by RunCommand when a Command sets none, keyed by
+// program (for "sudo -u <user> <prog>" the program after the sudo flags).
+var (
+	DefaultCmdTimeout = time.Minute
+	CmdTimeouts       = map[string]time.Duration{
+		"certbot":   5 * time.Minute,
+		"wp":        10 * time.Minute,
+		"mysql":     2 * time.Minute,
+		"tar":       10 * time.Minute,
+		"systemctl": 90 * time.Second,
+	}
+)
+
+// killGrace is how long a cancelled command gets to exit after SIGTERM
+// before it is killed.
+const killGrace = 5 * time.Second
+
+// TimeoutError is returned when a command is stopped because its timeout or
+// the caller's deadline passed. Handlers map it to 504 Gateway Timeout.
+type TimeoutError struct {
+	Command string
+	Timeout time.Duration // 0 when the caller's deadline, not the command's, expired
+}
+
+func (e *TimeoutError) Error() string {
+	if e.Timeout > 0 {
+		return e.Command + " timed out after " + e.Timeout.String()
+	}
+	return e.Command + " timed out"
+}
+
+func (e *TimeoutError) Unwrap() error { return context.DeadlineExceeded }
+
+// IsTimeout reports whether err is (or wraps) a *TimeoutError.
+func IsTimeout(err error) bool {
+	var te *TimeoutError
+	return errors.As(err, &te)
+}
+
+// CmdTimeout returns the default timeout for c.
+func CmdTimeout(c Command) time.Duration {
+	if d, ok := CmdTimeouts[programName(c)]; ok {
+		return d
+	}
+	return DefaultCmdTimeout
+}
+
+// programName returns the program c actually runs, looking through
+// "sudo -u <user>" and a leading path.
+func programName(c Command) string {
+	name, args := c.Name, c.Args
+	if name == "sudo" {
+		name = ""
+		for i := 0; i < len(args); i++ {
+			if args[i] == "-u" || args[i] == "-g" {
+				i++
+				continue
+			}
+			if !strings.HasPrefix(args[i], "-") {
+				name = args[i]
+				break
+			}
+		}
+	}
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	return name
+}
+
+// SudoExecutor runs commands through sudo, as allowed by blogron.sudoers.
+// Commands named "sudo" are run as given (e.g. "sudo -u www-data wp ...").
+//
+// Each command gets its own process group. On cancellation or timeout the
+// group is sent SIGTERM — sudo relays it to the root-owned command, which
+// the panel user cannot signal directly — and sudo is killed if it has not
+// exited after killGrace.
+type SudoExecutor struct{}
+
+func (SudoExecutor) Run(parent context.Context, c Command) (Result, error) {
+	ctx := parent
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(parent, c.Timeout)
+		defer cancel()
+	}
+
+	args := c.Args
+	if c.Name != "sudo" {
+		args = append([]string{c.Name}, c.Args...)
+	}
+	if len(c.Env) > 0 {
+		// sudo resets the environment; keep just the variables we set.
+		keys := make([]string, 0, len(c.Env))
+		for _, kv := range c.Env {
+			k, _, _ := strings.Cut(kv, "=")
+			keys = append(keys, k)
+		}
+		args = append([]string{"--preserve-env=" + strings.Join(keys, ",")}, args...)
+	}
+
+	cmd := exec.CommandContext(ctx, "sudo", args...)
+	setProcessGroup(cmd)
+	cmd.WaitDelay = killGrace
+	if len(c.Env) > 0 {
+		cmd.Env = append(os.Environ(), c.Env...)
+	}
+	if c.Stdin != nil {
+		cmd.Stdin = bytes.NewReader(c.Stdin)
+	}
+	var out, errBuf bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &errBuf
+	if c.Output != nil {
+		// stdout and stderr are copied by separate goroutines.
+		live := &syncWriter{w: c.Output}
+		cmd.Stdout = io.MultiWriter(&out, live)
+		cmd.Stderr = io.MultiWriter(&errBuf, live)
+	}
+
+	err := cmd.Run()
+	res := Result{Stdout: out.String(), Stderr: errBuf.String()}
+	if ctx.Err() == context.DeadlineExceeded {
+		te := &TimeoutError{Command: programName(c)}
+		if parent.Err() == nil {
+			te.Timeout = c.Timeout
+		}
+		res.ExitCode = -1
+		return res, te
+	}
+	if parent.Err() != nil {
+		res.ExitCode = -1
+		return res, parent.Err()
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		res.ExitCode = exitErr.ExitCode()
+	} else if err != nil {
+		res.ExitCode = -1
+		if res.Stderr == "" {
+			res.Stderr = err.Error()
+		}
+	}
+	return res, err
+}
+
+// DryRunExecutor executes nothing. It logs and remembers every command it is
+// given and reports success with empty output.
+type DryRunExecutor struct {
+	mu       sync.Mutex
+	commands []Command
+}
+
+func (d *DryRunExecutor) Run(ctx context.Context, c Command) (Result, error) {
+	log.Printf("dry-run: %s %s", c.Name, strings.Join(RedactArgs(c.Name, c.Args), " "))
+	d.mu.Lock()
+	d.commands = append(d.commands, c)
+	d.mu.Unlock()
+	return Result{}, nil
+}
+
+// Commands returns everything that would have been executed so far.
+func (d *DryRunExecutor) Commands() []Command {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]Command(nil), d.commands...)
+}
+
+// FakeExecutor answers commands from a script, for tests. A command matches
+// a scripted response when its "name arg1 arg2 ..." line starts with the
+// response's prefix; the longest matching prefix wins. Unmatched commands
+// get Default.
+type FakeExecutor struct {
+	mu         sync.Mutex
+	responses  map[string]fakeResponse
+	calls      []Command
+	Default    Result
+	DefaultErr error
+}
+
+type fakeResponse struct {
+	res Result
+	err error
+}
+
+// On scripts the result for commands whose line starts with prefix.
+func (f *FakeExecutor) On(prefix string, res Result, err error) *FakeExecutor {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.responses == nil {
+		f.responses = map[string]fakeResponse{}
+	}
+	f.responses[prefix] = fakeResponse{res: res, err: err}
+	return f
+}
+
+// OnOutput is shorthand for a successful command printing stdout.
+func (f *FakeExecutor) OnOutput(prefix, stdout string) *FakeExecutor {
+	return f.On(prefix, Result{Stdout: stdout}, nil)
+}
+
+func (f *FakeExecutor) Run(ctx context.Context, c Command) (Result, error) {
+	line := strings.Join(append([]string{c.Name}, c.Args...), " ")
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, c)
+	best, found := "", false
+	for prefix := range f.responses {
+		if strings.HasPrefix(line, prefix) && len(prefix) >= len(best) {
+			best, found = prefix, true
+		}
+	}
+	if !found {
+		if c.Output != nil {
+			io.WriteString(c.Output, f.Default.Stdout+f.Default.Stderr)
+		}
+		return f.Default, f.DefaultErr
+	}
+	r := f.responses[best]
+	if c.Output != nil {
+		io.WriteString(c.Output, r.res.Stdout+r.res.Stderr)
+	}
+	return r.res, r.err
+}
+
+// Calls returns every command the fake has been asked to run.
+func (f *FakeExecutor) Calls() []Command {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Command(nil), f.calls...)
+}
+
+// syncWriter serialises writes to w.
+type syncWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (s *syncWriter) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.w.Write(p)
+}
@@ -0,0 +1,5 @@
+//go:build !unix
+
+package util
+
+func FileOwner(path string) (string, bool) { return "", false }
@@ -0,0 +1,22 @@
+//go:build unix
+
+package util
+
+import (
+	"os"
+	"strconv"
+	"syscall"
+)
+
+// FileOwner returns the numeric "uid:gid" owning path, for chown.
+func FileOwner(path string) (string, bool) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return "", false
+	}
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return "", false
+	}
+	return strconv.FormatUint(uint64(st.Uid), 10) + ":" + strconv.FormatUint(uint64(st.Gid), 10), true
+}
@@ -0,0 +1,18 @@
+//go:build unix
+
+package util
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup starts cmd in its own process group and makes cancellation
+// signal the whole group instead of just the direct child.
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		// Negative pid = the process group led by the child.
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGTERM)
+	}
+}
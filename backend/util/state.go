@@ -0,0 +1,66 @@
+package util
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// ── Panel state files ───────────────────────────────────────────────────────
+
+var dataDir = "/var/lib/blogron"
+
+// SetDataDir moves the panel state directory (data_dir in panel.yaml).
+func SetDataDir(dir string) {
+	dataDir = dir
+}
+
+// DataDir returns the directory holding the panel's own persistent state
+// (accounts, sessions, ...).
+func DataDir() string {
+	return dataDir
+}
+
+// ReadState decodes the JSON state file name inside DataDir into v.
+// A missing file is not an error — v is left untouched.
+func ReadState(name string, v any) error {
+	data, err := os.ReadFile(filepath.Join(DataDir(), name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// WriteState atomically replaces the JSON state file name inside DataDir.
+// Files are written 0600 because they may contain password hashes.
+func WriteState(name string, v any) error {
+	dir := DataDir()
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, name+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(dir, name))
+}
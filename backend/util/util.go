@@ -1,13 +1,13 @@
 package util
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
-	"os"
-	"os/exec"
 	"strings"
+	"time"
 )
 
 // ── JSON helpers ────────────────────────────────────────────────────────────
@@ -18,19 +18,18 @@ func WriteJSON(w http.ResponseWriter, status int, v any) {
 	json.NewEncoder(w).Encode(v)
 }
 
-func WriteError(w http.ResponseWriter, status int, msg string) {
-	WriteJSON(w, status, map[string]string{"error": msg})
-}
-
 // ── JWT secret ──────────────────────────────────────────────────────────────
 
+var jwtSecret []byte
+
+// SetJWTSecret sets the key tokens are signed with; main calls it with the
+// validated auth.jwt_secret before serving.
+func SetJWTSecret(secret string) {
+	jwtSecret = []byte(secret)
+}
+
 func JWTSecret() []byte {
-	secret := os.Getenv("JWT_SECRET")
-	if secret == "" {
-		// Fallback for development — set a real secret in production!
-		secret = "change-me-in-production-use-env-var"
-	}
-	return []byte(secret)
+	return jwtSecret
 }
 
 // ── Safe command runner ──────────────────────────────────────────────────────
@@ -38,28 +37,40 @@ func JWTSecret() []byte {
 // RunCmd executes a whitelisted system command via sudo and returns combined output.
 // Only commands that appear in the allowlist are executed.
 func RunCmd(name string, args ...string) (string, error) {
-	if err := validateCommand(name, args); err != nil {
-		return "", err
-	}
+	return RunCmdContext(context.Background(), name, args...)
+}
 
-	// Prefix with sudo for privileged operations (skip if command is already sudo)
-	var cmd *exec.Cmd
-	if name == "sudo" {
-		cmd = exec.Command("sudo", args...)
-	} else {
-		cmdArgs := append([]string{name}, args...)
-		cmd = exec.Command("sudo", cmdArgs...)
-	}
+// RunCmdContext is RunCmd bound to ctx: the command is killed when ctx is
+// cancelled and recorded in ctx's command log (see WithCmdLog), if any.
+func RunCmdContext(ctx context.Context, name string, args ...string) (string, error) {
+	return RunCommand(ctx, DefaultExecutor, Command{Name: name, Args: args})
+}
 
-	var out bytes.Buffer
-	var errBuf bytes.Buffer
-	cmd.Stdout = &out
-	cmd.Stderr = &errBuf
+// RunCommand validates cmd against the allowlist, runs it with e and returns
+// its trimmed stdout. Commands without a Timeout get CmdTimeout, and those
+// without an Output stream to the context's (see WithCmdOutput). Timeouts
+// come back as *TimeoutError and cancellation as the context's error; other
+// failures as *CmdError.
+func RunCommand(ctx context.Context, e Executor, cmd Command) (out string, err error) {
+	defer func(started time.Time) { recordCmd(ctx, cmd.Name, cmd.Args, started, err) }(time.Now())
 
-	if err := cmd.Run(); err != nil {
-		return "", fmt.Errorf("command failed: %s", strings.TrimSpace(errBuf.String()))
+	if err := validateCommand(cmd.Name, cmd.Args); err != nil {
+		return "", err
+	}
+	if cmd.Timeout == 0 {
+		cmd.Timeout = CmdTimeout(cmd)
+	}
+	if cmd.Output == nil {
+		cmd.Output = cmdOutput(ctx)
+	}
+	res, err := e.Run(ctx, cmd)
+	if IsTimeout(err) || errors.Is(err, context.Canceled) {
+		return "", err
+	}
+	if err != nil {
+		return "", &CmdError{Command: programName(cmd), Args: cmd.Args, ExitCode: res.ExitCode, Stderr: strings.TrimSpace(res.Stderr)}
 	}
-	return strings.TrimSpace(out.String()), nil
+	return strings.TrimSpace(res.Stdout), nil
 }
 
 // allowedCommands restricts what RunCmd can execute.
@@ -99,13 +110,13 @@ var allowedCommands = map[string]bool{
 
 func validateCommand(name string, args []string) error {
 	if !allowedCommands[name] {
-		return fmt.Errorf("command %q is not allowed", name)
+		return NewError(http.StatusInternalServerError, CodeCommandNotAllowed, fmt.Sprintf("command %q is not allowed", name))
 	}
 	// Basic argument sanitization — reject shell metacharacters
 	for _, arg := range args {
 		for _, ch := range []string{";", "&", "|", "`", "$", "(", ")", "<", ">", "\n", "\r"} {
 			if strings.Contains(arg, ch) {
-				return fmt.Errorf("argument contains disallowed character: %q", arg)
+				return NewError(http.StatusBadRequest, CodeInvalidArgument, fmt.Sprintf("argument contains disallowed character: %q", arg))
 			}
 		}
 	}
@@ -3,16 +3,79 @@ import { useState, useEffect, useCallback } from "react";
 // ── Config ─────────────────────────────────────────────────────────────────
 const API_BASE = import.meta?.env?.VITE_API_URL || "http://localhost:8080";
 
-async function api(path, opts = {}) {
+function saveTokens(d) {
+  localStorage.setItem("sp_token", d.token);
+  if (d.refresh_token) localStorage.setItem("sp_refresh", d.refresh_token);
+}
+
+function clearTokens() {
+  localStorage.removeItem("sp_token");
+  localStorage.removeItem("sp_refresh");
+}
+
+// Access tokens are short-lived; swap the refresh token for a new pair.
+async function refreshTokens() {
+  const refresh = localStorage.getItem("sp_refresh");
+  if (!refresh) return false;
+  const r = await fetch(`${API_BASE}/api/auth/refresh`, { method:"POST", headers:{"Content-Type":"application/json"}, body: JSON.stringify({refresh_token:refresh}) });
+  if (!r.ok) return false;
+  saveTokens(await r.json());
+  return true;
+}
+
+async function api(path, opts = {}, retried = false) {
   const token = localStorage.getItem("sp_token");
   const res = await fetch(`${API_BASE}${path}`, {
     headers: { "Content-Type": "application/json", ...(token ? { Authorization: `Bearer ${token}` } : {}) },
     ...opts,
   });
-  if (res.status === 401) { localStorage.removeItem("sp_token"); window.location.reload(); }
+  if (res.status === 401) {
+    if (!retried && await refreshTokens()) return api(path, opts, true);
+    clearTokens(); window.location.reload();
+  }
   return res;
 }
 
+// Long operations answer 202 with a job. Follow its event stream (fetch
+// rather than EventSource, which cannot send the Authorization header),
+// passing each output line to onLine; fall back to polling.
+async function waitForJob(res, onLine = () => {}, interval = 2000) {
+  let job = await res.json();
+  const token = localStorage.getItem("sp_token");
+  try {
+    const s = await fetch(`${API_BASE}/api/jobs/${job.id}/stream`, { headers: { Authorization: `Bearer ${token}` } });
+    if (s.ok && s.body) {
+      const reader = s.body.getReader(), dec = new TextDecoder();
+      let buf = "";
+      for (;;) {
+        const { value, done } = await reader.read();
+        if (done) break;
+        buf += dec.decode(value, { stream:true });
+        let i;
+        while ((i = buf.indexOf("\n\n")) >= 0) {
+          const ev = buf.slice(0, i); buf = buf.slice(i + 2);
+          const type = (ev.match(/^event: (.*)$/m) || [])[1];
+          const data = (ev.match(/^data: (.*)$/m) || [])[1];
+          if (type === "output") onLine(data);
+          if (type === "done") return JSON.parse(data);
+        }
+      }
+    }
+  } catch { /* fall back to polling */ }
+  // Tolerate a few failed polls: the panel may be restarting
+  for (let misses = 0; job.status === "running"; ) {
+    await new Promise(r => setTimeout(r, interval));
+    const r = await api(`/api/jobs/${job.id}`).catch(() => null);
+    if (!r?.ok) {
+      if (++misses > 5 || r?.status === 404) return { status:"failed", error:"lost track of job" };
+      continue;
+    }
+    misses = 0;
+    job = await r.json();
+  }
+  return job;
+}
+
 // ── Nav ────────────────────────────────────────────────────────────────────
 const NAV = [
   { id: "dashboard",  label: "Dashboard",   icon: "⬡" },
@@ -122,13 +185,17 @@ function EmptyState({ icon, text }) {
 // ── Login ──────────────────────────────────────────────────────────────────
 function Login({ onLogin }) {
   const [u, setU] = useState(""); const [p, setP] = useState(""); const [err, setErr] = useState(""); const [loading, setLoading] = useState(false);
+  const [preAuth, setPreAuth] = useState(""); const [code, setCode] = useState("");
   const submit = async () => {
     setLoading(true); setErr("");
-    const r = await fetch(`${API_BASE}/api/auth/login`, { method:"POST", headers:{"Content-Type":"application/json"}, body: JSON.stringify({username:u, password:p}) });
+    const r = preAuth
+      ? await fetch(`${API_BASE}/api/auth/login/verify`, { method:"POST", headers:{"Content-Type":"application/json"}, body: JSON.stringify({pre_auth_token:preAuth, code}) })
+      : await fetch(`${API_BASE}/api/auth/login`, { method:"POST", headers:{"Content-Type":"application/json"}, body: JSON.stringify({username:u, password:p}) });
     const d = await r.json();
     setLoading(false);
-    if (d.token) { localStorage.setItem("sp_token", d.token); onLogin(); }
-    else setErr(d.error || "Login failed");
+    if (d.token) { saveTokens(d); onLogin(); }
+    else if (d.mfa_required) { setPreAuth(d.pre_auth_token); setCode(""); }
+    else { if (preAuth && r.status === 401) setPreAuth(""); setErr(d.error || "Login failed"); }
   };
   return (
     <div className="min-h-screen bg-zinc-950 flex items-center justify-center" style={{fontFamily:"'JetBrains Mono','Fira Code',monospace"}}>
@@ -139,8 +206,12 @@ function Login({ onLogin }) {
           <p className="text-zinc-600 text-xs mt-1">Sign in to manage your server</p>
         </div>
         <div className="bg-zinc-900 border border-zinc-800 rounded-xl p-6 space-y-4">
-          <Field label="Username" value={u} onChange={e=>setU(e.target.value)} placeholder="admin" />
-          <Field label="Password" type="password" value={p} onChange={e=>setP(e.target.value)} placeholder="••••••••" onKeyDown={e=>e.key==="Enter"&&submit()} />
+          {preAuth ? (
+            <Field label="Authenticator code" value={code} onChange={e=>setCode(e.target.value)} placeholder="123456 or recovery code" onKeyDown={e=>e.key==="Enter"&&submit()} />
+          ) : (<>
+            <Field label="Username" value={u} onChange={e=>setU(e.target.value)} placeholder="admin" />
+            <Field label="Password" type="password" value={p} onChange={e=>setP(e.target.value)} placeholder="••••••••" onKeyDown={e=>e.key==="Enter"&&submit()} />
+          </>)}
           {err && <p className="text-xs text-rose-400 font-mono">{err}</p>}
           <Btn onClick={submit} className="w-full justify-center">{loading ? "Signing in…" : "Sign In"}</Btn>
         </div>
@@ -258,12 +329,21 @@ function UsersPanel() {
 // ── Web Server ─────────────────────────────────────────────────────────────
 function WebServerPanel() {
   const [vhosts, setVhosts] = useState([]); const [modal, setModal] = useState(false);
-  const [form, setForm] = useState({domain:"",docroot:"",php:"8.2",ssl:false});
+  const [form, setForm] = useState({domain:"",docroot:"",php:"8.2",profile:"php",upstreams:"",balance:"round_robin",health_path:"",aliases:"",canonical:"none"});
+  const [profiles, setProfiles] = useState([]);
   const f = v => ({...form,...v});
   const load = useCallback(async()=>{ const r=await api("/api/vhosts"); if(r.ok) setVhosts(await r.json()); },[]);
   useEffect(()=>{load();},[load]);
-
-  const create = async()=>{ await api("/api/vhosts",{method:"POST",body:JSON.stringify(form)}); setModal(false); load(); };
+  useEffect(()=>{ api("/api/vhosts/profiles").then(r=>r.ok?r.json():[]).then(setProfiles); },[]);
+  const profile = profiles.find(p=>p.name===form.profile);
+
+  const create = async()=>{
+    const {upstreams,balance,health_path,aliases,canonical,...body} = form;
+    if (canonical!=="none") body.canonical = canonical;
+    if (aliases.trim()) body.aliases = aliases.split(",").map(a=>a.trim()).filter(Boolean);
+    if (profile?.upstream) body.proxy = {upstreams:upstreams.split(",").map(u=>u.trim()).filter(Boolean),balance,health_path};
+    await api("/api/vhosts",{method:"POST",body:JSON.stringify(body)}); setModal(false); load();
+  };
   const del = async d=>{ await api(`/api/vhosts/${d}`,{method:"DELETE"}); load(); };
   const toggle = async(d,enabled)=>{ await api(`/api/vhosts/${d}/${enabled?"disable":"enable"}`,{method:"POST"}); load(); };
 
@@ -280,8 +360,9 @@ function WebServerPanel() {
                   <span className="font-mono font-bold text-white">{v.domain}</span>
                   {v.ssl && <span className="text-xs font-mono px-1.5 py-0.5 rounded bg-emerald-500/10 text-emerald-400 border border-emerald-500/20">SSL</span>}
                   {v.php && <span className="text-xs font-mono px-1.5 py-0.5 rounded bg-violet-500/10 text-violet-400 border border-violet-500/20">PHP {v.php}</span>}
+                  {v.profile && <span className="text-xs font-mono px-1.5 py-0.5 rounded bg-zinc-800 text-zinc-400 border border-zinc-700">{v.profile}</span>}
                 </div>
-                <p className="text-xs text-zinc-600 font-mono mt-1">{v.docroot||"—"}</p>
+                <p className="text-xs text-zinc-600 font-mono mt-1">{v.upstreams?.length ? `→ ${v.upstreams.join(", ")}` : v.docroot||"—"}</p>
               </div>
               <div className="flex items-center gap-2">
                 <StatusBadge status={v.enabled?"active":"inactive"}/>
@@ -295,12 +376,16 @@ function WebServerPanel() {
       {modal && <Modal title="Add Virtual Host" onClose={()=>setModal(false)}>
         <div className="space-y-4">
           <Field label="Domain" value={form.domain} onChange={e=>setForm(f({domain:e.target.value}))} placeholder="example.com"/>
-          <Field label="Document Root (optional)" value={form.docroot} onChange={e=>setForm(f({docroot:e.target.value}))} placeholder="/var/www/example.com"/>
-          <Select label="PHP Version" value={form.php} onChange={e=>setForm(f({php:e.target.value}))} options={["8.3","8.2","8.1","8.0","7.4"]}/>
-          <div className="flex items-center gap-2">
-            <input type="checkbox" id="ssl" checked={form.ssl} onChange={e=>setForm(f({ssl:e.target.checked}))} className="accent-cyan-500"/>
-            <label htmlFor="ssl" className="text-xs text-zinc-400 font-mono">Enable SSL (Let's Encrypt)</label>
-          </div>
+          <Select label="Profile" value={form.profile} onChange={e=>setForm(f({profile:e.target.value}))} options={profiles.map(p=>({v:p.name,l:`${p.name} — ${p.description}`}))}/>
+          {(!profile || profile.docroot) && <Field label="Document Root (optional)" value={form.docroot} onChange={e=>setForm(f({docroot:e.target.value}))} placeholder={`/var/www/example.com/${profile?.docroot||"public_html"}`}/>}
+          {profile?.upstream && <>
+            <Field label="Upstreams (comma-separated)" value={form.upstreams} onChange={e=>setForm(f({upstreams:e.target.value}))} placeholder="3000, 127.0.0.1:3001, unix:/run/app.sock"/>
+            <Select label="Load Balancing" value={form.balance} onChange={e=>setForm(f({balance:e.target.value}))} options={["round_robin","least_conn","ip_hash","random"]}/>
+            <Field label="Health Check Path (optional)" value={form.health_path} onChange={e=>setForm(f({health_path:e.target.value}))} placeholder="/healthz"/>
+          </>}
+          <Field label="Aliases (comma-separated, optional)" value={form.aliases} onChange={e=>setForm(f({aliases:e.target.value}))} placeholder={`www.${form.domain||"example.com"}`}/>
+          <Select label="Canonical Host" value={form.canonical} onChange={e=>setForm(f({canonical:e.target.value}))} options={[{v:"none",l:"serve both"},{v:"www",l:"redirect to www"},{v:"non-www",l:"redirect to non-www"}]}/>
+          {(!profile || profile.php) && <Select label="PHP Version" value={form.php} onChange={e=>setForm(f({php:e.target.value}))} options={["8.3","8.2","8.1","8.0","7.4"]}/>}
           <div className="flex justify-end gap-2 pt-2">
             <Btn variant="ghost" onClick={()=>setModal(false)}>Cancel</Btn>
             <Btn onClick={create}>Create</Btn>
@@ -630,6 +715,7 @@ function WordPressPanel() {
   const [srModal, setSrModal] = useState(false);
   const [loading, setLoading] = useState(false);
   const [msg, setMsg] = useState("");
+  const [jobLog, setJobLog] = useState([]);
 
   const [form, setForm] = useState({
     domain:"", site_title:"", admin_user:"admin", admin_pass:"",
@@ -670,15 +756,21 @@ function WordPressPanel() {
   const createSite = async () => {
     setLoading(true);
     const r = await api("/api/wordpress", {method:"POST", body:JSON.stringify(form)});
+    if (!r.ok) {
+      setLoading(false);
+      const e = await r.json();
+      flash("✗ " + (e.error || "Install failed"));
+      return;
+    }
+    setJobLog([]);
+    const job = await waitForJob(r, line => setJobLog(l => [...l.slice(-199), line]));
     setLoading(false);
-    if (r.ok) {
-      const data = await r.json();
-      flash(`✓ WordPress installed! DB pass: ${data.db_pass}`);
+    if (job.status === "succeeded") {
+      flash(`✓ WordPress installed! DB pass: ${job.result?.db_pass}`);
       setCreateModal(false);
       loadSites();
     } else {
-      const e = await r.json();
-      flash("✗ " + (e.error || "Install failed"));
+      flash("✗ " + (job.error || "Install failed"));
     }
   };
 
@@ -717,9 +809,10 @@ function WordPressPanel() {
 
   const updateCore = async () => {
     setLoading(true);
-    await api(`/api/wordpress/${selected.domain}/update`, {method:"POST"});
+    const r = await api(`/api/wordpress/${selected.domain}/update`, {method:"POST"});
+    const job = r.ok ? await waitForJob(r) : await r.json();
     setLoading(false);
-    flash("✓ Core update complete");
+    flash(job.status === "succeeded" ? "✓ Core update complete" : "✗ " + (job.error || "Update failed"));
     loadSites();
   };
 
@@ -878,6 +971,7 @@ function WordPressPanel() {
           <div className="bg-zinc-800 rounded-lg p-3 text-xs text-zinc-500 font-mono">
             This will: create a MariaDB database, download WordPress, configure wp-config.php, run WP install, and set up an Nginx vhost.
           </div>
+          {loading && jobLog.length > 0 && <pre className="text-xs font-mono text-zinc-400 bg-zinc-950 border border-zinc-800 rounded-lg p-3 max-h-40 overflow-y-auto">{jobLog.join("\n")}</pre>}
           <div className="flex justify-end gap-2">
             <Btn variant="ghost" onClick={()=>setCreateModal(false)}>Cancel</Btn>
             <Btn onClick={createSite} className={loading?"opacity-50 cursor-not-allowed":""}>
@@ -967,7 +1061,7 @@ export default function App() {
 
   if (!auth) return <Login onLogin={()=>setAuth(true)}/>;
 
-  const logout = () => { localStorage.removeItem("sp_token"); setAuth(false); };
+  const logout = async () => { await api("/api/auth/logout", {method:"POST"}).catch(()=>{}); clearTokens(); setAuth(false); };
 
   const panels = { dashboard:<Dashboard/>, users:<UsersPanel/>, webserver:<WebServerPanel/>, wordpress:<WordPressPanel/>, databases:<DatabasePanel/>, filemanager:<FileManagerPanel/>, email:<EmailPanel/>, dns:<DNSPanel/>, cron:<CronPanel/>, ftp:<FTPPanel/> };
 
@@ -256,6 +256,9 @@ if ! id "$PANEL_USER" &>/dev/null; then
 else
   ok "User $PANEL_USER already exists"
 fi
+# Accounts the panel creates join this group; blogron.sudoers lets the
+# panel run cron jobs as its members only
+groupadd -f blogron-sites
 
 # ── Install dir ───────────────────────────────────────────────────────────
 step "Setting Up Install Directory"
@@ -273,12 +276,16 @@ export PATH="$PATH:/usr/local/go/bin"
 export HOME=/root
 export GOPATH=/root/go
 
+# The sqlite tag builds in the pure-Go SQLite driver pinned in go.mod
 go mod tidy 2>&1 | tail -5
-go build -o "$INSTALL_DIR/blogron" . 2>&1
+go build -tags sqlite -o "$INSTALL_DIR/blogron" . 2>&1
 chown "$PANEL_USER:$PANEL_USER" "$INSTALL_DIR/blogron"
 chmod 750 "$INSTALL_DIR/blogron"
 ok "Backend binary built: $INSTALL_DIR/blogron"
 
+go build -o /usr/local/bin/blogronctl ./cmd/blogronctl 2>&1
+ok "CLI installed: /usr/local/bin/blogronctl"
+
 # ── Build frontend ────────────────────────────────────────────────────────
 step "Building Frontend"
 cp -r "$SCRIPT_DIR/frontend/"* "$INSTALL_DIR/frontend/"
@@ -386,13 +393,54 @@ chmod 640 "$INSTALL_DIR/config/admin.json"
 chown "$PANEL_USER:$PANEL_USER" "$INSTALL_DIR/config/admin.json"
 ok "Admin credentials stored"
 
+# ── Panel configuration ───────────────────────────────────────────────────
+step "Writing Panel Configuration"
+mkdir -p /etc/blogron
+cat > /etc/blogron/panel.yaml << CONFEOF
+# BLOGRON Panel configuration — see backend/panel.example.yaml for every key
+port: "${PANEL_PORT}"
+panel_domain: ${PANEL_DOMAIN}
+
+auth:
+  jwt_secret: "${JWT_SECRET}"
+  admin_user: ${ADMIN_USER}
+  admin_password: '${ADMIN_PASS//\'/\'\'}'
+
+mysql:
+  user: root
+  password: '${MYSQL_ROOT_PASS//\'/\'\'}'
+
+php:
+  default_version: "${PHP_VER}"
+
+services:
+  bind: named            # updated once BIND9 is configured below
+CONFEOF
+chown "root:$PANEL_USER" /etc/blogron/panel.yaml
+chmod 640 /etc/blogron/panel.yaml
+ok "Configuration written to /etc/blogron/panel.yaml"
+
 # ── Systemd service ───────────────────────────────────────────────────────
 step "Installing Systemd Service"
+# systemd owns the listening socket, so connections queue instead of being
+# refused while the panel restarts
+cat > /etc/systemd/system/blogron.socket << SOCKEOF
+[Unit]
+Description=BLOGRON Panel API socket
+
+[Socket]
+ListenStream=${PANEL_PORT}
+
+[Install]
+WantedBy=sockets.target
+SOCKEOF
+
 cat > /etc/systemd/system/blogron.service << SVCEOF
 [Unit]
 Description=BLOGRON Panel API
-After=network.target mariadb.service nginx.service
+After=network.target mariadb.service nginx.service blogron.socket
 Wants=mariadb.service
+Requires=blogron.socket
 
 [Service]
 Type=simple
@@ -400,15 +448,9 @@ User=${PANEL_USER}
 Group=${PANEL_USER}
 WorkingDirectory=${INSTALL_DIR}
 ExecStart=${INSTALL_DIR}/blogron
-Environment="PORT=${PANEL_PORT}"
-Environment="JWT_SECRET=${JWT_SECRET}"
-Environment="MYSQL_USER=root"
-Environment="MYSQL_PASSWORD=${MYSQL_ROOT_PASS}"
-Environment="ADMIN_USER=${ADMIN_USER}"
-Environment="ADMIN_PASSWORD=${ADMIN_PASS}"
-Environment="PANEL_DOMAIN=${PANEL_DOMAIN}"
-Environment="PHP_VERSION=${PHP_VER}"
-Environment="BIND_SERVICE=${BIND_SVC}"
+Environment="PANEL_CONFIG=/etc/blogron/panel.yaml"
+StateDirectory=blogron
+StateDirectoryMode=0700
 NoNewPrivileges=false
 PrivateTmp=true
 ProtectSystem=full
@@ -420,13 +462,15 @@ StartLimitBurst=3
 StandardOutput=journal
 StandardError=journal
 SyslogIdentifier=blogron
+KillMode=mixed
+TimeoutStopSec=90s
 
 [Install]
 WantedBy=multi-user.target
 SVCEOF
 
 systemctl daemon-reload
-systemctl enable --now blogron
+systemctl enable --now blogron.socket blogron
 sleep 2
 systemctl is-active --quiet blogron && ok "blogron service started" || warn "blogron may have failed to start — check: journalctl -u blogron"
 
@@ -451,6 +495,7 @@ ok "Firewall configured"
 
 # ── Fail2ban ──────────────────────────────────────────────────────────────
 step "Configuring Fail2ban"
+cp "$SCRIPT_DIR/backend/blogron-fail2ban.conf" /etc/fail2ban/filter.d/blogron.conf
 cat > /etc/fail2ban/jail.d/blogron.conf << F2BEOF
 [sshd]
 enabled = true
@@ -463,6 +508,15 @@ enabled = true
 
 [nginx-limit-req]
 enabled = true
+
+[blogron]
+enabled = true
+filter = blogron
+backend = systemd
+port = http,https,${PANEL_PORT}
+maxretry = 10
+findtime = 600
+bantime = 3600
 F2BEOF
 systemctl enable --now fail2ban
 ok "Fail2ban configured"
@@ -511,6 +565,7 @@ else
   BIND_SVC="bind9"
 fi
 log "Using DNS service name: $BIND_SVC"
+sed -i "s/^  bind: .*/  bind: ${BIND_SVC}/" /etc/blogron/panel.yaml
 systemctl enable --now "$BIND_SVC"
 ok "BIND9 configured (service: $BIND_SVC)"
 
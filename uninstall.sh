@@ -11,13 +11,15 @@ read -rp "Type 'yes' to confirm: " CONFIRM
 [[ "$CONFIRM" != "yes" ]] && echo "Aborted." && exit 0
 
 echo "Stopping and disabling service..."
-systemctl stop blogron 2>/dev/null || true
-systemctl disable blogron 2>/dev/null || true
-rm -f /etc/systemd/system/blogron.service
+systemctl stop blogron blogron.socket 2>/dev/null || true
+systemctl disable blogron blogron.socket 2>/dev/null || true
+rm -f /etc/systemd/system/blogron.service /etc/systemd/system/blogron.socket
+rm -rf /etc/blogron
 systemctl daemon-reload
 
 echo "Removing install directory..."
-rm -rf /opt/blogron
+rm -rf /opt/blogron /var/lib/blogron
+rm -f /usr/local/bin/blogronctl
 
 echo "Removing sudo rules..."
 rm -f /etc/sudoers.d/blogron
@@ -26,7 +28,7 @@ echo "Removing system user..."
 userdel blogron 2>/dev/null || true
 
 echo "Removing fail2ban config..."
-rm -f /etc/fail2ban/jail.d/blogron.conf
+rm -f /etc/fail2ban/jail.d/blogron.conf /etc/fail2ban/filter.d/blogron.conf
 systemctl reload fail2ban 2>/dev/null || true
 
 echo -e "${YELLOW}Note: Nginx, MySQL, BIND9, Postfix, vsftpd packages were NOT removed.${NC}"